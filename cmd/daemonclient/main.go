@@ -23,7 +23,7 @@ Usage:
 {{if not .CommandUsage}}
 Arguments:
   socket	- name of unix socket to communicate with wal-g daemon
-  command	- command to send to the daemon: wal-push, wal-fetch
+  command	- command to send to the daemon: wal-push, wal-fetch, status, metrics, backup-push, backup-status
   command_args	- command specific arguments
 {{end}}
 Flags:
@@ -34,6 +34,8 @@ type commandOpts struct {
 	name    string
 	msgType daemon.SocketMessageType
 	args    []string
+	// query commands print the daemon response, they require the protocol version handshake
+	query bool
 
 	options *daemon.RunOptions
 }
@@ -60,6 +62,22 @@ var (
 			msgType: daemon.WalFetchType,
 			args:    []string{"wal_name", "destination_filename"},
 		},
+		"status": {
+			msgType: daemon.StatusType,
+			query:   true,
+		},
+		"metrics": {
+			msgType: daemon.MetricsType,
+			query:   true,
+		},
+		"backup-push": {
+			msgType: daemon.BackupPushType,
+			query:   true,
+		},
+		"backup-status": {
+			msgType: daemon.BackupStatusType,
+			query:   true,
+		},
 	}
 )
 
@@ -68,6 +86,7 @@ func parseArgs(args []string) (*commandOpts, *flag.FlagSet, error) {
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
 	fs.DurationVar(&opts.DaemonOperationTimeout, "timeout", 60*time.Second, "daemon operation execution timeout")
 	fs.DurationVar(&opts.DaemonSocketConnectionTimeout, "connection-timeout", 5*time.Second, "daemon socket connection timeout")
+	backupFlags := fs.String("backup-flags", "", "backup-push flags, e.g. \"--full --permanent\"")
 
 	if len(args) < 2 {
 		return nil, fs, fmt.Errorf("not enough arguments")
@@ -94,6 +113,10 @@ func parseArgs(args []string) (*commandOpts, *flag.FlagSet, error) {
 		}
 	}
 
+	if cmd.msgType == daemon.BackupPushType {
+		opts.MessageArgs = strings.Fields(*backupFlags)
+	}
+
 	cmd.options = opts
 	return cmd, fs, nil
 }
@@ -125,6 +148,15 @@ func main() {
 		log.Fatalf("daemon socket '%v' doesn't exist or is unavailable:\n\t%v", cmd.options.SocketName, err)
 	}
 
+	if cmd.query {
+		response, err := daemon.SendQuery(cmd.options)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(strings.TrimSpace(string(response)))
+		return
+	}
+
 	response, err := daemon.SendCommand(cmd.options)
 	if err != nil {
 		if response == daemon.ArchiveNonExistenceType {
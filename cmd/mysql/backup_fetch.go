@@ -11,6 +11,10 @@ import (
 const (
 	backupFetchShortDescription = "Fetch desired backup from storage"
 	targetUserDataDescription   = "Fetch storage backup which has the specified user data"
+	tablesDescription           = "Restore only the InnoDB tables (database.table) of the xtrabackup backup" +
+		" and export them for table-import"
+	tablesDirDescription = "Directory to restore the tables to, required for --tables of xtrabackup backups"
+	databasesDescription = "Restore only the databases of the logical backup"
 )
 
 var (
@@ -19,26 +23,31 @@ var (
 		Use:   "backup-fetch backup-name",
 		Short: backupFetchShortDescription,
 		Args:  cobra.RangeArgs(0, 1),
-		PreRun: func(cmd *cobra.Command, args []string) {
-			internal.RequiredSettings[internal.NameStreamRestoreCmd] = true
-			err := internal.AssertRequiredSettingsSet()
-			tracelog.ErrorLogger.FatalOnError(err)
-		},
 		Run: func(cmd *cobra.Command, args []string) {
 			internal.ConfigureLimiters()
 			storage, err := internal.ConfigureStorage()
 			tracelog.ErrorLogger.FatalOnError(err)
-			restoreCmd, err := internal.GetCommandSetting(internal.NameStreamRestoreCmd)
-			tracelog.ErrorLogger.FatalOnError(err)
+			// the logical backups are restored without the restore command
+			restoreCmd, _ := internal.GetCommandSetting(internal.NameStreamRestoreCmd)
 			prepareCmd, _ := internal.GetCommandSetting(internal.MysqlBackupPrepareCmd)
 
 			targetBackupSelector, err := createTargetBackupSelector(args, fetchTargetUserData)
 			tracelog.ErrorLogger.FatalOnError(err)
 
+			if len(fetchTables) > 0 || len(fetchDatabases) > 0 {
+				tables, err := mysql.ParseTableNames(fetchTables)
+				tracelog.ErrorLogger.FatalOnError(err)
+				mysql.HandleBackupFetchTables(storage.RootFolder(), targetBackupSelector, restoreCmd, prepareCmd,
+					fetchDatabases, tables, fetchTablesDir)
+				return
+			}
 			mysql.HandleBackupFetch(storage.RootFolder(), targetBackupSelector, restoreCmd, prepareCmd)
 		},
 	}
 	fetchTargetUserData string
+	fetchTables         []string
+	fetchTablesDir      string
+	fetchDatabases      []string
 )
 
 func createTargetBackupSelector(args []string, fetchTargetUserData string) (internal.BackupSelector, error) {
@@ -56,4 +65,7 @@ func init() {
 	cmd.AddCommand(backupFetchCmd)
 	backupFetchCmd.Flags().StringVar(&fetchTargetUserData, "target-user-data",
 		"", targetUserDataDescription)
+	backupFetchCmd.Flags().StringSliceVar(&fetchTables, "tables", nil, tablesDescription)
+	backupFetchCmd.Flags().StringVar(&fetchTablesDir, "tables-dir", "", tablesDirDescription)
+	backupFetchCmd.Flags().StringSliceVar(&fetchDatabases, "databases", nil, databasesDescription)
 }
@@ -13,6 +13,10 @@ const (
 	backupPushShortDescription = "Creates new backup and pushes it to storage"
 	permanentFlag              = "permanent"
 	addUserDataFlag            = "add-user-data"
+	cloneFlag                  = "clone"
+	cloneDescription           = "Make the backup by the CLONE plugin of MySQL 8 instead of " + internal.NameStreamCreateCmd
+	logicalFlag                = "logical"
+	logicalDescription         = "Make the logical backup of the tables dumped in parallel instead of " + internal.NameStreamCreateCmd
 
 	permanentShorthand = "p"
 )
@@ -23,7 +27,14 @@ var (
 		Use:   "backup-push",
 		Short: backupPushShortDescription,
 		PreRun: func(cmd *cobra.Command, args []string) {
-			internal.RequiredSettings[internal.NameStreamCreateCmd] = true
+			if cloneBackup && logicalBackup {
+				tracelog.ErrorLogger.Fatalf("--%s and --%s can not be used together", cloneFlag, logicalFlag)
+			}
+			if cloneBackup {
+				internal.RequiredSettings[internal.MysqlCloneDir] = true
+			} else if !logicalBackup {
+				internal.RequiredSettings[internal.NameStreamCreateCmd] = true
+			}
 			internal.RequiredSettings[internal.MysqlDatasourceNameSetting] = true
 			err := internal.AssertRequiredSettingsSet()
 			tracelog.ErrorLogger.FatalOnError(err)
@@ -35,13 +46,38 @@ var (
 			tracelog.ErrorLogger.FatalOnError(err)
 			folder := uploader.Folder()
 			uploader.ChangeDirectory(utility.BaseBackupPath)
-			backupCmd, err := internal.GetCommandSetting(internal.NameStreamCreateCmd)
-			tracelog.ErrorLogger.FatalOnError(err)
 
 			if userData == "" {
 				userData = viper.GetString(internal.SentinelUserDataSetting)
 			}
 
+			if cloneBackup {
+				mysql.HandleCloneBackupPush(
+					folder,
+					uploader,
+					permanent,
+					userData,
+					viper.GetString(internal.MysqlCloneDir),
+					viper.GetString(internal.MysqlCloneDonorDatasourceName),
+				)
+				return
+			}
+			if logicalBackup {
+				workers, err := internal.GetMaxUploadConcurrency()
+				tracelog.ErrorLogger.FatalOnError(err)
+				mysql.HandleLogicalBackupPush(
+					folder,
+					uploader,
+					permanent,
+					userData,
+					workers,
+					viper.GetInt64(internal.MysqlLogicalBackupChunkRows),
+				)
+				return
+			}
+			backupCmd, err := internal.GetCommandSetting(internal.NameStreamCreateCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+
 			mysql.HandleBackupPush(
 				folder,
 				uploader,
@@ -53,8 +89,10 @@ var (
 			)
 		},
 	}
-	permanent = false
-	userData  = ""
+	permanent     = false
+	userData      = ""
+	cloneBackup   = false
+	logicalBackup = false
 )
 
 func init() {
@@ -66,4 +104,6 @@ func init() {
 		false, "Pushes permanent backup")
 	backupPushCmd.Flags().StringVar(&userData, addUserDataFlag,
 		"", "Write the provided user data to the backup sentinel and metadata files.")
+	backupPushCmd.Flags().BoolVar(&cloneBackup, cloneFlag, false, cloneDescription)
+	backupPushCmd.Flags().BoolVar(&logicalBackup, logicalFlag, false, logicalDescription)
 }
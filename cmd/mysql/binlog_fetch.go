@@ -15,9 +15,19 @@ const fetchUntilFlagShortDescr = "time in RFC3339 for PITR"
 const fetchUntilBinlogLastModifiedFlagShortDescr = "time in RFC3339 that is used to prevent wal-g from replaying" +
 	" binlogs that was created/modified after this time"
 
+const (
+	untilGTIDFlagShortDescr          = "GTID set to stop after, the binlog is truncated after the last transaction of the set"
+	untilGTIDExclusiveFlagShortDescr = "stop before the first transaction of the --until-gtid set instead"
+	untilPositionFlagShortDescr      = "binlog_name:position to stop at, the events starting at the position and later" +
+		" are not applied"
+)
+
 var fetchBackupName string
 var fetchUntilTS string
 var fetchUntilBinlogLastModifiedTS string
+var fetchUntilGTID string
+var fetchUntilGTIDExclusive bool
+var fetchUntilPosition string
 
 // binlogPushCmd represents the cron command
 var binlogFetchCmd = &cobra.Command{
@@ -27,7 +37,10 @@ var binlogFetchCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		storage, err := internal.ConfigureStorage()
 		tracelog.ErrorLogger.FatalOnError(err)
-		mysql.HandleBinlogFetch(storage.RootFolder(), fetchBackupName, fetchUntilTS, fetchUntilBinlogLastModifiedTS)
+		stopTarget, err := mysql.NewBinlogStopTarget(fetchUntilGTID, fetchUntilGTIDExclusive, fetchUntilPosition)
+		tracelog.ErrorLogger.FatalOnError(err)
+		mysql.HandleBinlogFetch(storage.RootFolder(), fetchBackupName, fetchUntilTS, fetchUntilBinlogLastModifiedTS,
+			stopTarget)
 	},
 	PreRun: func(cmd *cobra.Command, args []string) {
 		internal.RequiredSettings[internal.MysqlBinlogDstSetting] = true
@@ -46,5 +59,9 @@ func init() {
 		"until-binlog-last-modified-time",
 		"",
 		fetchUntilBinlogLastModifiedFlagShortDescr)
+	binlogFetchCmd.PersistentFlags().StringVar(&fetchUntilGTID, "until-gtid", "", untilGTIDFlagShortDescr)
+	binlogFetchCmd.PersistentFlags().BoolVar(&fetchUntilGTIDExclusive, "until-gtid-exclusive", false,
+		untilGTIDExclusiveFlagShortDescr)
+	binlogFetchCmd.PersistentFlags().StringVar(&fetchUntilPosition, "until-position", "", untilPositionFlagShortDescr)
 	cmd.AddCommand(binlogFetchCmd)
 }
@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
+)
+
+const binlogReindexShortDescription = "Write index entries for the archived binlogs"
+
+var (
+	reindexFlavor string
+	reindexForce  bool
+)
+
+// binlogReindexCmd represents the binlog-reindex command
+var binlogReindexCmd = &cobra.Command{
+	Use:   "binlog-reindex",
+	Short: binlogReindexShortDescription,
+	Args:  cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if reindexFlavor != gomysql.MySQLFlavor && reindexFlavor != gomysql.MariaDBFlavor {
+			tracelog.ErrorLogger.Fatalf("Unknown flavor %s, should be %s or %s",
+				reindexFlavor, gomysql.MySQLFlavor, gomysql.MariaDBFlavor)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		storage, err := internal.ConfigureStorage()
+		tracelog.ErrorLogger.FatalOnError(err)
+		mysql.HandleBinlogReindex(storage.RootFolder(), reindexFlavor, reindexForce)
+	},
+}
+
+func init() {
+	cmd.AddCommand(binlogReindexCmd)
+	binlogReindexCmd.Flags().StringVar(&reindexFlavor, "flavor", gomysql.MySQLFlavor,
+		"flavor of the server which has written the binlogs: mysql or mariadb")
+	binlogReindexCmd.Flags().BoolVar(&reindexForce, "force", false, "rebuild the existing index entries too")
+}
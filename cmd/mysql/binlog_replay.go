@@ -12,12 +12,18 @@ import (
 
 const replaySinceFlagShortDescr = "backup name starting from which you want to fetch binlogs"
 const replayUntilFlagShortDescr = "time in RFC3339 for PITR"
+const replayRelayLogFlagShortDescr = "replay binlogs by the multi-threaded applier of MySQL as the relay logs" +
+	" of the replication channel instead of WALG_MYSQL_BINLOG_REPLAY_COMMAND"
 const replayUntilBinlogLastModifiedFlagShortDescr = "time in RFC3339 that is used to prevent wal-g from replaying" +
 	" binlogs that was created/modified after this time"
 
 var replayBackupName string
 var replayUntilTS string
 var replayUntilBinlogLastModifiedTS string
+var replayUntilGTID string
+var replayUntilGTIDExclusive bool
+var replayUntilPosition string
+var replayRelayLog bool
 
 var binlogReplayCmd = &cobra.Command{
 	Use:   "binlog-replay",
@@ -26,10 +32,22 @@ var binlogReplayCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		storage, err := internal.ConfigureStorage()
 		tracelog.ErrorLogger.FatalOnError(err)
-		mysql.HandleBinlogReplay(storage.RootFolder(), replayBackupName, replayUntilTS, replayUntilBinlogLastModifiedTS)
+		stopTarget, err := mysql.NewBinlogStopTarget(replayUntilGTID, replayUntilGTIDExclusive, replayUntilPosition)
+		tracelog.ErrorLogger.FatalOnError(err)
+		if replayRelayLog {
+			mysql.HandleBinlogReplayRelayLog(storage.RootFolder(), replayBackupName, replayUntilTS,
+				replayUntilBinlogLastModifiedTS, stopTarget)
+			return
+		}
+		mysql.HandleBinlogReplay(storage.RootFolder(), replayBackupName, replayUntilTS, replayUntilBinlogLastModifiedTS,
+			stopTarget)
 	},
 	PreRun: func(cmd *cobra.Command, args []string) {
-		internal.RequiredSettings[internal.MysqlBinlogReplayCmd] = true
+		if replayRelayLog {
+			internal.RequiredSettings[internal.MysqlDatasourceNameSetting] = true
+		} else {
+			internal.RequiredSettings[internal.MysqlBinlogReplayCmd] = true
+		}
 		err := internal.AssertRequiredSettingsSet()
 		tracelog.ErrorLogger.FatalOnError(err)
 	},
@@ -41,5 +59,10 @@ func init() {
 		utility.TimeNowCrossPlatformUTC().Format(time.RFC3339), replayUntilFlagShortDescr)
 	binlogReplayCmd.PersistentFlags().StringVar(&replayUntilBinlogLastModifiedTS, "until-binlog-last-modified-time",
 		"", replayUntilBinlogLastModifiedFlagShortDescr)
+	binlogReplayCmd.PersistentFlags().StringVar(&replayUntilGTID, "until-gtid", "", untilGTIDFlagShortDescr)
+	binlogReplayCmd.PersistentFlags().BoolVar(&replayUntilGTIDExclusive, "until-gtid-exclusive", false,
+		untilGTIDExclusiveFlagShortDescr)
+	binlogReplayCmd.PersistentFlags().StringVar(&replayUntilPosition, "until-position", "", untilPositionFlagShortDescr)
+	binlogReplayCmd.PersistentFlags().BoolVar(&replayRelayLog, "relay-log", false, replayRelayLogFlagShortDescr)
 	cmd.AddCommand(binlogReplayCmd)
 }
@@ -14,10 +14,12 @@ const (
 	binlogServerShortDescription = "Create server for backup slaves"
 	binlogSinceFlagShortDescr    = "backup name starting from which you want to use binlogs"
 	untilFlagShortDescr          = "time in RFC3339 for PITR"
+	followFlagShortDescr         = "keep running and send the newly archived binlogs instead of stopping at --until"
 )
 
 var untilTS string
 var BinlogBackupName string
+var followArchive bool
 
 var (
 	binlogServerCmd = &cobra.Command{
@@ -30,12 +32,18 @@ var (
 			internal.RequiredSettings[internal.MysqlBinlogServerUser] = true
 			internal.RequiredSettings[internal.MysqlBinlogServerPassword] = true
 			internal.RequiredSettings[internal.MysqlBinlogServerID] = true
-			internal.RequiredSettings[internal.MysqlBinlogServerReplicaSource] = true
+			if followArchive {
+				if cmd.Flags().Changed("until") {
+					tracelog.ErrorLogger.Fatal("--until and --follow can't be used together")
+				}
+			} else {
+				internal.RequiredSettings[internal.MysqlBinlogServerReplicaSource] = true
+			}
 			err := internal.AssertRequiredSettingsSet()
 			tracelog.ErrorLogger.FatalOnError(err)
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			mysql.HandleBinlogServer(BinlogBackupName, untilTS)
+			mysql.HandleBinlogServer(BinlogBackupName, untilTS, followArchive)
 		},
 	}
 )
@@ -46,5 +54,6 @@ func init() {
 		"until",
 		utility.TimeNowCrossPlatformUTC().Format(time.RFC3339),
 		untilFlagShortDescr)
+	binlogServerCmd.Flags().BoolVar(&followArchive, "follow", false, followFlagShortDescr)
 	cmd.AddCommand(binlogServerCmd)
 }
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
+)
+
+const binlogStreamShortDescription = "Stream binlogs to the storage over the replication protocol"
+
+var (
+	streamStartGTID   string
+	streamStartBinlog string
+)
+
+// binlogStreamCmd represents the binlog-stream command
+var binlogStreamCmd = &cobra.Command{
+	Use:   "binlog-stream",
+	Short: binlogStreamShortDescription,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		uploader, err := internal.ConfigureUploader()
+		tracelog.ErrorLogger.FatalOnError(err)
+		mysql.HandleBinlogStream(uploader, streamStartGTID, streamStartBinlog)
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		internal.RequiredSettings[internal.MysqlDatasourceNameSetting] = true
+		internal.RequiredSettings[internal.MysqlBinlogStreamServerID] = true
+		err := internal.AssertRequiredSettingsSet()
+		tracelog.ErrorLogger.FatalOnError(err)
+		if streamStartGTID != "" && streamStartBinlog != "" {
+			tracelog.ErrorLogger.Fatal("--start-gtid and --start-binlog can't be used together")
+		}
+	},
+}
+
+func init() {
+	cmd.AddCommand(binlogStreamCmd)
+	binlogStreamCmd.Flags().StringVar(&streamStartGTID, "start-gtid", "",
+		"GTID set of the already archived transactions to start after. The archived GTID set by default")
+	binlogStreamCmd.Flags().StringVar(&streamStartBinlog, "start-binlog", "",
+		"binlog file name to start from when there is no archived GTID set")
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
+)
+
+const (
+	tableImportShortDescription = "Import the tables exported by backup-fetch --tables into the running MySQL server"
+	tableImportFromDescription  = "Directory with the exported tables, the --tables-dir of backup-fetch"
+)
+
+var tableImportFromDir string
+
+var tableImportCmd = &cobra.Command{
+	Use:   "table-import database.table...",
+	Short: tableImportShortDescription,
+	Args:  cobra.MinimumNArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		internal.RequiredSettings[internal.MysqlDatasourceNameSetting] = true
+		err := internal.AssertRequiredSettingsSet()
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		tables, err := mysql.ParseTableNames(args)
+		tracelog.ErrorLogger.FatalOnError(err)
+		mysql.HandleTableImport(tableImportFromDir, tables)
+	},
+}
+
+func init() {
+	tableImportCmd.Flags().StringVar(&tableImportFromDir, "from", "", tableImportFromDescription)
+	_ = tableImportCmd.MarkFlagRequired("from")
+	cmd.AddCommand(tableImportCmd)
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/mysql"
+)
+
+const (
+	xtrabackupVerifyShortDescription = "Restores the xtrabackup backup to a scratch directory and checks it by a throwaway mysqld"
+	verifySampleDescription          = "Check this number of randomly chosen tables instead of all the tables"
+	verifyScratchDirDescription      = "Directory to create the scratch directory in, the system temporary directory by default"
+)
+
+var (
+	xtrabackupVerifyCmd = &cobra.Command{
+		Use:   "xtrabackup-verify [backup_name | LATEST]",
+		Short: xtrabackupVerifyShortDescription,
+		Args:  cobra.RangeArgs(0, 1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			internal.RequiredSettings[internal.NameStreamRestoreCmd] = true
+			internal.RequiredSettings[internal.MysqlBackupPrepareCmd] = true
+			err := internal.AssertRequiredSettingsSet()
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+			restoreCmd, err := internal.GetCommandSetting(internal.NameStreamRestoreCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+			prepareCmd, err := internal.GetCommandSetting(internal.MysqlBackupPrepareCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+			mysqldCmd, err := internal.GetCommandSetting(internal.MysqlVerifyMysqldCmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+			startupTimeout, err := internal.GetDurationSetting(internal.MysqlVerifyStartupTimeout)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			targetBackupSelector, err := createTargetBackupSelector(args, verifyTargetUserData)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			mysql.HandleXtrabackupVerify(storage.RootFolder(), targetBackupSelector, restoreCmd, prepareCmd, mysqldCmd,
+				verifyScratchDir, verifySample, startupTimeout)
+		},
+	}
+	verifyTargetUserData string
+	verifySample         int
+	verifyScratchDir     string
+)
+
+func init() {
+	cmd.AddCommand(xtrabackupVerifyCmd)
+	xtrabackupVerifyCmd.Flags().StringVar(&verifyTargetUserData, "target-user-data", "", targetUserDataDescription)
+	xtrabackupVerifyCmd.Flags().IntVar(&verifySample, "sample", 0, verifySampleDescription)
+	xtrabackupVerifyCmd.Flags().StringVar(&verifyScratchDir, "scratch-dir", "", verifyScratchDirDescription)
+}
@@ -23,7 +23,16 @@ For information about pattern syntax view: https://golang.org/pkg/path/filepath/
 	targetUserDataDescription     = "Fetch storage backup which has the specified user data"
 	restoreOnlyDescription        = `[Experimental] Downloads only databases or tables specified by passed names.
 Separate parameters with comma. Use 'database' or 'database/namespace.table' as a parameter ('public' namespace can be omitted).  
+Tables are restored with their TOAST tables and indexes.
 Sets reverse delta unpack & skip redundant tars options automatically. Always downloads system databases and tables.`
+	upgradeToDescription = `Upgrades the fetched backup with pg_upgrade using the PostgreSQL binaries from the specified directory.
+The backup is recovered up to the consistent point before the upgrade`
+	upgradeFromDescription       = "Bin directory of the backup PostgreSQL version, required by --upgrade-to"
+	upgradeDataDirDescription    = "Data directory of the upgraded cluster, destination_directory_upgraded by default"
+	upgradeUserDescription       = "Superuser name of the clusters, the OS user by default"
+	upgradeInitdbFlagDescription = "Flag passed to initdb of the upgraded cluster, can be repeated"
+	upgradePushConfigDescription = `Pushes the upgraded cluster as a new full backup with the specified config.
+The config should point to a storage other than the storage of the original cluster`
 )
 
 var fileMask string
@@ -32,6 +41,7 @@ var reverseDeltaUnpack bool
 var skipRedundantTars bool
 var fetchTargetUserData string
 var partialRestoreArgs []string
+var upgradeArgs postgres.UpgradeArgs
 
 var backupFetchCmd = &cobra.Command{
 	Use:   "backup-fetch destination_directory [backup_name | --target-user-data <data>]",
@@ -80,6 +90,10 @@ var backupFetchCmd = &cobra.Command{
 			pgFetcher = postgres.GetFetcherOld(args[0], fileMask, restoreSpec, extractProv)
 		}
 
+		if upgradeArgs.NewBinDir != "" {
+			postgres.HandleBackupFetchUpgrade(rootFolder, targetBackupSelector, pgFetcher, args[0], upgradeArgs)
+			return
+		}
 		internal.HandleBackupFetch(rootFolder, targetBackupSelector, pgFetcher)
 	},
 }
@@ -113,6 +127,13 @@ func init() {
 		nil, restoreOnlyDescription)
 	backupFetchCmd.Flags().StringVar(&targetStorage, "target-storage",
 		"", targetStorageDescription)
+	backupFetchCmd.Flags().StringVar(&upgradeArgs.NewBinDir, "upgrade-to", "", upgradeToDescription)
+	backupFetchCmd.Flags().StringVar(&upgradeArgs.OldBinDir, "upgrade-from", "", upgradeFromDescription)
+	backupFetchCmd.Flags().StringVar(&upgradeArgs.NewDataDirectory, "upgrade-data-dir", "", upgradeDataDirDescription)
+	backupFetchCmd.Flags().StringVar(&upgradeArgs.Username, "upgrade-user", "", upgradeUserDescription)
+	backupFetchCmd.Flags().StringArrayVar(&upgradeArgs.InitdbFlags, "upgrade-initdb-flag",
+		nil, upgradeInitdbFlagDescription)
+	backupFetchCmd.Flags().StringVar(&upgradeArgs.PushConfig, "upgrade-push-config", "", upgradePushConfigDescription)
 
 	Cmd.AddCommand(backupFetchCmd)
 }
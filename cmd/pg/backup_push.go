@@ -27,6 +27,7 @@ const (
 	deltaFromNameFlag         = "delta-from-name"
 	addUserDataFlag           = "add-user-data"
 	withoutFilesMetadataFlag  = "without-files-metadata"
+	includeWalFlag            = "include-wal"
 
 	permanentShorthand             = "p"
 	fullBackupShorthand            = "f"
@@ -98,6 +99,8 @@ var (
 				fullBackup = true
 			}
 
+			includeWal = includeWal || viper.GetBool(internal.PgIncludeWal)
+
 			deltaBaseSelector, err := internal.NewDeltaBaseSelector(
 				deltaFromName, deltaFromUserData, postgres.NewGenericMetaFetcher())
 			tracelog.ErrorLogger.FatalOnError(err)
@@ -109,7 +112,7 @@ var (
 				permanent, verifyPageChecksums || viper.GetBool(internal.VerifyPageChecksumsSetting),
 				fullBackup, storeAllCorruptBlocks || viper.GetBool(internal.StoreAllCorruptBlocksSetting),
 				tarBallComposerType, postgres.NewRegularDeltaBackupConfigurator(deltaBaseSelector),
-				userData, withoutFilesMetadata)
+				userData, withoutFilesMetadata, includeWal)
 
 			backupHandler, err := postgres.NewBackupHandler(arguments)
 			tracelog.ErrorLogger.FatalOnError(err)
@@ -127,6 +130,7 @@ var (
 	deltaFromUserData     = ""
 	userDataRaw           = ""
 	withoutFilesMetadata  = false
+	includeWal            = false
 )
 
 func chooseTarBallComposer() postgres.TarBallComposerType {
@@ -176,6 +180,8 @@ func init() {
 		"", "Write the provided user data to the backup sentinel and metadata files.")
 	backupPushCmd.Flags().BoolVar(&withoutFilesMetadata, withoutFilesMetadataFlag,
 		false, "Do not track files metadata, significantly reducing memory usage")
+	backupPushCmd.Flags().BoolVar(&includeWal, includeWalFlag,
+		false, "Copy the WAL required to restore the backup into the backup folder")
 	backupPushCmd.Flags().StringVar(&targetStorage, "target-storage", "",
 		targetStorageDescription)
 }
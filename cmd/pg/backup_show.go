@@ -0,0 +1,52 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	backupShowShortDescription = "Shows the backup details and its size by databases and tablespaces"
+	backupShowLongDescription  = "Shows the backup details and how much space the databases and the tablespaces " +
+		"take in the backup, and how many of their pages changed since the delta base."
+)
+
+var (
+	// backupShowCmd represents the backup-show command
+	backupShowCmd = &cobra.Command{
+		Use:   "backup-show [backup_name | --target-user-data <data>]",
+		Short: backupShowShortDescription,
+		Long:  backupShowLongDescription,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			targetName := ""
+			if len(args) > 0 {
+				targetName = args[0]
+			} else if backupShowTargetUserData == "" {
+				targetName = internal.LatestString
+			}
+			targetBackupSelector, err := internal.NewTargetBackupSelector(backupShowTargetUserData, targetName,
+				postgres.NewGenericMetaFetcher())
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			err = postgres.HandleBackupShow(storage.RootFolder(), targetBackupSelector, backupShowJSONOutput, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	backupShowTargetUserData string
+	backupShowJSONOutput     bool
+)
+
+func init() {
+	Cmd.AddCommand(backupShowCmd)
+
+	backupShowCmd.Flags().StringVar(&backupShowTargetUserData, "target-user-data", "", targetUserDataDescription)
+	backupShowCmd.Flags().BoolVar(&backupShowJSONOutput, "json", false, "Prints output in JSON format")
+}
@@ -0,0 +1,56 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	catchupReceiveShortDescription = "Receives incremental backup from catchup-send and applies it"
+	catchupReceiveLongDescription  = "Streams incremental backup from catchup-send running on the primary " +
+		"and applies it to the replica. The replica LSN is taken from its pg_control unless --from-lsn is set."
+
+	catchupReceiveFromFlag        = "from"
+	catchupReceiveFromDescription = "TCP address catchup-send listens on, e.g. primary:7777, " +
+		"WALG_CATCHUP_STREAM_SECRET must match the catchup-send one"
+	catchupReceiveCommandFlag        = "command"
+	catchupReceiveCommandDescription = "Command running catchup-send with stdin and stdout as the stream, " +
+		"e.g. \"ssh primary wal-g catchup-send /var/lib/postgresql/data\""
+)
+
+var (
+	// catchupReceiveCmd represents the catchup-receive command
+	catchupReceiveCmd = &cobra.Command{
+		Use:   "catchup-receive PGDATA (--from ADDRESS | --command COMMAND)",
+		Short: catchupReceiveShortDescription,
+		Long:  catchupReceiveLongDescription,
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if (catchupReceiveSource.Address == "") == (catchupReceiveSource.Command == "") {
+				tracelog.ErrorLogger.Fatalf("Exactly one of --%s and --%s is required",
+					catchupReceiveFromFlag, catchupReceiveCommandFlag)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			postgres.HandleCatchupReceive(catchupReceiveSource, args[0], postgres.LSN(catchupReceiveFromLSN), useNewUnwrap)
+		},
+	}
+	catchupReceiveSource  postgres.CatchupSource
+	catchupReceiveFromLSN uint64
+)
+
+func init() {
+	Cmd.AddCommand(catchupReceiveCmd)
+
+	catchupReceiveCmd.Flags().StringVar(&catchupReceiveSource.Address, catchupReceiveFromFlag, "",
+		catchupReceiveFromDescription)
+	catchupReceiveCmd.Flags().StringVar(&catchupReceiveSource.Command, catchupReceiveCommandFlag, "",
+		catchupReceiveCommandDescription)
+	catchupReceiveCmd.Flags().Uint64Var(&catchupReceiveFromLSN, "from-lsn", 0,
+		"LSN to start incremental backup, detected from pg_control by default")
+	catchupReceiveCmd.Flags().BoolVar(&useNewUnwrap, "use-new-unwrap", false, UseNewUnwrapDescription)
+}
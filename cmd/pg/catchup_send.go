@@ -0,0 +1,41 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	catchupSendShortDescription = "Streams incremental backup directly to catchup-receive"
+	catchupSendLongDescription  = "Creates incremental backup from the LSN of the replica and streams it " +
+		"to catchup-receive running on the replica, without intermediate storage. " +
+		"Without --listen, the stream goes through stdin and stdout, e.g. when catchup-receive runs it through SSH."
+
+	catchupSendListenFlag        = "listen"
+	catchupSendListenDescription = "TCP address to accept the catchup-receive connection on, e.g. 127.0.0.1:7777. " +
+		"The stream isn't encrypted, WALG_CATCHUP_STREAM_SECRET is required to authenticate catchup-receive"
+)
+
+var (
+	// catchupSendCmd represents the catchup-send command
+	catchupSendCmd = &cobra.Command{
+		Use:   "catchup-send PGDATA",
+		Short: catchupSendShortDescription,
+		Long:  catchupSendLongDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			postgres.HandleCatchupSend(cmd.Context(), args[0], catchupSendListenAddress)
+		},
+	}
+	catchupSendListenAddress string
+)
+
+func init() {
+	Cmd.AddCommand(catchupSendCmd)
+
+	catchupSendCmd.Flags().StringVar(&catchupSendListenAddress, catchupSendListenFlag, "",
+		catchupSendListenDescription)
+}
@@ -0,0 +1,67 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	logicalBackupDeleteShortDescription = "Clears old logical backups"
+)
+
+var logicalBackupDeleteConfirmed = false
+
+// logicalBackupDeleteCmd represents the logical-backup-delete command
+var logicalBackupDeleteCmd = &cobra.Command{
+	Use:   "logical-backup-delete",
+	Short: logicalBackupDeleteShortDescription,
+}
+
+var logicalBackupDeleteBeforeCmd = &cobra.Command{
+	Use:     internal.DeleteBeforeUsageExample,
+	Example: internal.DeleteBeforeExamples,
+	Args:    internal.DeleteBeforeArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		configureLogicalBackupDeleteHandler().HandleDeleteBefore(args, logicalBackupDeleteConfirmed)
+	},
+}
+
+var logicalBackupDeleteRetainCmd = &cobra.Command{
+	Use:       internal.DeleteRetainUsageExample,
+	Example:   internal.DeleteRetainExamples,
+	ValidArgs: internal.StringModifiers,
+	Args:      internal.DeleteRetainArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		configureLogicalBackupDeleteHandler().HandleDeleteRetain(args, logicalBackupDeleteConfirmed)
+	},
+}
+
+var logicalBackupDeleteEverythingCmd = &cobra.Command{
+	Use:       internal.DeleteEverythingUsageExample,
+	Example:   internal.DeleteEverythingExamples,
+	ValidArgs: internal.StringModifiersDeleteEverything,
+	Args:      internal.DeleteEverythingArgsValidator,
+	Run: func(cmd *cobra.Command, args []string) {
+		configureLogicalBackupDeleteHandler().HandleDeleteEverything(args, logicalBackupDeleteConfirmed)
+	},
+}
+
+func configureLogicalBackupDeleteHandler() *postgres.LogicalBackupDeleteHandler {
+	storage, err := internal.ConfigureStorage()
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	deleteHandler, err := postgres.NewLogicalBackupDeleteHandler(storage.RootFolder().GetSubFolder(utility.LogicalBackupPath))
+	tracelog.ErrorLogger.FatalOnError(err)
+	return deleteHandler
+}
+
+func init() {
+	Cmd.AddCommand(logicalBackupDeleteCmd)
+	logicalBackupDeleteCmd.AddCommand(logicalBackupDeleteBeforeCmd, logicalBackupDeleteRetainCmd,
+		logicalBackupDeleteEverythingCmd)
+	logicalBackupDeleteCmd.PersistentFlags().BoolVar(&logicalBackupDeleteConfirmed, internal.ConfirmFlag, false,
+		"Confirms backup deletion")
+}
@@ -0,0 +1,59 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	logicalBackupFetchShortDescription = "Fetches logical backup dumps from storage"
+)
+
+var (
+	// logicalBackupFetchCmd represents the logical-backup-fetch command
+	logicalBackupFetchCmd = &cobra.Command{
+		Use:   "logical-backup-fetch backup_name {--database NAME | --globals | --output-dir DIR}",
+		Short: logicalBackupFetchShortDescription,
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			targets := 0
+			for _, flagName := range []string{"database", "globals", "output-dir"} {
+				if cmd.Flags().Changed(flagName) {
+					targets++
+				}
+			}
+			if targets != 1 {
+				tracelog.ErrorLogger.Fatal("Exactly one of --database, --globals and --output-dir should be specified")
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			targetBackupSelector, err := internal.NewTargetBackupSelector("", args[0], postgres.NewLogicalBackupMetaFetcher())
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			postgres.HandleLogicalBackupFetch(storage.RootFolder().GetSubFolder(utility.LogicalBackupPath),
+				targetBackupSelector, logicalBackupFetchTarget, os.Stdout)
+		},
+	}
+	logicalBackupFetchTarget postgres.LogicalBackupFetchTarget
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupFetchCmd)
+
+	logicalBackupFetchCmd.Flags().StringVar(&logicalBackupFetchTarget.Database, "database", "",
+		"Writes the pg_dump archive of the database to stdout")
+	logicalBackupFetchCmd.Flags().BoolVar(&logicalBackupFetchTarget.Globals, "globals", false,
+		"Writes the roles and tablespaces SQL script to stdout")
+	logicalBackupFetchCmd.Flags().StringVar(&logicalBackupFetchTarget.OutputDirectory, "output-dir", "",
+		"Writes all dumps of the backup to the directory")
+}
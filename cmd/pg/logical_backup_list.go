@@ -0,0 +1,41 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	logicalBackupListShortDescription = "Prints available logical backups"
+)
+
+var (
+	// logicalBackupListCmd represents the logical-backup-list command
+	logicalBackupListCmd = &cobra.Command{
+		Use:   "logical-backup-list",
+		Short: logicalBackupListShortDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			backupsFolder := storage.RootFolder().GetSubFolder(utility.LogicalBackupPath).GetSubFolder(utility.BaseBackupPath)
+			if detail {
+				postgres.HandleDetailedLogicalBackupList(backupsFolder, pretty, json)
+			} else {
+				internal.HandleDefaultBackupList(backupsFolder, pretty, json)
+			}
+		},
+	}
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupListCmd)
+
+	logicalBackupListCmd.Flags().BoolVar(&pretty, PrettyFlag, false, "Prints more readable output")
+	logicalBackupListCmd.Flags().BoolVar(&json, JSONFlag, false, "Prints output in json format")
+	logicalBackupListCmd.Flags().BoolVar(&detail, DetailFlag, false, "Prints extra backup details")
+}
@@ -0,0 +1,54 @@
+package pg
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	logicalBackupPushShortDescription = "Makes logical backup with pg_dump and uploads it to storage"
+)
+
+var (
+	// logicalBackupPushCmd represents the logical-backup-push command
+	logicalBackupPushCmd = &cobra.Command{
+		Use:   "logical-backup-push [--database NAME]... [--dump-all]",
+		Short: logicalBackupPushShortDescription,
+		Args:  cobra.NoArgs,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if logicalBackupPushArgs.DumpAll && len(logicalBackupPushArgs.Databases) > 0 {
+				tracelog.ErrorLogger.Fatal("--database can't be used with --dump-all")
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			internal.ConfigureLimiters()
+
+			uploader, err := internal.ConfigureUploader()
+			tracelog.ErrorLogger.FatalOnError(err)
+			uploader.ChangeDirectory(utility.LogicalBackupPath + utility.BaseBackupPath)
+
+			postgres.HandleLogicalBackupPush(cmd.Context(), uploader, logicalBackupPushArgs)
+		},
+	}
+	logicalBackupPushArgs postgres.LogicalBackupPushArgs
+)
+
+func init() {
+	Cmd.AddCommand(logicalBackupPushCmd)
+
+	logicalBackupPushCmd.Flags().StringArrayVar(&logicalBackupPushArgs.Databases, "database", nil,
+		"Database to dump, all databases except the templates are dumped if not set")
+	logicalBackupPushCmd.Flags().BoolVar(&logicalBackupPushArgs.SkipGlobals, "skip-globals", false,
+		"Do not dump the roles and tablespaces")
+	logicalBackupPushCmd.Flags().BoolVar(&logicalBackupPushArgs.DumpAll, "dump-all", false,
+		"Make a single pg_dumpall dump of the whole cluster")
+	logicalBackupPushCmd.Flags().StringArrayVar(&logicalBackupPushArgs.DumpFlags, "dump-flag", nil,
+		"Extra flag passed to pg_dump, or to pg_dumpall with --dump-all")
+	logicalBackupPushCmd.Flags().BoolVarP(&logicalBackupPushArgs.IsPermanent, permanentFlag, permanentShorthand,
+		false, "Pushes permanent backup")
+	logicalBackupPushCmd.Flags().StringVar(&logicalBackupPushArgs.UserDataRaw, addUserDataFlag,
+		"", "Write the provided user data to the backup sentinel")
+}
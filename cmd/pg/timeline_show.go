@@ -0,0 +1,42 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	TimelineShowUsage            = "timeline-show"
+	TimelineShowShortDescription = "Show the tree of timelines found in storage."
+	TimelineShowLongDescription  = "Show how the timelines found in storage branch off each other: " +
+		"the switch points, the backups taken on each timeline and the abandoned branches."
+
+	timelineShowJSONFlag        = "json"
+	timelineShowJSONDescription = "Output the timeline tree in JSON format."
+)
+
+var (
+	// timelineShowCmd represents the timelineShow command
+	timelineShowCmd = &cobra.Command{
+		Use:   TimelineShowUsage,
+		Short: TimelineShowShortDescription,
+		Long:  TimelineShowLongDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			storage, err := internal.ConfigureStorage()
+			tracelog.ErrorLogger.FatalOnError(err)
+			err = postgres.HandleTimelineShow(storage.RootFolder(), timelineShowJSONOutput, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	timelineShowJSONOutput bool
+)
+
+func init() {
+	Cmd.AddCommand(timelineShowCmd)
+	timelineShowCmd.Flags().BoolVar(&timelineShowJSONOutput, timelineShowJSONFlag, false, timelineShowJSONDescription)
+}
@@ -0,0 +1,50 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/fsutil"
+)
+
+const (
+	WalDeltaBuildUsage            = "wal-delta-build first_segment_name last_segment_name"
+	WalDeltaBuildShortDescription = "Build delta files for WAL segments archived without WAL delta recording."
+	WalDeltaBuildLongDescription  = "Download WAL segments from storage, extract the changed block locations " +
+		"and upload the delta files used by delta backups. The range is extended to whole groups of " +
+		"16 segments, groups already having a delta file in storage are skipped."
+)
+
+// walDeltaBuildCmd represents the wal-delta-build command
+var walDeltaBuildCmd = &cobra.Command{
+	Use:   WalDeltaBuildUsage,
+	Short: WalDeltaBuildShortDescription,
+	Long:  WalDeltaBuildLongDescription,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		storage, err := postgres.ConfigureMultiStorage(true)
+		tracelog.ErrorLogger.FatalfOnError("Failed to configure multi-storage: %v", err)
+
+		walUploader, err := postgres.PrepareMultiStorageWalUploader(storage.RootFolder(), targetStorage)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		// part files of the groups, which can't be completed, are dropped with the temporary folder
+		// to keep the delta recording state of wal-push intact
+		deltaDataPath, err := os.MkdirTemp("", "walg_delta_build")
+		tracelog.ErrorLogger.FatalOnError(err)
+		deltaDataFolder, err := fsutil.NewDiskDataFolder(deltaDataPath)
+		tracelog.ErrorLogger.FatalOnError(err)
+		walUploader.DeltaFileManager = postgres.NewDeltaFileManager(deltaDataFolder)
+
+		err = postgres.HandleWalDeltaBuild(cmd.Context(), storage.RootFolder(), walUploader, args[0], args[1])
+		_ = os.RemoveAll(deltaDataPath)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	Cmd.AddCommand(walDeltaBuildCmd)
+	walDeltaBuildCmd.Flags().StringVar(&targetStorage, "target-storage", "", targetStorageDescription)
+}
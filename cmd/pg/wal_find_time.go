@@ -0,0 +1,62 @@
+package pg
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+const (
+	WalFindTimeUsage            = "wal-find-time timestamp"
+	WalFindTimeShortDescription = "Find the last transaction commit at or before the time in archived WAL."
+	WalFindTimeLongDescription  = "Scan the transaction commit records of archived WAL to find the exact LSN, xid and " +
+		"segment of the last commit at or before the timestamp (in RFC3339 format). " +
+		"Useful for choosing recovery_target_lsn or recovery_target_time."
+
+	findTimeTimelineFlag        = "timeline"
+	findTimeTimelineDescription = "Timeline to follow the history of. The highest timeline in storage is used by default."
+	findTimeJSONFlag            = "json"
+	findTimeJSONDescription     = "Show output in JSON format."
+)
+
+var (
+	// walFindTimeCmd represents the wal-find-time command
+	walFindTimeCmd = &cobra.Command{
+		Use:   WalFindTimeUsage,
+		Short: WalFindTimeShortDescription,
+		Long:  WalFindTimeLongDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			targetTime, err := time.Parse(time.RFC3339, args[0])
+			tracelog.ErrorLogger.FatalfOnError("Failed to parse the timestamp: %v", err)
+
+			storage, err := postgres.ConfigureMultiStorage(false)
+			tracelog.ErrorLogger.FatalfOnError("Failed to configure multi-storage: %v", err)
+
+			rootFolder, err := internal.PrepareMultiStorageFolder(storage.RootFolder(), targetStorage)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			outputType := postgres.WalFindTimeTextOutput
+			if findTimeJSONOutput {
+				outputType = postgres.WalFindTimeJSONOutput
+			}
+			outputWriter := postgres.NewWalFindTimeOutputWriter(outputType, os.Stdout)
+
+			err = postgres.HandleWalFindTime(rootFolder, targetTime, findTimeTimeline, outputWriter)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	findTimeTimeline   uint32
+	findTimeJSONOutput bool
+)
+
+func init() {
+	Cmd.AddCommand(walFindTimeCmd)
+	walFindTimeCmd.Flags().Uint32Var(&findTimeTimeline, findTimeTimelineFlag, 0, findTimeTimelineDescription)
+	walFindTimeCmd.Flags().BoolVar(&findTimeJSONOutput, findTimeJSONFlag, false, findTimeJSONDescription)
+	walFindTimeCmd.Flags().StringVar(&targetStorage, "target-storage", "", targetStorageDescription)
+}
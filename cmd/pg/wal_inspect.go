@@ -0,0 +1,114 @@
+package pg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/walparser"
+)
+
+const (
+	WalInspectUsage            = "wal-inspect segment_name|first_segment_name-last_segment_name"
+	WalInspectShortDescription = "Decode and print the records of WAL segments from storage."
+	WalInspectLongDescription  = "Download, decrypt and decompress WAL segments from storage and print their records " +
+		"in the pg_waldump-like format. Records can be filtered by resource manager, relation, transaction and LSN range."
+
+	inspectResourceManagerFlag        = "rmgr"
+	inspectResourceManagerDescription = "Show only the records of the specified resource managers, e.g. Heap,Btree."
+	inspectRelationFlag               = "relation"
+	inspectRelationDescription        = "Show only the records referencing blocks of the relation tablespace/database/relfilenode."
+	inspectXidFlag                    = "xid"
+	inspectXidDescription             = "Show only the records of the specified transaction."
+	inspectStartLSNFlag               = "start-lsn"
+	inspectStartLSNDescription        = "Show only the records starting at or after the LSN."
+	inspectEndLSNFlag                 = "end-lsn"
+	inspectEndLSNDescription          = "Show only the records starting before the LSN."
+	inspectJSONFlag                   = "json"
+	inspectJSONDescription            = "Print each record as a JSON object on a separate line."
+)
+
+var (
+	// walInspectCmd represents the wal-inspect command
+	walInspectCmd = &cobra.Command{
+		Use:   WalInspectUsage,
+		Short: WalInspectShortDescription,
+		Long:  WalInspectLongDescription,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := buildWalInspectFilter(cmd)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			storage, err := postgres.ConfigureMultiStorage(false)
+			tracelog.ErrorLogger.FatalfOnError("Failed to configure multi-storage: %v", err)
+
+			folderReader, err := internal.PrepareMultiStorageFolderReader(storage.RootFolder(), targetStorage)
+			tracelog.ErrorLogger.FatalOnError(err)
+
+			outputType := postgres.WalInspectTextOutput
+			if inspectJSONOutput {
+				outputType = postgres.WalInspectJSONOutput
+			}
+			outputWriter := postgres.NewWalInspectOutputWriter(outputType, os.Stdout)
+
+			err = postgres.HandleWalInspect(folderReader, args[0], filter, outputWriter)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	inspectResourceManagers []string
+	inspectRelation         string
+	inspectXid              uint32
+	inspectStartLSN         string
+	inspectEndLSN           string
+	inspectJSONOutput       bool
+)
+
+func buildWalInspectFilter(cmd *cobra.Command) (postgres.WalInspectFilter, error) {
+	filter := postgres.WalInspectFilter{}
+	for _, name := range inspectResourceManagers {
+		id, ok := walparser.ResourceManagerIDByName(name)
+		if !ok {
+			return filter, fmt.Errorf("unknown resource manager: %s", name)
+		}
+		filter.ResourceManagers = append(filter.ResourceManagers, id)
+	}
+	if inspectRelation != "" {
+		relFileNode, err := postgres.ParseRelFileNode(inspectRelation)
+		if err != nil {
+			return filter, err
+		}
+		filter.RelFileNode = relFileNode
+	}
+	if cmd.Flags().Changed(inspectXidFlag) {
+		filter.XactID = &inspectXid
+	}
+	var err error
+	if inspectStartLSN != "" {
+		filter.StartLSN, err = postgres.ParseLSN(inspectStartLSN)
+		if err != nil {
+			return filter, err
+		}
+	}
+	if inspectEndLSN != "" {
+		filter.EndLSN, err = postgres.ParseLSN(inspectEndLSN)
+		if err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func init() {
+	Cmd.AddCommand(walInspectCmd)
+	walInspectCmd.Flags().StringSliceVar(&inspectResourceManagers, inspectResourceManagerFlag, nil,
+		inspectResourceManagerDescription)
+	walInspectCmd.Flags().StringVar(&inspectRelation, inspectRelationFlag, "", inspectRelationDescription)
+	walInspectCmd.Flags().Uint32Var(&inspectXid, inspectXidFlag, 0, inspectXidDescription)
+	walInspectCmd.Flags().StringVar(&inspectStartLSN, inspectStartLSNFlag, "", inspectStartLSNDescription)
+	walInspectCmd.Flags().StringVar(&inspectEndLSN, inspectEndLSNFlag, "", inspectEndLSNDescription)
+	walInspectCmd.Flags().BoolVar(&inspectJSONOutput, inspectJSONFlag, false, inspectJSONDescription)
+	walInspectCmd.Flags().StringVar(&targetStorage, "target-storage", "", targetStorageDescription)
+}
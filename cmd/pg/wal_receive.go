@@ -2,13 +2,27 @@ package pg
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/asm"
 	"github.com/wal-g/wal-g/internal/databases/postgres"
 )
 
-const walReceiveShortDescription = "Receive WAL stream with postgres Streaming Replication Protocol and push to storage"
+const (
+	walReceiveShortDescription = "Receive WAL stream with postgres Streaming Replication Protocol and push to storage"
+	bufferDirFlag              = "buffer-dir"
+	bufferDirDescription       = "Act as a synchronous standby: confirm WAL flush once it's synced to this directory " +
+		"and upload complete segments from there"
+	slotHostsFlag        = "slot-hosts"
+	slotHostsDescription = "Comma separated host[:port] list of HA cluster members to create and advance " +
+		"the replication slot on"
+)
+
+var (
+	walReceiveBufferDir string
+	walReceiveSlotHosts string
+)
 
 // walReceiveCmd represents the walReceive command
 var walReceiveCmd = &cobra.Command{
@@ -29,10 +43,24 @@ var walReceiveCmd = &cobra.Command{
 			tracelog.ErrorLogger.PrintError(err)
 			uploader.ArchiveStatusManager = asm.NewNopASM()
 		}
-		postgres.HandleWALReceive(cmd.Context(), uploader)
+		if walReceiveBufferDir == "" {
+			walReceiveBufferDir = viper.GetString(internal.PgWalReceiveBufferDir)
+		}
+		if walReceiveSlotHosts == "" {
+			walReceiveSlotHosts = viper.GetString(internal.PgWalReceiveSlotHosts)
+		}
+		slotHosts, err := postgres.ParseSlotHosts(walReceiveSlotHosts)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		postgres.HandleWALReceive(cmd.Context(), uploader, postgres.WalReceiveOptions{
+			BufferDirectory: walReceiveBufferDir,
+			SlotHosts:       slotHosts,
+		})
 	},
 }
 
 func init() {
 	Cmd.AddCommand(walReceiveCmd)
+	walReceiveCmd.Flags().StringVar(&walReceiveBufferDir, bufferDirFlag, "", bufferDirDescription)
+	walReceiveCmd.Flags().StringVar(&walReceiveSlotHosts, slotHostsFlag, "", slotHostsDescription)
 }
@@ -15,20 +15,24 @@ import (
 
 const (
 	WalVerifyUsage            = "wal-verify"
-	WalVerifyShortDescription = "Verify WAL storage folder. Available checks: integrity, timeline."
+	WalVerifyShortDescription = "Verify WAL storage folder. Available checks: integrity, timeline, backup-coverage, pitr."
 	WalVerifyLongDescription  = "Run a set of specified checks to ensure WAL storage health."
 
 	useJSONOutputFlag        = "json"
 	useJSONOutputDescription = "Show output in JSON format."
 
-	checkIntegrityArg = "integrity"
-	checkTimelineArg  = "timeline"
+	checkIntegrityArg      = "integrity"
+	checkTimelineArg       = "timeline"
+	checkBackupCoverageArg = "backup-coverage"
+	checkPitrArg           = "pitr"
 )
 
 var (
 	availableChecks = map[string]postgres.WalVerifyCheckType{
-		checkIntegrityArg: postgres.WalVerifyIntegrityCheck,
-		checkTimelineArg:  postgres.WalVerifyTimelineCheck,
+		checkIntegrityArg:      postgres.WalVerifyIntegrityCheck,
+		checkTimelineArg:       postgres.WalVerifyTimelineCheck,
+		checkBackupCoverageArg: postgres.WalVerifyBackupCoverageCheck,
+		checkPitrArg:           postgres.WalVerifyPitrCheck,
 	}
 	// walVerifyCmd represents the walVerify command
 	walVerifyCmd = &cobra.Command{
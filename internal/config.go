@@ -114,6 +114,16 @@ const (
 	PgFailoverStoragesCheckSize            = "WALG_FAILOVER_STORAGES_CHECK_SIZE"
 	PgDaemonWALUploadTimeout               = "WALG_DAEMON_WAL_UPLOAD_TIMEOUT"
 	PgTargetStorage                        = "WALG_TARGET_STORAGE"
+	PgIncludeWal                           = "WALG_INCLUDE_WAL"
+	PgIncludeWalTimeout                    = "WALG_INCLUDE_WAL_TIMEOUT"
+	PgDaemonWalReadAhead                   = "WALG_DAEMON_WAL_READ_AHEAD"
+	PgDaemonWalReadAheadDir                = "WALG_DAEMON_WAL_READ_AHEAD_DIR"
+	PgWalReceiveBufferDir                  = "WALG_WAL_RECEIVE_BUFFER_DIR"
+	PgWalReceiveSlotHosts                  = "WALG_WAL_RECEIVE_SLOT_HOSTS"
+	PgStandbyWaitWal                       = "WALG_STANDBY_WAIT_WAL"
+	PgStandbyWaitWalTimeout                = "WALG_STANDBY_WAIT_WAL_TIMEOUT"
+	PgStandbyPrimaryConnInfo               = "WALG_STANDBY_PRIMARY_CONNINFO"
+	PgCatchupStreamSecret                  = "WALG_CATCHUP_STREAM_SECRET"
 
 	ProfileSamplingRatio = "PROFILE_SAMPLING_RATIO"
 	ProfileMode          = "PROFILE_MODE"
@@ -147,8 +157,15 @@ const (
 	MysqlBinlogServerPassword      = "WALG_MYSQL_BINLOG_SERVER_PASSWORD"
 	MysqlBinlogServerID            = "WALG_MYSQL_BINLOG_SERVER_ID"
 	MysqlBinlogServerReplicaSource = "WALG_MYSQL_BINLOG_SERVER_REPLICA_SOURCE"
+	MysqlBinlogStreamServerID      = "WALG_MYSQL_BINLOG_STREAM_SERVER_ID"
 	MysqlBackupDownloadMaxRetry    = "WALG_BACKUP_DOWNLOAD_MAX_RETRY"
 	MysqlIncrementalBackupDst      = "WALG_MYSQL_INCREMENTAL_BACKUP_DST"
+	MysqlXtrabackupLayout          = "WALG_MYSQL_XTRABACKUP_LAYOUT"
+	MysqlCloneDir                  = "WALG_MYSQL_CLONE_DIR"
+	MysqlCloneDonorDatasourceName  = "WALG_MYSQL_CLONE_DONOR_DATASOURCE_NAME"
+	MysqlLogicalBackupChunkRows    = "WALG_MYSQL_LOGICAL_BACKUP_CHUNK_ROWS"
+	MysqlVerifyMysqldCmd           = "WALG_MYSQL_VERIFY_MYSQLD_COMMAND"
+	MysqlVerifyStartupTimeout      = "WALG_MYSQL_VERIFY_STARTUP_TIMEOUT"
 	// Deprecated: unused
 	MysqlTakeBinlogsFromMaster = "WALG_MYSQL_TAKE_BINLOGS_FROM_MASTER"
 
@@ -260,6 +277,10 @@ var (
 		StreamSplitterBlockSize:     "1048576",
 		MysqlBackupDownloadMaxRetry: "1",
 		MysqlIncrementalBackupDst:   "/tmp",
+		MysqlXtrabackupLayout:       "stream",
+		MysqlLogicalBackupChunkRows: "1000000",
+		MysqlVerifyMysqldCmd:        "mysqld --no-defaults",
+		MysqlVerifyStartupTimeout:   "10m",
 	}
 
 	SQLServerDefaultSettings = map[string]string{
@@ -272,6 +293,11 @@ var (
 		PgAliveCheckInterval:        "1m",
 		PgFailoverStoragesCheckSize: "1mb",
 		PgDaemonWALUploadTimeout:    "60s",
+		PgIncludeWal:                "false",
+		PgIncludeWalTimeout:         "10m",
+		PgDaemonWalReadAhead:        "0",
+		PgStandbyWaitWal:            "true",
+		PgStandbyWaitWalTimeout:     "10m",
 	}
 
 	GPDefaultSettings = map[string]string{
@@ -449,6 +475,16 @@ var (
 		PgFailoverStorageCacheEMAAlphaDeadMin:  true,
 		PgFailoverStoragesCheckSize:            true,
 		PgDaemonWALUploadTimeout:               true,
+		PgIncludeWal:                           true,
+		PgIncludeWalTimeout:                    true,
+		PgDaemonWalReadAhead:                   true,
+		PgDaemonWalReadAheadDir:                true,
+		PgWalReceiveBufferDir:                  true,
+		PgWalReceiveSlotHosts:                  true,
+		PgStandbyWaitWal:                       true,
+		PgStandbyWaitWalTimeout:                true,
+		PgStandbyPrimaryConnInfo:               true,
+		PgCatchupStreamSecret:                  true,
 	}
 
 	MongoAllowedSettings = map[string]bool{
@@ -497,8 +533,15 @@ var (
 		MysqlBinlogServerPassword:      true,
 		MysqlBinlogServerID:            true,
 		MysqlBinlogServerReplicaSource: true,
+		MysqlBinlogStreamServerID:      true,
 		MysqlBackupDownloadMaxRetry:    true,
 		MysqlIncrementalBackupDst:      true,
+		MysqlXtrabackupLayout:          true,
+		MysqlCloneDir:                  true,
+		MysqlCloneDonorDatasourceName:  true,
+		MysqlLogicalBackupChunkRows:    true,
+		MysqlVerifyMysqldCmd:           true,
+		MysqlVerifyStartupTimeout:      true,
 	}
 
 	RedisAllowedSettings = map[string]bool{
@@ -2,12 +2,17 @@ package daemon
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"time"
 )
 
+var ErrHandshakeNotSupported = errors.New(
+	"daemon doesn't support the protocol version handshake, it's probably older than the client")
+
 type RunOptions struct {
 	MessageType SocketMessageType
 	SocketName  string
@@ -20,21 +25,19 @@ type RunOptions struct {
 func getMessage(messageType SocketMessageType, messageArgs []string) ([]byte, error) {
 	switch len(messageArgs) {
 	case 0:
-		return binary.BigEndian.AppendUint16(messageType.ToBytes(), uint16(3)), nil
+		return NewMessage(messageType, nil)
 	case 1:
-		res := binary.BigEndian.AppendUint16(messageType.ToBytes(), uint16(len(messageArgs[0])+3))
-		return append(res, []byte(messageArgs[0])...), nil
+		return NewMessage(messageType, []byte(messageArgs[0]))
 	}
 
 	messageBody, err := ArgsToBytes(messageArgs...)
 	if err != nil {
 		return nil, err
 	}
-	res := binary.BigEndian.AppendUint16(messageType.ToBytes(), uint16(len(messageBody)+3))
-	return append(res, messageBody...), nil
+	return NewMessage(messageType, messageBody)
 }
 
-func SendCommand(opts *RunOptions) (SocketMessageType, error) {
+func connect(opts *RunOptions) (net.Conn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), opts.DaemonSocketConnectionTimeout)
 	defer cancel()
 
@@ -42,13 +45,22 @@ func SendCommand(opts *RunOptions) (SocketMessageType, error) {
 	daemonAddr := net.UnixAddr{Name: opts.SocketName, Net: "unix"}
 	socketConnection, err := dialer.DialContext(ctx, "unix", daemonAddr.String())
 	if err != nil {
-		return ErrorType, fmt.Errorf("unix socket dial error: %w", err)
+		return nil, fmt.Errorf("unix socket dial error: %w", err)
 	}
-	defer socketConnection.Close()
 	err = socketConnection.SetDeadline(time.Now().Add(opts.DaemonOperationTimeout))
 	if err != nil {
-		return ErrorType, fmt.Errorf("unix socket set deadline error: %w", err)
+		socketConnection.Close()
+		return nil, fmt.Errorf("unix socket set deadline error: %w", err)
+	}
+	return socketConnection, nil
+}
+
+func SendCommand(opts *RunOptions) (SocketMessageType, error) {
+	socketConnection, err := connect(opts)
+	if err != nil {
+		return ErrorType, err
 	}
+	defer socketConnection.Close()
 
 	msg, err := getMessage(opts.MessageType, opts.MessageArgs)
 	if err != nil {
@@ -73,3 +85,47 @@ func SendCommand(opts *RunOptions) (SocketMessageType, error) {
 	}
 	return OkType, nil
 }
+
+// SendQuery negotiates the protocol version with the daemon, sends the message and returns the response body
+func SendQuery(opts *RunOptions) ([]byte, error) {
+	socketConnection, err := connect(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer socketConnection.Close()
+
+	_, err = exchangeMessages(socketConnection, VersionType, []string{strconv.Itoa(ProtocolVersion)})
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		// older daemons respond to the unknown message with a single error byte and close the connection
+		return nil, ErrHandshakeNotSupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("protocol version handshake failed: %w", err)
+	}
+	return exchangeMessages(socketConnection, opts.MessageType, opts.MessageArgs)
+}
+
+// exchangeMessages sends the message with the args encoded by ArgsToBytes and reads the response message
+func exchangeMessages(socketConnection net.Conn, messageType SocketMessageType, messageArgs []string) ([]byte, error) {
+	messageBody, err := ArgsToBytes(messageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := NewMessage(messageType, messageBody)
+	if err != nil {
+		return nil, err
+	}
+	_, err = socketConnection.Write(msg)
+	if err != nil {
+		return nil, fmt.Errorf("unix socket write error: %w", err)
+	}
+	responseType, responseBody, err := ReadMessage(socketConnection)
+	if err != nil {
+		return nil, err
+	}
+	if responseType != OkType {
+		return nil, fmt.Errorf("daemon command run error [message type: %v, args: %v, daemon response: %v %s]",
+			string(messageType), messageArgs, string(responseType), responseBody)
+	}
+	return responseBody, nil
+}
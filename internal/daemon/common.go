@@ -3,11 +3,16 @@ package daemon
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 )
 
 type SocketMessageType byte
 
+// ProtocolVersion is the daemon socket protocol version negotiated by the VersionType message.
+// Daemons and clients that don't support the handshake speak the version 1 protocol.
+const ProtocolVersion = 2
+
 const (
 	CheckType               SocketMessageType = 'C'
 	OkType                  SocketMessageType = 'O'
@@ -16,8 +21,18 @@ const (
 
 	WalPushType  SocketMessageType = 'F'
 	WalFetchType SocketMessageType = 'f'
+
+	// The messages below are available since protocol version 2 after the VersionType handshake,
+	// the daemon responds to them with a message with body rather than a single byte
+	VersionType      SocketMessageType = 'V'
+	StatusType       SocketMessageType = 'S'
+	MetricsType      SocketMessageType = 'M'
+	BackupPushType   SocketMessageType = 'B'
+	BackupStatusType SocketMessageType = 'b'
 )
 
+const messageHeaderLength = 3
+
 var (
 	ErrCorruptedCorruptedMessageBody = fmt.Errorf("currepted message body")
 )
@@ -70,3 +85,31 @@ func BytesToArgs(body []byte) ([]string, error) {
 	}
 	return res, nil
 }
+
+// NewMessage builds the message of the type with the body prefixed by the total message length
+func NewMessage(messageType SocketMessageType, body []byte) ([]byte, error) {
+	if len(body) > math.MaxUint16-messageHeaderLength {
+		return nil, fmt.Errorf("unsupported message body size")
+	}
+	res := binary.BigEndian.AppendUint16(messageType.ToBytes(), uint16(len(body)+messageHeaderLength))
+	return append(res, body...), nil
+}
+
+// ReadMessage reads the message built by NewMessage
+func ReadMessage(reader io.Reader) (SocketMessageType, []byte, error) {
+	header := make([]byte, messageHeaderLength)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return ErrorType, nil, fmt.Errorf("failed to read params: %w", err)
+	}
+	messageLength := int(binary.BigEndian.Uint16(header[1:]))
+	if messageLength < messageHeaderLength {
+		return ErrorType, nil, fmt.Errorf("invalid message length %d", messageLength)
+	}
+	body := make([]byte, messageLength-messageHeaderLength)
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return ErrorType, nil, fmt.Errorf("failed to read msg body: %w", err)
+	}
+	return SocketMessageType(header[0]), body, nil
+}
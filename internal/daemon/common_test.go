@@ -1,9 +1,15 @@
 package daemon
 
 import (
+	"bytes"
 	"fmt"
-	"github.com/stretchr/testify/assert"
+	"net"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDaemon_MessageBodyArrayConversion(t *testing.T) {
@@ -25,3 +31,89 @@ func TestDaemon_MessageBodyArrayConversion(t *testing.T) {
 		})
 	}
 }
+
+func TestDaemon_MessageRoundTrip(t *testing.T) {
+	for _, body := range [][]byte{nil, []byte("body")} {
+		message, err := NewMessage(StatusType, body)
+		assert.NoError(t, err)
+
+		messageType, readBody, err := ReadMessage(bytes.NewReader(message))
+		assert.NoError(t, err)
+		assert.Equal(t, StatusType, messageType)
+		assert.Equal(t, len(body), len(readBody))
+	}
+}
+
+func TestDaemon_ReadMessageInvalidLength(t *testing.T) {
+	_, _, err := ReadMessage(bytes.NewReader([]byte{byte(OkType), 0, 1}))
+	assert.Error(t, err)
+}
+
+func serveTestDaemon(t *testing.T, serve func(conn net.Conn)) string {
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	return socketPath
+}
+
+func newTestQueryOptions(socketPath string) *RunOptions {
+	return &RunOptions{
+		MessageType:                   StatusType,
+		SocketName:                    socketPath,
+		DaemonOperationTimeout:        5 * time.Second,
+		DaemonSocketConnectionTimeout: time.Second,
+	}
+}
+
+func TestDaemon_SendQuery(t *testing.T) {
+	socketPath := serveTestDaemon(t, func(conn net.Conn) {
+		for _, response := range []string{"2", "status"} {
+			_, body, err := ReadMessage(conn)
+			if err != nil {
+				return
+			}
+			_, err = BytesToArgs(body)
+			if err != nil {
+				return
+			}
+			message, _ := NewMessage(OkType, []byte(response))
+			_, _ = conn.Write(message)
+		}
+	})
+
+	response, err := SendQuery(newTestQueryOptions(socketPath))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("status"), response)
+}
+
+func TestDaemon_SendQueryErrorResponse(t *testing.T) {
+	socketPath := serveTestDaemon(t, func(conn net.Conn) {
+		_, _, _ = ReadMessage(conn)
+		message, _ := NewMessage(ErrorType, []byte("unsupported version"))
+		_, _ = conn.Write(message)
+	})
+
+	_, err := SendQuery(newTestQueryOptions(socketPath))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported version")
+}
+
+func TestDaemon_SendQueryToOldDaemon(t *testing.T) {
+	// daemons without the handshake support respond to unknown messages with a single byte
+	socketPath := serveTestDaemon(t, func(conn net.Conn) {
+		_, _, _ = ReadMessage(conn)
+		_, _ = conn.Write(ErrorType.ToBytes())
+	})
+
+	_, err := SendQuery(newTestQueryOptions(socketPath))
+	assert.ErrorIs(t, err, ErrHandshakeNotSupported)
+}
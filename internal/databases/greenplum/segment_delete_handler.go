@@ -182,7 +182,20 @@ func GetPermanentBackupsAndWals(rootFolder storage.Folder, contentID int) (map[p
 			}
 
 			startWalSegmentNo := postgres.NewWalSegmentNo(meta.StartLsn - 1)
-			lsn, err := postgres.ParseLSN(restorePointMeta.LsnBySegment[contentID])
+			backupObj := postgres.PermanentObject{
+				Name:        backupTime.BackupName,
+				StorageName: backupTime.StorageName,
+			}
+			permanentBackups[backupObj] = true
+
+			// the backup stays permanent even if its wals can't be found out
+			lsnStr, ok := restorePointMeta.LsnBySegment[contentID]
+			if !ok {
+				tracelog.WarningLogger.Printf("restore point %s has no lsn for segment %d, ignoring wals of backup %s...\n",
+					restorePoint, contentID, backupTime.BackupName)
+				continue
+			}
+			lsn, err := postgres.ParseLSN(lsnStr)
 			if err != nil {
 				tracelog.ErrorLogger.Printf("failed to parse lsn  %v\n", err)
 				continue
@@ -198,11 +211,6 @@ func GetPermanentBackupsAndWals(rootFolder storage.Folder, contentID int) (map[p
 				}
 				permanentWals[walObj] = true
 			}
-			backupObj := postgres.PermanentObject{
-				Name:        backupTime.BackupName,
-				StorageName: backupTime.StorageName,
-			}
-			permanentBackups[backupObj] = true
 		}
 	}
 	if len(permanentBackups) > 0 {
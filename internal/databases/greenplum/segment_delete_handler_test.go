@@ -0,0 +1,58 @@
+package greenplum_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/greenplum"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func putTestPermanentSegmentBackup(t *testing.T, rootFolder storage.Folder, lsnBySegment map[int]string) {
+	startTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	backupName := "base_000000010000000000000003"
+	segBackupsFolder := rootFolder.GetSubFolder(greenplum.FormatSegmentStoragePrefix(0)).GetSubFolder(utility.BaseBackupPath)
+	require.NoError(t, internal.UploadDto(segBackupsFolder, struct{}{}, backupName+utility.SentinelSuffix))
+	meta := postgres.ExtendedMetadataDto{StartTime: startTime, StartLsn: 0x3000028, IsPermanent: true}
+	require.NoError(t, internal.UploadDto(segBackupsFolder, meta, backupName+"/"+utility.MetadataFileName))
+
+	restorePoint := greenplum.RestorePointMetadata{
+		Name:         "rp",
+		StartTime:    startTime.Add(time.Minute),
+		FinishTime:   startTime.Add(time.Minute),
+		LsnBySegment: lsnBySegment,
+	}
+	require.NoError(t, internal.UploadDto(rootFolder.GetSubFolder(utility.BaseBackupPath), restorePoint,
+		greenplum.RestorePointMetadataFileName(restorePoint.Name)))
+}
+
+func TestGetPermanentBackupsAndWals(t *testing.T) {
+	rootFolder := testtools.MakeDefaultInMemoryStorageFolder()
+	putTestPermanentSegmentBackup(t, rootFolder, map[int]string{0: "0/5000000"})
+
+	backups, wals := greenplum.GetPermanentBackupsAndWals(rootFolder, 0)
+
+	assert.Equal(t, map[postgres.PermanentObject]bool{{Name: "base_000000010000000000000003", StorageName: "default"}: true}, backups)
+	assert.Equal(t, map[postgres.PermanentObject]bool{
+		{Name: "000000010000000000000003", StorageName: "default"}: true,
+		{Name: "000000010000000000000004", StorageName: "default"}: true,
+		{Name: "000000010000000000000005", StorageName: "default"}: true,
+	}, wals)
+}
+
+func TestGetPermanentBackupsAndWals_NoSegmentLSN(t *testing.T) {
+	rootFolder := testtools.MakeDefaultInMemoryStorageFolder()
+	putTestPermanentSegmentBackup(t, rootFolder, map[int]string{1: "0/5000000"})
+
+	backups, wals := greenplum.GetPermanentBackupsAndWals(rootFolder, 0)
+
+	// the backup isn't deleted even though its wals are unknown
+	assert.Equal(t, map[postgres.PermanentObject]bool{{Name: "base_000000010000000000000003", StorageName: "default"}: true}, backups)
+	assert.Empty(t, wals)
+}
@@ -18,12 +18,25 @@ func HandleBackupFetch(folder storage.Folder,
 	err = backup.FetchSentinel(&sentinel)
 	tracelog.ErrorLogger.FatalfOnError("Failed to fetch sentinel: %v", err)
 
+	if sentinel.Tool == WalgLogicalTool {
+		err = restoreLogicalBackup(backup, sentinel, nil, nil)
+		tracelog.ErrorLogger.FatalfOnError("Failed to restore backup: %v", err)
+		return
+	}
+	if restoreCmd == nil {
+		tracelog.ErrorLogger.Fatalf("%s is required to restore backup %s", internal.NameStreamRestoreCmd, backup.Name)
+	}
+
 	// we should ba able to read & restore any backup we ever created:
 	if sentinel.Tool == WalgXtrabackupTool {
 		internal.HandleBackupFetch(folder, targetBackupSelector, GetXtrabackupFetcher(restoreCmd, prepareCmd))
 	} else {
 		internal.HandleBackupFetch(folder, targetBackupSelector, internal.GetBackupToCommandFetcher(restoreCmd))
-		if prepareCmd != nil {
+		if sentinel.Tool == WalgCloneTool {
+			// the cloned data directory is consistent, mysqld recovers it on start
+			tracelog.InfoLogger.Printf("Restored the clone of %s at %s:%d, GTID set '%s'",
+				backup.Name, sentinel.BinLogFile, sentinel.BinLogPosition, sentinel.GTIDExecuted)
+		} else if prepareCmd != nil {
 			err = prepareCmd.Run()
 			tracelog.ErrorLogger.FatalfOnError("failed to prepare fetched backup: %v", err)
 		}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/limiters"
@@ -94,6 +95,9 @@ func HandleBackupPush(
 		Tool:              tool,
 		BinLogStart:       binlogStart,
 		BinLogEnd:         binlogEnd,
+		BinLogFile:        xtrabackupInfo.BinLogFile,
+		BinLogPosition:    xtrabackupInfo.BinLogPosition,
+		GTIDExecuted:      xtrabackupInfo.GTIDExecuted,
 		StartLocalTime:    timeStart,
 		StopLocalTime:     timeStop,
 		CompressedSize:    uploadedSize,
@@ -150,7 +154,16 @@ func handleXtrabackupBackup(
 	stdout, stderr, err := utility.StartCommandWithStdoutStderr(backupCmd)
 	tracelog.ErrorLogger.FatalfOnError("failed to start backup create command: %v", err)
 
-	backupName, err = uploader.PushStream(context.Background(), limiters.NewDiskLimitReader(stdout))
+	switch layout := viper.GetString(internal.MysqlXtrabackupLayout); layout {
+	case XtrabackupFilesLayout:
+		backupName, err = pushXtrabackupFiles(context.Background(), uploader, limiters.NewDiskLimitReader(stdout),
+			viper.GetInt64(internal.TarSizeThresholdSetting))
+	case XtrabackupStreamLayout:
+		backupName, err = uploader.PushStream(context.Background(), limiters.NewDiskLimitReader(stdout))
+	default:
+		tracelog.ErrorLogger.Fatalf("Unknown %s '%s', expected '%s' or '%s'", internal.MysqlXtrabackupLayout, layout,
+			XtrabackupStreamLayout, XtrabackupFilesLayout)
+	}
 	tracelog.ErrorLogger.FatalfOnError("failed to push backup: %v", err)
 
 	err = backupCmd.Wait()
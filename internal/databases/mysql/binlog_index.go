@@ -0,0 +1,319 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/wal-g/tracelog"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// BinlogIndexPath is the folder with the index entries of the archived binlogs, one entry per binlog
+const BinlogIndexPath = "binlog_index_" + utility.VersionStr + "/"
+
+// BinlogIndexEntry describes the archived binlog, so the lookups don't need to download it
+type BinlogIndexEntry struct {
+	BinlogName string `json:"BinlogName"`
+	Flavor     string `json:"Flavor"`
+	ServerUUID string `json:"ServerUUID,omitempty"`
+	// StartTime and EndTime are the timestamps of the first and the last events
+	StartTime time.Time `json:"StartTime"`
+	EndTime   time.Time `json:"EndTime"`
+	// PreviousGTIDs is the GTID set executed before the binlog
+	PreviousGTIDs string `json:"PreviousGTIDs"`
+	// GTIDs is the GTID set of the transactions in the binlog
+	GTIDs string `json:"GTIDs"`
+}
+
+func (entry *BinlogIndexEntry) String() string {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "-"
+	}
+	return string(b)
+}
+
+// GetPreviousGTIDs returns the parsed GTID set executed before the binlog
+func (entry *BinlogIndexEntry) GetPreviousGTIDs() (mysql.GTIDSet, error) {
+	return mysql.ParseGTIDSet(entry.Flavor, entry.PreviousGTIDs)
+}
+
+// BuildBinlogIndexEntry reads the whole binlog file to describe it
+//
+//gocyclo:ignore
+func BuildBinlogIndexEntry(filename string, flavor string, serverUUID string) (*BinlogIndexEntry, error) {
+	gtidSet, err := mysql.ParseGTIDSet(flavor, "")
+	if err != nil {
+		return nil, err
+	}
+	var firstTS, lastTS uint32
+	var previousGTIDs string
+	parser := replication.NewBinlogParser()
+	parser.SetFlavor(flavor)
+	parser.SetVerifyChecksum(false)
+	parser.SetRawMode(true)
+	err = parser.ParseFile(filename, 0, func(event *replication.BinlogEvent) error {
+		if event.Header.Timestamp != 0 {
+			if firstTS == 0 {
+				firstTS = event.Header.Timestamp
+			}
+			lastTS = event.Header.Timestamp
+		}
+		switch event.Header.EventType {
+		case replication.PREVIOUS_GTIDS_EVENT:
+			previousGTIDsEvent := &replication.PreviousGTIDsEvent{}
+			err := previousGTIDsEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			previousGTIDs = previousGTIDsEvent.GTIDSets
+		case replication.MARIADB_GTID_LIST_EVENT:
+			listEvent := &replication.MariadbGTIDListEvent{}
+			err := listEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			previousGTIDSet, _ := mysql.ParseMariadbGTIDSet("")
+			for i := range listEvent.GTIDs {
+				err = previousGTIDSet.(*mysql.MariadbGTIDSet).AddSet(&listEvent.GTIDs[i])
+				if err != nil {
+					return err
+				}
+			}
+			previousGTIDs = previousGTIDSet.String()
+		case replication.GTID_EVENT:
+			gtidEvent := &replication.GTIDEvent{}
+			err := gtidEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			sid, err := uuid.FromBytes(gtidEvent.SID)
+			if err != nil {
+				return err
+			}
+			gtidSet.(*mysql.MysqlGTIDSet).AddGTID(sid, gtidEvent.GNO)
+		case replication.MARIADB_GTID_EVENT:
+			gtidEvent := &replication.MariadbGTIDEvent{}
+			err := gtidEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			gtidEvent.GTID.ServerID = event.Header.ServerID
+			return gtidSet.(*mysql.MariadbGTIDSet).AddSet(&gtidEvent.GTID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse binlog %s: %w", filename, err)
+	}
+	return &BinlogIndexEntry{
+		BinlogName:    filepath.Base(filename),
+		Flavor:        flavor,
+		ServerUUID:    serverUUID,
+		StartTime:     time.Unix(int64(firstTS), 0).UTC(),
+		EndTime:       time.Unix(int64(lastTS), 0).UTC(),
+		PreviousGTIDs: previousGTIDs,
+		GTIDs:         gtidSet.String(),
+	}, nil
+}
+
+func UploadBinlogIndexEntry(folder storage.Folder, entry *BinlogIndexEntry) error {
+	entryName := entry.BinlogName + ".json"
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return internal.NewSentinelMarshallingError(entryName, err)
+	}
+	return folder.GetSubFolder(BinlogIndexPath).PutObject(entryName, bytes.NewReader(body))
+}
+
+// indexArchivedBinlog writes the index entry of the binlog which is just uploaded,
+// the binlog is archived anyway, so the failure is not fatal
+func indexArchivedBinlog(folder storage.Folder, binlogPath, flavor, serverUUID string) {
+	entry, err := BuildBinlogIndexEntry(binlogPath, flavor, serverUUID)
+	if err == nil {
+		err = UploadBinlogIndexEntry(folder, entry)
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to index binlog %s, run binlog-reindex to fix it: %v\n", binlogPath, err)
+	}
+}
+
+// binlogIndex reads the index entries on demand
+type binlogIndex struct {
+	folder  storage.Folder
+	exists  map[string]bool
+	entries map[string]*BinlogIndexEntry
+}
+
+func loadBinlogIndex(folder storage.Folder) (*binlogIndex, error) {
+	indexFolder := folder.GetSubFolder(BinlogIndexPath)
+	objects, _, err := indexFolder.ListFolder()
+	if err != nil {
+		return nil, err
+	}
+	index := &binlogIndex{
+		folder:  indexFolder,
+		exists:  make(map[string]bool, len(objects)),
+		entries: make(map[string]*BinlogIndexEntry),
+	}
+	for _, object := range objects {
+		index.exists[utility.TrimFileExtension(object.GetName())] = true
+	}
+	return index, nil
+}
+
+// get returns the index entry of the binlog, nil when the binlog is not indexed
+func (index *binlogIndex) get(binlogName string) *BinlogIndexEntry {
+	if index == nil || !index.exists[binlogName] {
+		return nil
+	}
+	if entry, ok := index.entries[binlogName]; ok {
+		return entry
+	}
+	entry, err := fetchBinlogIndexEntry(index.folder, binlogName)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read index entry of binlog %s: %v\n", binlogName, err)
+		index.entries[binlogName] = nil
+		return nil
+	}
+	index.entries[binlogName] = entry
+	return entry
+}
+
+func fetchBinlogIndexEntry(indexFolder storage.Folder, binlogName string) (*BinlogIndexEntry, error) {
+	reader, err := indexFolder.ReadObject(binlogName + ".json")
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var entry BinlogIndexEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// getBinlogIndexOrNil loads the binlog index, the lookups fall back to reading the binlogs when it is not available
+func getBinlogIndexOrNil(folder storage.Folder) *binlogIndex {
+	index, err := loadBinlogIndex(folder)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list binlog index: %v\n", err)
+		return nil
+	}
+	return index
+}
+
+// getBinlogPreviousGTIDs takes the Previous_gtids set of the archived binlog from the index, and reads
+// the beginning of the binlog when it is not indexed
+func getBinlogPreviousGTIDs(index *binlogIndex, logFolder storage.Folder, filename string,
+	flavor string) (mysql.GTIDSet, error) {
+	if entry := index.get(utility.TrimFileExtension(filename)); entry != nil && entry.Flavor == flavor {
+		return entry.GetPreviousGTIDs()
+	}
+	return GetBinlogPreviousGTIDsRemote(logFolder, filename, flavor)
+}
+
+// getBinlogStartTimestamp takes the first event timestamp of the downloaded binlog from the index,
+// and parses the binlog when it is not indexed
+func getBinlogStartTimestamp(index *binlogIndex, binlogPath string) (time.Time, error) {
+	if entry := index.get(filepath.Base(binlogPath)); entry != nil {
+		return entry.StartTime, nil
+	}
+	return GetBinlogStartTimestamp(binlogPath, mysql.MySQLFlavor)
+}
+
+// binlogSelector picks the archived binlogs to fetch by their index entries before downloading them,
+// the binlogs which are not indexed are always fetched
+type binlogSelector struct {
+	index *binlogIndex
+	endTS time.Time
+	// backupGTIDs is the GTID set of the backup, the binlogs with only these transactions are skipped
+	backupGTIDs string
+	stopTarget  *BinlogStopTarget
+}
+
+// check reports whether the binlog should be fetched and whether the binlogs since this one are not needed
+func (selector *binlogSelector) check(binlogName string) (fetch bool, stop bool) {
+	if selector == nil {
+		return true, false
+	}
+	entry := selector.index.get(binlogName)
+	if entry == nil {
+		return true, false
+	}
+	if entry.StartTime.After(selector.endTS) {
+		tracelog.InfoLogger.Printf("Binlog %s starts at %s after the end of the interval\n", binlogName, entry.StartTime)
+		return false, true
+	}
+	if selector.backupGTIDs == "" || entry.GTIDs == "" {
+		return true, false
+	}
+	if selector.stopTarget != nil && selector.stopTarget.mayStopIn(entry) {
+		return true, false
+	}
+	backupGTIDs, err := mysql.ParseGTIDSet(entry.Flavor, selector.backupGTIDs)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to parse backup GTID set '%s': %v\n", selector.backupGTIDs, err)
+		return true, false
+	}
+	gtids, err := mysql.ParseGTIDSet(entry.Flavor, entry.GTIDs)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to parse GTID set of binlog %s: %v\n", binlogName, err)
+		return true, false
+	}
+	if backupGTIDs.Contain(gtids) {
+		tracelog.InfoLogger.Printf("Skip binlog %s, the backup has all its transactions %s\n", binlogName, entry.GTIDs)
+		return false, false
+	}
+	return true, false
+}
+
+// HandleBinlogReindex writes the index entries for the archived binlogs, only the missing ones unless force is set
+func HandleBinlogReindex(folder storage.Folder, flavor string, force bool) {
+	logFolder := folder.GetSubFolder(BinlogPath)
+	logFiles, _, err := logFolder.ListFolder()
+	tracelog.ErrorLogger.FatalOnError(err)
+	index, err := loadBinlogIndex(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	tmpDir, err := os.MkdirTemp("", "walg_binlog_reindex")
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer os.RemoveAll(tmpDir)
+
+	indexed := 0
+	for _, logFile := range logFiles {
+		binlogName := utility.TrimFileExtension(logFile.GetName())
+		if !force && index.exists[binlogName] {
+			tracelog.DebugLogger.Printf("Skip binlog %s, it is already indexed\n", binlogName)
+			continue
+		}
+		binlogPath := filepath.Join(tmpDir, binlogName)
+		err = internal.DownloadFileTo(internal.NewFolderReader(logFolder), binlogName, binlogPath)
+		tracelog.ErrorLogger.FatalfOnError("Failed to download binlog: %v", err)
+		entry, err := BuildBinlogIndexEntry(binlogPath, flavor, "")
+		tracelog.ErrorLogger.FatalOnError(err)
+		err = os.Remove(binlogPath)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tracelog.InfoLogger.Printf("Indexing %s: %s\n", binlogName, entry)
+		err = UploadBinlogIndexEntry(folder, entry)
+		tracelog.ErrorLogger.FatalOnError(err)
+		indexed++
+	}
+	tracelog.InfoLogger.Printf("Indexed %d binlogs of %d\n", indexed, len(logFiles))
+}
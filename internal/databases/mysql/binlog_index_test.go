@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestBuildBinlogIndexEntry(t *testing.T) {
+	entry, err := BuildBinlogIndexEntry(testFilenameSmall, mysql.MySQLFlavor, "server-uuid")
+	require.NoError(t, err)
+	assert.Equal(t, &BinlogIndexEntry{
+		BinlogName:    "binlog_small_test",
+		Flavor:        mysql.MySQLFlavor,
+		ServerUUID:    "server-uuid",
+		StartTime:     time.Unix(1566047760, 0).UTC(),
+		EndTime:       time.Unix(1566047766, 0).UTC(),
+		PreviousGTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:1-106777",
+		GTIDs:         "",
+	}, entry)
+}
+
+func TestBinlogIndex(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	entry := &BinlogIndexEntry{
+		BinlogName:    "mysql-bin.000002",
+		Flavor:        mysql.MySQLFlavor,
+		StartTime:     time.Unix(1566047760, 0).UTC(),
+		PreviousGTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:1-100",
+	}
+	require.NoError(t, UploadBinlogIndexEntry(folder, entry))
+
+	index, err := loadBinlogIndex(folder)
+	require.NoError(t, err)
+	assert.Equal(t, entry, index.get("mysql-bin.000002"))
+	assert.Nil(t, index.get("mysql-bin.000003"))
+
+	// the indexed binlog is not downloaded to read its Previous_gtids
+	previousGTIDs, err := getBinlogPreviousGTIDs(index, folder.GetSubFolder(BinlogPath), "mysql-bin.000002.br",
+		mysql.MySQLFlavor)
+	require.NoError(t, err)
+	assert.Equal(t, entry.PreviousGTIDs, previousGTIDs.String())
+	_, err = getBinlogPreviousGTIDs(index, folder.GetSubFolder(BinlogPath), "mysql-bin.000003.br", mysql.MySQLFlavor)
+	assert.Error(t, err)
+
+	timestamp, err := getBinlogStartTimestamp(index, "/tmp/mysql-bin.000002")
+	require.NoError(t, err)
+	assert.Equal(t, entry.StartTime, timestamp)
+	timestamp, err = getBinlogStartTimestamp(nil, testFilenameSmall)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1566047760, 0), timestamp)
+}
+
+func TestGetLogsCoveringIntervalBySelector(t *testing.T) {
+	uploadTime := time.Unix(1566040000, 0)
+	folder := memory.NewFolder("in_memory/", memory.NewKVS(memory.WithCustomTime(func() time.Time {
+		uploadTime = uploadTime.Add(time.Minute)
+		return uploadTime
+	})))
+	logFolder := folder.GetSubFolder(BinlogPath)
+	for _, name := range []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003", "mysql-bin.000004"} {
+		require.NoError(t, logFolder.PutObject(name+".lz4", strings.NewReader("binlog")))
+	}
+	endTS := time.Unix(1566050000, 0).UTC()
+	entries := []*BinlogIndexEntry{
+		{BinlogName: "mysql-bin.000001", Flavor: mysql.MySQLFlavor, StartTime: endTS.Add(-time.Hour),
+			GTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:1-10"},
+		{BinlogName: "mysql-bin.000002", Flavor: mysql.MySQLFlavor, StartTime: endTS.Add(-time.Minute),
+			GTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:11-20"},
+		// mysql-bin.000003 is not indexed
+		{BinlogName: "mysql-bin.000004", Flavor: mysql.MySQLFlavor, StartTime: endTS.Add(time.Minute),
+			GTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:31-40"},
+	}
+	for _, entry := range entries {
+		require.NoError(t, UploadBinlogIndexEntry(folder, entry))
+	}
+	index, err := loadBinlogIndex(folder)
+	require.NoError(t, err)
+	names := func(objects []storage.Object) []string {
+		var result []string
+		for _, object := range objects {
+			result = append(result, utility.TrimFileExtension(object.GetName()))
+		}
+		return result
+	}
+
+	// the binlog with the backup transactions only and the binlog started after the end are not downloaded
+	selector := &binlogSelector{index: index, endTS: endTS, backupGTIDs: "6abc8ecb-bf5c-11e9-9821-c897993b5a14:1-15"}
+	logs, lastLog, err := getLogsCoveringInterval(logFolder, time.Time{}, true, utility.MaxTime, selector)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mysql-bin.000002", "mysql-bin.000003"}, names(logs))
+	assert.True(t, lastLog)
+
+	// the binlog with the stop target is fetched even if the backup has all its transactions
+	selector.stopTarget, err = NewBinlogStopTarget("6abc8ecb-bf5c-11e9-9821-c897993b5a14:1-5", false, "")
+	require.NoError(t, err)
+	logs, _, err = getLogsCoveringInterval(logFolder, time.Time{}, true, utility.MaxTime, selector)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"}, names(logs))
+
+	// the binlogs are picked by the last modified time only without the index
+	logs, lastLog, err = getLogsCoveringInterval(logFolder, time.Time{}, true, utility.MaxTime,
+		&binlogSelector{endTS: endTS})
+	require.NoError(t, err)
+	assert.Len(t, logs, 4)
+	assert.False(t, lastLog)
+}
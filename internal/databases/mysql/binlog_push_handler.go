@@ -60,11 +60,23 @@ func HandleBinlogPush(uploader internal.Uploader, untilBinlog string, checkGTIDs
 		}
 	}
 
-	var filter gtidFilter
+	// the binlogs are indexed when the flavor is known, only the GTID check can't go without it
+	flavor, err := getMySQLFlavor(db)
 	if checkGTIDs {
-		flavor, err := getMySQLFlavor(db)
 		tracelog.ErrorLogger.FatalOnError(err)
+	} else if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get MySQL flavor, binlogs are not indexed: %v\n", err)
+	}
+	serverUUID := ""
+	if flavor != "" {
+		serverUUID, err = getServerUUID(db, flavor)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to get server UUID: %v\n", err)
+		}
+	}
 
+	var filter gtidFilter
+	if checkGTIDs {
 		switch flavor {
 		case mysql.MySQLFlavor:
 			gtid, _ := mysql.ParseMysqlGTIDSet(binlogSentinelDto.GTIDArchived)
@@ -121,6 +133,9 @@ func HandleBinlogPush(uploader internal.Uploader, untilBinlog string, checkGTIDs
 		// Upload binlogs:
 		err = archiveBinLog(uploader, binlogsFolder, binlog)
 		tracelog.ErrorLogger.FatalOnError(err)
+		if flavor != "" {
+			indexArchivedBinlog(rootFolder, path.Join(binlogsFolder, binlog), flavor, serverUUID)
+		}
 
 		cache.LastArchivedBinlog = binlog
 		putCache(cache)
@@ -72,42 +72,43 @@ func (rh *replayHandler) handleBinlog(binlogPath string) error {
 	}
 }
 
-func HandleBinlogReplay(folder storage.Folder, backupName string, untilTS string, untilBinlogLastModifiedTS string) {
+func HandleBinlogReplay(folder storage.Folder, backupName string, untilTS string, untilBinlogLastModifiedTS string,
+	stopTarget *BinlogStopTarget) {
 	dstDir, err := internal.GetLogsDstSettings(internal.MysqlBinlogDstSetting)
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	startTS, endTS, endBinlogTS, err := getTimestamps(folder, backupName, untilTS, untilBinlogLastModifiedTS)
+	fetchRange, err := getTimestamps(folder, backupName, untilTS, untilBinlogLastModifiedTS)
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	handler := newReplayHandler(endTS)
+	handler := newReplayHandler(fetchRange.endTS)
 
-	tracelog.InfoLogger.Printf("Fetching binlogs since %s until %s", startTS, endTS)
-	err = fetchLogs(folder, dstDir, startTS, endTS, endBinlogTS, handler)
+	tracelog.InfoLogger.Printf("Fetching binlogs since %s until %s", fetchRange.startTS, fetchRange.endTS)
+	err = fetchLogs(folder, dstDir, fetchRange, stopTarget, handler)
 	tracelog.ErrorLogger.FatalfOnError("Failed to fetch binlogs: %v", err)
 
 	err = handler.wait()
 	tracelog.ErrorLogger.FatalfOnError("Failed to apply binlogs: %v", err)
 }
 
-func getTimestamps(folder storage.Folder, backupName, untilTS, untilBinlogLastModifiedTS string) (time.Time, time.Time, time.Time, error) {
+func getTimestamps(folder storage.Folder, backupName, untilTS, untilBinlogLastModifiedTS string) (binlogFetchRange, error) {
 	backup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, folder)
 	if err != nil {
-		return time.Time{}, time.Time{}, time.Time{}, errors.Wrap(err, "Unable to get backup")
+		return binlogFetchRange{}, errors.Wrap(err, "Unable to get backup")
 	}
 
-	startTS, err := getBinlogSinceTS(folder, backup)
+	startTS, backupGTIDs, err := getBinlogSinceTS(folder, backup)
 	if err != nil {
-		return time.Time{}, time.Time{}, time.Time{}, err
+		return binlogFetchRange{}, err
 	}
 
 	endTS, err := utility.ParseUntilTS(untilTS)
 	if err != nil {
-		return time.Time{}, time.Time{}, time.Time{}, err
+		return binlogFetchRange{}, err
 	}
 
 	endBinlogTS, err := utility.ParseUntilTS(untilBinlogLastModifiedTS)
 	if err != nil {
-		return time.Time{}, time.Time{}, time.Time{}, err
+		return binlogFetchRange{}, err
 	}
-	return startTS, endTS, endBinlogTS, nil
+	return binlogFetchRange{startTS: startTS, endTS: endTS, endBinlogTS: endBinlogTS, backupGTIDs: backupGTIDs}, nil
 }
@@ -0,0 +1,307 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/mod/semver"
+
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// RelayLogReplayChannel is the replication channel which applies the fetched binlogs
+	RelayLogReplayChannel = "walg_replay"
+	// relayLogReplaySourceHost is never connected to, the channel only runs the applier
+	relayLogReplaySourceHost     = "walg-relay-log-replay"
+	relayLogReplayPollInterval   = 5 * time.Second
+	replicaSyntaxMinMySQLVersion = "v8.0.23"
+)
+
+var mysqlVersionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// replicationStatements are the replication statements of the MySQL version,
+// the SOURCE/REPLICA syntax replaces the MASTER/SLAVE one since 8.0.23
+type replicationStatements struct {
+	stop        string
+	reset       string
+	changeTo    string
+	sourceHost  string
+	start       string
+	showStatus  string
+	sqlRunning  string
+	channelName string
+}
+
+// isMySQLVersionAtLeast compares the @@version of the server with the semver version like v8.0.23
+func isMySQLVersionAtLeast(version, minVersion string) bool {
+	match := mysqlVersionRegexp.FindStringSubmatch(version)
+	return match != nil && semver.Compare(fmt.Sprintf("v%s.%s.%s", match[1], match[2], match[3]), minVersion) >= 0
+}
+
+func newReplicationStatements(version string) replicationStatements {
+	statements := replicationStatements{
+		stop:       "STOP SLAVE",
+		reset:      "RESET SLAVE ALL",
+		changeTo:   "CHANGE MASTER TO",
+		sourceHost: "MASTER_HOST",
+		start:      "START SLAVE",
+		showStatus: "SHOW SLAVE STATUS",
+		sqlRunning: "Slave_SQL_Running",
+	}
+	if isMySQLVersionAtLeast(version, replicaSyntaxMinMySQLVersion) {
+		statements = replicationStatements{
+			stop:       "STOP REPLICA",
+			reset:      "RESET REPLICA ALL",
+			changeTo:   "CHANGE REPLICATION SOURCE TO",
+			sourceHost: "SOURCE_HOST",
+			start:      "START REPLICA",
+			showStatus: "SHOW REPLICA STATUS",
+			sqlRunning: "Replica_SQL_Running",
+		}
+	}
+	statements.channelName = RelayLogReplayChannel
+	return statements
+}
+
+func (statements replicationStatements) forChannel(statement string) string {
+	return fmt.Sprintf("%s FOR CHANNEL '%s'", statement, statements.channelName)
+}
+
+func (statements replicationStatements) changeToRelayLog(relayLogName string) string {
+	return statements.forChannel(fmt.Sprintf("%s RELAY_LOG_FILE='%s', RELAY_LOG_POS=%d, %s='%s'",
+		statements.changeTo, relayLogName, len(replication.BinLogFileHeader), statements.sourceHost, relayLogReplaySourceHost))
+}
+
+// startUntil starts the applier until the end of the last relay log, the relay logs are already cut at the stop target
+func (statements replicationStatements) startUntil(lastRelayLog string, lastRelayLogSize int64) string {
+	return statements.forChannel(fmt.Sprintf("%s SQL_THREAD UNTIL RELAY_LOG_FILE='%s', RELAY_LOG_POS=%d",
+		statements.start, lastRelayLog, lastRelayLogSize))
+}
+
+// relayLogHandler turns the fetched binlogs into the relay logs of the replay channel
+type relayLogHandler struct {
+	relayLogDir      string
+	relayLogBasename string
+	relayLogIndex    string
+	serverID         uint32
+	endTS            time.Time
+	relayLogs        []string
+}
+
+func newRelayLogHandler(relayLogBasename, relayLogIndex string, serverID uint32, endTS time.Time) *relayLogHandler {
+	return &relayLogHandler{
+		relayLogDir:      filepath.Dir(relayLogBasename),
+		relayLogBasename: filepath.Base(relayLogBasename) + "-" + RelayLogReplayChannel,
+		relayLogIndex:    strings.TrimSuffix(relayLogIndex, ".index") + "-" + RelayLogReplayChannel + ".index",
+		serverID:         serverID,
+		endTS:            endTS,
+	}
+}
+
+func (rh *relayLogHandler) handleBinlog(binlogPath string) error {
+	binlogServerID, err := getBinlogServerID(binlogPath)
+	if err != nil {
+		return err
+	}
+	if binlogServerID == rh.serverID {
+		// the applier skips the events with its own server_id unless replicate_same_server_id is set
+		return fmt.Errorf("binlog %s is written by server_id %d, the restored server has the same server_id "+
+			"and would skip its events: change server_id of the restored server", filepath.Base(binlogPath), binlogServerID)
+	}
+	_, err = truncateBinlogAfterTimestamp(binlogPath, rh.endTS)
+	if err != nil {
+		return err
+	}
+	relayLogName := fmt.Sprintf("%s.%06d", rh.relayLogBasename, len(rh.relayLogs)+1)
+	tracelog.InfoLogger.Printf("Placing %s as relay log %s", filepath.Base(binlogPath), relayLogName)
+	err = os.Rename(binlogPath, filepath.Join(rh.relayLogDir, relayLogName))
+	if err != nil {
+		return err
+	}
+	rh.relayLogs = append(rh.relayLogs, relayLogName)
+	return nil
+}
+
+func (rh *relayLogHandler) createIndexFile() error {
+	indexFile, err := os.Create(rh.relayLogIndex)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(indexFile, "")
+	for _, relayLog := range rh.relayLogs {
+		_, err = indexFile.WriteString(filepath.Join(rh.relayLogDir, relayLog) + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rh *relayLogHandler) lastRelayLog() (string, int64, error) {
+	lastRelayLog := rh.relayLogs[len(rh.relayLogs)-1]
+	info, err := os.Stat(filepath.Join(rh.relayLogDir, lastRelayLog))
+	if err != nil {
+		return "", 0, err
+	}
+	return lastRelayLog, info.Size(), nil
+}
+
+// getBinlogServerID reads the server_id from the header of the first event
+func getBinlogServerID(binlogPath string) (uint32, error) {
+	file, err := os.Open(binlogPath)
+	if err != nil {
+		return 0, err
+	}
+	defer utility.LoggedClose(file, "")
+	header := make([]byte, len(replication.BinLogFileHeader)+replication.EventHeaderSize)
+	_, err = file.ReadAt(header, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read binlog %s header: %w", binlogPath, err)
+	}
+	return binary.LittleEndian.Uint32(header[len(replication.BinLogFileHeader)+5:]), nil
+}
+
+// truncateBinlogAfterTimestamp cuts off the transactions committed after endTS like mysqlbinlog --stop-datetime,
+// it reports whether the binlog has such transactions
+func truncateBinlogAfterTimestamp(binlogPath string, endTS time.Time) (bool, error) {
+	var cutOffset int64
+	err := parseBinlogEventOffsets(binlogPath, func(offset int64, event *replication.BinlogEvent) error {
+		switch event.Header.EventType {
+		case replication.GTID_EVENT, replication.ANONYMOUS_GTID_EVENT:
+			if int64(event.Header.Timestamp) > endTS.Unix() {
+				cutOffset = offset
+				return errBinlogStopTargetReached
+			}
+		}
+		return nil
+	})
+	if err != nil || cutOffset == 0 {
+		return false, err
+	}
+	tracelog.InfoLogger.Printf("Time %s is reached in %s at position %d\n",
+		endTS.Format(time.RFC3339), filepath.Base(binlogPath), cutOffset)
+	return true, os.Truncate(binlogPath, cutOffset)
+}
+
+// HandleBinlogReplayRelayLog fetches the binlogs as the relay logs of the replay channel
+// and lets the applier of the running MySQL server replay them
+func HandleBinlogReplayRelayLog(folder storage.Folder, backupName string, untilTS string, untilBinlogLastModifiedTS string,
+	stopTarget *BinlogStopTarget) {
+	fetchRange, err := getTimestamps(folder, backupName, untilTS, untilBinlogLastModifiedTS)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	db, err := getMySQLConnection()
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(db, "")
+	version, err := getMySQLVersion(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	statements := newReplicationStatements(version)
+
+	var relayLogBasename, relayLogIndex string
+	var serverID uint32
+	err = db.QueryRow("SELECT @@relay_log_basename, @@relay_log_index, @@server_id").
+		Scan(&relayLogBasename, &relayLogIndex, &serverID)
+	tracelog.ErrorLogger.FatalfOnError("Failed to query relay log settings: %v", err)
+
+	// remove the channel and the relay logs left by the previous replay
+	resetRelayLogReplayChannel(db, statements)
+
+	handler := newRelayLogHandler(relayLogBasename, relayLogIndex, serverID, fetchRange.endTS)
+	tracelog.InfoLogger.Printf("Fetching binlogs since %s until %s into %s", fetchRange.startTS, fetchRange.endTS, handler.relayLogDir)
+	err = fetchLogs(folder, handler.relayLogDir, fetchRange, stopTarget, handler)
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch binlogs: %v", err)
+	if len(handler.relayLogs) == 0 {
+		tracelog.InfoLogger.Println("No binlogs to replay")
+		return
+	}
+	err = handler.createIndexFile()
+	tracelog.ErrorLogger.FatalfOnError("Failed to create relay log index file: %v", err)
+
+	lastRelayLog, lastRelayLogSize, err := handler.lastRelayLog()
+	tracelog.ErrorLogger.FatalOnError(err)
+	for _, statement := range []string{
+		statements.changeToRelayLog(handler.relayLogs[0]),
+		statements.startUntil(lastRelayLog, lastRelayLogSize),
+	} {
+		tracelog.InfoLogger.Println(statement)
+		_, err = db.Exec(statement)
+		tracelog.ErrorLogger.FatalfOnError("Failed to start the replay channel: %v", err)
+	}
+
+	err = waitRelayLogReplayIsDone(db, statements, len(handler.relayLogs))
+	tracelog.ErrorLogger.FatalfOnError("Failed to apply binlogs: %v", err)
+	resetRelayLogReplayChannel(db, statements)
+	tracelog.InfoLogger.Println("Replay is done")
+}
+
+func resetRelayLogReplayChannel(db *sql.DB, statements replicationStatements) {
+	for _, statement := range []string{statements.forChannel(statements.stop), statements.forChannel(statements.reset)} {
+		if _, err := db.Exec(statement); err != nil {
+			// the channel does not exist
+			tracelog.DebugLogger.Printf("%s: %v", statement, err)
+		}
+	}
+}
+
+// waitRelayLogReplayIsDone logs the applier progress until the applier stops at the UNTIL condition
+func waitRelayLogReplayIsDone(db *sql.DB, statements replicationStatements, relayLogCount int) error {
+	for {
+		status, err := getReplicaStatus(db, statements)
+		if err != nil {
+			return err
+		}
+		if status["Last_SQL_Errno"] != "" && status["Last_SQL_Errno"] != "0" {
+			return fmt.Errorf("applier stopped with error %s: %s", status["Last_SQL_Errno"], status["Last_SQL_Error"])
+		}
+		if status[statements.sqlRunning] != "Yes" {
+			return nil
+		}
+		tracelog.InfoLogger.Printf("Applying relay log %s (of %d) at position %s",
+			status["Relay_Log_File"], relayLogCount, status["Relay_Log_Pos"])
+		time.Sleep(relayLogReplayPollInterval)
+	}
+}
+
+// getReplicaStatus returns the status columns of the replay channel by their names,
+// which are different in the MySQL versions
+func getReplicaStatus(db *sql.DB, statements replicationStatements) (map[string]string, error) {
+	rows, err := db.Query(statements.forChannel(statements.showStatus))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(rows, "")
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("replication channel '%s' is not found", statements.channelName)
+	}
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err = rows.Scan(pointers...)
+	if err != nil {
+		return nil, err
+	}
+	status := make(map[string]string, len(columns))
+	for i, column := range columns {
+		status[column] = values[i].String
+	}
+	return status, nil
+}
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReplicationStatements(t *testing.T) {
+	legacy := newReplicationStatements("5.7.40-log")
+	assert.Equal(t, "CHANGE MASTER TO RELAY_LOG_FILE='relay-bin-walg_replay.000001', RELAY_LOG_POS=4, "+
+		"MASTER_HOST='walg-relay-log-replay' FOR CHANNEL 'walg_replay'", legacy.changeToRelayLog("relay-bin-walg_replay.000001"))
+	assert.Equal(t, "START SLAVE SQL_THREAD UNTIL RELAY_LOG_FILE='relay-bin-walg_replay.000003', RELAY_LOG_POS=1234 "+
+		"FOR CHANNEL 'walg_replay'", legacy.startUntil("relay-bin-walg_replay.000003", 1234))
+	assert.Equal(t, "STOP SLAVE", newReplicationStatements("8.0.22").stop)
+
+	statements := newReplicationStatements("8.0.35-27")
+	assert.Equal(t, "SHOW REPLICA STATUS FOR CHANNEL 'walg_replay'", statements.forChannel(statements.showStatus))
+	assert.Equal(t, "START REPLICA SQL_THREAD UNTIL RELAY_LOG_FILE='relay-bin-walg_replay.000003', RELAY_LOG_POS=1234 "+
+		"FOR CHANNEL 'walg_replay'", statements.startUntil("relay-bin-walg_replay.000003", 1234))
+}
+
+func TestRelayLogHandler(t *testing.T) {
+	relayLogDir := t.TempDir()
+	fetchDir := t.TempDir()
+	handler := newRelayLogHandler(filepath.Join(relayLogDir, "relay-bin"), filepath.Join(relayLogDir, "relay-bin.index"),
+		12345, time.Unix(1566047760, 0))
+
+	_, size := writeTestBinlog(t, filepath.Join(fetchDir, "mysql-bin.000002"), 6, 7)
+	require.NoError(t, handler.handleBinlog(filepath.Join(fetchDir, "mysql-bin.000002")))
+	_, size3 := writeTestBinlog(t, filepath.Join(fetchDir, "mysql-bin.000003"), 8)
+	require.NoError(t, handler.handleBinlog(filepath.Join(fetchDir, "mysql-bin.000003")))
+	require.NoError(t, handler.createIndexFile())
+
+	assert.Equal(t, []string{"relay-bin-walg_replay.000001", "relay-bin-walg_replay.000002"}, handler.relayLogs)
+	info, err := os.Stat(filepath.Join(relayLogDir, "relay-bin-walg_replay.000001"))
+	require.NoError(t, err)
+	assert.Equal(t, size, info.Size())
+	lastRelayLog, lastRelayLogSize, err := handler.lastRelayLog()
+	require.NoError(t, err)
+	assert.Equal(t, "relay-bin-walg_replay.000002", lastRelayLog)
+	assert.Equal(t, size3, lastRelayLogSize)
+
+	index, err := os.ReadFile(filepath.Join(relayLogDir, "relay-bin-walg_replay.index"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(relayLogDir, "relay-bin-walg_replay.000001")+"\n"+
+		filepath.Join(relayLogDir, "relay-bin-walg_replay.000002")+"\n", string(index))
+
+	// the applier would skip the events of the binlogs written by the restored server itself
+	path := filepath.Join(fetchDir, "mysql-bin.000004")
+	writeTestBinlog(t, path)
+	serverID, err := getBinlogServerID(path)
+	require.NoError(t, err)
+	handler.serverID = serverID
+	assert.Error(t, handler.handleBinlog(path))
+}
+
+func TestTruncateBinlogAfterTimestamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000002")
+
+	_, size := writeTestBinlog(t, path, 6, 7)
+	reached, err := truncateBinlogAfterTimestamp(path, time.Unix(1566047760, 0))
+	require.NoError(t, err)
+	assert.False(t, reached)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, size, info.Size())
+
+	offsets, _ := writeTestBinlog(t, path, 6, 7)
+	reached, err = truncateBinlogAfterTimestamp(path, time.Unix(1566047759, 0))
+	require.NoError(t, err)
+	assert.True(t, reached)
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, offsets[0], info.Size())
+}
@@ -1,15 +1,21 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"net"
+	"net/http"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
@@ -18,16 +24,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/wal-g/tracelog"
 	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/webserver"
 	"github.com/wal-g/wal-g/pkg/storages/storage"
 	"github.com/wal-g/wal-g/utility"
 )
 
-var (
-	startTS      time.Time
-	untilTS      time.Time
-	lastSentGTID string
+const (
+	// binlogServerPollInterval is how often the archive is listed for the new binlogs,
+	// the idle replicas get a heartbeat at the same interval
+	binlogServerPollInterval = 10 * time.Second
 )
 
+var errBinlogServerSessionClosed = errors.New("replica connection is closed")
+
 func handleEventError(err error, s *replication.BinlogStreamer) {
 	if err == nil {
 		return
@@ -39,64 +48,47 @@ func handleEventError(err error, s *replication.BinlogStreamer) {
 	}
 }
 
+// newArtificialEvent builds the event which is not in the binlog files, like the fake rotate and the heartbeat,
+// the event has the CRC32 checksum as the replica expects it from the binlog server
+func newArtificialEvent(serverID uint32, eventType replication.EventType, logPos uint32, body []byte) *replication.BinlogEvent {
+	eventLength := replication.EventHeaderSize + len(body) + replication.BinlogChecksumLength
+	rawData := make([]byte, eventLength)
+	// header: timestamp (4 bytes, zero for the artificial events), type (1 byte), server_id (4 bytes),
+	// event_length (4 bytes), end_log_pos (4 bytes), flags (2 bytes)
+	rawData[4] = byte(eventType)
+	binary.LittleEndian.PutUint32(rawData[5:], serverID)
+	binary.LittleEndian.PutUint32(rawData[9:], uint32(eventLength))
+	binary.LittleEndian.PutUint32(rawData[13:], logPos)
+	binary.LittleEndian.PutUint16(rawData[17:], replication.LOG_EVENT_ARTIFICIAL_F)
+	copy(rawData[replication.EventHeaderSize:], body)
+
+	checksum := crc32.ChecksumIEEE(rawData[:replication.EventHeaderSize+len(body)])
+	binary.LittleEndian.PutUint32(rawData[replication.EventHeaderSize+len(body):], checksum)
+
+	header := &replication.EventHeader{}
+	_ = header.Decode(rawData)
+	return &replication.BinlogEvent{RawData: rawData, Header: header}
+}
+
 // see: https://dev.mysql.com/doc/dev/mysql-server/latest/classbinary__log_1_1Rotate__event.html
-func addRotateEvent(s *replication.BinlogStreamer, pos mysql.Position) error {
-	serverID, err := internal.GetRequiredSetting(internal.MysqlBinlogServerID)
-	tracelog.ErrorLogger.FatalOnError(err)
-	ServerIDNum, err := strconv.Atoi(serverID)
-	tracelog.ErrorLogger.FatalOnError(err)
+func newRotateEvent(serverID uint32, pos mysql.Position) *replication.BinlogEvent {
+	body := make([]byte, 8+len(pos.Name))
+	binary.LittleEndian.PutUint64(body, uint64(pos.Pos))
+	copy(body[8:], pos.Name)
+	return newArtificialEvent(serverID, replication.ROTATE_EVENT, 0, body)
+}
 
-	// create rotate event
-	rotateBinlogEvent := replication.BinlogEvent{}
-
-	messageBodySize := 8 + len(pos.Name) + 1
-	eventLength := replication.EventHeaderSize + messageBodySize + replication.BinlogChecksumLength
-
-	rotateBinlogEvent.RawData = make([]byte, eventLength)
-	// generate header:
-	// timestamp default 4 bytes
-	binlogEventPos := 4
-	// type - 1 byte
-	rotateBinlogEvent.RawData[binlogEventPos] = byte(replication.ROTATE_EVENT)
-	binlogEventPos++
-	// server_id- 4 bytes
-	binary.LittleEndian.PutUint32(rotateBinlogEvent.RawData[binlogEventPos:], uint32(ServerIDNum))
-	binlogEventPos += 4
-	// event_length - 4 bytes
-	binary.LittleEndian.PutUint32(rotateBinlogEvent.RawData[binlogEventPos:], uint32(eventLength))
-	binlogEventPos += 4
-	// end_log_pos - 4 bytes
-	binary.LittleEndian.PutUint32(rotateBinlogEvent.RawData[binlogEventPos:], 0)
-	binlogEventPos += 4
-	// flags - 2 bytes
-	binary.LittleEndian.PutUint16(rotateBinlogEvent.RawData[binlogEventPos:], 0)
-	binlogEventPos += 2
-
-	// set binlog event data:
-	// position - 8 bytes
-	binary.LittleEndian.PutUint64(rotateBinlogEvent.RawData[binlogEventPos:], uint64(pos.Pos))
-	binlogEventPos += 8
-	// new binlog name - zero-terminated string
-	copy(rotateBinlogEvent.RawData[binlogEventPos:], pos.Name)
-	binlogEventPos += len(pos.Name)
-	rotateBinlogEvent.RawData[binlogEventPos] = 0
-	binlogEventPos++
-
-	checksum := crc32.ChecksumIEEE(rotateBinlogEvent.RawData[0 : replication.EventHeaderSize+messageBodySize])
-	binary.LittleEndian.PutUint32(rotateBinlogEvent.RawData[binlogEventPos:], checksum)
-
-	return s.AddEventToStreamer(&rotateBinlogEvent)
-}
-
-func waitReplicationIsDone() error {
-	replicaSource, err := internal.GetRequiredSetting(internal.MysqlBinlogServerReplicaSource)
-	if err != nil {
-		return err
-	}
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/classbinary__log_1_1Heartbeat__event.html
+func newHeartbeatEvent(serverID uint32, pos mysql.Position) *replication.BinlogEvent {
+	return newArtificialEvent(serverID, replication.HEARTBEAT_EVENT, pos.Pos, []byte(pos.Name))
+}
+
+func waitReplicationIsDone(replicaSource string, sentGTIDSet mysql.GTIDSet) error {
 	db, err := sql.Open("mysql", replicaSource)
 	if err != nil {
 		return err
 	}
+	defer utility.LoggedClose(db, "")
 	for {
 		// get executed GTID set from replica
 		gtidSet, err := getMySQLGTIDExecuted(db, "mysql")
@@ -104,14 +96,9 @@ func waitReplicationIsDone() error {
 			return err
 		}
 
-		lastSentGTIDSet, err := mysql.ParseGTIDSet("mysql", lastSentGTID)
-		if err != nil {
-			return err
-		}
-
-		tracelog.DebugLogger.Printf("Expected GTID set: %v; MySQL GTID set: %v", lastSentGTIDSet.String(), gtidSet.String())
+		tracelog.DebugLogger.Printf("Expected GTID set: %v; MySQL GTID set: %v", sentGTIDSet.String(), gtidSet.String())
 
-		if gtidSet.Contain(lastSentGTIDSet) {
+		if gtidSet.Contain(sentGTIDSet) {
 			tracelog.InfoLogger.Println("Replication is done")
 			return nil
 		}
@@ -119,107 +106,527 @@ func waitReplicationIsDone() error {
 	}
 }
 
-func sendEventsFromBinlogFiles(logFilesProvider *storage.ObjectProvider, pos mysql.Position, s *replication.BinlogStreamer) {
-	err := addRotateEvent(s, pos)
-	handleEventError(err, s)
+// binlogArchive keeps the listing of the archived binlogs, so the replicas don't list the storage on their own
+type binlogArchive struct {
+	folder storage.Folder
+
+	mutex     sync.Mutex
+	binlogs   []storage.Object
+	listedAt  time.Time
+	listError error
+}
+
+func (archive *binlogArchive) refresh() {
+	binlogs, _, err := archive.folder.ListFolder()
+	if err == nil {
+		sortBinlogsByLastModified(binlogs)
+	}
+
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+	archive.listedAt = utility.TimeNowCrossPlatformUTC()
+	archive.listError = err
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list the archived binlogs: %v\n", err)
+		return
+	}
+	archive.binlogs = binlogs
+}
+
+// list returns the archived binlogs ordered by the upload time and the error of the last listing
+func (archive *binlogArchive) list() ([]storage.Object, error) {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+	return archive.binlogs, archive.listError
+}
+
+func (archive *binlogArchive) poll(ctx context.Context) {
+	ticker := time.NewTicker(binlogServerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			archive.refresh()
+		}
+	}
+}
+
+func sortBinlogsByLastModified(binlogs []storage.Object) {
+	sort.SliceStable(binlogs, func(i, j int) bool {
+		if binlogs[i].GetLastModified().Equal(binlogs[j].GetLastModified()) {
+			return binlogs[i].GetName() < binlogs[j].GetName()
+		}
+		return binlogs[i].GetLastModified().Before(binlogs[j].GetLastModified())
+	})
+}
+
+// binlogServerSession is the replication stream of one connected replica
+type binlogServerSession struct {
+	id        uint64
+	addr      string
+	startedAt time.Time
+
+	mutex         sync.Mutex
+	currentBinlog string
+	sentGTIDs     *mysql.MysqlGTIDSet
+}
+
+type binlogServerSessionStatus struct {
+	ID            uint64    `json:"id"`
+	Addr          string    `json:"addr"`
+	StartedAt     time.Time `json:"started_at"`
+	CurrentBinlog string    `json:"current_binlog"`
+	SentGTIDs     string    `json:"sent_gtids"`
+}
+
+func (session *binlogServerSession) update(binlogName string, sentGTIDs *mysql.MysqlGTIDSet) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	session.currentBinlog = binlogName
+	if sentGTIDs != nil {
+		session.sentGTIDs = sentGTIDs.Clone().(*mysql.MysqlGTIDSet)
+	}
+}
+
+func (session *binlogServerSession) status() binlogServerSessionStatus {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	status := binlogServerSessionStatus{
+		ID:            session.id,
+		Addr:          session.addr,
+		StartedAt:     session.startedAt,
+		CurrentBinlog: session.currentBinlog,
+	}
+	if session.sentGTIDs != nil {
+		status.SentGTIDs = session.sentGTIDs.String()
+	}
+	return status
+}
+
+// binlogServer serves the archived binlogs to any number of replicas until it is stopped
+type binlogServer struct {
+	rootFolder storage.Folder
+	archive    *binlogArchive
+	serverID   uint32
+	dstDir     string
+	// sinceTS is the last modified time of the first binlog the replicas with GTID auto-positioning can start from
+	sinceTS time.Time
+	// untilTS is zero when the replicas follow the archive forever
+	untilTS time.Time
+	// replicaSource is set for the PITR: the server stops when the replica applies everything before untilTS
+	replicaSource string
+	// stop makes the server return when the PITR is done
+	stop context.CancelFunc
+
+	mutex         sync.Mutex
+	sessions      map[uint64]*binlogServerSession
+	lastSessionID uint64
+}
+
+func (srv *binlogServer) addSession(addr string) *binlogServerSession {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.lastSessionID++
+	session := &binlogServerSession{
+		id:        srv.lastSessionID,
+		addr:      addr,
+		startedAt: utility.TimeNowCrossPlatformUTC(),
+	}
+	srv.sessions[session.id] = session
+	return session
+}
+
+func (srv *binlogServer) removeSession(session *binlogServerSession) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	delete(srv.sessions, session.id)
+}
+
+func (srv *binlogServer) sessionStatuses() []binlogServerSessionStatus {
+	srv.mutex.Lock()
+	sessions := make([]*binlogServerSession, 0, len(srv.sessions))
+	for _, session := range srv.sessions {
+		sessions = append(sessions, session)
+	}
+	srv.mutex.Unlock()
+
+	statuses := make([]binlogServerSessionStatus, 0, len(sessions))
+	for _, session := range sessions {
+		statuses = append(statuses, session.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// findGTIDStartBinlog returns the index of the newest binlog which starts with the transactions
+// already executed by the replica, the replica gets the transactions it misses from this binlog on
+func (srv *binlogServer) findGTIDStartBinlog(binlogs []storage.Object, replicaGTIDs *mysql.MysqlGTIDSet) (int, error) {
+	index := getBinlogIndexOrNil(srv.rootFolder)
+	for i := len(binlogs) - 1; i >= 0; i-- {
+		previousGTIDs, err := getBinlogPreviousGTIDs(index, srv.archive.folder, binlogs[i].GetName(), mysql.MySQLFlavor)
+		if err != nil {
+			return 0, err
+		}
+		if replicaGTIDs.Contain(previousGTIDs) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("the archive has no binlog to continue GTID set '%s' from: "+
+		"the replica misses the transactions which are not archived", replicaGTIDs.String())
+}
+
+// binlogsSince returns the binlogs archived since the time, the binlogs are ordered by the upload time
+func binlogsSince(binlogs []storage.Object, sinceTS time.Time) []storage.Object {
+	for i, binlog := range binlogs {
+		if !binlog.GetLastModified().Before(sinceTS) {
+			return binlogs[i:]
+		}
+	}
+	return nil
+}
 
+func findBinlog(binlogs []storage.Object, binlogName string) (int, error) {
+	for i, binlog := range binlogs {
+		if utility.TrimFileExtension(binlog.GetName()) == binlogName {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("binlog %s is not found in the archive", binlogName)
+}
+
+// newBinlogsAfter returns the binlogs archived after the last sent one
+func newBinlogsAfter(binlogs []storage.Object, lastModified time.Time, sent map[string]bool) []storage.Object {
+	var newBinlogs []storage.Object
+	for _, binlog := range binlogs {
+		if binlog.GetLastModified().Before(lastModified) || sent[utility.TrimFileExtension(binlog.GetName())] {
+			continue
+		}
+		newBinlogs = append(newBinlogs, binlog)
+	}
+	return newBinlogs
+}
+
+// binlogEventSender passes the events of the downloaded binlogs to the replica
+type binlogEventSender struct {
+	streamer *replication.BinlogStreamer
+	serverID uint32
+	untilTS  time.Time
+	// replicaGTIDs are the transactions the replica has already executed, they are not sent again
+	replicaGTIDs *mysql.MysqlGTIDSet
+	// sentGTIDs are the transactions the replica has after the sent events
+	sentGTIDs *mysql.MysqlGTIDSet
+	position  mysql.Position
+	skipping  bool
+}
+
+func newBinlogEventSender(streamer *replication.BinlogStreamer, serverID uint32, untilTS time.Time,
+	replicaGTIDs *mysql.MysqlGTIDSet) *binlogEventSender {
+	sender := &binlogEventSender{
+		streamer:     streamer,
+		serverID:     serverID,
+		untilTS:      untilTS,
+		replicaGTIDs: replicaGTIDs,
+	}
+	if replicaGTIDs != nil {
+		sender.sentGTIDs = replicaGTIDs.Clone().(*mysql.MysqlGTIDSet)
+	}
+	return sender
+}
+
+func (sender *binlogEventSender) sendRotate(pos mysql.Position) error {
+	sender.position = pos
+	return sender.streamer.AddEventToStreamer(newRotateEvent(sender.serverID, pos))
+}
+
+func (sender *binlogEventSender) sendHeartbeat() error {
+	return sender.streamer.AddEventToStreamer(newHeartbeatEvent(sender.serverID, sender.position))
+}
+
+// sendBinlogFile sends the events of the binlog from the offset and reports whether untilTS is reached
+func (sender *binlogEventSender) sendBinlogFile(binlogPath string, offset int64) (bool, error) {
 	p := replication.NewBinlogParser()
 	p.SetRawMode(true)
 	p.SetFlavor(mysql.MySQLFlavor)
-	// check checksum on our side - we should exit with error here rather than stuck waiting for MySQL apply all binlogs till `lastSentGTID`.
+	// check checksum on our side - we should exit with error here rather than stuck waiting for MySQL apply all binlogs
 	p.SetVerifyChecksum(true)
 
-	f := func(e *replication.BinlogEvent) error {
-		if int64(e.Header.Timestamp) > untilTS.Unix() {
+	untilReached := false
+	err := p.ParseFile(binlogPath, offset, func(e *replication.BinlogEvent) error {
+		if !sender.untilTS.IsZero() && int64(e.Header.Timestamp) > sender.untilTS.Unix() {
+			untilReached = true
 			return nil
 		}
-		if e.Header.EventType == replication.GTID_EVENT {
-			gtidEvent := &replication.GTIDEvent{}
-			err = gtidEvent.Decode(e.RawData[replication.EventHeaderSize:])
-			tracelog.ErrorLogger.FatalOnError(err)
-			u, _ := uuid.FromBytes(gtidEvent.SID)
-			lastSentGTID = u.String() + ":1-" + strconv.Itoa(int(gtidEvent.GNO))
+		send, err := sender.trackEvent(e)
+		if err != nil || !send {
+			return err
 		}
-		err := s.AddEventToStreamer(e)
+		return sender.streamer.AddEventToStreamer(e)
+	})
+	return untilReached, err
+}
+
+// trackEvent updates the position and the sent GTID set, it reports whether the event should be sent
+func (sender *binlogEventSender) trackEvent(e *replication.BinlogEvent) (bool, error) {
+	switch e.Header.EventType {
+	case replication.GTID_EVENT:
+		gtidEvent := &replication.GTIDEvent{}
+		err := gtidEvent.Decode(e.RawData[replication.EventHeaderSize:])
+		if err != nil {
+			return false, err
+		}
+		sid, err := uuid.FromBytes(gtidEvent.SID)
+		if err != nil {
+			return false, err
+		}
+		transactionGTID := &mysql.MysqlGTIDSet{Sets: make(map[string]*mysql.UUIDSet)}
+		transactionGTID.AddGTID(sid, gtidEvent.GNO)
+		sender.skipping = sender.replicaGTIDs != nil && sender.replicaGTIDs.Contain(transactionGTID)
+		if !sender.skipping && sender.sentGTIDs != nil {
+			sender.sentGTIDs.AddGTID(sid, gtidEvent.GNO)
+		}
+	case replication.PREVIOUS_GTIDS_EVENT:
+		sender.skipping = false
+		if sender.sentGTIDs == nil {
+			// the replica is positioned by the binlog name, it has the transactions before the binlog
+			previousGTIDsEvent := &replication.PreviousGTIDsEvent{}
+			err := previousGTIDsEvent.Decode(e.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return false, err
+			}
+			previousGTIDs, err := mysql.ParseMysqlGTIDSet(previousGTIDsEvent.GTIDSets)
+			if err != nil {
+				return false, err
+			}
+			sender.sentGTIDs = previousGTIDs.(*mysql.MysqlGTIDSet)
+		}
+	case replication.FORMAT_DESCRIPTION_EVENT, replication.ROTATE_EVENT, replication.STOP_EVENT:
+		// the replica needs these events to follow the binlog files even when the transactions are skipped
+		sender.skipping = false
+	}
+	if sender.skipping {
+		return false, nil
+	}
+	if rotateEvent, ok := e.Event.(*replication.RotateEvent); ok {
+		sender.position = mysql.Position{Name: string(rotateEvent.NextLogName), Pos: uint32(rotateEvent.Position)}
+	} else if e.Header.LogPos != 0 {
+		sender.position.Pos = e.Header.LogPos
+	}
+	return true, nil
+}
+
+// stream sends the binlogs starting from the position and then the binlogs archived later,
+// it returns when the session is closed or when untilTS is reached
+func (srv *binlogServer) stream(ctx context.Context, session *binlogServerSession, s *replication.BinlogStreamer,
+	binlogs []storage.Object, pos mysql.Position, replicaGTIDs *mysql.MysqlGTIDSet) error {
+	sessionDir := filepath.Join(srv.dstDir, fmt.Sprintf("session_%d", session.id))
+	err := os.MkdirAll(sessionDir, 0755)
+	if err != nil {
 		return err
 	}
-	dstDir, _ := internal.GetLogsDstSettings(internal.MysqlBinlogDstSetting)
+	defer os.RemoveAll(sessionDir)
 
+	sender := newBinlogEventSender(s, srv.serverID, srv.untilTS, replicaGTIDs)
+	err = sender.sendRotate(pos)
+	if err != nil {
+		return err
+	}
+	offset := int64(pos.Pos)
+	sent := make(map[string]bool)
+	var lastModified time.Time
 	for {
-		logFile, err := logFilesProvider.GetObject()
-		if errors.Is(err, storage.ErrNoMoreObjects) {
-			err := waitReplicationIsDone()
+		for _, binlog := range binlogs {
+			binlogName := utility.TrimFileExtension(binlog.GetName())
+			binlogPath := filepath.Join(sessionDir, binlogName)
+			err = internal.DownloadFileTo(internal.NewFolderReader(srv.archive.folder), binlogName, binlogPath)
+			if err != nil {
+				return err
+			}
+			tracelog.InfoLogger.Printf("Session %d: sending binlog file %s", session.id, binlogName)
+			untilReached, err := sender.sendBinlogFile(binlogPath, offset)
 			if err != nil {
-				tracelog.InfoLogger.Println("Error while waiting MySQL applied binlogs: ", err)
+				return err
+			}
+			err = os.Remove(binlogPath)
+			if err != nil {
+				return err
+			}
+			offset = int64(len(replication.BinLogFileHeader))
+			sent[binlogName] = true
+			lastModified = binlog.GetLastModified()
+			session.update(binlogName, sender.sentGTIDs)
+			if untilReached {
+				return srv.finishUntil(ctx, session, sender)
 			}
-			os.Exit(0)
 		}
-		handleEventError(err, s)
+
+		binlogs, err = srv.waitNewBinlogs(ctx, sender, lastModified, sent)
 		if err != nil {
-			break
+			return err
 		}
-		binlogName := utility.TrimFileExtension(logFile.GetName())
-		tracelog.InfoLogger.Printf("Synced binlog file %s", binlogName)
-		binlogPath := path.Join(dstDir, binlogName)
-		err = p.ParseFile(binlogPath, int64(pos.Pos), f)
-		handleEventError(err, s)
+	}
+}
 
-		err = os.Remove(binlogPath)
-		handleEventError(err, s)
-		pos.Pos = 4
+// waitNewBinlogs sends the heartbeats to the replica until the new binlogs are archived
+func (srv *binlogServer) waitNewBinlogs(ctx context.Context, sender *binlogEventSender,
+	lastModified time.Time, sent map[string]bool) ([]storage.Object, error) {
+	ticker := time.NewTicker(binlogServerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		err := sender.sendHeartbeat()
+		if err != nil {
+			return nil, err
+		}
+		binlogs, err := srv.archive.list()
+		if err != nil {
+			continue
+		}
+		if newBinlogs := newBinlogsAfter(binlogs, lastModified, sent); len(newBinlogs) > 0 {
+			return newBinlogs, nil
+		}
 	}
 }
 
-func syncBinlogFiles(pos mysql.Position, startTS time.Time, s *replication.BinlogStreamer) error {
-	// get necessary settings
-	st, err := internal.ConfigureStorage()
-	if err != nil {
-		return err
+// finishUntil waits for the replica to apply the sent transactions and stops the server when it is used for the PITR,
+// otherwise the replica stays connected and gets the heartbeats
+func (srv *binlogServer) finishUntil(ctx context.Context, session *binlogServerSession, sender *binlogEventSender) error {
+	tracelog.InfoLogger.Printf("Session %d: all the binlogs before %s are sent", session.id, srv.untilTS.Format(time.RFC3339))
+	if srv.replicaSource != "" && sender.sentGTIDs != nil {
+		err := waitReplicationIsDone(srv.replicaSource, sender.sentGTIDs)
+		if err != nil {
+			tracelog.InfoLogger.Println("Error while waiting MySQL applied binlogs: ", err)
+		}
+		srv.stop()
+		return nil
 	}
-	dstDir, err := internal.GetLogsDstSettings(internal.MysqlBinlogDstSetting)
+	ticker := time.NewTicker(binlogServerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		err := sender.sendHeartbeat()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (srv *binlogServer) serveConnection(serverCtx context.Context, c net.Conn, user, password string) {
+	session := srv.addSession(c.RemoteAddr().String())
+	defer srv.removeSession(session)
+	tracelog.InfoLogger.Printf("Session %d: connection from %s accepted", session.id, session.addr)
+
+	ctx, cancel := context.WithCancel(serverCtx)
+	defer cancel()
+	handler := &Handler{binlogServer: srv, session: session, ctx: ctx}
+	conn, err := server.NewConn(c, user, password, handler)
 	if err != nil {
-		return err
+		tracelog.WarningLogger.Printf("Session %d: failed to create connection: %v", session.id, err)
+		utility.LoggedClose(c, "")
+		return
+	}
+	for {
+		if err := conn.HandleCommand(); err != nil {
+			tracelog.WarningLogger.Printf("Session %d: error handling command: %v", session.id, err)
+			break
+		}
+	}
+	if !conn.Closed() {
+		conn.Close()
 	}
-	logFilesProvider := storage.NewLowMemoryObjectProvider()
-	// start sync
-	go sendEventsFromBinlogFiles(logFilesProvider, pos, s)
-	go provideLogs(st.RootFolder(), dstDir, startTS, untilTS, logFilesProvider)
+	// the streaming goroutine may wait for the replica to read the events, unblock it
+	cancel()
+	if handler.streamer != nil {
+		handler.streamer.AddErrorToStreamer(errBinlogServerSessionClosed)
+	}
+	tracelog.InfoLogger.Printf("Session %d: connection closed", session.id)
+}
 
-	return nil
+func (srv *binlogServer) startStreaming(h *Handler, binlogs []storage.Object, pos mysql.Position,
+	replicaGTIDs *mysql.MysqlGTIDSet) *replication.BinlogStreamer {
+	s := replication.NewBinlogStreamer()
+	h.streamer = s
+	go func() {
+		err := srv.stream(h.ctx, h.session, s, binlogs, pos, replicaGTIDs)
+		if err != nil && h.ctx.Err() == nil {
+			handleEventError(err, s)
+		}
+	}()
+	return s
+}
+
+func (srv *binlogServer) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	srv.archive.mutex.Lock()
+	listedAt, listError := srv.archive.listedAt, srv.archive.listError
+	srv.archive.mutex.Unlock()
+	if listError != nil {
+		http.Error(w, fmt.Sprintf("failed to list the archived binlogs at %s: %v", listedAt.Format(time.RFC3339), listError),
+			http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "OK, the archive is listed at %s\n", listedAt.Format(time.RFC3339))
+}
+
+func (srv *binlogServer) handleSessions(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(srv.sessionStatuses())
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to write binlog server sessions: %v", err)
+	}
 }
 
 type Handler struct {
 	server.EmptyReplicationHandler
+	binlogServer *binlogServer
+	session      *binlogServerSession
+	ctx          context.Context
+	streamer     *replication.BinlogStreamer
 }
 
-func (h Handler) HandleRegisterSlave(data []byte) error {
+func (h *Handler) HandleRegisterSlave(data []byte) error {
 	return nil
 }
 
-func (h Handler) HandleBinlogDump(pos mysql.Position) (*replication.BinlogStreamer, error) {
-	s := replication.NewBinlogStreamer()
-
-	st, err := internal.ConfigureStorage()
+func (h *Handler) HandleBinlogDump(pos mysql.Position) (*replication.BinlogStreamer, error) {
+	binlogs, err := h.binlogServer.archive.list()
 	if err != nil {
 		return nil, err
 	}
-
-	startTime, err := GetBinlogTS(st.RootFolder(), pos.Name)
+	start, err := findBinlog(binlogs, pos.Name)
 	if err != nil {
 		return nil, err
 	}
-	err = syncBinlogFiles(pos, startTime, s)
-	return s, err
+	tracelog.InfoLogger.Printf("Session %d: replica starts from %s:%d", h.session.id, pos.Name, pos.Pos)
+	return h.binlogServer.startStreaming(h, binlogs[start:], pos, nil), nil
 }
 
-func (h Handler) HandleBinlogDumpGTID(gtidSet *mysql.MysqlGTIDSet) (*replication.BinlogStreamer, error) {
-	s := replication.NewBinlogStreamer()
-
-	err := syncBinlogFiles(mysql.Position{Name: "host-binlog-file", Pos: 4}, startTS, s)
-	return s, err
+func (h *Handler) HandleBinlogDumpGTID(gtidSet *mysql.MysqlGTIDSet) (*replication.BinlogStreamer, error) {
+	binlogs, err := h.binlogServer.archive.list()
+	if err != nil {
+		return nil, err
+	}
+	binlogs = binlogsSince(binlogs, h.binlogServer.sinceTS)
+	start, err := h.binlogServer.findGTIDStartBinlog(binlogs, gtidSet)
+	if err != nil {
+		return nil, err
+	}
+	binlogName := utility.TrimFileExtension(binlogs[start].GetName())
+	tracelog.InfoLogger.Printf("Session %d: replica with GTID set '%s' starts from %s",
+		h.session.id, gtidSet.String(), binlogName)
+	pos := mysql.Position{Name: binlogName, Pos: uint32(len(replication.BinLogFileHeader))}
+	return h.binlogServer.startStreaming(h, binlogs[start:], pos, gtidSet), nil
 }
 
-func (h Handler) HandleQuery(query string) (*mysql.Result, error) {
+func (h *Handler) HandleQuery(query string) (*mysql.Result, error) {
 	switch strings.ToLower(query) {
 	case "select @master_binlog_checksum":
 		resultSet, _ := mysql.BuildSimpleTextResultset([]string{"master_binlog_checksum"}, [][]interface{}{{"CRC32"}})
@@ -232,9 +639,7 @@ func (h Handler) HandleQuery(query string) (*mysql.Result, error) {
 		resultSet, _ := mysql.BuildSimpleTextResultset([]string{"BINLOG_CHECKSUM"}, [][]interface{}{{"CRC32"}})
 		return &mysql.Result{Status: 34, Warnings: 0, InsertId: 0, AffectedRows: 0, Resultset: resultSet}, nil
 	case "select @@global.server_id":
-		serverID, err := internal.GetRequiredSetting(internal.MysqlBinlogServerID)
-		tracelog.ErrorLogger.FatalOnError(err)
-		resultSet, err := mysql.BuildSimpleTextResultset([]string{"SERVER_ID"}, [][]interface{}{{serverID}})
+		resultSet, err := mysql.BuildSimpleTextResultset([]string{"SERVER_ID"}, [][]interface{}{{h.binlogServer.serverID}})
 		tracelog.ErrorLogger.FatalOnError(err)
 		return &mysql.Result{Status: 34, Warnings: 0, InsertId: 0, AffectedRows: 0, Resultset: resultSet}, nil
 	case "select @@global.gtid_mode":
@@ -257,11 +662,67 @@ func (h Handler) HandleQuery(query string) (*mysql.Result, error) {
 	}
 }
 
-func HandleBinlogServer(since string, until string) {
+// serve accepts the replicas until the context is cancelled
+func (srv *binlogServer) serve(ctx context.Context, l net.Listener, user, password string) error {
+	go func() {
+		<-ctx.Done()
+		utility.LoggedClose(l, "")
+	}()
+	for {
+		c, err := l.Accept()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go srv.serveConnection(ctx, c, user, password)
+	}
+}
+
+// HandleBinlogServer serves the archived binlogs since the backup to the replicas. The server stops when the replica
+// of WALG_MYSQL_BINLOG_SERVER_REPLICA_SOURCE applies the binlogs up to until, with follow it keeps running
+// and sends the newly archived binlogs
+func HandleBinlogServer(since string, until string, follow bool) {
 	st, err := internal.ConfigureStorage()
 	tracelog.ErrorLogger.FatalOnError(err)
-	startTS, untilTS, _, err = getTimestamps(st.RootFolder(), since, until, "")
+	rootFolder := st.RootFolder()
+
+	fetchRange, err := getTimestamps(rootFolder, since, until, "")
 	tracelog.ErrorLogger.FatalOnError(err)
+	untilTS := fetchRange.endTS
+	replicaSource, _ := internal.GetSetting(internal.MysqlBinlogServerReplicaSource)
+	if follow {
+		untilTS = time.Time{}
+		replicaSource = ""
+	}
+	serverIDSetting, err := internal.GetRequiredSetting(internal.MysqlBinlogServerID)
+	tracelog.ErrorLogger.FatalOnError(err)
+	serverID, err := strconv.ParseUint(serverIDSetting, 10, 32)
+	tracelog.ErrorLogger.FatalfOnError("Failed to parse server id: %v", err)
+	dstDir, err := internal.GetLogsDstSettings(internal.MysqlBinlogDstSetting)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	srv := &binlogServer{
+		rootFolder:    rootFolder,
+		archive:       &binlogArchive{folder: rootFolder.GetSubFolder(BinlogPath)},
+		serverID:      uint32(serverID),
+		dstDir:        dstDir,
+		sinceTS:       fetchRange.startTS,
+		untilTS:       untilTS,
+		replicaSource: replicaSource,
+		stop:          stop,
+		sessions:      make(map[uint64]*binlogServerSession),
+	}
+	srv.archive.refresh()
+	go srv.archive.poll(ctx)
+
+	if webserver.DefaultWebServer != nil {
+		webserver.DefaultWebServer.HandleFunc("/binlog-server/health", srv.handleHealth)
+		webserver.DefaultWebServer.HandleFunc("/binlog-server/sessions", srv.handleSessions)
+	}
 
 	tracelog.InfoLogger.Printf("Starting binlog server")
 
@@ -269,26 +730,15 @@ func HandleBinlogServer(since string, until string) {
 	tracelog.ErrorLogger.FatalOnError(err)
 	serverPort, err := internal.GetRequiredSetting(internal.MysqlBinlogServerPort)
 	tracelog.ErrorLogger.FatalOnError(err)
-	l, err := net.Listen("tcp", serverAddress+":"+serverPort)
-	tracelog.ErrorLogger.FatalOnError(err)
-	tracelog.InfoLogger.Printf("Listening on %s, wait connection", l.Addr())
-
-	c, err := l.Accept()
-	tracelog.ErrorLogger.FatalOnError(err)
-	tracelog.InfoLogger.Printf("connection accepted")
-
 	user, err := internal.GetRequiredSetting(internal.MysqlBinlogServerUser)
 	tracelog.ErrorLogger.FatalOnError(err)
 	password, err := internal.GetRequiredSetting(internal.MysqlBinlogServerPassword)
 	tracelog.ErrorLogger.FatalOnError(err)
-	conn, err := server.NewConn(c, user, password, Handler{})
+	l, err := net.Listen("tcp", net.JoinHostPort(serverAddress, serverPort))
 	tracelog.ErrorLogger.FatalOnError(err)
-	tracelog.InfoLogger.Printf("connection created")
+	tracelog.InfoLogger.Printf("Listening on %s, wait connections", l.Addr())
 
-	for {
-		if err := conn.HandleCommand(); err != nil {
-			tracelog.WarningLogger.Printf("Error handling command: %v", err)
-			break
-		}
-	}
+	err = srv.serve(ctx, l, user, password)
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Binlog server is stopped")
 }
@@ -0,0 +1,156 @@
+package mysql
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+func TestNewRotateEvent(t *testing.T) {
+	event := newRotateEvent(99, mysql.Position{Name: "mysql-bin.000042", Pos: 4})
+	data := event.RawData
+
+	assert.Equal(t, replication.ROTATE_EVENT, event.Header.EventType)
+	assert.Equal(t, uint32(99), event.Header.ServerID)
+	assert.Equal(t, uint32(len(data)), event.Header.EventSize)
+	assert.Equal(t, uint32(0), event.Header.LogPos)
+	assert.Equal(t, crc32.ChecksumIEEE(data[:len(data)-4]), binary.LittleEndian.Uint32(data[len(data)-4:]))
+
+	rotateEvent := &replication.RotateEvent{}
+	require.NoError(t, rotateEvent.Decode(data[replication.EventHeaderSize:len(data)-replication.BinlogChecksumLength]))
+	assert.Equal(t, uint64(4), rotateEvent.Position)
+	assert.Equal(t, "mysql-bin.000042", string(rotateEvent.NextLogName))
+}
+
+func TestNewHeartbeatEvent(t *testing.T) {
+	event := newHeartbeatEvent(99, mysql.Position{Name: "mysql-bin.000042", Pos: 1234})
+	data := event.RawData
+
+	assert.Equal(t, replication.HEARTBEAT_EVENT, event.Header.EventType)
+	assert.Equal(t, uint32(1234), event.Header.LogPos)
+	assert.Equal(t, "mysql-bin.000042", string(data[replication.EventHeaderSize:len(data)-replication.BinlogChecksumLength]))
+	assert.Equal(t, crc32.ChecksumIEEE(data[:len(data)-4]), binary.LittleEndian.Uint32(data[len(data)-4:]))
+}
+
+func TestBinlogServer_FindGTIDStartBinlog(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	srv := &binlogServer{rootFolder: rootFolder, archive: &binlogArchive{folder: rootFolder.GetSubFolder(BinlogPath)}}
+	var binlogs []storage.Object
+	for i, previousGTIDs := range []string{"", testServerUUID.String() + ":1-10", testServerUUID.String() + ":1-20"} {
+		name := fmt.Sprintf("mysql-bin.%06d", i+1)
+		require.NoError(t, UploadBinlogIndexEntry(rootFolder, &BinlogIndexEntry{
+			BinlogName:    name,
+			Flavor:        mysql.MySQLFlavor,
+			PreviousGTIDs: previousGTIDs,
+		}))
+		binlogs = append(binlogs, storage.NewLocalObject(name+".br", time.Unix(int64(i), 0), 0))
+	}
+
+	for replicaGTIDs, expected := range map[string]int{
+		"":                                0,
+		testServerUUID.String() + ":1-5":  0,
+		testServerUUID.String() + ":1-15": 1,
+		testServerUUID.String() + ":1-25": 2,
+	} {
+		gtidSet, err := mysql.ParseMysqlGTIDSet(replicaGTIDs)
+		require.NoError(t, err)
+		start, err := srv.findGTIDStartBinlog(binlogs, gtidSet.(*mysql.MysqlGTIDSet))
+		require.NoError(t, err)
+		assert.Equal(t, expected, start, replicaGTIDs)
+	}
+
+	// the replica misses the transactions before the oldest binlog
+	gtidSet, err := mysql.ParseMysqlGTIDSet(testServerUUID.String() + ":1-15")
+	require.NoError(t, err)
+	_, err = srv.findGTIDStartBinlog(binlogs[1:], gtidSet.(*mysql.MysqlGTIDSet))
+	require.NoError(t, err)
+	_, err = srv.findGTIDStartBinlog(binlogs[2:], gtidSet.(*mysql.MysqlGTIDSet))
+	assert.Error(t, err)
+}
+
+func TestNewBinlogsAfter(t *testing.T) {
+	binlogs := []storage.Object{
+		storage.NewLocalObject("mysql-bin.000001.br", time.Unix(10, 0), 0),
+		storage.NewLocalObject("mysql-bin.000002.br", time.Unix(20, 0), 0),
+		storage.NewLocalObject("mysql-bin.000003.br", time.Unix(20, 0), 0),
+		storage.NewLocalObject("mysql-bin.000004.br", time.Unix(30, 0), 0),
+	}
+	newBinlogs := newBinlogsAfter(binlogs, time.Unix(20, 0), map[string]bool{"mysql-bin.000002": true})
+	require.Len(t, newBinlogs, 2)
+	assert.Equal(t, "mysql-bin.000003.br", newBinlogs[0].GetName())
+	assert.Equal(t, "mysql-bin.000004.br", newBinlogs[1].GetName())
+}
+
+func TestBinlogsSince(t *testing.T) {
+	binlogs := []storage.Object{
+		storage.NewLocalObject("mysql-bin.000001.br", time.Unix(10, 0), 0),
+		storage.NewLocalObject("mysql-bin.000002.br", time.Unix(20, 0), 0),
+		storage.NewLocalObject("mysql-bin.000003.br", time.Unix(30, 0), 0),
+	}
+	assert.Len(t, binlogsSince(binlogs, time.Time{}), 3)
+	since := binlogsSince(binlogs, time.Unix(20, 0))
+	require.Len(t, since, 2)
+	assert.Equal(t, "mysql-bin.000002.br", since[0].GetName())
+	assert.Empty(t, binlogsSince(binlogs, time.Unix(40, 0)))
+}
+
+func TestBinlogServer_ServeReturnsWhenStopped(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	srv := &binlogServer{stop: stop, sessions: make(map[uint64]*binlogServerSession)}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.serve(ctx, l, "user", "password")
+	}()
+	srv.stop()
+	select {
+	case err = <-served:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve didn't return after the server is stopped")
+	}
+}
+
+func TestBinlogEventSender_SkipsExecutedTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000002")
+	writeTestBinlog(t, path, 6, 7, 8)
+	replicaGTIDs, err := mysql.ParseMysqlGTIDSet(testServerUUID.String() + ":1-7")
+	require.NoError(t, err)
+	sender := newBinlogEventSender(nil, 99, time.Time{}, replicaGTIDs.(*mysql.MysqlGTIDSet))
+
+	var sent []replication.EventType
+	var lastSentEnd uint32
+	err = parseBinlogEventOffsets(path, func(offset int64, event *replication.BinlogEvent) error {
+		send, err := sender.trackEvent(event)
+		if send {
+			sent = append(sent, event.Header.EventType)
+			lastSentEnd = event.Header.LogPos
+		}
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []replication.EventType{
+		replication.FORMAT_DESCRIPTION_EVENT,
+		replication.PREVIOUS_GTIDS_EVENT,
+		replication.GTID_EVENT,
+		replication.QUERY_EVENT,
+		replication.XID_EVENT,
+	}, sent)
+	assert.Equal(t, lastSentEnd, sender.position.Pos)
+	assert.Equal(t, testServerUUID.String()+":1-8", sender.sentGTIDs.String())
+	assert.Equal(t, testServerUUID.String()+":1-7", sender.replicaGTIDs.String())
+}
@@ -0,0 +1,216 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var errBinlogStopTargetReached = errors.New("binlog stop target is reached")
+
+// BinlogStopTarget is the exact point in the binlogs where the PITR stops
+type BinlogStopTarget struct {
+	// gtidSet stops after all the transactions of the set or, when gtidExclusive is set,
+	// before the first transaction of the set (like SQL_AFTER_GTIDS and SQL_BEFORE_GTIDS)
+	gtidSet       *mysql.MysqlGTIDSet
+	gtidExclusive bool
+	// binlogName and position stop before the event starting at the position or after it (like --stop-position)
+	binlogName string
+	position   uint32
+}
+
+// NewBinlogStopTarget parses the --until-gtid and --until-position values, it returns nil when both are empty
+func NewBinlogStopTarget(untilGTID string, gtidExclusive bool, untilPosition string) (*BinlogStopTarget, error) {
+	switch {
+	case untilGTID != "" && untilPosition != "":
+		return nil, fmt.Errorf("GTID and position stop targets can't be used together")
+	case untilGTID != "":
+		gtidSet, err := mysql.ParseMysqlGTIDSet(untilGTID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stop GTID set '%s': %w", untilGTID, err)
+		}
+		return &BinlogStopTarget{gtidSet: gtidSet.(*mysql.MysqlGTIDSet), gtidExclusive: gtidExclusive}, nil
+	case untilPosition != "":
+		separator := strings.LastIndex(untilPosition, ":")
+		if separator <= 0 {
+			return nil, fmt.Errorf("stop position '%s' should look like binlog_name:position", untilPosition)
+		}
+		position, err := strconv.ParseUint(untilPosition[separator+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stop position '%s': %w", untilPosition, err)
+		}
+		return &BinlogStopTarget{binlogName: untilPosition[:separator], position: uint32(position)}, nil
+	}
+	return nil, nil
+}
+
+func (target *BinlogStopTarget) String() string {
+	switch {
+	case target.gtidSet != nil && target.gtidExclusive:
+		return "before GTID set " + target.gtidSet.String()
+	case target.gtidSet != nil:
+		return "after GTID set " + target.gtidSet.String()
+	}
+	return fmt.Sprintf("at %s:%d", target.binlogName, target.position)
+}
+
+// checkArchived makes sure the binlog of the position stop target is in storage before the binlogs are fetched
+func (target *BinlogStopTarget) checkArchived(logFolder storage.Folder) error {
+	if target.gtidSet != nil {
+		return nil
+	}
+	logFiles, _, err := logFolder.ListFolder()
+	if err != nil {
+		return err
+	}
+	for _, logFile := range logFiles {
+		if utility.TrimFileExtension(logFile.GetName()) == target.binlogName {
+			return nil
+		}
+	}
+	return fmt.Errorf("binlog %s of the stop target is not archived", target.binlogName)
+}
+
+// mayStopIn reports whether the stop target can be in the indexed binlog
+func (target *BinlogStopTarget) mayStopIn(entry *BinlogIndexEntry) bool {
+	if target.gtidSet == nil {
+		return entry.BinlogName == target.binlogName
+	}
+	gtids, err := mysql.ParseMysqlGTIDSet(entry.GTIDs)
+	if err != nil {
+		return true
+	}
+	rest := gtids.Clone().(*mysql.MysqlGTIDSet)
+	err = rest.Minus(*target.gtidSet)
+	return err != nil || !rest.Equal(gtids)
+}
+
+// truncateBinlog cuts off the events of the binlog after the stop target and reports whether the target is reached,
+// the binlogs after the reached target should not be applied
+func (target *BinlogStopTarget) truncateBinlog(binlogPath string) (bool, error) {
+	var cutOffset int64
+	var err error
+	if target.gtidSet != nil {
+		cutOffset, err = target.findGTIDCutOffset(binlogPath)
+	} else {
+		cutOffset, err = target.findPositionCutOffset(binlogPath)
+	}
+	switch {
+	case err != nil:
+		return false, err
+	case cutOffset < 0:
+		return false, nil
+	case cutOffset == 0:
+		// the target is reached at the end of the binlog
+		return true, nil
+	}
+	tracelog.InfoLogger.Printf("Stop target %s is reached in %s at position %d\n",
+		target.String(), filepath.Base(binlogPath), cutOffset)
+	return true, os.Truncate(binlogPath, cutOffset)
+}
+
+// findPositionCutOffset returns the offset to truncate the binlog at, zero when the binlog is applied completely
+// and -1 when it is not the target binlog
+func (target *BinlogStopTarget) findPositionCutOffset(binlogPath string) (int64, error) {
+	if filepath.Base(binlogPath) != target.binlogName {
+		return -1, nil
+	}
+	var cutOffset int64
+	err := parseBinlogEventOffsets(binlogPath, func(offset int64, event *replication.BinlogEvent) error {
+		if offset >= int64(target.position) {
+			cutOffset = offset
+			return errBinlogStopTargetReached
+		}
+		return nil
+	})
+	return cutOffset, err
+}
+
+// findGTIDCutOffset returns the offset to truncate the binlog at, zero when the target is reached at the end
+// of the binlog and -1 when it is not reached
+func (target *BinlogStopTarget) findGTIDCutOffset(binlogPath string) (int64, error) {
+	var remaining *mysql.MysqlGTIDSet
+	var cutOffset int64 = -1
+	err := parseBinlogEventOffsets(binlogPath, func(offset int64, event *replication.BinlogEvent) error {
+		switch event.Header.EventType {
+		case replication.PREVIOUS_GTIDS_EVENT:
+			previousGTIDsEvent := &replication.PreviousGTIDsEvent{}
+			err := previousGTIDsEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			previousGTIDs, err := mysql.ParseMysqlGTIDSet(previousGTIDsEvent.GTIDSets)
+			if err != nil {
+				return err
+			}
+			remaining = target.gtidSet.Clone().(*mysql.MysqlGTIDSet)
+			return remaining.Minus(*previousGTIDs.(*mysql.MysqlGTIDSet))
+		case replication.GTID_EVENT:
+			if !target.gtidExclusive && remaining != nil && remaining.String() == "" {
+				// the last transaction of the target set is complete
+				cutOffset = offset
+				return errBinlogStopTargetReached
+			}
+			gtidEvent := &replication.GTIDEvent{}
+			err := gtidEvent.Decode(event.RawData[replication.EventHeaderSize:])
+			if err != nil {
+				return err
+			}
+			sid, err := uuid.FromBytes(gtidEvent.SID)
+			if err != nil {
+				return err
+			}
+			transactionGTID, err := mysql.ParseMysqlGTIDSet(fmt.Sprintf("%s:%d", sid, gtidEvent.GNO))
+			if err != nil {
+				return err
+			}
+			if target.gtidExclusive && target.gtidSet.Contain(transactionGTID) {
+				cutOffset = offset
+				return errBinlogStopTargetReached
+			}
+			if remaining != nil {
+				return remaining.Minus(*transactionGTID.(*mysql.MysqlGTIDSet))
+			}
+		}
+		return nil
+	})
+	if err == nil && cutOffset < 0 && !target.gtidExclusive && remaining != nil && remaining.String() == "" {
+		cutOffset = 0
+	}
+	return cutOffset, err
+}
+
+// parseBinlogEventOffsets calls onEvent with the offset of every event in the file
+// until onEvent returns errBinlogStopTargetReached
+func parseBinlogEventOffsets(binlogPath string, onEvent func(offset int64, event *replication.BinlogEvent) error) error {
+	offset := int64(len(replication.BinLogFileHeader))
+	parser := replication.NewBinlogParser()
+	parser.SetFlavor(mysql.MySQLFlavor)
+	parser.SetVerifyChecksum(false)
+	parser.SetRawMode(true)
+	stopped := false
+	err := parser.ParseFile(binlogPath, 0, func(event *replication.BinlogEvent) error {
+		err := onEvent(offset, event)
+		offset += int64(event.Header.EventSize)
+		// the parser wraps the errors, so the stop is remembered here
+		stopped = errors.Is(err, errBinlogStopTargetReached)
+		return err
+	})
+	if stopped {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse binlog %s: %w", binlogPath, err)
+	}
+	return nil
+}
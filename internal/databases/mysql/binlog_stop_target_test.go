@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// writeTestBinlog writes the binlog with the transactions of testServerUUID after the Previous_gtids 1-5,
+// it returns the offsets of the transactions and the binlog size
+func writeTestBinlog(t *testing.T, path string, gnos ...uint64) ([]int64, int64) {
+	small, err := os.ReadFile(testFilenameSmall)
+	require.NoError(t, err)
+	formatDescriptionSize := binary.LittleEndian.Uint32(small[4+9:])
+	content := append([]byte{}, small[:4+formatDescriptionSize]...)
+
+	addEvent := func(eventType replication.EventType, payload []byte) {
+		header := make([]byte, replication.EventHeaderSize)
+		size := uint32(replication.EventHeaderSize + len(payload) + replication.BinlogChecksumLength)
+		binary.LittleEndian.PutUint32(header, 1566047760)
+		header[4] = byte(eventType)
+		binary.LittleEndian.PutUint32(header[9:], size)
+		binary.LittleEndian.PutUint32(header[13:], uint32(len(content))+size)
+		content = append(append(append(content, header...), payload...), 0, 0, 0, 0)
+	}
+	previousGTIDs := binary.LittleEndian.AppendUint64(nil, 1)
+	previousGTIDs = append(previousGTIDs, testServerUUID[:]...)
+	previousGTIDs = binary.LittleEndian.AppendUint64(previousGTIDs, 1)
+	previousGTIDs = binary.LittleEndian.AppendUint64(previousGTIDs, 1)
+	previousGTIDs = binary.LittleEndian.AppendUint64(previousGTIDs, 6)
+	addEvent(replication.PREVIOUS_GTIDS_EVENT, previousGTIDs)
+
+	var offsets []int64
+	for _, gno := range gnos {
+		offsets = append(offsets, int64(len(content)))
+		gtid := append([]byte{1}, testServerUUID[:]...)
+		addEvent(replication.GTID_EVENT, binary.LittleEndian.AppendUint64(gtid, gno))
+		addEvent(replication.QUERY_EVENT, []byte("BEGIN"))
+		addEvent(replication.XID_EVENT, make([]byte, 8))
+	}
+	require.NoError(t, os.WriteFile(path, content, 0600))
+	return offsets, int64(len(content))
+}
+
+func checkStopTarget(t *testing.T, target *BinlogStopTarget, path string, expectedReached bool, expectedSize int64) {
+	reached, err := target.truncateBinlog(path)
+	require.NoError(t, err)
+	assert.Equal(t, expectedReached, reached)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSize, info.Size())
+}
+
+func TestBinlogStopTarget_GTID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000002")
+	target, err := NewBinlogStopTarget(testServerUUID.String()+":7", false, "")
+	require.NoError(t, err)
+
+	offsets, _ := writeTestBinlog(t, path, 6, 7, 8)
+	checkStopTarget(t, target, path, true, offsets[2])
+
+	offsets, _ = writeTestBinlog(t, path, 6, 7, 8)
+	target.gtidExclusive = true
+	checkStopTarget(t, target, path, true, offsets[1])
+
+	// the target is at the end of the binlog
+	_, size := writeTestBinlog(t, path, 6, 7)
+	target.gtidExclusive = false
+	checkStopTarget(t, target, path, true, size)
+
+	_, size = writeTestBinlog(t, path, 6)
+	checkStopTarget(t, target, path, false, size)
+	target.gtidExclusive = true
+	checkStopTarget(t, target, path, false, size)
+}
+
+func TestBinlogStopTarget_GTIDBeforeBinlog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000002")
+	target, err := NewBinlogStopTarget(testServerUUID.String()+":1-3", false, "")
+	require.NoError(t, err)
+
+	offsets, _ := writeTestBinlog(t, path, 6, 7)
+	checkStopTarget(t, target, path, true, offsets[0])
+}
+
+func TestBinlogStopTarget_Position(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql-bin.000002")
+	offsets, size := writeTestBinlog(t, path, 6, 7, 8)
+
+	target, err := NewBinlogStopTarget("", false, "mysql-bin.000001:100")
+	require.NoError(t, err)
+	checkStopTarget(t, target, path, false, size)
+
+	target, err = NewBinlogStopTarget("", false, fmt.Sprintf("mysql-bin.000002:%d", offsets[1]+1))
+	require.NoError(t, err)
+	checkStopTarget(t, target, path, true, offsets[1]+int64(replication.EventHeaderSize+25+replication.BinlogChecksumLength))
+}
+
+func TestNewBinlogStopTarget(t *testing.T) {
+	target, err := NewBinlogStopTarget("", false, "")
+	assert.NoError(t, err)
+	assert.Nil(t, target)
+
+	_, err = NewBinlogStopTarget(testServerUUID.String()+":7", false, "mysql-bin.000001:4")
+	assert.Error(t, err)
+	_, err = NewBinlogStopTarget("", false, "mysql-bin.000001")
+	assert.Error(t, err)
+
+	target, err = NewBinlogStopTarget("", false, "host:mysql-bin.000001:1234")
+	require.NoError(t, err)
+	assert.Equal(t, "at host:mysql-bin.000001:1234", target.String())
+}
+
+type recordingBinlogHandler struct {
+	binlogs []string
+}
+
+func (handler *recordingBinlogHandler) handleBinlog(binlogPath string) error {
+	handler.binlogs = append(handler.binlogs, filepath.Base(binlogPath))
+	return nil
+}
+
+func putTestBinlogs(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	binlogPath := filepath.Join(t.TempDir(), "mysql-bin.000002")
+	writeTestBinlog(t, binlogPath, 6, 7, 8)
+	content, err := os.ReadFile(binlogPath)
+	require.NoError(t, err)
+	compressed := internal.CompressAndEncrypt(bytes.NewReader(content), compression.Compressors[lz4.AlgorithmName], nil)
+	require.NoError(t, folder.GetSubFolder(BinlogPath).PutObject("mysql-bin.000002.lz4", compressed))
+	return folder
+}
+
+func TestFetchLogs_StopTargetNotArchived(t *testing.T) {
+	folder := putTestBinlogs(t)
+	target, err := NewBinlogStopTarget("", false, "mysql-bin.000003:100")
+	require.NoError(t, err)
+
+	handler := &recordingBinlogHandler{}
+	fetchRange := binlogFetchRange{endTS: utility.MaxTime, endBinlogTS: utility.MaxTime}
+	err = fetchLogs(folder, t.TempDir(), fetchRange, target, handler)
+	assert.ErrorContains(t, err, "mysql-bin.000003")
+	assert.Empty(t, handler.binlogs)
+}
+
+func TestFetchLogs_StopTargetNotReached(t *testing.T) {
+	folder := putTestBinlogs(t)
+	target, err := NewBinlogStopTarget(testServerUUID.String()+":100", false, "")
+	require.NoError(t, err)
+
+	handler := &recordingBinlogHandler{}
+	fetchRange := binlogFetchRange{endTS: utility.MaxTime, endBinlogTS: utility.MaxTime}
+	err = fetchLogs(folder, t.TempDir(), fetchRange, target, handler)
+	assert.ErrorContains(t, err, "is not reached")
+	assert.Equal(t, []string{"mysql-bin.000002"}, handler.binlogs)
+}
@@ -0,0 +1,427 @@
+package mysql
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	binlogStreamHeartbeatPeriod = 30 * time.Second
+	// the server sends a heartbeat when there are no events, so silence for a few periods means a broken connection
+	binlogStreamReadTimeout    = 3 * binlogStreamHeartbeatPeriod
+	binlogStreamReconnectDelay = 5 * time.Second
+
+	// HEARTBEAT_LOG_EVENT_V2 of MySQL 8.0.26+ is unknown to go-mysql
+	heartbeatLogEventV2 replication.EventType = 41
+)
+
+// binlogFileAssembler cuts the replication stream into the binlog files
+// which match the files written by the server
+type binlogFileAssembler struct {
+	directory string
+	// onFileDone is called for every file rotated by the server with the GTID set executed at the end of the file
+	onFileDone func(filePath string, consistent bool, gtidSet *gomysql.MysqlGTIDSet) error
+
+	nextFileName string
+	file         *os.File
+	fileName     string
+	nextPos      uint32
+	// consistent is false when the server has skipped some events of the file
+	consistent   bool
+	transactions int
+	gtidSet      *gomysql.MysqlGTIDSet
+}
+
+func newBinlogFileAssembler(directory string,
+	onFileDone func(string, bool, *gomysql.MysqlGTIDSet) error) *binlogFileAssembler {
+	return &binlogFileAssembler{directory: directory, onFileDone: onFileDone}
+}
+
+func (a *binlogFileAssembler) handleEvent(e *replication.BinlogEvent) error {
+	switch e.Header.EventType {
+	case replication.HEARTBEAT_EVENT, heartbeatLogEventV2:
+		return nil
+	case replication.ROTATE_EVENT:
+		rotateEvent := e.Event.(*replication.RotateEvent)
+		if e.Header.Timestamp == 0 || e.Header.LogPos == 0 {
+			// the fake rotate event is sent at the start of the stream and it is not a part of the binlog file
+			a.discardFile()
+			a.nextFileName = string(rotateEvent.NextLogName)
+			return nil
+		}
+		err := a.writeEvent(e)
+		if err != nil {
+			return err
+		}
+		a.nextFileName = string(rotateEvent.NextLogName)
+		return a.finishFile()
+	case replication.STOP_EVENT:
+		err := a.writeEvent(e)
+		if err != nil {
+			return err
+		}
+		return a.finishFile()
+	case replication.FORMAT_DESCRIPTION_EVENT:
+		err := a.startFile()
+		if err != nil {
+			return err
+		}
+		return a.writeEvent(e)
+	case replication.PREVIOUS_GTIDS_EVENT:
+		previousGTIDs := &replication.PreviousGTIDsEvent{}
+		err := previousGTIDs.Decode(e.RawData[replication.EventHeaderSize:])
+		if err != nil {
+			return err
+		}
+		gtidSet, err := gomysql.ParseMysqlGTIDSet(previousGTIDs.GTIDSets)
+		if err != nil {
+			return err
+		}
+		a.gtidSet = gtidSet.(*gomysql.MysqlGTIDSet)
+	case replication.GTID_EVENT:
+		gtidEvent := &replication.GTIDEvent{}
+		err := gtidEvent.Decode(e.RawData[replication.EventHeaderSize:])
+		if err != nil {
+			return err
+		}
+		sid, err := uuid.FromBytes(gtidEvent.SID)
+		if err != nil {
+			return err
+		}
+		if a.gtidSet != nil {
+			a.gtidSet.AddGTID(sid, gtidEvent.GNO)
+		}
+		a.transactions++
+	}
+	return a.writeEvent(e)
+}
+
+func (a *binlogFileAssembler) startFile() error {
+	a.discardFile()
+	if a.nextFileName == "" {
+		return fmt.Errorf("the stream has no rotate event with the binlog file name")
+	}
+	file, err := os.Create(filepath.Join(a.directory, a.nextFileName))
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(replication.BinLogFileHeader)
+	if err != nil {
+		utility.LoggedClose(file, "failed to close binlog file")
+		return err
+	}
+	a.file = file
+	a.fileName = a.nextFileName
+	a.nextPos = uint32(len(replication.BinLogFileHeader))
+	a.consistent = true
+	a.transactions = 0
+	a.gtidSet = nil
+	return nil
+}
+
+func (a *binlogFileAssembler) writeEvent(e *replication.BinlogEvent) error {
+	if a.file == nil {
+		tracelog.DebugLogger.Printf("Skip %s event outside of a binlog file\n", e.Header.EventType)
+		return nil
+	}
+	if e.Header.LogPos != 0 {
+		if e.Header.LogPos-e.Header.EventSize != a.nextPos {
+			a.consistent = false
+		}
+		a.nextPos = e.Header.LogPos
+	}
+	_, err := a.file.Write(e.RawData)
+	return err
+}
+
+func (a *binlogFileAssembler) finishFile() error {
+	if a.file == nil {
+		return nil
+	}
+	filePath := a.file.Name()
+	err := a.file.Close()
+	a.file = nil
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(filePath)
+	}()
+	if !a.consistent && a.transactions == 0 {
+		tracelog.InfoLogger.Printf("Binlog %s has no transactions which are not archived yet\n", a.fileName)
+		return nil
+	}
+	return a.onFileDone(filePath, a.consistent, a.gtidSet)
+}
+
+// discardFile drops the partially received file, it will be received again after the reconnect
+func (a *binlogFileAssembler) discardFile() {
+	if a.file == nil {
+		return
+	}
+	tracelog.DebugLogger.Printf("Discard partially received binlog %s\n", a.fileName)
+	utility.LoggedClose(a.file, "failed to close binlog file")
+	_ = os.Remove(a.file.Name())
+	a.file = nil
+}
+
+type binlogStreamer struct {
+	uploader     internal.Uploader
+	rootFolder   storage.Folder
+	syncerConfig replication.BinlogSyncerConfig
+	serverUUID   string
+	// gtidArchived is the set of the archived transactions, nil until the first GTID start point is known
+	gtidArchived *gomysql.MysqlGTIDSet
+	startBinlog  string
+}
+
+func HandleBinlogStream(uploader internal.Uploader, startGTID string, startBinlog string) {
+	rootFolder := uploader.Folder()
+	uploader.ChangeDirectory(BinlogPath)
+
+	db, err := getMySQLConnection()
+	tracelog.ErrorLogger.FatalOnError(err)
+	flavor, err := getMySQLFlavor(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if flavor != gomysql.MySQLFlavor {
+		tracelog.ErrorLogger.Fatalf("Unsupported flavor type: %s. binlog-stream requires MySQL GTIDs.", flavor)
+	}
+	serverUUID, err := getServerUUID(db, flavor)
+	utility.LoggedClose(db, "")
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	syncerConfig, err := getBinlogSyncerConfig(flavor)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	streamer := &binlogStreamer{
+		uploader:     uploader,
+		rootFolder:   rootFolder,
+		syncerConfig: syncerConfig,
+		serverUUID:   serverUUID,
+		startBinlog:  startBinlog,
+	}
+	var binlogSentinelDto BinlogSentinelDto
+	err = FetchBinlogSentinel(rootFolder, &binlogSentinelDto)
+	if err != nil {
+		tracelog.InfoLogger.Printf("Failed to fetch binlog sentinel: %v\n", err)
+	}
+	switch {
+	case startGTID != "" || startBinlog != "":
+		if startGTID != "" {
+			streamer.gtidArchived, err = parseMysqlGTIDSet(startGTID)
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+	case binlogSentinelDto.GTIDArchived != "":
+		tracelog.InfoLogger.Printf("fetched binlog archived GTID SET: %s\n", binlogSentinelDto.GTIDArchived)
+		streamer.gtidArchived, err = parseMysqlGTIDSet(binlogSentinelDto.GTIDArchived)
+		tracelog.ErrorLogger.FatalOnError(err)
+	default:
+		tracelog.ErrorLogger.Fatal("There is no archived GTID set in the storage, set the start with --start-gtid or --start-binlog")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	directory, err := os.MkdirTemp("", "walg_binlog_stream")
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer os.RemoveAll(directory)
+
+	assembler := newBinlogFileAssembler(directory, streamer.uploadBinlog)
+	for {
+		err = streamer.stream(ctx, assembler)
+		if ctx.Err() != nil {
+			tracelog.InfoLogger.Println("Binlog streaming is stopped")
+			return
+		}
+		var uploadErr binlogUploadError
+		if errors.As(err, &uploadErr) {
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+		tracelog.ErrorLogger.Printf("Binlog streaming is interrupted: %v, reconnecting in %v\n", err, binlogStreamReconnectDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(binlogStreamReconnectDelay):
+		}
+	}
+}
+
+func (s *binlogStreamer) stream(ctx context.Context, assembler *binlogFileAssembler) error {
+	syncer := replication.NewBinlogSyncer(s.syncerConfig)
+	defer syncer.Close()
+	// the partial file is received again from its beginning
+	defer assembler.discardFile()
+
+	var binlogStreamer *replication.BinlogStreamer
+	var err error
+	if s.gtidArchived != nil {
+		tracelog.InfoLogger.Printf("Start streaming binlogs after GTID set %s\n", s.gtidArchived.String())
+		binlogStreamer, err = syncer.StartSyncGTID(s.gtidArchived.Clone())
+	} else {
+		tracelog.InfoLogger.Printf("Start streaming binlogs from %s\n", s.startBinlog)
+		binlogStreamer, err = syncer.StartSync(gomysql.Position{Name: s.startBinlog, Pos: uint32(len(replication.BinLogFileHeader))})
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := binlogStreamer.GetEvent(ctx)
+		if err != nil {
+			return err
+		}
+		err = assembler.handleEvent(event)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type binlogUploadError struct {
+	error
+}
+
+func (err binlogUploadError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+func (s *binlogStreamer) uploadBinlog(filePath string, consistent bool, gtidSet *gomysql.MysqlGTIDSet) error {
+	binlogName := filepath.Base(filePath)
+	if !consistent {
+		uploaded, err := isBinlogUploaded(s.uploader.Folder(), binlogName)
+		if err != nil {
+			return binlogUploadError{err}
+		}
+		if uploaded {
+			tracelog.WarningLogger.Printf("Binlog %s misses the events skipped by the server, "+
+				"keep the archived copy\n", binlogName)
+			return nil
+		}
+		tracelog.WarningLogger.Printf("Binlog %s misses the events skipped by the server\n", binlogName)
+	}
+
+	err := archiveBinLog(s.uploader, filepath.Dir(filePath), binlogName)
+	if err != nil {
+		return binlogUploadError{err}
+	}
+	indexArchivedBinlog(s.rootFolder, filePath, s.syncerConfig.Flavor, s.serverUUID)
+	// a stream started by position learns the GTIDs of the binlogs only from the received files
+	s.startBinlog = nextBinlogName(binlogName)
+	if gtidSet == nil {
+		return nil
+	}
+	s.gtidArchived = gtidSet.Clone().(*gomysql.MysqlGTIDSet)
+	binlogSentinelDto := BinlogSentinelDto{GTIDArchived: s.gtidArchived.String()}
+	tracelog.InfoLogger.Printf("Uploading binlog sentinel: %s", binlogSentinelDto)
+	err = UploadBinlogSentinel(s.rootFolder, &binlogSentinelDto)
+	if err != nil {
+		return binlogUploadError{err}
+	}
+	return nil
+}
+
+func isBinlogUploaded(binlogsFolder storage.Folder, binlogName string) (bool, error) {
+	objects, _, err := binlogsFolder.ListFolder()
+	if err != nil {
+		return false, err
+	}
+	for _, object := range objects {
+		if utility.TrimFileExtension(object.GetName()) == binlogName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// nextBinlogName returns the name of the binlog the server writes after the given one, e.g. mysql-bin.000010
+// for mysql-bin.000009
+func nextBinlogName(binlogName string) string {
+	num := BinlogNum(binlogName)
+	numLength := len(binlogName) - len(BinlogPrefix(binlogName)) - 1
+	return fmt.Sprintf("%s.%0*d", BinlogPrefix(binlogName), numLength, num+1)
+}
+
+func parseMysqlGTIDSet(gtidSet string) (*gomysql.MysqlGTIDSet, error) {
+	parsed, err := gomysql.ParseMysqlGTIDSet(gtidSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GTID set '%s': %w", gtidSet, err)
+	}
+	return parsed.(*gomysql.MysqlGTIDSet), nil
+}
+
+// getBinlogSyncerConfig builds the replica connection config from WALG_MYSQL_DATASOURCE_NAME
+func getBinlogSyncerConfig(flavor string) (replication.BinlogSyncerConfig, error) {
+	datasourceName, err := internal.GetRequiredSetting(internal.MysqlDatasourceNameSetting)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	serverIDSetting, err := internal.GetRequiredSetting(internal.MysqlBinlogStreamServerID)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	serverID, err := strconv.ParseUint(serverIDSetting, 10, 32)
+	if err != nil || serverID == 0 {
+		return replication.BinlogSyncerConfig{},
+			fmt.Errorf("%s should be a positive 32-bit number: '%s'", internal.MysqlBinlogStreamServerID, serverIDSetting)
+	}
+
+	dsn, err := mysql.ParseDSN(datasourceName)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	if dsn.Net != "tcp" {
+		return replication.BinlogSyncerConfig{}, fmt.Errorf("binlog streaming requires a TCP address in %s",
+			internal.MysqlDatasourceNameSetting)
+	}
+	host, port, err := net.SplitHostPort(dsn.Addr)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+
+	config := replication.BinlogSyncerConfig{
+		ServerID:         uint32(serverID),
+		Flavor:           flavor,
+		Host:             host,
+		Port:             uint16(portNum),
+		User:             dsn.User,
+		Password:         dsn.Passwd,
+		RawModeEnabled:   true,
+		VerifyChecksum:   true,
+		HeartbeatPeriod:  binlogStreamHeartbeatPeriod,
+		ReadTimeout:      binlogStreamReadTimeout,
+		DisableRetrySync: true,
+	}
+	if caFile, ok := internal.GetSetting(internal.MysqlSslCaSetting); ok {
+		rootCertPool := x509.NewCertPool()
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return replication.BinlogSyncerConfig{}, err
+		}
+		if ok := rootCertPool.AppendCertsFromPEM(pem); !ok {
+			return replication.BinlogSyncerConfig{}, fmt.Errorf("failed to load certificate from %s", caFile)
+		}
+		config.TLSConfig = &tls.Config{RootCAs: rootCertPool, ServerName: host}
+	}
+	return config, nil
+}
@@ -0,0 +1,176 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testServerUUID = uuid.MustParse("b9a7e1d4-3f5c-11ee-8c0a-0242ac120002")
+
+type testBinlogEventWriter struct {
+	pos    uint32
+	events []*replication.BinlogEvent
+}
+
+func (w *testBinlogEventWriter) add(eventType replication.EventType, payload []byte) *replication.BinlogEvent {
+	size := uint32(replication.EventHeaderSize + len(payload))
+	w.pos += size
+	event := &replication.BinlogEvent{
+		Header:  &replication.EventHeader{Timestamp: 1, EventType: eventType, EventSize: size, LogPos: w.pos},
+		RawData: append(make([]byte, replication.EventHeaderSize), payload...),
+	}
+	binary.LittleEndian.PutUint32(event.RawData[9:], w.pos)
+	w.events = append(w.events, event)
+	return event
+}
+
+func (w *testBinlogEventWriter) startFile() {
+	w.pos = uint32(len(replication.BinLogFileHeader))
+	w.add(replication.FORMAT_DESCRIPTION_EVENT, []byte("format"))
+}
+
+func (w *testBinlogEventWriter) addPreviousGTIDs(start, stop uint64) {
+	payload := binary.LittleEndian.AppendUint64(nil, 1)
+	payload = append(payload, testServerUUID[:]...)
+	payload = binary.LittleEndian.AppendUint64(payload, 1)
+	payload = binary.LittleEndian.AppendUint64(payload, start)
+	payload = binary.LittleEndian.AppendUint64(payload, stop+1)
+	w.add(replication.PREVIOUS_GTIDS_EVENT, payload)
+}
+
+func (w *testBinlogEventWriter) addTransaction(gno uint64) {
+	payload := append([]byte{1}, testServerUUID[:]...)
+	w.add(replication.GTID_EVENT, binary.LittleEndian.AppendUint64(payload, gno))
+	w.add(replication.QUERY_EVENT, []byte("BEGIN"))
+	w.add(replication.XID_EVENT, []byte("commit"))
+}
+
+func (w *testBinlogEventWriter) addRotate(nextLogName string) {
+	event := w.add(replication.ROTATE_EVENT, []byte(nextLogName))
+	event.Event = &replication.RotateEvent{Position: 4, NextLogName: []byte(nextLogName)}
+}
+
+func fakeRotateEvent(logName string) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.ROTATE_EVENT},
+		Event:  &replication.RotateEvent{Position: 4, NextLogName: []byte(logName)},
+	}
+}
+
+type testBinlogFile struct {
+	name       string
+	content    []byte
+	consistent bool
+	gtidSet    string
+}
+
+func runBinlogFileAssembler(t *testing.T, events []*replication.BinlogEvent) []testBinlogFile {
+	var files []testBinlogFile
+	directory := t.TempDir()
+	assembler := newBinlogFileAssembler(directory,
+		func(filePath string, consistent bool, gtidSet *gomysql.MysqlGTIDSet) error {
+			content, err := os.ReadFile(filePath)
+			require.NoError(t, err)
+			files = append(files, testBinlogFile{filePath[len(directory)+1:], content, consistent, gtidSet.String()})
+			return nil
+		})
+	for _, event := range events {
+		require.NoError(t, assembler.handleEvent(event))
+	}
+	return files
+}
+
+func TestBinlogFileAssembler(t *testing.T) {
+	writer := &testBinlogEventWriter{}
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 5)
+	writer.addTransaction(6)
+	writer.addTransaction(7)
+	// the heartbeat is not a part of the binlog, it holds the current position
+	writer.events = append(writer.events, &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.HEARTBEAT_EVENT, EventSize: 30, LogPos: writer.pos},
+	})
+	writer.addRotate("mysql-bin.000002")
+	firstFileEvents := len(writer.events)
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 7)
+	writer.addTransaction(8)
+
+	events := append([]*replication.BinlogEvent{fakeRotateEvent("mysql-bin.000001")}, writer.events...)
+	files := runBinlogFileAssembler(t, events)
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "mysql-bin.000001", files[0].name)
+	assert.True(t, files[0].consistent)
+	assert.Equal(t, testServerUUID.String()+":1-7", files[0].gtidSet)
+	expected := bytes.NewBuffer(append([]byte{}, replication.BinLogFileHeader...))
+	for _, event := range writer.events[:firstFileEvents] {
+		if event.Header.EventType != replication.HEARTBEAT_EVENT {
+			expected.Write(event.RawData)
+		}
+	}
+	assert.Equal(t, expected.Bytes(), files[0].content)
+}
+
+func TestBinlogFileAssembler_SkippedTransactions(t *testing.T) {
+	writer := &testBinlogEventWriter{}
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 5)
+	writer.addTransaction(6)
+	writer.addTransaction(7)
+	writer.addRotate("mysql-bin.000002")
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 7)
+	writer.addTransaction(8)
+	writer.addTransaction(9)
+	writer.addRotate("mysql-bin.000003")
+
+	// the server skips the archived transactions 1-8
+	var events []*replication.BinlogEvent
+	for i, event := range writer.events {
+		if i < 2 || i >= 8 && i < 11 || i >= 14 {
+			events = append(events, event)
+		}
+	}
+	files := runBinlogFileAssembler(t, append([]*replication.BinlogEvent{fakeRotateEvent("mysql-bin.000001")}, events...))
+
+	require.Len(t, files, 1)
+	assert.Equal(t, "mysql-bin.000002", files[0].name)
+	assert.False(t, files[0].consistent)
+	assert.Equal(t, testServerUUID.String()+":1-7:9", files[0].gtidSet)
+}
+
+func TestBinlogFileAssembler_Reconnect(t *testing.T) {
+	writer := &testBinlogEventWriter{}
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 5)
+	writer.addTransaction(6)
+	partialFile := writer.events
+	writer.events = nil
+	writer.startFile()
+	writer.addPreviousGTIDs(1, 5)
+	writer.addTransaction(6)
+	writer.addTransaction(7)
+	writer.add(replication.STOP_EVENT, nil)
+
+	events := append([]*replication.BinlogEvent{fakeRotateEvent("mysql-bin.000001")}, partialFile...)
+	events = append(events, fakeRotateEvent("mysql-bin.000001"))
+	files := runBinlogFileAssembler(t, append(events, writer.events...))
+
+	require.Len(t, files, 1)
+	assert.True(t, files[0].consistent)
+	assert.Equal(t, testServerUUID.String()+":1-7", files[0].gtidSet)
+}
+
+func TestNextBinlogName(t *testing.T) {
+	assert.Equal(t, "mysql-bin.000010", nextBinlogName("mysql-bin.000009"))
+	assert.Equal(t, "host.log.1000000", nextBinlogName("host.log.999999"))
+}
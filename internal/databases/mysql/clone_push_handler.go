@@ -0,0 +1,328 @@
+package mysql
+
+import (
+	"archive/tar"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/wal-g/tracelog"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const cloneMinMySQLVersion = "v8.0.17"
+
+// cloneStatus is the row of performance_schema.clone_status of the last clone operation
+type cloneStatus struct {
+	state          string
+	errorNumber    int
+	errorMessage   string
+	binlogFile     string
+	binlogPosition uint64
+	gtidExecuted   string
+}
+
+func getCloneStatus(db *sql.DB) (cloneStatus, error) {
+	var status cloneStatus
+	var binlogFile, gtidExecuted sql.NullString
+	var binlogPosition sql.NullInt64
+	row := db.QueryRow("SELECT STATE, ERROR_NO, ERROR_MESSAGE, BINLOG_FILE, BINLOG_POSITION, GTID_EXECUTED " +
+		"FROM performance_schema.clone_status")
+	err := row.Scan(&status.state, &status.errorNumber, &status.errorMessage, &binlogFile, &binlogPosition, &gtidExecuted)
+	if err != nil {
+		return cloneStatus{}, fmt.Errorf("failed to query clone status: %w", err)
+	}
+	status.binlogFile = binlogFile.String
+	status.binlogPosition = uint64(binlogPosition.Int64)
+	status.gtidExecuted = normalizeGTIDSet(gtidExecuted.String)
+	return status, nil
+}
+
+// normalizeGTIDSet removes the line breaks MySQL puts after the commas of the long GTID sets
+func normalizeGTIDSet(gtidSet string) string {
+	return strings.Join(strings.Fields(gtidSet), "")
+}
+
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// cloneStatement clones the server locally or, if the donor is set, clones the donor to the server
+func cloneStatement(dataDir string, donor *mysql.Config) (string, error) {
+	if donor == nil {
+		return "CLONE LOCAL DATA DIRECTORY = " + quoteString(dataDir), nil
+	}
+	host, port, err := splitDonorAddress(donor)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CLONE INSTANCE FROM %s@%s:%s IDENTIFIED BY %s DATA DIRECTORY = %s",
+		quoteString(donor.User), quoteString(host), port, quoteString(donor.Passwd), quoteString(dataDir)), nil
+}
+
+func splitDonorAddress(donor *mysql.Config) (host, port string, err error) {
+	if donor.Net != "tcp" {
+		return "", "", fmt.Errorf("clone donor should be connected by tcp, got %s", donor.Net)
+	}
+	host, port, found := strings.Cut(donor.Addr, ":")
+	if !found {
+		port = "3306"
+	}
+	return host, port, nil
+}
+
+// parseCloneDonor parses the donor datasource name, the donor is not connected to by wal-g itself
+func parseCloneDonor(donorDatasourceName string) (*mysql.Config, error) {
+	if donorDatasourceName == "" {
+		return nil, nil
+	}
+	donor, err := mysql.ParseDSN(donorDatasourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", internal.MysqlCloneDonorDatasourceName, err)
+	}
+	if _, _, err = splitDonorAddress(donor); err != nil {
+		return nil, err
+	}
+	return donor, nil
+}
+
+// cloneValidDonorListStatement sets clone_valid_donor_list to the value, NULL is the default
+func cloneValidDonorListStatement(donorList sql.NullString) string {
+	if !donorList.Valid {
+		return "SET GLOBAL clone_valid_donor_list = DEFAULT"
+	}
+	return "SET GLOBAL clone_valid_donor_list = " + quoteString(donorList.String)
+}
+
+// setCloneValidDonorList lets the receiver clone from the donor, the returned function restores the previous donor list
+func setCloneValidDonorList(db *sql.DB, donor *mysql.Config) (func(), error) {
+	var previousDonorList sql.NullString
+	err := db.QueryRow("SELECT @@GLOBAL.clone_valid_donor_list").Scan(&previousDonorList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clone_valid_donor_list: %w", err)
+	}
+	host, port, _ := splitDonorAddress(donor)
+	// the receiver clones only from the listed donors
+	_, err = db.Exec(cloneValidDonorListStatement(sql.NullString{String: host + ":" + port, Valid: true}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set clone_valid_donor_list: %w", err)
+	}
+	return func() {
+		_, err := db.Exec(cloneValidDonorListStatement(previousDonorList))
+		if err != nil {
+			tracelog.ErrorLogger.Printf("Failed to restore clone_valid_donor_list '%s': %v", previousDonorList.String, err)
+		}
+	}, nil
+}
+
+func runClone(db *sql.DB, dataDir string, donor *mysql.Config) (cloneStatus, error) {
+	if donor != nil {
+		restoreDonorList, err := setCloneValidDonorList(db, donor)
+		if err != nil {
+			return cloneStatus{}, err
+		}
+		defer restoreDonorList()
+	}
+	statement, err := cloneStatement(dataDir, donor)
+	if err != nil {
+		return cloneStatus{}, err
+	}
+	tracelog.InfoLogger.Printf("Cloning to %s", dataDir)
+	_, cloneErr := db.Exec(statement)
+	status, err := getCloneStatus(db)
+	if cloneErr != nil {
+		if err == nil && status.errorMessage != "" {
+			return status, fmt.Errorf("clone failed: %w (%d: %s)", cloneErr, status.errorNumber, status.errorMessage)
+		}
+		return status, fmt.Errorf("clone failed: %w", cloneErr)
+	}
+	if err != nil {
+		return status, err
+	}
+	if status.state != "Completed" {
+		return status, fmt.Errorf("clone is not completed, state '%s': %d: %s", status.state, status.errorNumber, status.errorMessage)
+	}
+	return status, nil
+}
+
+// writeDirectoryTar writes the directory tree to the tar stream, the paths are relative to the directory
+func writeDirectoryTar(dir string, writer io.Writer) error {
+	tarWriter := tar.NewWriter(writer)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			tracelog.WarningLogger.Printf("Skipping %s: not a regular file or directory", path)
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err = tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer utility.LoggedClose(file, "")
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
+
+func pushDirectory(uploader internal.Uploader, dir string) (string, error) {
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		_ = pipeWriter.CloseWithError(writeDirectoryTar(dir, pipeWriter))
+	}()
+	backupName, err := uploader.PushStream(context.Background(), limiters.NewDiskLimitReader(pipeReader))
+	// the tar writer stops if the upload fails
+	_ = pipeReader.CloseWithError(err)
+	return backupName, err
+}
+
+func removeCloneDir(cloneDir string) {
+	if err := os.RemoveAll(cloneDir); err != nil {
+		tracelog.ErrorLogger.Printf("Failed to remove %s: %v", cloneDir, err)
+	}
+}
+
+// HandleCloneBackupPush makes the backup by the CLONE plugin: the server clones itself (or the donor)
+// to cloneDir, the cloned data directory is uploaded as a tar stream and removed
+func HandleCloneBackupPush(folder storage.Folder, uploader internal.Uploader, isPermanent bool, userDataRaw string,
+	cloneDir string, donorDatasourceName string) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+		tracelog.WarningLogger.Printf("Failed to obtain the OS hostname")
+	}
+	donor, err := parseCloneDonor(donorDatasourceName)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if !filepath.IsAbs(cloneDir) {
+		tracelog.ErrorLogger.Fatalf("%s should be an absolute path, got '%s'", internal.MysqlCloneDir, cloneDir)
+	}
+	// the clone creates the directory, it is removed after the upload
+	if _, err = os.Stat(cloneDir); !os.IsNotExist(err) {
+		tracelog.ErrorLogger.Fatalf("%s '%s' should not exist", internal.MysqlCloneDir, cloneDir)
+	}
+
+	db, err := getMySQLConnection()
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(db, "")
+
+	version, err := getMySQLVersion(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	flavor, err := getMySQLFlavor(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if flavor != gomysql.MySQLFlavor || !isMySQLVersionAtLeast(version, cloneMinMySQLVersion) {
+		tracelog.ErrorLogger.Fatalf("The CLONE plugin requires MySQL %s or later, got %s",
+			strings.TrimPrefix(cloneMinMySQLVersion, "v"), version)
+	}
+	// the receiver's server_uuid is not the one of the cloned donor
+	serverUUID := ""
+	if donor == nil {
+		serverUUID, err = getServerUUID(db, flavor)
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
+	timeStart := utility.TimeNowCrossPlatformLocal()
+
+	status, err := runClone(db, cloneDir, donor)
+	if err != nil {
+		removeCloneDir(cloneDir)
+		tracelog.ErrorLogger.Fatal(err)
+	}
+	tracelog.InfoLogger.Printf("Cloned at %s:%d, GTID set '%s'", status.binlogFile, status.binlogPosition, status.gtidExecuted)
+
+	binlogStart := ""
+	if status.gtidExecuted != "" {
+		gtidExecuted, err := gomysql.ParseGTIDSet(flavor, status.gtidExecuted)
+		if err != nil {
+			removeCloneDir(cloneDir)
+			tracelog.ErrorLogger.Fatal(err)
+		}
+		binlogStart, err = getLastUploadedBinlogBeforeGTID(folder, gtidExecuted, flavor)
+		if err != nil {
+			removeCloneDir(cloneDir)
+			tracelog.ErrorLogger.Fatalf("failed to get last uploaded binlog: %v", err)
+		}
+	}
+
+	backupName, err := pushDirectory(uploader, cloneDir)
+	removeCloneDir(cloneDir)
+	tracelog.ErrorLogger.FatalfOnError("failed to push backup: %v", err)
+
+	binlogEnd, err := getLastUploadedBinlog(folder)
+	tracelog.ErrorLogger.FatalfOnError("failed to get last uploaded binlog (after): %v", err)
+	timeStop := utility.TimeNowCrossPlatformLocal()
+
+	uploadedSize, err := uploader.UploadedDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc uploaded data size: %v", err)
+	}
+	rawSize, err := uploader.RawDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc raw data size: %v", err)
+	}
+	userData, err := internal.UnmarshalSentinelUserData(userDataRaw)
+	tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+
+	incrementCount := 0
+	sentinel := StreamSentinelDto{
+		Tool:             WalgCloneTool,
+		BinLogStart:      binlogStart,
+		BinLogEnd:        binlogEnd,
+		BinLogFile:       status.binlogFile,
+		BinLogPosition:   status.binlogPosition,
+		GTIDExecuted:     status.gtidExecuted,
+		StartLocalTime:   timeStart,
+		StopLocalTime:    timeStop,
+		CompressedSize:   uploadedSize,
+		UncompressedSize: rawSize,
+		Hostname:         hostname,
+		ServerUUID:       serverUUID,
+		ServerVersion:    version,
+		IsPermanent:      isPermanent,
+		UserData:         userData,
+		IncrementCount:   &incrementCount,
+	}
+	tracelog.InfoLogger.Printf("Backup sentinel: %s", sentinel.String())
+
+	err = internal.UploadSentinel(uploader, &sentinel, backupName)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
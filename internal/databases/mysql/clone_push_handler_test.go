@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"archive/tar"
+	"bytes"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneStatement(t *testing.T) {
+	statement, err := cloneStatement("/var/lib/walg-clone", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "CLONE LOCAL DATA DIRECTORY = '/var/lib/walg-clone'", statement)
+
+	donor, err := parseCloneDonor("clone_user:pa'ss@tcp(db1.example.net:3307)/")
+	require.NoError(t, err)
+	statement, err = cloneStatement("/var/lib/walg-clone", donor)
+	require.NoError(t, err)
+	assert.Equal(t, "CLONE INSTANCE FROM 'clone_user'@'db1.example.net':3307 IDENTIFIED BY 'pa\\'ss' "+
+		"DATA DIRECTORY = '/var/lib/walg-clone'", statement)
+
+	donor, err = parseCloneDonor("")
+	require.NoError(t, err)
+	assert.Nil(t, donor)
+	_, err = parseCloneDonor("clone_user@unix(/var/run/mysqld/mysqld.sock)/")
+	assert.Error(t, err)
+}
+
+func TestCloneValidDonorListStatement(t *testing.T) {
+	assert.Equal(t, "SET GLOBAL clone_valid_donor_list = 'db1.example.net:3307'",
+		cloneValidDonorListStatement(sql.NullString{String: "db1.example.net:3307", Valid: true}))
+	assert.Equal(t, "SET GLOBAL clone_valid_donor_list = ''", cloneValidDonorListStatement(sql.NullString{Valid: true}))
+	// the donor list was not set before the clone
+	assert.Equal(t, "SET GLOBAL clone_valid_donor_list = DEFAULT", cloneValidDonorListStatement(sql.NullString{}))
+}
+
+func TestNormalizeGTIDSet(t *testing.T) {
+	assert.Equal(t, "8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7",
+		normalizeGTIDSet("8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,\n9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7\n"))
+	assert.True(t, isMySQLVersionAtLeast("8.0.35-27", cloneMinMySQLVersion))
+	assert.False(t, isMySQLVersionAtLeast("8.0.16", cloneMinMySQLVersion))
+	assert.False(t, isMySQLVersionAtLeast("5.7.40-log", cloneMinMySQLVersion))
+}
+
+func TestWriteDirectoryTar(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "#innodb_temp"), 0750))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "shop"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ibdata1"), []byte("system tablespace"), 0640))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shop", "orders.ibd"), []byte("orders"), 0640))
+
+	var buffer bytes.Buffer
+	require.NoError(t, writeDirectoryTar(dir, &buffer))
+
+	files := make(map[string]string)
+	tarReader := tar.NewReader(&buffer)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+		if header.Name == "ibdata1" {
+			assert.Equal(t, int64(0640), header.Mode)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"#innodb_temp/":   "",
+		"ibdata1":         "system tablespace",
+		"shop/":           "",
+		"shop/orders.ibd": "orders",
+	}, files)
+}
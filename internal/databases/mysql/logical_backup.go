@@ -0,0 +1,212 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const (
+	LogicalBackupMetadataName = "logical_metadata.json"
+	LogicalBackupTableKind    = "table"
+	LogicalBackupViewKind     = "view"
+
+	logicalBackupDatabasesPath = "databases/"
+	// logicalBackupStatementSize is the size the INSERT statements are batched up to
+	logicalBackupStatementSize = 1 << 20
+	// logicalBackupSessionSettings are set by the dump and the restore sessions
+	logicalBackupSessionSettings = "SET SESSION time_zone = '+00:00', NAMES utf8mb4, sql_mode = 'NO_AUTO_VALUE_ON_ZERO'"
+)
+
+var logicalBackupSystemDatabases = []string{"mysql", "sys", "information_schema", "performance_schema"}
+
+// LogicalBackupMetadata is the manifest of the logical backup, the object paths are relative to the backup
+type LogicalBackupMetadata struct {
+	Databases []*LogicalBackupDatabase `json:"databases"`
+}
+
+type LogicalBackupDatabase struct {
+	Name   string                `json:"name"`
+	Schema string                `json:"schema"`
+	Tables []*LogicalBackupTable `json:"tables"`
+}
+
+type LogicalBackupTable struct {
+	Name string `json:"name"`
+	// Kind is either table or view
+	Kind   string `json:"kind"`
+	Schema string `json:"schema"`
+	// Chunks are the objects with the INSERT statements, one statement per line
+	Chunks []*LogicalBackupChunk `json:"chunks,omitempty"`
+}
+
+type LogicalBackupChunk struct {
+	Path string `json:"path"`
+	Rows int64  `json:"rows"`
+}
+
+func getLogicalBackupMetadataPath(backupName string) string {
+	return backupName + "/" + LogicalBackupMetadataName
+}
+
+// getLogicalBackupObjectPath builds the path of the object of the database or of its table,
+// the names are escaped as they may contain any characters
+func getLogicalBackupObjectPath(database, table, name, extension string) string {
+	path := logicalBackupDatabasesPath + url.PathEscape(database) + "/"
+	if table != "" {
+		path += url.PathEscape(table) + "/"
+	}
+	return path + name + "." + extension
+}
+
+// newLogicalBackupFilter keeps the listed databases and tables, everything is kept if nothing is listed
+func newLogicalBackupFilter(databases []string, tables []TableName) func(database, table string) bool {
+	return func(database, table string) bool {
+		if len(databases) == 0 && len(tables) == 0 {
+			return true
+		}
+		for _, name := range databases {
+			if name == database {
+				return true
+			}
+		}
+		for _, name := range tables {
+			if name.Database == database && (table == "" || name.Table == table) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// isGeneratedColumn checks the EXTRA of information_schema.COLUMNS, the values of the generated columns
+// can't be inserted, while the DEFAULT_GENERATED columns only have the expression defaults
+func isGeneratedColumn(extra string) bool {
+	return strings.Contains(extra, "VIRTUAL GENERATED") || strings.Contains(extra, "STORED GENERATED")
+}
+
+// quoteColumns joins the quoted column names by comma
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// getLogicalBackupSelectQuery selects the columns of the chunk by name, so the invisible columns are dumped too
+func getLogicalBackupSelectQuery(database, table string, columns []string, where string) string {
+	query := "SELECT " + quoteColumns(columns) + " FROM " + quoteIdentifier(database) + "." + quoteIdentifier(table)
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return query
+}
+
+// getLogicalBackupInsertPrefix names the dumped columns, the generated ones are computed by the restore
+func getLogicalBackupInsertPrefix(table string, columns []string) string {
+	return "INSERT INTO " + quoteIdentifier(table) + " (" + quoteColumns(columns) + ") VALUES "
+}
+
+func isBinaryColumn(columnType *sql.ColumnType) bool {
+	switch columnType.DatabaseTypeName() {
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return true
+	}
+	return false
+}
+
+// appendSQLValue appends the value of the text protocol as the SQL literal: the binary values are hex literals,
+// the others are quoted, MySQL converts them to the column types on insert
+func appendSQLValue(buffer *bytes.Buffer, value sql.RawBytes, binary bool) {
+	switch {
+	case value == nil:
+		buffer.WriteString("NULL")
+	case binary && len(value) == 0:
+		buffer.WriteString("''")
+	case binary:
+		buffer.WriteString("0x")
+		buffer.WriteString(hex.EncodeToString(value))
+	default:
+		buffer.WriteByte('\'')
+		for _, char := range value {
+			switch char {
+			case 0:
+				buffer.WriteString(`\0`)
+			case '\n':
+				buffer.WriteString(`\n`)
+			case '\r':
+				buffer.WriteString(`\r`)
+			case '\x1a':
+				buffer.WriteString(`\Z`)
+			case '\'', '\\':
+				buffer.WriteByte('\\')
+				buffer.WriteByte(char)
+			default:
+				buffer.WriteByte(char)
+			}
+		}
+		buffer.WriteByte('\'')
+	}
+}
+
+// writeInsertStatements writes the rows as the INSERT statements batched up to statementSize, one statement per line
+func writeInsertStatements(rows *sql.Rows, table string, columns []string, writer io.Writer, statementSize int) (int64, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	binary := make([]bool, len(columnTypes))
+	for i, columnType := range columnTypes {
+		binary[i] = isBinaryColumn(columnType)
+	}
+	values := make([]sql.RawBytes, len(columnTypes))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	prefix := getLogicalBackupInsertPrefix(table, columns)
+	statement := &bytes.Buffer{}
+	var count int64
+	flush := func() error {
+		if statement.Len() == 0 {
+			return nil
+		}
+		statement.WriteString(";\n")
+		_, err := writer.Write(statement.Bytes())
+		statement.Reset()
+		return err
+	}
+	for rows.Next() {
+		if err = rows.Scan(scanArgs...); err != nil {
+			return count, err
+		}
+		if statement.Len() == 0 {
+			statement.WriteString(prefix)
+		} else {
+			statement.WriteByte(',')
+		}
+		statement.WriteByte('(')
+		for i, value := range values {
+			if i > 0 {
+				statement.WriteByte(',')
+			}
+			appendSQLValue(statement, value, binary[i])
+		}
+		statement.WriteByte(')')
+		count++
+		if statement.Len() >= statementSize {
+			if err = flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
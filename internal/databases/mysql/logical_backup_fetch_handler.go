@@ -0,0 +1,266 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type logicalBackupRestorer struct {
+	ctx    context.Context
+	backup internal.Backup
+}
+
+func (restorer *logicalBackupRestorer) readObject(path string) (string, error) {
+	reader, err := openBackupObject(restorer.backup.Folder, restorer.backup.Name+"/"+path)
+	if err != nil {
+		return "", err
+	}
+	defer utility.LoggedClose(reader, "")
+	content, err := io.ReadAll(reader)
+	return string(content), err
+}
+
+func (restorer *logicalBackupRestorer) execSchema(conn *sql.Conn, database, path string) error {
+	schema, err := restorer.readObject(path)
+	if err != nil {
+		return err
+	}
+	if database != "" {
+		if _, err = conn.ExecContext(restorer.ctx, "USE "+quoteIdentifier(database)); err != nil {
+			return err
+		}
+	}
+	_, err = conn.ExecContext(restorer.ctx, schema)
+	return err
+}
+
+// restoreTables creates the databases and recreates their tables accepted by keep
+func (restorer *logicalBackupRestorer) restoreTables(conn *sql.Conn, metadata *LogicalBackupMetadata,
+	keep func(database, table string) bool) error {
+	for _, database := range metadata.Databases {
+		if !keep(database.Name, "") {
+			continue
+		}
+		if err := restorer.execSchema(conn, "", database.Schema); err != nil {
+			return fmt.Errorf("failed to create database %s: %w", database.Name, err)
+		}
+		for _, table := range database.Tables {
+			if table.Kind != LogicalBackupTableKind || !keep(database.Name, table.Name) {
+				continue
+			}
+			tableName := quoteIdentifier(database.Name) + "." + quoteIdentifier(table.Name)
+			if _, err := conn.ExecContext(restorer.ctx, "DROP TABLE IF EXISTS "+tableName); err != nil {
+				return fmt.Errorf("failed to drop table %s.%s: %w", database.Name, table.Name, err)
+			}
+			if err := restorer.execSchema(conn, database.Name, table.Schema); err != nil {
+				return fmt.Errorf("failed to create table %s.%s: %w", database.Name, table.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreViews recreates the views accepted by keep, the views are created in several passes
+// as they may depend on each other
+func (restorer *logicalBackupRestorer) restoreViews(conn *sql.Conn, metadata *LogicalBackupMetadata,
+	keep func(database, table string) bool) error {
+	type view struct {
+		database string
+		table    *LogicalBackupTable
+	}
+	var pending []view
+	for _, database := range metadata.Databases {
+		for _, table := range database.Tables {
+			if table.Kind == LogicalBackupViewKind && keep(database.Name, table.Name) {
+				pending = append(pending, view{database: database.Name, table: table})
+			}
+		}
+	}
+	for len(pending) > 0 {
+		var failed []view
+		var lastErr error
+		for _, view := range pending {
+			viewName := quoteIdentifier(view.database) + "." + quoteIdentifier(view.table.Name)
+			if _, err := conn.ExecContext(restorer.ctx, "DROP VIEW IF EXISTS "+viewName); err != nil {
+				return fmt.Errorf("failed to drop view %s.%s: %w", view.database, view.table.Name, err)
+			}
+			if err := restorer.execSchema(conn, view.database, view.table.Schema); err != nil {
+				failed = append(failed, view)
+				lastErr = fmt.Errorf("failed to create view %s.%s: %w", view.database, view.table.Name, err)
+			}
+		}
+		if len(failed) == len(pending) {
+			return lastErr
+		}
+		pending = failed
+	}
+	return nil
+}
+
+// restoreChunk executes the INSERT statements of the chunk, one statement per line
+func (restorer *logicalBackupRestorer) restoreChunk(conn *sql.Conn, database string, chunk *LogicalBackupChunk) error {
+	reader, err := openBackupObject(restorer.backup.Folder, restorer.backup.Name+"/"+chunk.Path)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+	if _, err = conn.ExecContext(restorer.ctx, "USE "+quoteIdentifier(database)); err != nil {
+		return err
+	}
+	bufReader := bufio.NewReader(reader)
+	for {
+		statement, err := bufReader.ReadBytes('\n')
+		if len(bytes.TrimSpace(statement)) > 0 {
+			if _, execErr := conn.ExecContext(restorer.ctx, string(statement)); execErr != nil {
+				return fmt.Errorf("failed to restore %s: %w", chunk.Path, execErr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", chunk.Path, err)
+		}
+	}
+}
+
+type logicalBackupRestoreTask struct {
+	database string
+	chunk    *LogicalBackupChunk
+}
+
+// restoreData restores the chunks of the tables accepted by keep by the connections in parallel
+func (restorer *logicalBackupRestorer) restoreData(conns []*sql.Conn, metadata *LogicalBackupMetadata,
+	keep func(database, table string) bool) error {
+	taskQueue := make(chan logicalBackupRestoreTask)
+	errGroup, ctx := errgroup.WithContext(restorer.ctx)
+	errGroup.Go(func() error {
+		defer close(taskQueue)
+		for _, database := range metadata.Databases {
+			for _, table := range database.Tables {
+				if table.Kind != LogicalBackupTableKind || !keep(database.Name, table.Name) {
+					continue
+				}
+				for _, chunk := range table.Chunks {
+					select {
+					case taskQueue <- logicalBackupRestoreTask{database: database.Name, chunk: chunk}:
+					case <-ctx.Done():
+						return nil
+					}
+				}
+			}
+		}
+		return nil
+	})
+	for _, conn := range conns {
+		conn := conn
+		errGroup.Go(func() error {
+			for task := range taskQueue {
+				if err := restorer.restoreChunk(conn, task.database, task.chunk); err != nil {
+					return err
+				}
+				tracelog.DebugLogger.Printf("Restored %d rows of %s", task.chunk.Rows, task.chunk.Path)
+			}
+			return nil
+		})
+	}
+	return errGroup.Wait()
+}
+
+// checkLogicalBackupContains makes sure the requested databases and tables are in the backup
+func checkLogicalBackupContains(metadata *LogicalBackupMetadata, databases []string, tables []TableName) error {
+	for _, name := range databases {
+		if !containsDatabase(metadata.Databases, name) {
+			return fmt.Errorf("database %s is not found in the backup", name)
+		}
+	}
+	for _, name := range tables {
+		found := false
+		for _, database := range metadata.Databases {
+			for _, table := range database.Tables {
+				found = found || database.Name == name.Database && table.Name == name.Table
+			}
+		}
+		if !found {
+			return fmt.Errorf("table %s.%s is not found in the backup", name.Database, name.Table)
+		}
+	}
+	return nil
+}
+
+func openLogicalBackupRestoreConns(ctx context.Context, db *sql.DB, count int) ([]*sql.Conn, error) {
+	conns := make([]*sql.Conn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, err := db.Conn(ctx)
+		if err == nil {
+			_, err = conn.ExecContext(ctx, logicalBackupSessionSettings+", foreign_key_checks = 0, unique_checks = 0")
+			conns = append(conns, conn)
+		}
+		if err != nil {
+			for _, conn := range conns {
+				utility.LoggedClose(conn, "")
+			}
+			return nil, err
+		}
+	}
+	return conns, nil
+}
+
+// restoreLogicalBackup restores the databases and tables of the logical backup to the server of
+// WALG_MYSQL_DATASOURCE_NAME, everything is restored if no databases and tables are listed
+func restoreLogicalBackup(backup internal.Backup, sentinel StreamSentinelDto, databases []string, tables []TableName) error {
+	var metadata LogicalBackupMetadata
+	err := internal.FetchDto(backup.Folder, &metadata, getLogicalBackupMetadataPath(backup.Name))
+	if err != nil {
+		return fmt.Errorf("failed to fetch backup metadata: %w", err)
+	}
+	if err = checkLogicalBackupContains(&metadata, databases, tables); err != nil {
+		return err
+	}
+	workers, err := internal.GetMaxDownloadConcurrency()
+	if err != nil {
+		return err
+	}
+
+	db, err := getMySQLConnection()
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(db, "")
+	restorer := &logicalBackupRestorer{ctx: context.Background(), backup: backup}
+	conns, err := openLogicalBackupRestoreConns(restorer.ctx, db, workers)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, conn := range conns {
+			utility.LoggedClose(conn, "")
+		}
+	}()
+
+	keep := newLogicalBackupFilter(databases, tables)
+	if err = restorer.restoreTables(conns[0], &metadata, keep); err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Restoring the data of %s by %d workers", backup.Name, workers)
+	if err = restorer.restoreData(conns, &metadata, keep); err != nil {
+		return err
+	}
+	if err = restorer.restoreViews(conns[0], &metadata, keep); err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Restored %s at %s:%d, GTID set '%s'",
+		backup.Name, sentinel.BinLogFile, sentinel.BinLogPosition, sentinel.GTIDExecuted)
+	return nil
+}
@@ -0,0 +1,512 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const binaryLogStatusMinMySQLVersion = "v8.2.0"
+
+var integerDataTypes = map[string]bool{"tinyint": true, "smallint": true, "mediumint": true, "int": true, "bigint": true}
+
+// logicalBackupSnapshot is the set of the connections with the transactions of the same consistent snapshot
+type logicalBackupSnapshot struct {
+	conns          []*sql.Conn
+	gtidExecuted   gomysql.GTIDSet
+	binlogFile     string
+	binlogPosition uint64
+}
+
+func (snapshot *logicalBackupSnapshot) Close() {
+	for _, conn := range snapshot.conns {
+		utility.LoggedClose(conn, "")
+	}
+}
+
+// openLogicalBackupSnapshot starts the snapshot transactions while the commits are blocked by FLUSH TABLES WITH READ LOCK,
+// so the GTID set and the binlog position of the lock are the ones of the snapshot
+func openLogicalBackupSnapshot(ctx context.Context, db *sql.DB, flavor, version string, workers int) (*logicalBackupSnapshot, error) {
+	control, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(control, "")
+	if _, err = control.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		return nil, fmt.Errorf("failed to lock tables: %w", err)
+	}
+	defer func() {
+		if _, err := control.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
+			tracelog.ErrorLogger.Printf("Failed to unlock tables: %v", err)
+		}
+	}()
+
+	snapshot := &logicalBackupSnapshot{}
+	for i := 0; i < workers; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			snapshot.Close()
+			return nil, err
+		}
+		snapshot.conns = append(snapshot.conns, conn)
+		for _, statement := range []string{
+			logicalBackupSessionSettings,
+			"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+			"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+		} {
+			if _, err = conn.ExecContext(ctx, statement); err != nil {
+				snapshot.Close()
+				return nil, fmt.Errorf("failed to start snapshot: %w", err)
+			}
+		}
+	}
+
+	snapshot.gtidExecuted, err = getMySQLGTIDExecuted(db, flavor)
+	if err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+	snapshot.binlogFile, snapshot.binlogPosition, err = getBinlogStatus(ctx, control, flavor, version)
+	if err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// getBinlogStatus returns the current binlog position, it is empty if the binlog is disabled
+func getBinlogStatus(ctx context.Context, conn *sql.Conn, flavor, version string) (string, uint64, error) {
+	query := "SHOW MASTER STATUS"
+	if flavor == gomysql.MySQLFlavor && isMySQLVersionAtLeast(version, binaryLogStatusMinMySQLVersion) {
+		query = "SHOW BINARY LOG STATUS"
+	}
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return "", 0, err
+	}
+	defer utility.LoggedClose(rows, "")
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+	if !rows.Next() {
+		return "", 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		return "", 0, err
+	}
+	// File and Position are the first columns
+	position, err := strconv.ParseUint(values[1].String, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to parse binlog position '%s': %w", values[1].String, err)
+	}
+	return values[0].String, position, nil
+}
+
+// logicalBackupTask is the chunk of the table to dump, the whole table is dumped if where is empty
+type logicalBackupTask struct {
+	database string
+	table    string
+	// columns are the columns to dump, all except the generated ones
+	columns []string
+	where   string
+	chunk   *LogicalBackupChunk
+}
+
+type logicalBackupDumper struct {
+	ctx        context.Context
+	uploader   internal.Uploader
+	backupName string
+	chunkRows  int64
+}
+
+func (dumper *logicalBackupDumper) objectPath(database, table, name string) string {
+	return getLogicalBackupObjectPath(database, table, name, dumper.uploader.Compression().FileExtension())
+}
+
+func (dumper *logicalBackupDumper) uploadSchema(path, schema string) error {
+	return dumper.uploader.PushStreamToDestination(dumper.ctx, strings.NewReader(schema), dumper.backupName+"/"+path)
+}
+
+// dumpSchema uploads the definitions of the databases and of their tables and views,
+// and splits the tables into the chunks to dump
+func (dumper *logicalBackupDumper) dumpSchema(conn *sql.Conn) (*LogicalBackupMetadata, []*logicalBackupTask, error) {
+	rows, err := conn.QueryContext(dumper.ctx, "SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE FROM information_schema.TABLES "+
+		"WHERE TABLE_SCHEMA NOT IN ('"+strings.Join(logicalBackupSystemDatabases, "','")+"') "+
+		"AND TABLE_TYPE IN ('BASE TABLE', 'VIEW') ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := &LogicalBackupMetadata{}
+	var database *LogicalBackupDatabase
+	for rows.Next() {
+		var databaseName, tableName, tableType string
+		if err = rows.Scan(&databaseName, &tableName, &tableType); err != nil {
+			utility.LoggedClose(rows, "")
+			return nil, nil, err
+		}
+		if database == nil || database.Name != databaseName {
+			database = &LogicalBackupDatabase{Name: databaseName}
+			metadata.Databases = append(metadata.Databases, database)
+		}
+		kind := LogicalBackupTableKind
+		if tableType == "VIEW" {
+			kind = LogicalBackupViewKind
+		}
+		database.Tables = append(database.Tables, &LogicalBackupTable{Name: tableName, Kind: kind})
+	}
+	utility.LoggedClose(rows, "")
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	// the databases without tables are not listed above
+	databases, err := listDatabases(dumper.ctx, conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range databases {
+		if !containsDatabase(metadata.Databases, name) {
+			metadata.Databases = append(metadata.Databases, &LogicalBackupDatabase{Name: name})
+		}
+	}
+
+	var tasks []*logicalBackupTask
+	for _, database := range metadata.Databases {
+		var schema string
+		err = conn.QueryRowContext(dumper.ctx, "SHOW CREATE DATABASE IF NOT EXISTS "+quoteIdentifier(database.Name)).
+			Scan(new(string), &schema)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to show database %s: %w", database.Name, err)
+		}
+		database.Schema = dumper.objectPath(database.Name, "", "schema.sql")
+		if err = dumper.uploadSchema(database.Schema, schema); err != nil {
+			return nil, nil, err
+		}
+		for _, table := range database.Tables {
+			tableTasks, err := dumper.dumpTableSchema(conn, database.Name, table)
+			if err != nil {
+				return nil, nil, err
+			}
+			tasks = append(tasks, tableTasks...)
+		}
+	}
+	return metadata, tasks, nil
+}
+
+func listDatabases(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT SCHEMA_NAME FROM information_schema.SCHEMATA "+
+		"WHERE SCHEMA_NAME NOT IN ('"+strings.Join(logicalBackupSystemDatabases, "','")+"') ORDER BY SCHEMA_NAME")
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(rows, "")
+	var databases []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+	return databases, rows.Err()
+}
+
+func containsDatabase(databases []*LogicalBackupDatabase, name string) bool {
+	for _, database := range databases {
+		if database.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (dumper *logicalBackupDumper) dumpTableSchema(conn *sql.Conn, database string,
+	table *LogicalBackupTable) ([]*logicalBackupTask, error) {
+	tableName := quoteIdentifier(database) + "." + quoteIdentifier(table.Name)
+	var schema string
+	var err error
+	if table.Kind == LogicalBackupViewKind {
+		err = conn.QueryRowContext(dumper.ctx, "SHOW CREATE VIEW "+tableName).Scan(new(string), &schema, new(string), new(string))
+	} else {
+		err = conn.QueryRowContext(dumper.ctx, "SHOW CREATE TABLE "+tableName).Scan(new(string), &schema)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to show %s %s.%s: %w", table.Kind, database, table.Name, err)
+	}
+	table.Schema = dumper.objectPath(database, table.Name, "schema.sql")
+	if err = dumper.uploadSchema(table.Schema, schema); err != nil {
+		return nil, err
+	}
+	if table.Kind == LogicalBackupViewKind {
+		return nil, nil
+	}
+
+	columns, err := dumper.listDumpColumns(conn, database, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := dumper.splitTable(conn, database, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*logicalBackupTask, 0, len(ranges))
+	for i, where := range ranges {
+		chunk := &LogicalBackupChunk{Path: dumper.objectPath(database, table.Name, fmt.Sprintf("data_%04d.sql", i+1))}
+		table.Chunks = append(table.Chunks, chunk)
+		tasks = append(tasks, &logicalBackupTask{database: database, table: table.Name, columns: columns, where: where, chunk: chunk})
+	}
+	return tasks, nil
+}
+
+// listDumpColumns lists the columns of the table including the invisible ones, the generated columns are skipped
+// as MySQL computes them on insert
+func (dumper *logicalBackupDumper) listDumpColumns(conn *sql.Conn, database, table string) ([]string, error) {
+	rows, err := conn.QueryContext(dumper.ctx, "SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", database, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list columns of %s.%s: %w", database, table, err)
+	}
+	defer utility.LoggedClose(rows, "")
+	var columns []string
+	for rows.Next() {
+		var column, extra string
+		if err = rows.Scan(&column, &extra); err != nil {
+			return nil, err
+		}
+		if !isGeneratedColumn(extra) {
+			columns = append(columns, column)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns to dump in %s.%s", database, table)
+	}
+	return columns, nil
+}
+
+// splitTable splits the table with the integer primary key into the ranges of about chunkRows rows,
+// the other tables are dumped as one chunk
+func (dumper *logicalBackupDumper) splitTable(conn *sql.Conn, database, table string) ([]string, error) {
+	rows, err := conn.QueryContext(dumper.ctx, "SELECT COLUMN_NAME, DATA_TYPE FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_KEY = 'PRI'", database, table)
+	if err != nil {
+		return nil, err
+	}
+	var keyColumns, keyTypes []string
+	for rows.Next() {
+		var column, dataType string
+		if err = rows.Scan(&column, &dataType); err != nil {
+			utility.LoggedClose(rows, "")
+			return nil, err
+		}
+		keyColumns = append(keyColumns, column)
+		keyTypes = append(keyTypes, dataType)
+	}
+	utility.LoggedClose(rows, "")
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keyColumns) != 1 || !integerDataTypes[keyTypes[0]] {
+		return []string{""}, nil
+	}
+
+	var minKey, maxKey sql.NullString
+	var count int64
+	key := quoteIdentifier(keyColumns[0])
+	err = conn.QueryRowContext(dumper.ctx, fmt.Sprintf("SELECT MIN(%s), MAX(%s), COUNT(*) FROM %s.%s",
+		key, key, quoteIdentifier(database), quoteIdentifier(table))).Scan(&minKey, &maxKey, &count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split %s.%s: %w", database, table, err)
+	}
+	if !minKey.Valid || count <= dumper.chunkRows {
+		return []string{""}, nil
+	}
+	minValue, minErr := strconv.ParseInt(minKey.String, 10, 64)
+	maxValue, maxErr := strconv.ParseInt(maxKey.String, 10, 64)
+	// the unsigned keys above the int64 range are not split
+	if minErr != nil || maxErr != nil || maxValue-minValue < 0 {
+		return []string{""}, nil
+	}
+	return splitKeyRange(key, minValue, maxValue, (count+dumper.chunkRows-1)/dumper.chunkRows), nil
+}
+
+// splitKeyRange splits the key range into the chunks of the same width,
+// they have about the same number of rows if the keys are dense enough
+func splitKeyRange(key string, minKey, maxKey, chunks int64) []string {
+	step := (maxKey-minKey)/chunks + 1
+	var ranges []string
+	for from := minKey; ; from += step {
+		var conditions []string
+		if from > minKey {
+			conditions = append(conditions, fmt.Sprintf("%s >= %d", key, from))
+		}
+		isLast := maxKey-from < step
+		if !isLast {
+			conditions = append(conditions, fmt.Sprintf("%s < %d", key, from+step))
+		}
+		ranges = append(ranges, strings.Join(conditions, " AND "))
+		if isLast {
+			return ranges
+		}
+	}
+}
+
+// dumpChunk uploads the rows of the chunk as the INSERT statements
+func (dumper *logicalBackupDumper) dumpChunk(conn *sql.Conn, task *logicalBackupTask) error {
+	rows, err := conn.QueryContext(dumper.ctx, getLogicalBackupSelectQuery(task.database, task.table, task.columns, task.where))
+	if err != nil {
+		return fmt.Errorf("failed to dump %s.%s: %w", task.database, task.table, err)
+	}
+	defer utility.LoggedClose(rows, "")
+
+	pipeReader, pipeWriter := io.Pipe()
+	errGroup := errgroup.Group{}
+	errGroup.Go(func() error {
+		err := dumper.uploader.PushStreamToDestination(dumper.ctx, pipeReader, dumper.backupName+"/"+task.chunk.Path)
+		// the dump stops if the upload fails
+		_ = pipeReader.CloseWithError(err)
+		return err
+	})
+	task.chunk.Rows, err = writeInsertStatements(rows, task.table, task.columns, pipeWriter, logicalBackupStatementSize)
+	_ = pipeWriter.CloseWithError(err)
+	uploadErr := errGroup.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to dump %s.%s: %w", task.database, task.table, err)
+	}
+	return uploadErr
+}
+
+// dumpData dumps the chunks by the connections of the snapshot in parallel
+func (dumper *logicalBackupDumper) dumpData(conns []*sql.Conn, tasks []*logicalBackupTask) error {
+	taskQueue := make(chan *logicalBackupTask, len(tasks))
+	for _, task := range tasks {
+		taskQueue <- task
+	}
+	close(taskQueue)
+
+	errGroup, ctx := errgroup.WithContext(dumper.ctx)
+	for _, conn := range conns {
+		conn := conn
+		errGroup.Go(func() error {
+			for task := range taskQueue {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if err := dumper.dumpChunk(conn, task); err != nil {
+					return err
+				}
+				tracelog.DebugLogger.Printf("Dumped %d rows of %s.%s", task.chunk.Rows, task.database, task.table)
+			}
+			return nil
+		})
+	}
+	return errGroup.Wait()
+}
+
+// HandleLogicalBackupPush dumps the databases of the server by the parallel workers of the same consistent snapshot,
+// every chunk of the tables is uploaded as its own object
+func HandleLogicalBackupPush(folder storage.Folder, uploader internal.Uploader, isPermanent bool, userDataRaw string,
+	workers int, chunkRows int64) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+		tracelog.WarningLogger.Printf("Failed to obtain the OS hostname")
+	}
+	if chunkRows <= 0 {
+		tracelog.ErrorLogger.Fatalf("%s should be positive, got %d", internal.MysqlLogicalBackupChunkRows, chunkRows)
+	}
+
+	db, err := getMySQLConnection()
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(db, "")
+
+	version, err := getMySQLVersion(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	flavor, err := getMySQLFlavor(db)
+	tracelog.ErrorLogger.FatalOnError(err)
+	serverUUID, err := getServerUUID(db, flavor)
+	tracelog.ErrorLogger.FatalOnError(err)
+	timeStart := utility.TimeNowCrossPlatformLocal()
+
+	ctx := context.Background()
+	snapshot, err := openLogicalBackupSnapshot(ctx, db, flavor, version, workers)
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer snapshot.Close()
+	tracelog.InfoLogger.Printf("Opened the snapshot at %s:%d, GTID set '%s'",
+		snapshot.binlogFile, snapshot.binlogPosition, snapshot.gtidExecuted)
+
+	binlogStart, err := getLastUploadedBinlogBeforeGTID(folder, snapshot.gtidExecuted, flavor)
+	tracelog.ErrorLogger.FatalfOnError("failed to get last uploaded binlog: %v", err)
+
+	dumper := &logicalBackupDumper{
+		ctx:        ctx,
+		uploader:   uploader,
+		backupName: internal.StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat),
+		chunkRows:  chunkRows,
+	}
+	metadata, tasks, err := dumper.dumpSchema(snapshot.conns[0])
+	tracelog.ErrorLogger.FatalfOnError("failed to dump schema: %v", err)
+	tracelog.InfoLogger.Printf("Dumping %d databases in %d chunks by %d workers", len(metadata.Databases), len(tasks), workers)
+	err = dumper.dumpData(snapshot.conns, tasks)
+	tracelog.ErrorLogger.FatalfOnError("failed to dump data: %v", err)
+	err = internal.UploadDto(uploader.Folder(), metadata, getLogicalBackupMetadataPath(dumper.backupName))
+	tracelog.ErrorLogger.FatalfOnError("failed to upload backup metadata: %v", err)
+
+	binlogEnd, err := getLastUploadedBinlog(folder)
+	tracelog.ErrorLogger.FatalfOnError("failed to get last uploaded binlog (after): %v", err)
+	timeStop := utility.TimeNowCrossPlatformLocal()
+
+	uploadedSize, err := uploader.UploadedDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc uploaded data size: %v", err)
+	}
+	rawSize, err := uploader.RawDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc raw data size: %v", err)
+	}
+	userData, err := internal.UnmarshalSentinelUserData(userDataRaw)
+	tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+
+	incrementCount := 0
+	sentinel := StreamSentinelDto{
+		Tool:             WalgLogicalTool,
+		BinLogStart:      binlogStart,
+		BinLogEnd:        binlogEnd,
+		BinLogFile:       snapshot.binlogFile,
+		BinLogPosition:   snapshot.binlogPosition,
+		GTIDExecuted:     snapshot.gtidExecuted.String(),
+		StartLocalTime:   timeStart,
+		StopLocalTime:    timeStop,
+		CompressedSize:   uploadedSize,
+		UncompressedSize: rawSize,
+		Hostname:         hostname,
+		ServerUUID:       serverUUID,
+		ServerVersion:    version,
+		IsPermanent:      isPermanent,
+		UserData:         userData,
+		IncrementCount:   &incrementCount,
+	}
+	tracelog.InfoLogger.Printf("Backup sentinel: %s", sentinel.String())
+
+	err = internal.UploadSentinel(uploader, &sentinel, dumper.backupName)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitKeyRange(t *testing.T) {
+	assert.Equal(t, []string{"`id` < 4", "`id` >= 4 AND `id` < 7", "`id` >= 7"}, splitKeyRange("`id`", 1, 9, 3))
+	assert.Equal(t, []string{"`id` < 6", "`id` >= 6"}, splitKeyRange("`id`", 1, 10, 2))
+	assert.Equal(t, []string{""}, splitKeyRange("`id`", 5, 5, 4))
+	assert.Equal(t, []string{"`id` < 0", "`id` >= 0"}, splitKeyRange("`id`", -10, 9, 2))
+}
+
+func TestLogicalBackupGeneratedColumns(t *testing.T) {
+	assert.True(t, isGeneratedColumn("VIRTUAL GENERATED"))
+	assert.True(t, isGeneratedColumn("STORED GENERATED INVISIBLE"))
+	assert.False(t, isGeneratedColumn("DEFAULT_GENERATED"))
+	assert.False(t, isGeneratedColumn("DEFAULT_GENERATED on update CURRENT_TIMESTAMP"))
+	assert.False(t, isGeneratedColumn("INVISIBLE"))
+	assert.False(t, isGeneratedColumn("auto_increment"))
+
+	// `total` is the generated column of the table (`id`, `price`, `total`, `note` INVISIBLE)
+	var columns []string
+	for _, column := range []struct{ name, extra string }{
+		{"id", "auto_increment"}, {"price", ""}, {"total", "STORED GENERATED"}, {"note", "INVISIBLE"},
+	} {
+		if !isGeneratedColumn(column.extra) {
+			columns = append(columns, column.name)
+		}
+	}
+	assert.Equal(t, "SELECT `id`,`price`,`note` FROM `shop`.`orders`",
+		getLogicalBackupSelectQuery("shop", "orders", columns, ""))
+	assert.Equal(t, "SELECT `id`,`price`,`note` FROM `shop`.`orders` WHERE `id` < 4",
+		getLogicalBackupSelectQuery("shop", "orders", columns, "`id` < 4"))
+	assert.Equal(t, "INSERT INTO `orders` (`id`,`price`,`note`) VALUES ", getLogicalBackupInsertPrefix("orders", columns))
+	assert.Equal(t, "`a``b`,`c`", quoteColumns([]string{"a`b", "c"}))
+}
+
+func TestAppendSQLValue(t *testing.T) {
+	cases := []struct {
+		value    sql.RawBytes
+		binary   bool
+		expected string
+	}{
+		{nil, false, "NULL"},
+		{nil, true, "NULL"},
+		{sql.RawBytes{}, true, "''"},
+		{sql.RawBytes{0x00, 0xff}, true, "0x00ff"},
+		{sql.RawBytes("42"), false, "'42'"},
+		{sql.RawBytes("it's\n\\a\x00\r\x1a"), false, `'it\'s\n\\a\0\r\Z'`},
+	}
+	for _, c := range cases {
+		var buffer bytes.Buffer
+		appendSQLValue(&buffer, c.value, c.binary)
+		assert.Equal(t, c.expected, buffer.String())
+	}
+}
+
+func TestLogicalBackupFilter(t *testing.T) {
+	keepAll := newLogicalBackupFilter(nil, nil)
+	assert.True(t, keepAll("shop", ""))
+	assert.True(t, keepAll("shop", "orders"))
+
+	keep := newLogicalBackupFilter([]string{"crm"}, []TableName{{Database: "shop", Table: "orders"}})
+	assert.True(t, keep("crm", ""))
+	assert.True(t, keep("crm", "clients"))
+	assert.True(t, keep("shop", ""))
+	assert.True(t, keep("shop", "orders"))
+	assert.False(t, keep("shop", "items"))
+	assert.False(t, keep("blog", ""))
+}
+
+func TestLogicalBackupObjectPath(t *testing.T) {
+	assert.Equal(t, "databases/shop/schema.sql.lz4", getLogicalBackupObjectPath("shop", "", "schema.sql", "lz4"))
+	assert.Equal(t, "databases/shop/order%20items/data_0001.sql.lz4",
+		getLogicalBackupObjectPath("shop", "order items", "data_0001.sql", "lz4"))
+	assert.Equal(t, "databases/a%2Fb/c/schema.sql.br", getLogicalBackupObjectPath("a/b", "c", "schema.sql", "br"))
+}
+
+func TestCheckLogicalBackupContains(t *testing.T) {
+	metadata := &LogicalBackupMetadata{Databases: []*LogicalBackupDatabase{
+		{Name: "shop", Tables: []*LogicalBackupTable{
+			{Name: "orders", Kind: LogicalBackupTableKind},
+			{Name: "totals", Kind: LogicalBackupViewKind},
+		}},
+		{Name: "empty"},
+	}}
+	require.NoError(t, checkLogicalBackupContains(metadata, nil, nil))
+	require.NoError(t, checkLogicalBackupContains(metadata, []string{"empty"},
+		[]TableName{{Database: "shop", Table: "orders"}, {Database: "shop", Table: "totals"}}))
+	assert.Error(t, checkLogicalBackupContains(metadata, []string{"crm"}, nil))
+	assert.Error(t, checkLogicalBackupContains(metadata, nil, []TableName{{Database: "shop", Table: "items"}}))
+	assert.Error(t, checkLogicalBackupContains(metadata, nil, []TableName{{Database: "empty", Table: "orders"}}))
+}
@@ -31,6 +31,8 @@ type BackupTool string
 const (
 	WalgUnspecifiedStreamBackupTool BackupTool = "WALG_UNSPECIFIED_STREAM_BACKUP_TOOL"
 	WalgXtrabackupTool              BackupTool = "WALG_XTRABACKUP_TOOL"
+	WalgCloneTool                   BackupTool = "WALG_CLONE_TOOL"
+	WalgLogicalTool                 BackupTool = "WALG_LOGICAL_TOOL"
 )
 
 func getMySQLVersion(db *sql.DB) (string, error) {
@@ -118,8 +120,8 @@ func getLastUploadedBinlog(folder storage.Folder) (string, error) {
 	return name, nil
 }
 
-func getLastUploadedBinlogBeforeGTID(folder storage.Folder, gtid gomysql.GTIDSet, flavor string) (string, error) {
-	folder = folder.GetSubFolder(BinlogPath)
+func getLastUploadedBinlogBeforeGTID(rootFolder storage.Folder, gtid gomysql.GTIDSet, flavor string) (string, error) {
+	folder := rootFolder.GetSubFolder(BinlogPath)
 	logFiles, _, err := folder.ListFolder()
 	if err != nil {
 		return "", err
@@ -130,8 +132,9 @@ func getLastUploadedBinlogBeforeGTID(folder storage.Folder, gtid gomysql.GTIDSet
 	if len(logFiles) == 0 {
 		return "", nil
 	}
+	index := getBinlogIndexOrNil(rootFolder)
 	for i := len(logFiles) - 1; i > 0; i-- {
-		prevGtid, err := GetBinlogPreviousGTIDsRemote(folder, logFiles[i].GetName(), flavor)
+		prevGtid, err := getBinlogPreviousGTIDs(index, folder, logFiles[i].GetName(), flavor)
 		if err != nil {
 			return "", err
 		}
@@ -216,7 +219,11 @@ type StreamSentinelDto struct {
 	BinLogStart string     `json:"BinLogStart,omitempty"`
 	// BinLogEnd field is for debug purpose only.
 	// As we can not guarantee that transactions in BinLogEnd file happened before or after backup
-	BinLogEnd      string    `json:"BinLogEnd,omitempty"`
+	BinLogEnd string `json:"BinLogEnd,omitempty"`
+	// BinLogFile, BinLogPosition and GTIDExecuted are the exact coordinates of the xtrabackup, CLONE and logical backups
+	BinLogFile     string    `json:"BinLogFile,omitempty"`
+	BinLogPosition uint64    `json:"BinLogPosition,omitempty"`
+	GTIDExecuted   string    `json:"GTIDExecuted,omitempty"`
 	StartLocalTime time.Time `json:"StartLocalTime,omitempty"`
 	StopLocalTime  time.Time `json:"StopLocalTime,omitempty"`
 
@@ -236,6 +243,10 @@ type StreamSentinelDto struct {
 	IncrementFrom     *string `json:"DeltaFrom,omitempty"`
 	IncrementFullName *string `json:"DeltaFullName,omitempty"`
 	IncrementCount    *int    `json:"DeltaCount,omitempty"`
+	// VerifiedAt is the time of the last successful xtrabackup-verify
+	VerifiedAt *time.Time `json:"VerifiedAt,omitempty"`
+	// GTIDVerified is set by xtrabackup-verify when the GTID set of the restored backup is compared with GTIDExecuted
+	GTIDVerified *bool `json:"GTIDVerified,omitempty"`
 	//todo: add other fields from internal.GenericMetadata
 }
 
@@ -251,12 +262,28 @@ type binlogHandler interface {
 	handleBinlog(binlogPath string) error
 }
 
-func fetchLogs(folder storage.Folder, dstDir string, startTS, endTS, endBinlogTS time.Time, handler binlogHandler) error {
+//gocyclo:ignore
+func fetchLogs(folder storage.Folder, dstDir string, fetchRange binlogFetchRange,
+	stopTarget *BinlogStopTarget, handler binlogHandler) error {
 	logFolder := folder.GetSubFolder(BinlogPath)
+	selector := &binlogSelector{
+		index:       getBinlogIndexOrNil(folder),
+		endTS:       fetchRange.endTS,
+		backupGTIDs: fetchRange.backupGTIDs,
+		stopTarget:  stopTarget,
+	}
+	if stopTarget != nil {
+		err := stopTarget.checkArchived(logFolder)
+		if err != nil {
+			return err
+		}
+	}
+	startTS, endTS := fetchRange.startTS, fetchRange.endTS
+	index := selector.index
 	includeStart := true
 outer:
 	for {
-		logsToFetch, err := getLogsCoveringInterval(logFolder, startTS, includeStart, endBinlogTS)
+		logsToFetch, lastLog, err := getLogsCoveringInterval(logFolder, startTS, includeStart, fetchRange.endBinlogTS, selector)
 		includeStart = false
 		if err != nil {
 			return err
@@ -270,89 +297,66 @@ outer:
 				tracelog.ErrorLogger.Printf("failed to download %s: %v", binlogName, err)
 				return err
 			}
-			timestamp, err := GetBinlogStartTimestamp(binlogPath, gomysql.MySQLFlavor)
+			timestamp, err := getBinlogStartTimestamp(index, binlogPath)
 			if err != nil {
 				return err
 			}
+			stopTargetReached := false
+			if stopTarget != nil {
+				stopTargetReached, err = stopTarget.truncateBinlog(binlogPath)
+				if err != nil {
+					return err
+				}
+			}
 			err = handler.handleBinlog(binlogPath)
 			if err != nil {
 				return err
 			}
+			if stopTargetReached {
+				return nil
+			}
 			if timestamp.After(endTS) {
 				break outer
 			}
 		}
-		if len(logsToFetch) == 0 {
+		if lastLog || len(logsToFetch) == 0 {
 			break
 		}
 	}
+	if stopTarget != nil {
+		// the fetched binlogs are applied up to the end time, which is past the point asked for
+		return fmt.Errorf("stop target %s is not reached in the binlogs fetched until %s",
+			stopTarget.String(), endTS.Format(time.RFC3339))
+	}
 	return nil
 }
 
-func provideLogs(folder storage.Folder, dstDir string, startTS, endTS time.Time, p *storage.ObjectProvider) {
-	defer p.Close()
-	_, err := os.Stat(dstDir)
-	if os.IsNotExist(err) {
-		err = os.MkdirAll(dstDir, 0777)
-		p.HandleError(err)
-		if err != nil {
-			return
-		}
-	}
-
-	logFolder := folder.GetSubFolder(BinlogPath)
-	logsToFetch, err := getLogsCoveringInterval(logFolder, startTS, true, utility.MaxTime)
-	p.HandleError(err)
-	if err != nil {
-		return
-	}
-
-	for _, logFile := range logsToFetch {
-		// download log files
-		binlogName := utility.TrimFileExtension(logFile.GetName())
-		binlogPath := path.Join(dstDir, binlogName)
-		tracelog.InfoLogger.Printf("downloading %s into %s", binlogName, binlogPath)
-		if err = internal.DownloadFileTo(internal.NewFolderReader(logFolder), binlogName, binlogPath); err != nil {
-			if os.IsExist(err) {
-				tracelog.WarningLogger.Printf("file %s exist skipping", binlogName)
-			} else {
-				tracelog.ErrorLogger.Printf("failed to download %s: %v", binlogName, err)
-				p.HandleError(err)
-				return
-			}
-		}
-
-		// add file to provider
-		err = p.AddObject(logFile)
-		p.HandleError(err)
-		if err != nil {
-			return
-		}
-
-		timestamp, err := GetBinlogStartTimestamp(binlogPath, gomysql.MySQLFlavor)
-		p.HandleError(err)
-		if err != nil {
-			return
-		}
-		if timestamp.After(endTS) {
-			return
-		}
-	}
+// binlogFetchRange is the part of the archived binlogs to fetch after the backup
+type binlogFetchRange struct {
+	// startTS is the last modified time of the first binlog to fetch
+	startTS time.Time
+	// endTS is the time of the last event to apply
+	endTS time.Time
+	// endBinlogTS is the last modified time of the last binlog to fetch
+	endBinlogTS time.Time
+	// backupGTIDs is the GTID set of the backup, empty when the backup doesn't record it
+	backupGTIDs string
 }
 
-func getBinlogSinceTS(folder storage.Folder, backup internal.Backup) (time.Time, error) {
+// getBinlogSinceTS returns the last modified time of the first binlog to fetch and the GTID set of the backup
+func getBinlogSinceTS(folder storage.Folder, backup internal.Backup) (time.Time, string, error) {
 	startTS := utility.MaxTime // far future
 	var streamSentinel StreamSentinelDto
 	err := backup.FetchSentinel(&streamSentinel)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, "", err
 	}
 	tracelog.InfoLogger.Printf("Backup sentinel: %s", streamSentinel.String())
 
 	// case when backup was uploaded before first binlog
 	sentinels, _, err := folder.GetSubFolder(utility.BaseBackupPath).ListFolder()
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, "", err
 	}
 	for _, sentinel := range sentinels {
 		if strings.HasPrefix(sentinel.GetName(), backup.Name) {
@@ -365,7 +369,7 @@ func getBinlogSinceTS(folder storage.Folder, backup internal.Backup) (time.Time,
 	// case when binlog was uploaded before backup
 	binlogs, _, err := folder.GetSubFolder(BinlogPath).ListFolder()
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, "", err
 	}
 	for _, binlog := range binlogs {
 		if strings.HasPrefix(binlog.GetName(), streamSentinel.BinLogStart) {
@@ -375,14 +379,16 @@ func getBinlogSinceTS(folder storage.Folder, backup internal.Backup) (time.Time,
 			}
 		}
 	}
-	return startTS, nil
+	return startTS, streamSentinel.GTIDExecuted, nil
 }
 
-// getLogsCoveringInterval lists the operation logs that cover the interval
-func getLogsCoveringInterval(folder storage.Folder, start time.Time, includeStart bool, endBinlogTS time.Time) ([]storage.Object, error) {
+// getLogsCoveringInterval lists the operation logs that cover the interval, the indexed binlogs are picked by
+// the selector, it also reports whether the binlogs after the listed ones are not needed
+func getLogsCoveringInterval(folder storage.Folder, start time.Time, includeStart bool, endBinlogTS time.Time,
+	selector *binlogSelector) ([]storage.Object, bool, error) {
 	logFiles, _, err := folder.ListFolder()
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	sort.Slice(logFiles, func(i, j int) bool {
 		return logFiles[i].GetLastModified().Before(logFiles[j].GetLastModified())
@@ -393,8 +399,14 @@ func getLogsCoveringInterval(folder storage.Folder, start time.Time, includeStar
 			continue // don't fetch binlogs from future
 		}
 		if start.Before(logFile.GetLastModified()) || includeStart && start.Equal(logFile.GetLastModified()) {
-			logsToFetch = append(logsToFetch, logFile)
+			fetch, stop := selector.check(utility.TrimFileExtension(logFile.GetName()))
+			if stop {
+				return logsToFetch, true, nil
+			}
+			if fetch {
+				logsToFetch = append(logsToFetch, logFile)
+			}
 		}
 	}
-	return logsToFetch, nil
+	return logsToFetch, false, nil
 }
@@ -0,0 +1,133 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// findTableFiles returns the files of the table or of its partitions with the extension
+func findTableFiles(dir string, table TableName, extension string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, table.Database))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && filepath.Ext(entry.Name()) == extension && table.isTableFile(table.Database, entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+func copyTableFile(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(src, "")
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		utility.LoggedClose(dst, "")
+		return err
+	}
+	return dst.Close()
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// importTable replaces the tablespace of the existing table with the exported one
+func importTable(conn *sql.Conn, fromDir, dataDir string, table TableName) error {
+	tablespaces, err := findTableFiles(fromDir, table, ".ibd")
+	if err != nil {
+		return err
+	}
+	if len(tablespaces) == 0 {
+		return fmt.Errorf("table %s is not found in %s", table, fromDir)
+	}
+	tablespaceClause := "TABLESPACE"
+	if len(tablespaces) > 1 || tablespaces[0] != table.Table+".ibd" {
+		tablespaceClause = "PARTITION ALL TABLESPACE"
+	}
+	tableName := quoteIdentifier(table.Database) + "." + quoteIdentifier(table.Table)
+	ctx := context.Background()
+
+	tracelog.InfoLogger.Printf("Discarding the tablespace of %s", table)
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s DISCARD %s", tableName, tablespaceClause))
+	if err != nil {
+		return fmt.Errorf("failed to discard the tablespace of %s: %w", table, err)
+	}
+
+	var copied []string
+	defer func() {
+		// the .cfg files are not needed after the import
+		for _, path := range copied {
+			if filepath.Ext(path) == ".cfg" {
+				_ = os.Remove(path)
+			}
+		}
+	}()
+	for _, tablespace := range tablespaces {
+		for _, filename := range []string{tablespace, strings.TrimSuffix(tablespace, ".ibd") + ".cfg"} {
+			srcPath := filepath.Join(fromDir, table.Database, filename)
+			if _, err = os.Stat(srcPath); os.IsNotExist(err) && filepath.Ext(filename) == ".cfg" {
+				// the table is imported without the .cfg file, MySQL does not check its schema then
+				tracelog.WarningLogger.Printf("%s is not found, the table schema is not checked on import", srcPath)
+				continue
+			}
+			dstPath := filepath.Join(dataDir, table.Database, filename)
+			if err = copyTableFile(srcPath, dstPath); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", srcPath, dstPath, err)
+			}
+			copied = append(copied, dstPath)
+		}
+	}
+
+	tracelog.InfoLogger.Printf("Importing the tablespace of %s", table)
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s IMPORT %s", tableName, tablespaceClause))
+	if err != nil {
+		return fmt.Errorf("failed to import the tablespace of %s: %w", table, err)
+	}
+	return nil
+}
+
+// HandleTableImport imports the tables exported by backup-fetch --tables into the running MySQL server,
+// the tables should exist with the same definition
+func HandleTableImport(fromDir string, tables []TableName) {
+	db, err := getMySQLConnection()
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(db, "")
+	conn, err := db.Conn(context.Background())
+	tracelog.ErrorLogger.FatalOnError(err)
+	defer utility.LoggedClose(conn, "")
+
+	var dataDir string
+	err = conn.QueryRowContext(context.Background(), "SELECT @@datadir").Scan(&dataDir)
+	tracelog.ErrorLogger.FatalfOnError("Failed to query datadir: %v", err)
+	// the tablespaces of the tables referenced by the foreign keys can't be discarded otherwise
+	_, err = conn.ExecContext(context.Background(), "SET SESSION foreign_key_checks = 0")
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	for _, table := range tables {
+		err = importTable(conn, fromDir, dataDir, table)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tracelog.InfoLogger.Printf("Table %s is imported", table)
+	}
+}
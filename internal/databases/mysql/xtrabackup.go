@@ -10,6 +10,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +26,28 @@ type XtrabackupInfo struct {
 	ToLSN *LSN
 	// max LSN that were observed at the end of the backup
 	LastLSN *LSN
+	// binlog coordinates of the backup from xtrabackup_info, they are empty if the binlog is disabled
+	BinLogFile     string
+	BinLogPosition uint64
+	GTIDExecuted   string
+}
+
+// xtrabackupBinlogPos matches the binlog_pos of xtrabackup_info, the GTID set may span several lines
+var xtrabackupBinlogPos = regexp.MustCompile(`binlog_pos = filename '([^']*)', position '(\d+)'(?:, GTID of the last change '([^']*)')?`)
+
+// parseXtrabackupBinlogPos reads the binlog coordinates from the content of xtrabackup_info
+func parseXtrabackupBinlogPos(content string, info *XtrabackupInfo) {
+	match := xtrabackupBinlogPos.FindStringSubmatch(content)
+	if match == nil {
+		return
+	}
+	position, err := strconv.ParseUint(match[2], 10, 64)
+	if err != nil {
+		return
+	}
+	info.BinLogFile = match[1]
+	info.BinLogPosition = position
+	info.GTIDExecuted = normalizeGTIDSet(match[3])
 }
 
 func NewXtrabackupInfo(content string) XtrabackupInfo {
@@ -83,7 +107,14 @@ func readXtrabackupInfo(xtrabackupExtraDirectory string) (XtrabackupInfo, error)
 	if err != nil {
 		return XtrabackupInfo{}, err
 	}
-	return NewXtrabackupInfo(string(raw)), nil
+	info := NewXtrabackupInfo(string(raw))
+	raw, err = os.ReadFile(filepath.Join(xtrabackupExtraDirectory, "xtrabackup_info"))
+	if err != nil {
+		tracelog.WarningLogger.Printf("failed to read `xtrabackup_info`, binlog coordinates are not known: %v", err)
+		return info, nil
+	}
+	parseXtrabackupBinlogPos(string(raw), &info)
+	return info, nil
 }
 
 func enrichBackupArgs(backupCmd *exec.Cmd, xtrabackupExtraDirectory string, isFullBackup bool, prevBackupInfo *PrevBackupInfo) {
@@ -101,17 +132,38 @@ func enrichBackupArgs(backupCmd *exec.Cmd, xtrabackupExtraDirectory string, isFu
 
 func GetXtrabackupFetcher(restoreCmd, prepareCmd *exec.Cmd) func(folder storage.Folder, backup internal.Backup) {
 	return func(folder storage.Folder, backup internal.Backup) {
-		err := xtrabackupFetch(backup.Name, folder, restoreCmd, prepareCmd, true)
+		err := xtrabackupFetch(backup.Name, folder, restoreCmd, prepareCmd, true, nil)
 		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v", err)
 	}
 }
 
+// getBackupPrepareCommand returns the prepare command of the backup in the increments chain.
+// The passed command is shared by the whole chain, so it's cloned instead of modified:
+// --apply-log-only of the base backups must not get into the prepare command of the last one.
+func getBackupPrepareCommand(prepareCmd *exec.Cmd, isIncremental bool, deltaDir string, isLast, isPartial bool) *exec.Cmd {
+	if prepareCmd == nil {
+		return nil
+	}
+	prepareCmd = cloneCommand(prepareCmd)
+	if isIncremental {
+		injectCommandArgument(prepareCmd, XtrabackupIncrementalDir+"="+deltaDir)
+	}
+	if !isLast {
+		injectCommandArgument(prepareCmd, XtrabackupApplyLogOnly)
+	} else if isPartial {
+		// the partial restore is prepared to import the tables into another server
+		injectCommandArgument(prepareCmd, XtrabackupExport)
+	}
+	return prepareCmd
+}
+
 func xtrabackupFetch(
 	backupName string,
 	folder storage.Folder,
 	restoreCmd *exec.Cmd,
 	prepareCmd *exec.Cmd,
-	isLast bool) error {
+	isLast bool,
+	keepFile func(path string) bool) error {
 	backup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, folder)
 	tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v", err)
 
@@ -133,7 +185,7 @@ func xtrabackupFetch(
 
 	if sentinel.IsIncremental {
 		tracelog.InfoLogger.Printf("Delta from %v at LSN %x \n", *sentinel.IncrementFrom, *sentinel.IncrementFromLSN)
-		err = xtrabackupFetch(*sentinel.IncrementFrom, folder, restoreCmd, prepareCmd, false)
+		err = xtrabackupFetch(*sentinel.IncrementFrom, folder, restoreCmd, prepareCmd, false, keepFile)
 		if err != nil {
 			return err
 		}
@@ -143,13 +195,8 @@ func xtrabackupFetch(
 		restoreCmd = cloneCommand(restoreCmd)
 		restoreArgs := strings.Fields(restoreCmd.Args[len(restoreCmd.Args)-1])
 		replaceCommandArgument(restoreCmd, restoreArgs[len(restoreArgs)-1], tempDeltaDir)
-
-		prepareCmd = cloneCommand(prepareCmd)
-		injectCommandArgument(prepareCmd, XtrabackupIncrementalDir+"="+tempDeltaDir)
-	}
-	if !isLast {
-		injectCommandArgument(prepareCmd, XtrabackupApplyLogOnly)
 	}
+	prepareCmd = getBackupPrepareCommand(prepareCmd, sentinel.IsIncremental, tempDeltaDir, isLast, keepFile != nil)
 
 	stdin, err := restoreCmd.StdinPipe()
 	tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v", err)
@@ -161,12 +208,7 @@ func xtrabackupFetch(
 	if err != nil {
 		return err
 	}
-	fetcher, err := internal.GetBackupStreamFetcher(backup)
-	if err != nil {
-		tracelog.ErrorLogger.Printf("Failed to detect backup format: %v\n", err)
-		return err
-	}
-	err = fetcher(backup, stdin)
+	err = fetchXtrabackupStream(backup, stdin, keepFile)
 	cmdErr := restoreCmd.Wait()
 	if cmdErr != nil {
 		tracelog.ErrorLogger.Printf("Restore command output:\n%s", stderr.String())
@@ -0,0 +1,507 @@
+package mysql
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/checksum"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	XtrabackupStreamLayout = "stream"
+	XtrabackupFilesLayout  = "files"
+
+	// XtrabackupFilesBackup is the type of the backup stream metadata of the backups in the files layout
+	XtrabackupFilesBackup       = "XTRABACKUP_FILES_BACKUP"
+	XtrabackupFilesMetadataName = "files_metadata.json"
+)
+
+// XtrabackupFilesMetadata is the manifest of the xbstream demultiplexed into the tar partitions,
+// every tar entry is one xbstream chunk named by the path of its file
+type XtrabackupFilesMetadata struct {
+	// Partitions are in the order of the xbstream
+	Partitions []string                              `json:"partitions"`
+	Files      map[string]*XtrabackupFileDescription `json:"files"`
+}
+
+type XtrabackupFileDescription struct {
+	// Size is the size of the chunk payloads, the sparse files take less space once extracted
+	Size   int64 `json:"size"`
+	Chunks int   `json:"chunks"`
+	// Partitions are the indexes of the partitions with the chunks of the file
+	Partitions []int `json:"partitions"`
+	// SHA256 is the checksum of the chunk payloads
+	SHA256 string `json:"sha256"`
+}
+
+func getXtrabackupFilesMetadataPath(backupName string) string {
+	return backupName + "/" + XtrabackupFilesMetadataName
+}
+
+// selectPartitions returns the indexes of the partitions with the files accepted by keepFile in the xbstream order
+func (metadata *XtrabackupFilesMetadata) selectPartitions(keepFile func(path string) bool) []int {
+	selected := make([]bool, len(metadata.Partitions))
+	for path, file := range metadata.Files {
+		if keepFile != nil && !keepFile(path) {
+			continue
+		}
+		for _, partition := range file.Partitions {
+			selected[partition] = true
+		}
+	}
+	partitions := make([]int, 0, len(metadata.Partitions))
+	for partition, isSelected := range selected {
+		if isSelected {
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions
+}
+
+// xtrabackupFilesUploader puts the xbstream chunks to the tar partitions, the next partition is started
+// once the current one exceeds the size threshold
+type xtrabackupFilesUploader struct {
+	ctx               context.Context
+	uploader          internal.Uploader
+	backupName        string
+	partSizeThreshold int64
+	metadata          XtrabackupFilesMetadata
+	checksums         map[string]*checksum.Calculator
+	uploads           *errgroup.Group
+
+	pipeWriter *io.PipeWriter
+	tarWriter  *tar.Writer
+	partSize   int64
+}
+
+func newXtrabackupFilesUploader(ctx context.Context, uploader internal.Uploader, backupName string,
+	partSizeThreshold int64) *xtrabackupFilesUploader {
+	uploads, ctx := errgroup.WithContext(ctx)
+	return &xtrabackupFilesUploader{
+		ctx:               ctx,
+		uploader:          uploader,
+		backupName:        backupName,
+		partSizeThreshold: partSizeThreshold,
+		metadata:          XtrabackupFilesMetadata{Files: make(map[string]*XtrabackupFileDescription)},
+		checksums:         make(map[string]*checksum.Calculator),
+		uploads:           uploads,
+	}
+}
+
+func (filesUploader *xtrabackupFilesUploader) startPartition() {
+	name := fmt.Sprintf("part_%0.3d.tar.%s", len(filesUploader.metadata.Partitions)+1,
+		filesUploader.uploader.Compression().FileExtension())
+	filesUploader.metadata.Partitions = append(filesUploader.metadata.Partitions, name)
+
+	pipeReader, pipeWriter := io.Pipe()
+	dstPath := filesUploader.backupName + internal.TarPartitionFolderName + name
+	filesUploader.uploads.Go(func() error {
+		err := filesUploader.uploader.PushStreamToDestination(filesUploader.ctx, pipeReader, dstPath)
+		// the writes fail with the upload error from now on
+		_ = pipeReader.CloseWithError(err)
+		return err
+	})
+	filesUploader.pipeWriter = pipeWriter
+	filesUploader.tarWriter = tar.NewWriter(pipeWriter)
+	filesUploader.partSize = 0
+}
+
+func (filesUploader *xtrabackupFilesUploader) finishPartition() error {
+	if filesUploader.tarWriter == nil {
+		return nil
+	}
+	err := filesUploader.tarWriter.Close()
+	filesUploader.tarWriter = nil
+	if err != nil {
+		_ = filesUploader.pipeWriter.CloseWithError(err)
+		return err
+	}
+	return filesUploader.pipeWriter.Close()
+}
+
+func (filesUploader *xtrabackupFilesUploader) addChunk(chunk *xbstreamChunkHeader, payload io.Reader) error {
+	if filesUploader.tarWriter == nil {
+		filesUploader.startPartition()
+	}
+	partition := len(filesUploader.metadata.Partitions) - 1
+	file, ok := filesUploader.metadata.Files[chunk.path]
+	if !ok {
+		file = &XtrabackupFileDescription{}
+		filesUploader.metadata.Files[chunk.path] = file
+		filesUploader.checksums[chunk.path] = checksum.CreateCalculator()
+	}
+	calculator := filesUploader.checksums[chunk.path]
+	if calculator == nil {
+		return fmt.Errorf("xbstream chunk of %s after its end", chunk.path)
+	}
+	if len(file.Partitions) == 0 || file.Partitions[len(file.Partitions)-1] != partition {
+		file.Partitions = append(file.Partitions, partition)
+	}
+
+	size := int64(len(chunk.raw)) + chunk.payloadLength
+	err := filesUploader.tarWriter.WriteHeader(&tar.Header{
+		Name:     chunk.path,
+		Mode:     0600,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = filesUploader.tarWriter.Write(chunk.raw); err != nil {
+		return err
+	}
+	_, err = io.CopyN(io.MultiWriter(filesUploader.tarWriter, calculator.Hash), payload, chunk.payloadLength)
+	if err != nil {
+		return fmt.Errorf("failed to copy xbstream chunk of %s: %w", chunk.path, err)
+	}
+	file.Size += chunk.payloadLength
+	file.Chunks++
+	if chunk.chunkType == xbstreamChunkTypeEOF {
+		file.SHA256 = calculator.Checksum()
+		delete(filesUploader.checksums, chunk.path)
+	}
+
+	filesUploader.partSize += size
+	if filesUploader.partSize >= filesUploader.partSizeThreshold {
+		return filesUploader.finishPartition()
+	}
+	return nil
+}
+
+// pushXtrabackupFiles demultiplexes the xbstream into the tar partitions and uploads them with the files manifest
+func pushXtrabackupFiles(ctx context.Context, uploader internal.Uploader, stream io.Reader,
+	partSizeThreshold int64) (string, error) {
+	backupName := internal.StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat)
+	filesUploader := newXtrabackupFilesUploader(ctx, uploader, backupName, partSizeThreshold)
+
+	err := filesUploader.demultiplex(bufio.NewReader(stream))
+	if err != nil {
+		if filesUploader.pipeWriter != nil {
+			_ = filesUploader.pipeWriter.CloseWithError(err)
+		}
+		_ = filesUploader.uploads.Wait()
+		return backupName, err
+	}
+	if err = filesUploader.uploads.Wait(); err != nil {
+		return backupName, err
+	}
+	for path := range filesUploader.checksums {
+		tracelog.WarningLogger.Printf("The xbstream has no end chunk of %s, its checksum is not saved", path)
+	}
+
+	err = internal.UploadDto(uploader.Folder(), &filesUploader.metadata, getXtrabackupFilesMetadataPath(backupName))
+	if err != nil {
+		return backupName, err
+	}
+	meta := internal.BackupStreamMetadata{
+		Type:        XtrabackupFilesBackup,
+		Partitions:  uint(len(filesUploader.metadata.Partitions)),
+		Compression: uploader.Compression().FileExtension(),
+	}
+	return backupName, internal.UploadBackupStreamMetadata(uploader, meta, backupName)
+}
+
+func (filesUploader *xtrabackupFilesUploader) demultiplex(reader io.Reader) error {
+	for {
+		chunk, err := readXbstreamChunkHeader(reader)
+		if errors.Is(err, io.EOF) {
+			return filesUploader.finishPartition()
+		}
+		if err != nil {
+			return err
+		}
+		if err = filesUploader.addChunk(chunk, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// partitionPrefetcher downloads the partitions ahead of the restore, at most concurrency partitions are
+// downloaded or wait for the restore at once
+type partitionPrefetcher struct {
+	results []chan prefetchedPartition
+	slots   chan struct{}
+	cancel  context.CancelFunc
+	wait    sync.WaitGroup
+	next    int
+}
+
+type prefetchedPartition struct {
+	path string
+	err  error
+}
+
+func newPartitionPrefetcher(count int, concurrency int, download func(i int) (string, error)) *partitionPrefetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	prefetcher := &partitionPrefetcher{
+		results: make([]chan prefetchedPartition, count),
+		slots:   make(chan struct{}, concurrency),
+		cancel:  cancel,
+	}
+	for i := range prefetcher.results {
+		prefetcher.results[i] = make(chan prefetchedPartition, 1)
+	}
+	prefetcher.wait.Add(1)
+	go func() {
+		defer prefetcher.wait.Done()
+		// the slots are taken in order, so the next partition to restore never waits for the later ones
+		for i := 0; i < count; i++ {
+			select {
+			case prefetcher.slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			prefetcher.wait.Add(1)
+			go func(i int) {
+				defer prefetcher.wait.Done()
+				path, err := download(i)
+				prefetcher.results[i] <- prefetchedPartition{path: path, err: err}
+			}(i)
+		}
+	}()
+	return prefetcher
+}
+
+// Next waits for the next partition to be downloaded
+func (prefetcher *partitionPrefetcher) Next() (string, error) {
+	result := <-prefetcher.results[prefetcher.next]
+	prefetcher.next++
+	return result.path, result.err
+}
+
+// Release removes the restored partition and lets the next one be downloaded
+func (prefetcher *partitionPrefetcher) Release(path string) {
+	if err := os.Remove(path); err != nil {
+		tracelog.WarningLogger.Printf("Failed to remove %s: %v", path, err)
+	}
+	<-prefetcher.slots
+}
+
+// Close stops the prefetching and waits for the started downloads
+func (prefetcher *partitionPrefetcher) Close() {
+	prefetcher.cancel()
+	prefetcher.wait.Wait()
+}
+
+// openBackupObject downloads, decrypts and decompresses the object of the backup
+func openBackupObject(folder storage.Folder, path string) (io.ReadCloser, error) {
+	decompressor := compression.FindDecompressor(strings.TrimPrefix(filepath.Ext(path), "."))
+	if decompressor == nil {
+		return nil, fmt.Errorf("decompressor for %s was not found", path)
+	}
+	archiveReader, exists, err := internal.TryDownloadFile(internal.NewFolderReader(folder), path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("object %s does not exist", path)
+	}
+	decompressedReader, err := internal.DecompressDecryptBytes(archiveReader, decompressor)
+	if err != nil {
+		utility.LoggedClose(archiveReader, "")
+		return nil, err
+	}
+	return ioextensions.ReadCascadeCloser{
+		Reader: decompressedReader,
+		Closer: ioextensions.NewMultiCloser([]io.Closer{archiveReader, decompressedReader}),
+	}, nil
+}
+
+func downloadXtrabackupPartition(folder storage.Folder, path, dstPath string) error {
+	reader, err := openBackupObject(folder, path)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+
+	file, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	if _, err = utility.FastCopy(file, reader); err != nil {
+		utility.LoggedClose(file, "")
+		return fmt.Errorf("failed to download %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// xtrabackupFilesVerifier checks the restored files against the manifest
+type xtrabackupFilesVerifier struct {
+	metadata  *XtrabackupFilesMetadata
+	checksums map[string]*checksum.Calculator
+	chunks    map[string]int
+}
+
+func newXtrabackupFilesVerifier(metadata *XtrabackupFilesMetadata) *xtrabackupFilesVerifier {
+	return &xtrabackupFilesVerifier{
+		metadata:  metadata,
+		checksums: make(map[string]*checksum.Calculator),
+		chunks:    make(map[string]int),
+	}
+}
+
+func (verifier *xtrabackupFilesVerifier) payloadWriter(path string) io.Writer {
+	calculator, ok := verifier.checksums[path]
+	if !ok {
+		calculator = checksum.CreateCalculator()
+		verifier.checksums[path] = calculator
+	}
+	verifier.chunks[path]++
+	return calculator.Hash
+}
+
+func (verifier *xtrabackupFilesVerifier) verifyFile(path string) error {
+	file, ok := verifier.metadata.Files[path]
+	if !ok {
+		return fmt.Errorf("file %s is not found in the files manifest", path)
+	}
+	if verifier.chunks[path] != file.Chunks {
+		return fmt.Errorf("file %s is restored from %d chunks, expected %d", path, verifier.chunks[path], file.Chunks)
+	}
+	calculator := verifier.checksums[path]
+	if file.SHA256 != "" && calculator.Checksum() != file.SHA256 {
+		return fmt.Errorf("checksum mismatch of file %s: expected %s, got %s", path, file.SHA256, calculator.Checksum())
+	}
+	return nil
+}
+
+// verifyRestored checks that the files accepted by keepFile are restored completely
+func (verifier *xtrabackupFilesVerifier) verifyRestored(keepFile func(path string) bool) error {
+	for path, file := range verifier.metadata.Files {
+		if keepFile != nil && !keepFile(path) {
+			continue
+		}
+		if verifier.chunks[path] != file.Chunks {
+			return fmt.Errorf("file %s is restored from %d chunks, expected %d", path, verifier.chunks[path], file.Chunks)
+		}
+	}
+	return nil
+}
+
+// restorePartition writes the xbstream chunks of the files accepted by keepFile from the tar partition
+func restorePartition(reader io.Reader, writer io.Writer, keepFile func(path string) bool,
+	verifier *xtrabackupFilesVerifier) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if keepFile != nil && !keepFile(header.Name) {
+			continue
+		}
+		chunk, err := readXbstreamChunkHeader(tarReader)
+		if err != nil {
+			return fmt.Errorf("failed to read the chunk of %s: %w", header.Name, err)
+		}
+		if chunk.path != header.Name {
+			return fmt.Errorf("chunk of %s is found in the tar entry %s", chunk.path, header.Name)
+		}
+		if _, err = writer.Write(chunk.raw); err != nil {
+			return err
+		}
+		_, err = io.CopyN(io.MultiWriter(writer, verifier.payloadWriter(chunk.path)), tarReader, chunk.payloadLength)
+		if err != nil {
+			return fmt.Errorf("failed to copy xbstream chunk of %s: %w", chunk.path, err)
+		}
+		if chunk.chunkType == xbstreamChunkTypeEOF {
+			if err = verifier.verifyFile(chunk.path); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fetchXtrabackupFiles rebuilds the xbstream of the files accepted by keepFile (all files if it is nil),
+// only the partitions with these files are downloaded
+func fetchXtrabackupFiles(backup internal.Backup, writer io.WriteCloser, keepFile func(path string) bool) error {
+	defer utility.LoggedClose(writer, "")
+
+	var metadata XtrabackupFilesMetadata
+	err := internal.FetchDto(backup.Folder, &metadata, getXtrabackupFilesMetadataPath(backup.Name))
+	if err != nil {
+		return fmt.Errorf("failed to fetch files manifest: %w", err)
+	}
+	concurrency, err := internal.GetMaxDownloadConcurrency()
+	if err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp("", "walg-xtrabackup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	partitions := metadata.selectPartitions(keepFile)
+	tracelog.InfoLogger.Printf("Downloading %d of %d partitions of %s", len(partitions), len(metadata.Partitions), backup.Name)
+	prefetcher := newPartitionPrefetcher(len(partitions), concurrency, func(i int) (string, error) {
+		name := metadata.Partitions[partitions[i]]
+		dstPath := filepath.Join(tempDir, name)
+		return dstPath, downloadXtrabackupPartition(backup.Folder, backup.Name+internal.TarPartitionFolderName+name, dstPath)
+	})
+	defer prefetcher.Close()
+
+	verifier := newXtrabackupFilesVerifier(&metadata)
+	for range partitions {
+		path, err := prefetcher.Next()
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = restorePartition(bufio.NewReader(file), writer, keepFile, verifier)
+		utility.LoggedClose(file, "")
+		prefetcher.Release(path)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", filepath.Base(path), err)
+		}
+	}
+	return verifier.verifyRestored(keepFile)
+}
+
+// fetchXtrabackupStream writes the xbstream of the backup to the writer, only the files accepted by keepFile
+// are written if it is set
+func fetchXtrabackupStream(backup internal.Backup, writer io.WriteCloser, keepFile func(path string) bool) error {
+	var metadata internal.BackupStreamMetadata
+	err := internal.FetchDto(backup.Folder, &metadata, internal.StreamMetadataNameFromBackup(backup.Name))
+	if err == nil && metadata.Type == XtrabackupFilesBackup {
+		return fetchXtrabackupFiles(backup, writer, keepFile)
+	}
+
+	fetcher, err := internal.GetBackupStreamFetcher(backup)
+	if err != nil {
+		utility.LoggedClose(writer, "")
+		return fmt.Errorf("failed to detect backup format: %w", err)
+	}
+	if keepFile == nil {
+		return fetcher(backup, writer)
+	}
+	filter := newXbstreamFilterWriter(writer, keepFile)
+	err = fetcher(backup, filter)
+	if closeErr := filter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+)
+
+func init() {
+	internal.ConfigureSettings(internal.MYSQL)
+	internal.InitConfig()
+}
+
+func pushTestXtrabackupFiles(t *testing.T, chunks [][]byte) internal.Backup {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder)
+	// every partition gets about two chunks
+	backupName, err := pushXtrabackupFiles(context.Background(), uploader, bytes.NewReader(bytes.Join(chunks, nil)), 64)
+	require.NoError(t, err)
+	backup, err := internal.NewBackup(folder, backupName)
+	require.NoError(t, err)
+	return backup
+}
+
+func TestXtrabackupFiles(t *testing.T) {
+	chunks := [][]byte{
+		xbstreamChunk('P', "ibdata1", []byte("system tablespace")),
+		xbstreamChunk('P', "shop/orders.ibd", []byte("orders")),
+		xbstreamChunk('P', "shop/customers.ibd", []byte("customers")),
+		xbstreamChunk(xbstreamChunkTypeSparse, "shop/customers.ibd", []byte("sparse"), 16384, 6),
+		xbstreamChunk('P', "shop/customers.ibd", []byte("more customers")),
+		xbstreamChunk(xbstreamChunkTypeEOF, "shop/customers.ibd", nil),
+		xbstreamChunk(xbstreamChunkTypeSparse, "shop/orders.ibd", []byte("sparse orders"), 16384, 13),
+		xbstreamChunk(xbstreamChunkTypeEOF, "shop/orders.ibd", nil),
+		xbstreamChunk(xbstreamChunkTypeEOF, "ibdata1", nil),
+	}
+	backup := pushTestXtrabackupFiles(t, chunks)
+
+	var metadata XtrabackupFilesMetadata
+	require.NoError(t, internal.FetchDto(backup.Folder, &metadata, getXtrabackupFilesMetadataPath(backup.Name)))
+	assert.Len(t, metadata.Partitions, 5)
+	assert.Equal(t, []int{1, 2, 3}, metadata.Files["shop/customers.ibd"].Partitions)
+	assert.Equal(t, int64(len("customers")+len("sparse")+len("more customers")), metadata.Files["shop/customers.ibd"].Size)
+	assert.Equal(t, 4, metadata.Files["shop/customers.ibd"].Chunks)
+	keep := newTablesFileFilter([]TableName{{"shop", "orders"}})
+	assert.Equal(t, []int{0, 3, 4}, metadata.selectPartitions(keep))
+
+	// the streaming fetch rebuilds the same xbstream
+	destination := &testWriteCloser{}
+	require.NoError(t, fetchXtrabackupStream(backup, destination, nil))
+	assert.True(t, destination.closed)
+	assert.Equal(t, bytes.Join(chunks, nil), destination.Bytes())
+
+	destination = &testWriteCloser{}
+	require.NoError(t, fetchXtrabackupStream(backup, destination, keep))
+	assert.Equal(t, bytes.Join([][]byte{chunks[0], chunks[1], chunks[6], chunks[7], chunks[8]}, nil), destination.Bytes())
+}
+
+func TestXtrabackupFiles_ChecksumMismatch(t *testing.T) {
+	backup := pushTestXtrabackupFiles(t, [][]byte{
+		xbstreamChunk('P', "ibdata1", []byte("system tablespace")),
+		xbstreamChunk(xbstreamChunkTypeEOF, "ibdata1", nil),
+	})
+
+	var metadata XtrabackupFilesMetadata
+	require.NoError(t, internal.FetchDto(backup.Folder, &metadata, getXtrabackupFilesMetadataPath(backup.Name)))
+	metadata.Files["ibdata1"].SHA256 = "0000"
+	require.NoError(t, internal.UploadDto(backup.Folder, &metadata, getXtrabackupFilesMetadataPath(backup.Name)))
+
+	err := fetchXtrabackupStream(backup, &testWriteCloser{}, nil)
+	assert.ErrorContains(t, err, "checksum mismatch of file ibdata1")
+}
@@ -0,0 +1,296 @@
+package mysql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	XtrabackupExport    = "--export"
+	XtrabackupTargetDir = "--target-dir"
+
+	xbstreamMagic           = "XBSTCK01"
+	xbstreamChunkTypeEOF    = 'E'
+	xbstreamChunkTypeSparse = 'S'
+)
+
+// xbstreamDirectoryArgument is the -C or --directory argument of the xbstream extracting the backup
+var xbstreamDirectoryArgument = regexp.MustCompile(`(?:^|\s)(?:-C|--directory)(?:=|\s+)(\S+)`)
+
+// TableName is the InnoDB table to restore, the names are the ones of the files in the datadir
+type TableName struct {
+	Database string
+	Table    string
+}
+
+func (table TableName) String() string {
+	return table.Database + "." + table.Table
+}
+
+// ParseTableNames parses the db.table names
+func ParseTableNames(names []string) ([]TableName, error) {
+	tables := make([]TableName, 0, len(names))
+	for _, name := range names {
+		database, table, found := strings.Cut(name, ".")
+		if !found || database == "" || table == "" || strings.Contains(table, ".") {
+			return nil, fmt.Errorf("table name '%s' should look like database.table", name)
+		}
+		tables = append(tables, TableName{Database: database, Table: table})
+	}
+	return tables, nil
+}
+
+// isTableFile reports whether the file in the database directory belongs to the table or to its partitions
+func (table TableName) isTableFile(database, filename string) bool {
+	if database != table.Database || !strings.HasPrefix(filename, table.Table) {
+		return false
+	}
+	rest := filename[len(table.Table):]
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "#p#") || strings.HasPrefix(rest, "#P#")
+}
+
+// newTablesFileFilter keeps the files of the tables and the files in the root of the backup,
+// the system tablespaces, the undo tablespaces and the redo log are needed to prepare the tables
+func newTablesFileFilter(tables []TableName) func(path string) bool {
+	return func(path string) bool {
+		database, filename, found := strings.Cut(strings.TrimPrefix(path, "./"), "/")
+		if !found {
+			return true
+		}
+		for _, table := range tables {
+			if table.isTableFile(database, filename) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type xbstreamChunkHeader struct {
+	raw           []byte
+	path          string
+	chunkType     byte
+	payloadLength int64
+}
+
+// readXbstreamChunkHeader reads the chunk up to its payload, see xbstream_read.cc for the chunk format,
+// io.EOF is returned at the end of the stream
+func readXbstreamChunkHeader(reader io.Reader) (*xbstreamChunkHeader, error) {
+	// magic, flags, type and path length
+	header := make([]byte, len(xbstreamMagic)+1+1+4)
+	_, err := io.ReadFull(reader, header)
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xbstream chunk: %w", err)
+	}
+	if string(header[:len(xbstreamMagic)]) != xbstreamMagic {
+		return nil, fmt.Errorf("wrong xbstream chunk magic %q", header[:len(xbstreamMagic)])
+	}
+	chunkType := header[len(xbstreamMagic)+1]
+	pathLength := binary.LittleEndian.Uint32(header[len(xbstreamMagic)+2:])
+	header, err = readMore(reader, header, int(pathLength))
+	if err != nil {
+		return nil, err
+	}
+	chunk := &xbstreamChunkHeader{path: string(header[len(header)-int(pathLength):]), chunkType: chunkType}
+
+	if chunkType != xbstreamChunkTypeEOF {
+		var sparseMapSize uint32
+		if chunkType == xbstreamChunkTypeSparse {
+			if header, err = readMore(reader, header, 4); err != nil {
+				return nil, err
+			}
+			sparseMapSize = binary.LittleEndian.Uint32(header[len(header)-4:])
+		}
+		// payload length, payload offset and checksum
+		if header, err = readMore(reader, header, 8+8+4); err != nil {
+			return nil, err
+		}
+		chunk.payloadLength = int64(binary.LittleEndian.Uint64(header[len(header)-20:]))
+		if header, err = readMore(reader, header, int(sparseMapSize)*8); err != nil {
+			return nil, err
+		}
+	}
+	chunk.raw = header
+	return chunk, nil
+}
+
+// filterXbstream copies the chunks of the files accepted by keep from the xbstream
+func filterXbstream(reader io.Reader, writer io.Writer, keep func(path string) bool) error {
+	bufReader := bufio.NewReader(reader)
+	for {
+		chunk, err := readXbstreamChunkHeader(bufReader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		destination := io.Discard
+		if keep(chunk.path) {
+			destination = writer
+		}
+		if _, err = destination.Write(chunk.raw); err != nil {
+			return err
+		}
+		if _, err = io.CopyN(destination, bufReader, chunk.payloadLength); err != nil {
+			return fmt.Errorf("failed to copy xbstream chunk of %s: %w", chunk.path, err)
+		}
+	}
+}
+
+func readMore(reader io.Reader, buffer []byte, length int) ([]byte, error) {
+	chunk := make([]byte, length)
+	_, err := io.ReadFull(reader, chunk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xbstream chunk: %w", err)
+	}
+	return append(buffer, chunk...), nil
+}
+
+// xbstreamFilterWriter passes only the files accepted by keep to the restore command
+type xbstreamFilterWriter struct {
+	pipeWriter *io.PipeWriter
+	done       chan error
+	closeOnce  sync.Once
+	err        error
+}
+
+func newXbstreamFilterWriter(destination io.WriteCloser, keep func(path string) bool) *xbstreamFilterWriter {
+	pipeReader, pipeWriter := io.Pipe()
+	filter := &xbstreamFilterWriter{pipeWriter: pipeWriter, done: make(chan error, 1)}
+	go func() {
+		err := filterXbstream(pipeReader, destination, keep)
+		closeErr := destination.Close()
+		if err == nil {
+			err = closeErr
+		}
+		// the writes fail with the filter error from now on
+		pipeReader.CloseWithError(err)
+		filter.done <- err
+	}()
+	return filter
+}
+
+func (filter *xbstreamFilterWriter) Write(p []byte) (int, error) {
+	return filter.pipeWriter.Write(p)
+}
+
+// Close waits for the filter to finish and returns its error
+func (filter *xbstreamFilterWriter) Close() error {
+	filter.closeOnce.Do(func() {
+		_ = filter.pipeWriter.Close()
+		filter.err = <-filter.done
+	})
+	return filter.err
+}
+
+// setCommandTargetDir points the restore command (its -C or --directory) and the prepare command (its --target-dir)
+// to the directory, the restore command which doesn't name its directory can't be redirected
+func setCommandTargetDir(restoreCmd, prepareCmd *exec.Cmd, targetDir string) error {
+	command := restoreCmd.Args[len(restoreCmd.Args)-1]
+	matches := xbstreamDirectoryArgument.FindAllStringSubmatchIndex(command, -1)
+	if len(matches) != 1 {
+		return fmt.Errorf("%s should set the directory to extract the backup to by one -C or --directory argument: '%s'",
+			internal.NameStreamRestoreCmd, command)
+	}
+	restoreCmd.Args[len(restoreCmd.Args)-1] = command[:matches[0][2]] + targetDir + command[matches[0][3]:]
+	if prepareCmd == nil {
+		return nil
+	}
+	prepareArgs := strings.Fields(prepareCmd.Args[len(prepareCmd.Args)-1])
+	for i, arg := range prepareArgs {
+		switch {
+		case strings.HasPrefix(arg, XtrabackupTargetDir+"="):
+			replaceCommandArgument(prepareCmd, arg, XtrabackupTargetDir+"="+targetDir)
+			return nil
+		case arg == XtrabackupTargetDir && i+1 < len(prepareArgs):
+			replaceCommandArgument(prepareCmd, arg+" "+prepareArgs[i+1], XtrabackupTargetDir+"="+targetDir)
+			return nil
+		}
+	}
+	injectCommandArgument(prepareCmd, XtrabackupTargetDir+"="+targetDir)
+	return nil
+}
+
+// HandleBackupFetchTables restores the tables of the xtrabackup backup to the target directory
+// and prepares them for the import by table-import, the tables (and the databases) of the logical backups
+// are restored to the server directly
+func HandleBackupFetchTables(folder storage.Folder,
+	targetBackupSelector internal.BackupSelector,
+	restoreCmd *exec.Cmd,
+	prepareCmd *exec.Cmd,
+	databases []string,
+	tables []TableName,
+	targetDir string) {
+	backup, err := targetBackupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalfOnError("Failed to get backup: %v", err)
+
+	var sentinel StreamSentinelDto
+	err = backup.FetchSentinel(&sentinel)
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch sentinel: %v", err)
+	if sentinel.Tool == WalgLogicalTool {
+		err = restoreLogicalBackup(backup, sentinel, databases, tables)
+		tracelog.ErrorLogger.FatalfOnError("Failed to restore backup: %v", err)
+		return
+	}
+	if sentinel.Tool != WalgXtrabackupTool {
+		tracelog.ErrorLogger.Fatalf("Tables can be restored from xtrabackup and logical backups only, backup %s is made by %s",
+			backup.Name, sentinel.Tool)
+	}
+	if len(databases) > 0 {
+		tracelog.ErrorLogger.Fatalf("Databases can be restored from logical backups only, backup %s is made by %s",
+			backup.Name, sentinel.Tool)
+	}
+	if targetDir == "" {
+		tracelog.ErrorLogger.Fatal("--tables-dir is required to restore the tables of xtrabackup backups")
+	}
+	if restoreCmd == nil {
+		tracelog.ErrorLogger.Fatalf("%s is required to restore the tables", internal.NameStreamRestoreCmd)
+	}
+	if prepareCmd == nil {
+		tracelog.ErrorLogger.Fatalf("%s is required to export the tables", internal.MysqlBackupPrepareCmd)
+	}
+
+	err = setCommandTargetDir(restoreCmd, prepareCmd, targetDir)
+	tracelog.ErrorLogger.FatalOnError(err)
+	err = xtrabackupFetch(backup.Name, folder, restoreCmd, prepareCmd, true, newTablesFileFilter(tables))
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v", err)
+	err = checkExportedTables(targetDir, tables)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.String())
+	}
+	tracelog.InfoLogger.Printf("Tables are exported to %s, import them with: wal-g table-import --from %s %s",
+		targetDir, targetDir, strings.Join(names, " "))
+}
+
+// checkExportedTables makes sure the tables are found in the backup
+func checkExportedTables(targetDir string, tables []TableName) error {
+	for _, table := range tables {
+		files, err := findTableFiles(targetDir, table, ".ibd")
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("table %s is not found in the backup", table)
+		}
+	}
+	return nil
+}
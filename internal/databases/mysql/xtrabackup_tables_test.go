@@ -0,0 +1,161 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *testWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func xbstreamChunk(chunkType byte, path string, payload []byte, sparseMap ...uint32) []byte {
+	chunk := append([]byte(xbstreamMagic), 0, chunkType)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(path)))
+	chunk = append(chunk, path...)
+	if chunkType == xbstreamChunkTypeEOF {
+		return chunk
+	}
+	if chunkType == xbstreamChunkTypeSparse {
+		chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(sparseMap)/2))
+	}
+	chunk = binary.LittleEndian.AppendUint64(chunk, uint64(len(payload)))
+	chunk = binary.LittleEndian.AppendUint64(chunk, 0)
+	chunk = binary.LittleEndian.AppendUint32(chunk, 0)
+	for _, value := range sparseMap {
+		chunk = binary.LittleEndian.AppendUint32(chunk, value)
+	}
+	return append(chunk, payload...)
+}
+
+func TestParseTableNames(t *testing.T) {
+	tables, err := ParseTableNames([]string{"shop.orders", "shop.order_items"})
+	require.NoError(t, err)
+	assert.Equal(t, []TableName{{"shop", "orders"}, {"shop", "order_items"}}, tables)
+
+	for _, name := range []string{"orders", ".orders", "shop.", "shop.orders.ibd"} {
+		_, err = ParseTableNames([]string{name})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestTablesFileFilter(t *testing.T) {
+	keep := newTablesFileFilter([]TableName{{"shop", "orders"}})
+	for path, expected := range map[string]bool{
+		"ibdata1":                      true,
+		"undo_001":                     true,
+		"xtrabackup_checkpoints":       true,
+		"./xtrabackup_logfile":         true,
+		"shop/orders.ibd":              true,
+		"shop/orders.ibd.delta":        true,
+		"shop/orders.ibd.meta":         true,
+		"shop/orders.ibd.zst":          true,
+		"shop/orders.frm":              true,
+		"shop/orders#p#p2023.ibd":      true,
+		"shop/orders#P#p2023.ibd":      true,
+		"shop/orders_archive.ibd":      false,
+		"shop/order_items.ibd":         false,
+		"crm/orders.ibd":               false,
+		"mysql/innodb_index_stats.ibd": false,
+	} {
+		assert.Equal(t, expected, keep(path), path)
+	}
+}
+
+func TestFilterXbstream(t *testing.T) {
+	chunks := [][]byte{
+		xbstreamChunk('P', "ibdata1", []byte("system tablespace")),
+		xbstreamChunk('P', "shop/orders.ibd", []byte("orders")),
+		xbstreamChunk('P', "shop/customers.ibd", []byte("customers")),
+		xbstreamChunk(xbstreamChunkTypeSparse, "shop/customers.ibd", []byte("sparse"), 16384, 6),
+		xbstreamChunk(xbstreamChunkTypeSparse, "shop/orders.ibd", []byte("sparse orders"), 16384, 13),
+		xbstreamChunk(xbstreamChunkTypeEOF, "shop/customers.ibd", nil),
+		xbstreamChunk(xbstreamChunkTypeEOF, "shop/orders.ibd", nil),
+		xbstreamChunk(xbstreamChunkTypeEOF, "ibdata1", nil),
+	}
+	keep := newTablesFileFilter([]TableName{{"shop", "orders"}})
+
+	destination := &testWriteCloser{}
+	filter := newXbstreamFilterWriter(destination, keep)
+	stream := bytes.Join(chunks, nil)
+	// the chunks are split between the writes
+	for len(stream) > 0 {
+		n := 7
+		if n > len(stream) {
+			n = len(stream)
+		}
+		_, err := filter.Write(stream[:n])
+		require.NoError(t, err)
+		stream = stream[n:]
+	}
+	require.NoError(t, filter.Close())
+
+	assert.True(t, destination.closed)
+	assert.Equal(t, bytes.Join([][]byte{chunks[0], chunks[1], chunks[4], chunks[6], chunks[7]}, nil), destination.Bytes())
+}
+
+func TestFilterXbstream_Truncated(t *testing.T) {
+	chunk := xbstreamChunk('P', "shop/orders.ibd", []byte("orders"))
+	err := filterXbstream(bytes.NewReader(chunk[:len(chunk)-2]), &bytes.Buffer{}, func(string) bool { return true })
+	assert.Error(t, err)
+	err = filterXbstream(bytes.NewReader([]byte("XBSTCK02 garbage")), &bytes.Buffer{}, func(string) bool { return true })
+	assert.Error(t, err)
+}
+
+func TestSetCommandTargetDir(t *testing.T) {
+	for prepare, expected := range map[string]string{
+		"xtrabackup --prepare --target-dir=/var/lib/mysql":                 "xtrabackup --prepare --target-dir=/tmp/tables",
+		"xtrabackup --prepare --target-dir /var/lib/mysql --use-memory=1G": "xtrabackup --prepare --target-dir=/tmp/tables --use-memory=1G",
+		"xtrabackup --prepare": "xtrabackup --prepare --target-dir=/tmp/tables",
+	} {
+		restoreCmd := exec.Command("/bin/sh", "-c", "xbstream -x -C /var/lib/mysql")
+		prepareCmd := exec.Command("/bin/sh", "-c", prepare)
+		require.NoError(t, setCommandTargetDir(restoreCmd, prepareCmd, "/tmp/tables"))
+		assert.Equal(t, "xbstream -x -C /tmp/tables", restoreCmd.Args[2])
+		assert.Equal(t, expected, prepareCmd.Args[2])
+	}
+
+	for restore, expected := range map[string]string{
+		"xbstream -x -C /var/lib/mysql --parallel=4":          "xbstream -x -C /tmp/tables --parallel=4",
+		"xbstream -x --directory=/var/lib/mysql":              "xbstream -x --directory=/tmp/tables",
+		"xbstream --directory /var/lib/mysql -x --decompress": "xbstream --directory /tmp/tables -x --decompress",
+	} {
+		restoreCmd := exec.Command("/bin/sh", "-c", restore)
+		require.NoError(t, setCommandTargetDir(restoreCmd, nil, "/tmp/tables"))
+		assert.Equal(t, expected, restoreCmd.Args[2])
+	}
+	for _, restore := range []string{"cd /var/lib/mysql && xbstream -x", "xbstream -x -C /a | xbstream -x -C /b"} {
+		restoreCmd := exec.Command("/bin/sh", "-c", restore)
+		assert.Error(t, setCommandTargetDir(restoreCmd, nil, "/tmp/tables"))
+		assert.Equal(t, restore, restoreCmd.Args[2])
+	}
+}
+
+func TestFindTableFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "shop"), 0755))
+	for _, name := range []string{"orders#p#p2022.ibd", "orders#p#p2023.ibd", "orders#p#p2023.cfg", "orders_archive.ibd"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "shop", name), nil, 0600))
+	}
+	files, err := findTableFiles(dir, TableName{"shop", "orders"}, ".ibd")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders#p#p2022.ibd", "orders#p#p2023.ibd"}, files)
+
+	files, err = findTableFiles(dir, TableName{"crm", "orders"}, ".ibd")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	assert.Error(t, checkExportedTables(dir, []TableName{{"shop", "orders"}, {"crm", "orders"}}))
+}
@@ -46,3 +46,48 @@ func TestReadXtrabackupInfo(t *testing.T) {
 	assert.Equal(t, uint64(3738001), uint64(*info.ToLSN))
 	assert.Equal(t, uint64(3738068), uint64(*info.LastLSN))
 }
+
+const xtrabackup_info_example = `
+uuid = 4b3e9c58-6d1a-11ee-a7a4-0242ac120002
+tool_command = --backup --stream=xbstream --extra-lsndir=/tmp/wal-g123
+binlog_pos = filename 'mysql-bin.000042', position '1337', GTID of the last change '8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,
+9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7'
+innodb_from_lsn = 0`
+
+func TestParseXtrabackupBinlogPos(t *testing.T) {
+	var info XtrabackupInfo
+	parseXtrabackupBinlogPos(xtrabackup_info_example, &info)
+	assert.Equal(t, "mysql-bin.000042", info.BinLogFile)
+	assert.Equal(t, uint64(1337), info.BinLogPosition)
+	assert.Equal(t, "8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7", info.GTIDExecuted)
+
+	info = XtrabackupInfo{}
+	parseXtrabackupBinlogPos("binlog_pos = filename 'mysql-bin.000001', position '4'", &info)
+	assert.Equal(t, "mysql-bin.000001", info.BinLogFile)
+	assert.Equal(t, uint64(4), info.BinLogPosition)
+	assert.Empty(t, info.GTIDExecuted)
+
+	info = XtrabackupInfo{}
+	parseXtrabackupBinlogPos("tool_name = xtrabackup", &info)
+	assert.Equal(t, XtrabackupInfo{}, info)
+}
+
+func TestGetBackupPrepareCommand(t *testing.T) {
+	prepare := "xtrabackup --prepare --target-dir=/var/lib/mysql"
+	prepareCmd := exec.Command("/bin/sh", "-c", prepare)
+
+	// the chain of base, inc1 and inc2 is prepared from the base one
+	baseCmd := getBackupPrepareCommand(prepareCmd, false, "/tmp/base", false, false)
+	inc1Cmd := getBackupPrepareCommand(prepareCmd, true, "/tmp/inc1", false, false)
+	inc2Cmd := getBackupPrepareCommand(prepareCmd, true, "/tmp/inc2", true, false)
+
+	assert.Equal(t, prepare+" --apply-log-only", baseCmd.Args[2])
+	assert.Equal(t, prepare+" --incremental-dir=/tmp/inc1 --apply-log-only", inc1Cmd.Args[2])
+	// the last prepare rolls back the uncommitted transactions
+	assert.Equal(t, prepare+" --incremental-dir=/tmp/inc2", inc2Cmd.Args[2])
+	assert.Equal(t, prepare, prepareCmd.Args[2])
+
+	partialCmd := getBackupPrepareCommand(prepareCmd, false, "/tmp/base", true, true)
+	assert.Equal(t, prepare+" --export", partialCmd.Args[2])
+	assert.Nil(t, getBackupPrepareCommand(nil, true, "/tmp/inc1", false, false))
+}
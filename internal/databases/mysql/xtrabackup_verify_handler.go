@@ -0,0 +1,400 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/wal-g/tracelog"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	verifyServerSocketName      = "mysqld.sock"
+	verifyServerPidFileName     = "mysqld.pid"
+	verifyServerErrorLogName    = "mysqld.err"
+	verifyServerShutdownTimeout = 5 * time.Minute
+	// --skip-slave-start is renamed to --skip-replica-start in MySQL 8.0.26
+	skipReplicaStartMinMySQLVersion = "v8.0.26"
+)
+
+// verifyServer is the throwaway mysqld started on the restored data directory
+type verifyServer struct {
+	cmd      *exec.Cmd
+	socket   string
+	errorLog string
+	exited   chan error
+}
+
+// getFreePort returns the port which is not listened on at the moment
+func getFreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer utility.LoggedClose(listener, "")
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// verifyServerArgs are the options of the throwaway mysqld: it listens on localhost only,
+// does not start the replication and does not check the grants, so it is connected to by the socket as root.
+// MySQL reports gtid_executed with gtid_mode ON only, so it's enabled when the GTID set of the backup is known.
+func verifyServerArgs(dataDir, scratchDir string, port int, version string, withGTIDs bool) []string {
+	isMariaDB := strings.Contains(version, "MariaDB")
+	skipReplicaStart := "--skip-slave-start"
+	if !isMariaDB && isMySQLVersionAtLeast(version, skipReplicaStartMinMySQLVersion) {
+		skipReplicaStart = "--skip-replica-start"
+	}
+	args := []string{
+		"--datadir=" + dataDir,
+		"--port=" + strconv.Itoa(port),
+		"--bind-address=127.0.0.1",
+		"--socket=" + filepath.Join(scratchDir, verifyServerSocketName),
+		"--pid-file=" + filepath.Join(scratchDir, verifyServerPidFileName),
+		"--log-error=" + filepath.Join(scratchDir, verifyServerErrorLogName),
+		"--skip-grant-tables",
+		skipReplicaStart,
+	}
+	if withGTIDs && !isMariaDB {
+		args = append(args, "--gtid-mode=ON", "--enforce-gtid-consistency=ON")
+	}
+	return args
+}
+
+func startVerifyServer(mysqldCmd *exec.Cmd, dataDir, scratchDir, version string, withGTIDs bool) (*verifyServer, error) {
+	port, err := getFreePort()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	for _, arg := range verifyServerArgs(dataDir, scratchDir, port, version, withGTIDs) {
+		injectCommandArgument(mysqldCmd, arg)
+	}
+	tracelog.InfoLogger.Printf("Starting mysqld with cmd %v", mysqldCmd.Args)
+	if err = mysqldCmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mysqld: %w", err)
+	}
+	server := &verifyServer{
+		cmd:      mysqldCmd,
+		socket:   filepath.Join(scratchDir, verifyServerSocketName),
+		errorLog: filepath.Join(scratchDir, verifyServerErrorLogName),
+		exited:   make(chan error, 1),
+	}
+	go func() {
+		server.exited <- mysqldCmd.Wait()
+	}()
+	return server, nil
+}
+
+// connect waits for the server to accept the connections, the crash recovery may take a while
+func (server *verifyServer) connect(timeout time.Duration) (*sql.DB, error) {
+	db, err := sql.Open("mysql", "root@unix("+server.socket+")/")
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if err = db.Ping(); err == nil {
+			return db, nil
+		}
+		select {
+		case exitErr := <-server.exited:
+			// stop waits for the exit too
+			server.exited <- exitErr
+			utility.LoggedClose(db, "")
+			return nil, fmt.Errorf("mysqld exited before accepting connections: %v, see %s", exitErr, server.errorLog)
+		case <-deadline:
+			utility.LoggedClose(db, "")
+			return nil, fmt.Errorf("mysqld does not accept connections in %v: %w, see %s", timeout, err, server.errorLog)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop shuts the server down, it is killed if it is not connected to or does not stop in time
+func (server *verifyServer) stop(db *sql.DB) {
+	timeout := time.Duration(0)
+	if db != nil {
+		if _, err := db.Exec("SHUTDOWN"); err != nil {
+			tracelog.WarningLogger.Printf("Failed to shut mysqld down: %v", err)
+		} else {
+			timeout = verifyServerShutdownTimeout
+		}
+		utility.LoggedClose(db, "")
+	}
+	select {
+	case <-server.exited:
+		return
+	case <-time.After(timeout):
+	}
+	tracelog.WarningLogger.Printf("Killing mysqld")
+	if err := server.cmd.Process.Kill(); err != nil {
+		tracelog.ErrorLogger.Printf("Failed to kill mysqld: %v", err)
+		return
+	}
+	<-server.exited
+}
+
+func listVerifyTables(db *sql.DB) ([]TableName, error) {
+	rows, err := db.Query("SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES " +
+		"WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('information_schema', 'performance_schema')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer utility.LoggedClose(rows, "")
+	var tables []TableName
+	for rows.Next() {
+		var table TableName
+		if err = rows.Scan(&table.Database, &table.Table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// sampleTables chooses the sample of the tables, all the tables are chosen if the sample is not positive
+func sampleTables(tables []TableName, sample int, random *rand.Rand) []TableName {
+	chosen := append([]TableName(nil), tables...)
+	if sample > 0 && sample < len(chosen) {
+		random.Shuffle(len(chosen), func(i, j int) {
+			chosen[i], chosen[j] = chosen[j], chosen[i]
+		})
+		chosen = chosen[:sample]
+	}
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].String() < chosen[j].String()
+	})
+	return chosen
+}
+
+// isCheckTableFailure tells if the row of the CHECK TABLE result reports the problem
+func isCheckTableFailure(msgType, msgText string) bool {
+	switch strings.ToLower(msgType) {
+	case "error":
+		return true
+	case "status":
+		return msgText != "OK"
+	}
+	return false
+}
+
+// checkTable runs CHECK TABLE and returns its error and status messages if the table is not OK
+func checkTable(db *sql.DB, table TableName) ([]string, error) {
+	rows, err := db.Query("CHECK TABLE " + quoteIdentifier(table.Database) + "." + quoteIdentifier(table.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(rows, "")
+	var problems []string
+	for rows.Next() {
+		var name, operation, msgType, msgText string
+		if err = rows.Scan(&name, &operation, &msgType, &msgText); err != nil {
+			return nil, err
+		}
+		if isCheckTableFailure(msgType, msgText) {
+			problems = append(problems, msgType+": "+msgText)
+		}
+	}
+	return problems, rows.Err()
+}
+
+func checkTables(db *sql.DB, tables []TableName) error {
+	failed := 0
+	for _, table := range tables {
+		problems, err := checkTable(db, table)
+		if err != nil {
+			return fmt.Errorf("failed to check table %s: %w", table, err)
+		}
+		if len(problems) > 0 {
+			failed++
+			tracelog.ErrorLogger.Printf("Table %s is not OK: %s", table, strings.Join(problems, "; "))
+			continue
+		}
+		tracelog.DebugLogger.Printf("Table %s is OK", table)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checked tables are not OK", failed, len(tables))
+	}
+	return nil
+}
+
+// parseXtrabackupBinlogInfo reads the GTID set from xtrabackup_binlog_info: the binlog file,
+// the position and the GTID set, which may span several lines
+func parseXtrabackupBinlogInfo(content string) string {
+	fields := strings.Fields(content)
+	if len(fields) < 3 {
+		return ""
+	}
+	return strings.Join(fields[2:], "")
+}
+
+// getExpectedGTIDExecuted returns the GTID set of the backup, the backups made before it was saved to the sentinel
+// have it in xtrabackup_binlog_info of the restored data directory
+func getExpectedGTIDExecuted(sentinel StreamSentinelDto, dataDir string) (string, error) {
+	if sentinel.GTIDExecuted != "" {
+		return sentinel.GTIDExecuted, nil
+	}
+	raw, err := os.ReadFile(filepath.Join(dataDir, "xtrabackup_binlog_info"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return parseXtrabackupBinlogInfo(string(raw)), nil
+}
+
+// compareGTIDExecuted compares gtid_executed of the restored server with the GTID set of the backup and reports
+// whether it is checked: the server doesn't rebuild gtid_executed when it is started without the binlogs
+// (e.g. MySQL 5.7), so the empty set is not checked, and the mismatch is not an error when gtid_mode is OFF
+func compareGTIDExecuted(expected, actual gomysql.GTIDSet, gtidMode string) (bool, error) {
+	switch {
+	case actual.String() == "":
+		tracelog.WarningLogger.Printf("The restored server doesn't report gtid_executed, it is not checked")
+		return false, nil
+	case actual.Equal(expected):
+		return true, nil
+	case !strings.HasPrefix(gtidMode, "ON"):
+		tracelog.WarningLogger.Printf("gtid_executed of the restored server with gtid_mode %s is '%s', expected '%s', "+
+			"it is not checked", gtidMode, actual, expected)
+		return false, nil
+	}
+	return false, fmt.Errorf("GTID set of the restored backup is '%s', expected '%s'", actual, expected)
+}
+
+func checkGTIDExecuted(db *sql.DB, expected string) (bool, error) {
+	flavor, err := getMySQLFlavor(db)
+	if err != nil {
+		return false, err
+	}
+	expectedSet, err := gomysql.ParseGTIDSet(flavor, expected)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the GTID set of the backup '%s': %w", expected, err)
+	}
+	actualSet, err := getMySQLGTIDExecuted(db, flavor)
+	if err != nil {
+		return false, err
+	}
+	// MariaDB has no gtid_mode, its GTIDs are always on
+	gtidMode := "ON"
+	if flavor == gomysql.MySQLFlavor {
+		if err = db.QueryRow("SELECT @@global.gtid_mode").Scan(&gtidMode); err != nil {
+			return false, fmt.Errorf("failed to get gtid_mode: %w", err)
+		}
+	}
+	return compareGTIDExecuted(expectedSet, actualSet, gtidMode)
+}
+
+// verifyXtrabackup restores and checks the backup, it reports whether the GTID set of the backup is checked too
+func verifyXtrabackup(folder storage.Folder, backup internal.Backup, sentinel StreamSentinelDto,
+	restoreCmd, prepareCmd, mysqldCmd *exec.Cmd, scratchDir string, sample int, startupTimeout time.Duration) (bool, error) {
+	dataDir := filepath.Join(scratchDir, "data")
+	if err := os.Mkdir(dataDir, 0750); err != nil {
+		return false, err
+	}
+	if err := setCommandTargetDir(restoreCmd, prepareCmd, dataDir); err != nil {
+		return false, err
+	}
+	if err := xtrabackupFetch(backup.Name, folder, restoreCmd, prepareCmd, true, nil); err != nil {
+		return false, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	expectedGTIDExecuted, err := getExpectedGTIDExecuted(sentinel, dataDir)
+	if err != nil {
+		return false, err
+	}
+
+	server, err := startVerifyServer(mysqldCmd, dataDir, scratchDir, sentinel.ServerVersion, expectedGTIDExecuted != "")
+	if err != nil {
+		return false, err
+	}
+	db, err := server.connect(startupTimeout)
+	defer server.stop(db)
+	if err != nil {
+		return false, err
+	}
+
+	tables, err := listVerifyTables(db)
+	if err != nil {
+		return false, err
+	}
+	chosen := sampleTables(tables, sample, rand.New(rand.NewSource(time.Now().UnixNano())))
+	tracelog.InfoLogger.Printf("Checking %d of %d tables", len(chosen), len(tables))
+	if err = checkTables(db, chosen); err != nil {
+		return false, err
+	}
+
+	if expectedGTIDExecuted == "" {
+		tracelog.WarningLogger.Printf("GTID set of %s is not known, it is not checked", backup.Name)
+		return false, nil
+	}
+	checked, err := checkGTIDExecuted(db, expectedGTIDExecuted)
+	if err != nil {
+		return false, err
+	}
+	if checked {
+		tracelog.InfoLogger.Printf("GTID set of the restored backup is '%s'", expectedGTIDExecuted)
+	}
+	return checked, nil
+}
+
+// HandleXtrabackupVerify restores the xtrabackup backup (with its incremental chain) to the scratch directory,
+// starts the throwaway mysqld on it, checks the tables and compares the GTID set with the backup's one.
+// The time of the successful verification is saved to the backup sentinel along with whether the GTID set is checked.
+func HandleXtrabackupVerify(folder storage.Folder,
+	targetBackupSelector internal.BackupSelector,
+	restoreCmd *exec.Cmd,
+	prepareCmd *exec.Cmd,
+	mysqldCmd *exec.Cmd,
+	scratchDirRoot string,
+	sample int,
+	startupTimeout time.Duration) {
+	backup, err := targetBackupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalfOnError("Failed to get backup: %v", err)
+
+	var sentinel StreamSentinelDto
+	err = backup.FetchSentinel(&sentinel)
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch sentinel: %v", err)
+	if sentinel.Tool != WalgXtrabackupTool {
+		tracelog.ErrorLogger.Fatalf("Only xtrabackup backups can be verified, backup %s is made by %s", backup.Name, sentinel.Tool)
+	}
+
+	scratchDir, err := prepareTemporaryDirectory(scratchDirRoot)
+	tracelog.ErrorLogger.FatalfOnError("Failed to prepare scratch dir: %v", err)
+	tracelog.InfoLogger.Printf("Verifying %s in %s", backup.Name, scratchDir)
+	gtidVerified, err := verifyXtrabackup(folder, backup, sentinel, restoreCmd, prepareCmd, mysqldCmd,
+		scratchDir, sample, startupTimeout)
+	if removeErr := removeTemporaryDirectory(scratchDir); removeErr != nil {
+		tracelog.ErrorLogger.Printf("Failed to remove scratch dir: %v", removeErr)
+	}
+	if err != nil {
+		tracelog.ErrorLogger.Fatalf("Backup %s is not verified: %v", backup.Name, err)
+	}
+
+	verifiedAt := utility.TimeNowCrossPlatformUTC()
+	err = modifyBackupSentinel(backup.Name, backup.Folder, func(dto StreamSentinelDto) StreamSentinelDto {
+		dto.VerifiedAt = &verifiedAt
+		dto.GTIDVerified = &gtidVerified
+		return dto
+	})
+	tracelog.ErrorLogger.FatalfOnError("Failed to save the verification time: %v", err)
+	if !gtidVerified {
+		tracelog.WarningLogger.Printf("Backup %s is verified at %s without its GTID set",
+			backup.Name, verifiedAt.Format(time.RFC3339))
+		return
+	}
+	tracelog.InfoLogger.Printf("Backup %s is verified at %s", backup.Name, verifiedAt.Format(time.RFC3339))
+}
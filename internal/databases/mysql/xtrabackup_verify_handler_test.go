@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyServerArgs(t *testing.T) {
+	args := verifyServerArgs("/tmp/wal-g1/data", "/tmp/wal-g1", 33061, "8.0.35-27", false)
+	assert.Equal(t, []string{
+		"--datadir=/tmp/wal-g1/data",
+		"--port=33061",
+		"--bind-address=127.0.0.1",
+		"--socket=/tmp/wal-g1/mysqld.sock",
+		"--pid-file=/tmp/wal-g1/mysqld.pid",
+		"--log-error=/tmp/wal-g1/mysqld.err",
+		"--skip-grant-tables",
+		"--skip-replica-start",
+	}, args)
+	assert.Contains(t, verifyServerArgs("/d", "/s", 1, "5.7.40-log", false), "--skip-slave-start")
+	assert.Contains(t, verifyServerArgs("/d", "/s", 1, "10.11.5-MariaDB", false), "--skip-slave-start")
+
+	// gtid_executed is reported with gtid_mode ON only, MariaDB has no gtid_mode
+	args = verifyServerArgs("/d", "/s", 1, "5.7.40-log", true)
+	assert.Contains(t, args, "--gtid-mode=ON")
+	assert.Contains(t, args, "--enforce-gtid-consistency=ON")
+	assert.NotContains(t, verifyServerArgs("/d", "/s", 1, "10.11.5-MariaDB", true), "--gtid-mode=ON")
+}
+
+func TestSampleTables(t *testing.T) {
+	tables := []TableName{{"shop", "orders"}, {"crm", "clients"}, {"shop", "items"}, {"blog", "posts"}}
+	all := []TableName{{"blog", "posts"}, {"crm", "clients"}, {"shop", "items"}, {"shop", "orders"}}
+	assert.Equal(t, all, sampleTables(tables, 0, rand.New(rand.NewSource(1))))
+	assert.Equal(t, all, sampleTables(tables, 10, rand.New(rand.NewSource(1))))
+
+	sample := sampleTables(tables, 2, rand.New(rand.NewSource(1)))
+	assert.Len(t, sample, 2)
+	assert.NotEqual(t, sample[0], sample[1])
+	assert.Subset(t, tables, sample)
+	// the tables are not changed by the sampling
+	assert.Equal(t, TableName{"shop", "orders"}, tables[0])
+}
+
+func TestIsCheckTableFailure(t *testing.T) {
+	assert.False(t, isCheckTableFailure("status", "OK"))
+	assert.False(t, isCheckTableFailure("note", "The storage engine for the table doesn't support check"))
+	assert.False(t, isCheckTableFailure("warning", "InnoDB: The B-tree of index PRIMARY is corrupted."))
+	assert.True(t, isCheckTableFailure("status", "Corrupt"))
+	assert.True(t, isCheckTableFailure("Error", "Table 'shop.orders' doesn't exist"))
+}
+
+func TestGetExpectedGTIDExecuted(t *testing.T) {
+	dataDir := t.TempDir()
+	gtidExecuted, err := getExpectedGTIDExecuted(StreamSentinelDto{}, dataDir)
+	require.NoError(t, err)
+	assert.Empty(t, gtidExecuted)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "xtrabackup_binlog_info"),
+		[]byte("mysql-bin.000042\t1337\t8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,\n9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7\n"),
+		0640))
+	gtidExecuted, err = getExpectedGTIDExecuted(StreamSentinelDto{}, dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, "8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100,9b4e5f56-0c4a-11ee-8d7e-0242ac120002:1-7", gtidExecuted)
+
+	// the sentinel takes precedence
+	gtidExecuted, err = getExpectedGTIDExecuted(StreamSentinelDto{GTIDExecuted: "8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-5"}, dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, "8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-5", gtidExecuted)
+
+	assert.Empty(t, parseXtrabackupBinlogInfo("mysql-bin.000042\t1337\n"))
+}
+
+func TestCompareGTIDExecuted(t *testing.T) {
+	parse := func(gtids string) gomysql.GTIDSet {
+		set, err := gomysql.ParseMysqlGTIDSet(gtids)
+		require.NoError(t, err)
+		return set
+	}
+	expected := parse("8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100")
+
+	checked, err := compareGTIDExecuted(expected, parse("8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-100"), "ON")
+	require.NoError(t, err)
+	assert.True(t, checked)
+
+	_, err = compareGTIDExecuted(expected, parse("8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-99"), "ON")
+	assert.Error(t, err)
+
+	// the server without the binlogs doesn't rebuild gtid_executed
+	checked, err = compareGTIDExecuted(expected, parse(""), "ON")
+	require.NoError(t, err)
+	assert.False(t, checked)
+	checked, err = compareGTIDExecuted(expected, parse("8a4e5f56-0c4a-11ee-8d7e-0242ac120002:1-99"), "OFF")
+	require.NoError(t, err)
+	assert.False(t, checked)
+}
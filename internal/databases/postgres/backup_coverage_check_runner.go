@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+type BackupCoverageCheckDetails []*BackupCoverage
+
+func (coverages BackupCoverageCheckDetails) NewPlainTextReader() (io.Reader, error) {
+	var outputBuffer bytes.Buffer
+
+	tableWriter := table.NewWriter()
+	tableWriter.SetOutputMirror(&outputBuffer)
+	defer tableWriter.Render()
+
+	tableWriter.AppendHeader(table.Row{"Backup", "TLI", "Start", "End", "Missing segments", "Status"})
+	for _, row := range coverages {
+		tableWriter.AppendRow(table.Row{row.BackupName, row.TimelineID,
+			row.StartSegment, row.EndSegment, len(row.MissingSegments), row.Status})
+	}
+
+	return &outputBuffer, nil
+}
+
+// BackupCoverage is the WAL range required to make the backup consistent
+type BackupCoverage struct {
+	BackupName      string               `json:"backup_name"`
+	TimelineID      uint32               `json:"timeline_id"`
+	StartSegment    string               `json:"start_segment"`
+	EndSegment      string               `json:"end_segment"`
+	MissingSegments []string             `json:"missing_segments"`
+	Status          ScannedSegmentStatus `json:"status"`
+}
+
+// BackupCoverageCheckRunner checks that every backup in storage has the WAL segments
+// from its start LSN to its finish LSN on its timeline, so the backup can be restored
+type BackupCoverageCheckRunner struct {
+	currentWalSegment         WalSegmentDescription
+	uploadingSegmentRangeSize int
+	walFolderFilenames        []string
+	backups                   []BackupDetail
+}
+
+func NewBackupCoverageCheckRunner(
+	rootFolder storage.Folder,
+	walFolderFilenames []string,
+	currentWalSegment WalSegmentDescription,
+) (BackupCoverageCheckRunner, error) {
+	backups, err := getTimelineBackups(rootFolder)
+	if err != nil {
+		return BackupCoverageCheckRunner{}, errors.Wrap(err, "Failed to get backups")
+	}
+
+	// the missing segments of the latest backups may be still uploading
+	uploadingSegmentRangeSize, err := internal.GetMaxUploadConcurrency()
+	if err != nil {
+		return BackupCoverageCheckRunner{}, errors.Wrap(err, "Failed to resolve MaxUploadConcurrency")
+	}
+
+	return BackupCoverageCheckRunner{
+		currentWalSegment:         currentWalSegment,
+		uploadingSegmentRangeSize: uploadingSegmentRangeSize,
+		walFolderFilenames:        walFolderFilenames,
+		backups:                   backups,
+	}, nil
+}
+
+func (check BackupCoverageCheckRunner) Run() (WalVerifyCheckResult, error) {
+	storageSegments := getSegmentsFromFiles(check.walFolderFilenames)
+
+	coverages := make([]*BackupCoverage, 0, len(check.backups))
+	for i := range check.backups {
+		coverage, err := check.checkBackup(&check.backups[i], storageSegments)
+		if err != nil {
+			return WalVerifyCheckResult{}, err
+		}
+		coverages = append(coverages, coverage)
+	}
+	sort.Slice(coverages, func(i, j int) bool {
+		return coverages[i].StartSegment < coverages[j].StartSegment
+	})
+
+	return newBackupCoverageCheckResult(coverages), nil
+}
+
+func (check BackupCoverageCheckRunner) Type() WalVerifyCheckType {
+	return WalVerifyBackupCoverageCheck
+}
+
+func (check BackupCoverageCheckRunner) checkBackup(backup *BackupDetail,
+	storageSegments map[WalSegmentDescription]bool) (*BackupCoverage, error) {
+	timeline, startSegmentNo, err := ParseWALFilename(backup.WalFileName)
+	if err != nil {
+		return nil, err
+	}
+	startSegment := WalSegmentNo(startSegmentNo)
+	endSegment := startSegment
+	// the finish LSN points to the end of the last WAL record the backup requires
+	if backup.FinishLsn > 0 && NewWalSegmentNo(backup.FinishLsn-1) > startSegment {
+		endSegment = NewWalSegmentNo(backup.FinishLsn - 1)
+	}
+
+	coverage := &BackupCoverage{
+		BackupName:      backup.BackupName,
+		TimelineID:      timeline,
+		StartSegment:    startSegment.GetFilename(timeline),
+		EndSegment:      endSegment.GetFilename(timeline),
+		MissingSegments: make([]string, 0),
+		Status:          Found,
+	}
+	for segmentNo := startSegment; segmentNo <= endSegment; segmentNo = segmentNo.Next() {
+		segment := WalSegmentDescription{Timeline: timeline, Number: segmentNo}
+		if storageSegments[segment] {
+			continue
+		}
+		coverage.MissingSegments = append(coverage.MissingSegments, segment.GetFileName())
+		if check.isProbablyUploading(segment) {
+			if coverage.Status == Found {
+				coverage.Status = ProbablyUploading
+			}
+		} else {
+			coverage.Status = Lost
+		}
+	}
+	return coverage, nil
+}
+
+func (check BackupCoverageCheckRunner) isProbablyUploading(segment WalSegmentDescription) bool {
+	return segment.Timeline == check.currentWalSegment.Timeline &&
+		segment.Number.add(uint64(check.uploadingSegmentRangeSize)) >= check.currentWalSegment.Number
+}
+
+// newBackupCoverageCheckResult check produces the WalVerifyCheckResult with status:
+// StatusOk if all backups have the WAL segments they require
+// StatusWarning if no backups found or the latest backups miss segments which are probably still uploading
+// StatusFailure if some backup misses Lost segments
+func newBackupCoverageCheckResult(coverages []*BackupCoverage) WalVerifyCheckResult {
+	result := WalVerifyCheckResult{
+		Status:  StatusOk,
+		Details: BackupCoverageCheckDetails(coverages),
+	}
+	if len(coverages) == 0 {
+		result.Status = StatusWarning
+		return result
+	}
+	for _, coverage := range coverages {
+		switch coverage.Status {
+		case Lost:
+			result.Status = StatusFailure
+			return result
+		case ProbablyUploading:
+			result.Status = StatusWarning
+		}
+	}
+	return result
+}
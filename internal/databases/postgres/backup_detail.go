@@ -3,6 +3,7 @@ package postgres
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/wal-g/wal-g/internal"
 	"github.com/wal-g/wal-g/internal/printlist"
@@ -60,5 +61,28 @@ func (bd *BackupDetail) PrintableFields() []printlist.TableField {
 			PrettyName: "Permanent",
 			Value:      fmt.Sprintf("%v", bd.IsPermanent),
 		},
+		bd.sizeByDatabaseField(),
 	)
 }
+
+// sizeByDatabaseField lists the compressed sizes of the databases from the largest one
+func (bd *BackupDetail) sizeByDatabaseField() printlist.TableField {
+	field := printlist.TableField{
+		Name:       "size_by_database",
+		PrettyName: "Size by database",
+	}
+	if bd.SizeStats == nil {
+		return field
+	}
+	values := make([]string, 0, len(bd.SizeStats.Databases))
+	prettyValues := make([]string, 0, len(bd.SizeStats.Databases))
+	for _, name := range bd.SizeStats.sortedDatabases() {
+		compressedSize := bd.SizeStats.Databases[name].CompressedSize
+		values = append(values, fmt.Sprintf("%s=%d", name, compressedSize))
+		prettyValues = append(prettyValues, fmt.Sprintf("%s %s", name, prettyFormatSize(compressedSize)))
+	}
+	prettyValue := strings.Join(prettyValues, ", ")
+	field.Value = strings.Join(values, ",")
+	field.PrettyValue = &prettyValue
+	return field
+}
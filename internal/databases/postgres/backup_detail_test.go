@@ -106,6 +106,29 @@ func TestBackupDetail_PrintableFields(t *testing.T) {
 			Value:       "true",
 			PrettyValue: nil,
 		},
+		{
+			Name:        "size_by_database",
+			PrettyName:  "Size by database",
+			Value:       "",
+			PrettyValue: nil,
+		},
 	}
 	assert.Equal(t, want, got)
 }
+
+func TestBackupDetail_SizeByDatabase(t *testing.T) {
+	bd := &BackupDetail{
+		ExtendedMetadataDto: ExtendedMetadataDto{
+			SizeStats: &BackupSizeStats{
+				Databases: map[string]SizeStats{
+					"postgres": {CompressedSize: 512},
+					"app":      {CompressedSize: 3 * 1024 * 1024},
+				},
+			},
+		},
+	}
+	fields := bd.PrintableFields()
+	field := fields[len(fields)-1]
+	assert.Equal(t, "app=3145728,postgres=512", field.Value)
+	assert.Equal(t, "app 3.0 MiB, postgres 512 B", *field.PrettyValue)
+}
@@ -115,6 +115,9 @@ func GetFetcherOld(dbDataDirectory, fileMask, restoreSpecPath string, extractPro
 
 		err = deltaFetchRecursionOld(pgBackup, rootFolder, utility.ResolveSymlink(dbDataDirectory), spec, filesToUnwrap, extractProv)
 		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
+
+		err = fetchIncludedWal(pgBackup, utility.ResolveSymlink(dbDataDirectory))
+		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup: %v\n", err)
 	}
 }
 
@@ -0,0 +1,480 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	// the staging clusters listen only on the unix socket in the upgrade work directory
+	upgradeStagingPort         = 50432
+	upgradeRecoveryPollPeriod  = time.Second
+	upgradeDefaultWalSegSize   = 16 * 1024 * 1024
+	upgradeStagingConfigHeader = "# added by wal-g backup-fetch --upgrade-to"
+	// upgradeStagingStartTimeout is how long pg_ctl -W start may take to write postmaster.pid
+	upgradeStagingStartTimeout = time.Minute
+)
+
+// UpgradeArgs holds the arguments of backup-fetch --upgrade-to
+type UpgradeArgs struct {
+	// OldBinDir is the bin directory of the backup PostgreSQL version
+	OldBinDir string
+	// NewBinDir is the bin directory of the PostgreSQL version to upgrade to
+	NewBinDir string
+	// NewDataDirectory is the data directory of the upgraded cluster
+	NewDataDirectory string
+	// Username is the bootstrap superuser of the clusters, the OS user if empty
+	Username    string
+	InitdbFlags []string
+	// PushConfig is the WAL-G config of the storage the upgraded cluster is pushed to, nothing is pushed if empty
+	PushConfig string
+}
+
+// HandleBackupFetchUpgrade is invoked to perform a wal-g backup-fetch --upgrade-to. It restores the backup
+// into the staging directory, replays the WAL up to the consistent point and upgrades the restored
+// cluster with pg_upgrade in the link mode into the new data directory.
+func HandleBackupFetchUpgrade(folder storage.Folder, targetBackupSelector internal.BackupSelector,
+	fetcher internal.Fetcher, stagingDirectory string, args UpgradeArgs) {
+	err := args.check()
+	tracelog.ErrorLogger.FatalOnError(err)
+	if args.NewDataDirectory == "" {
+		args.NewDataDirectory = filepath.Clean(stagingDirectory) + "_upgraded"
+	}
+
+	internal.HandleBackupFetch(folder, targetBackupSelector, fetcher)
+
+	workDirectory, err := os.MkdirTemp("", "wal-g-upgrade-")
+	tracelog.ErrorLogger.FatalfOnError("Failed to create upgrade work directory: %v", err)
+	tracelog.InfoLogger.Printf("Upgrade logs and reports are kept in %s", workDirectory)
+
+	upgrader := &clusterUpgrader{args: args, stagingDirectory: stagingDirectory, workDirectory: workDirectory}
+	err = upgrader.recoverStagingCluster()
+	tracelog.ErrorLogger.FatalfOnError("Failed to recover the staging cluster: %v", err)
+	err = upgrader.initNewCluster()
+	tracelog.ErrorLogger.FatalfOnError("Failed to init the new cluster: %v", err)
+	err = upgrader.upgrade()
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Upgraded cluster is in %s", args.NewDataDirectory)
+
+	if args.PushConfig != "" {
+		err = upgrader.pushNewCluster()
+		tracelog.ErrorLogger.FatalfOnError("Failed to push the upgraded cluster: %v", err)
+	}
+}
+
+func (args UpgradeArgs) check() error {
+	if args.OldBinDir == "" {
+		return errors.New("the bin directory of the backup PostgreSQL version should be specified with --upgrade-from")
+	}
+	for _, binDir := range []string{args.OldBinDir, args.NewBinDir} {
+		if _, err := os.Stat(filepath.Join(binDir, "pg_ctl")); err != nil {
+			return errors.Wrapf(err, "%s is not a PostgreSQL bin directory", binDir)
+		}
+	}
+	if args.NewDataDirectory != "" {
+		if _, err := os.Stat(args.NewDataDirectory); err == nil {
+			return errors.Errorf("new cluster data directory %s already exists", args.NewDataDirectory)
+		}
+	}
+	return nil
+}
+
+type clusterUpgrader struct {
+	args             UpgradeArgs
+	stagingDirectory string
+	workDirectory    string
+	controlData      map[string]string
+}
+
+// recoverStagingCluster replays the WAL up to the consistent point, promotes the restored cluster
+// and shuts it down cleanly, as pg_upgrade requires
+func (upgrader *clusterUpgrader) recoverStagingCluster() error {
+	version, err := readPgMajorVersion(upgrader.stagingDirectory)
+	if err != nil {
+		return err
+	}
+	restoreCommand, err := walgCommandLine(viper.ConfigFileUsed(), "wal-fetch", "%f", "%p")
+	if err != nil {
+		return err
+	}
+	err = writeUpgradeStagingConfig(upgrader.stagingDirectory, version, upgrader.workDirectory, restoreCommand)
+	if err != nil {
+		return err
+	}
+
+	tracelog.InfoLogger.Println("Starting the staging cluster to replay WAL")
+	err = upgrader.runPgCtl(upgrader.args.OldBinDir, "-D", upgrader.stagingDirectory,
+		"-l", filepath.Join(upgrader.workDirectory, "staging.log"), "-W", "start")
+	if err != nil {
+		return err
+	}
+	err = upgrader.waitForPromotion(version, time.Now())
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Println("Staging cluster reached the consistent point, stopping it")
+	err = upgrader.runPgCtl(upgrader.args.OldBinDir, "-D", upgrader.stagingDirectory, "-w", "-m", "fast", "stop")
+	if err != nil {
+		return err
+	}
+
+	upgrader.controlData, err = readControlData(upgrader.args.OldBinDir, upgrader.stagingDirectory)
+	return err
+}
+
+// isStagingClusterStarting reports whether the postmaster started by pg_ctl -W may not have written its pid file yet,
+// pg_ctl status fails until it is written
+func isStagingClusterStarting(stagingDirectory string, startedAt time.Time) bool {
+	_, err := os.Stat(filepath.Join(stagingDirectory, "postmaster.pid"))
+	return os.IsNotExist(err) && time.Since(startedAt) < upgradeStagingStartTimeout
+}
+
+func (upgrader *clusterUpgrader) waitForPromotion(version int, startedAt time.Time) error {
+	recoveryFile := filepath.Join(upgrader.stagingDirectory, "recovery.signal")
+	if version < 12 {
+		recoveryFile = filepath.Join(upgrader.stagingDirectory, "recovery.conf")
+	}
+	for {
+		// the recovery file is removed on promotion before the cluster goes to production
+		_, err := os.Stat(recoveryFile)
+		if os.IsNotExist(err) {
+			controlData, err := readControlData(upgrader.args.OldBinDir, upgrader.stagingDirectory)
+			if err != nil {
+				return err
+			}
+			if controlData["Database cluster state"] == "in production" {
+				return nil
+			}
+		}
+		status := exec.Command(filepath.Join(upgrader.args.OldBinDir, "pg_ctl"), "-D", upgrader.stagingDirectory, "status")
+		if status.Run() != nil && !isStagingClusterStarting(upgrader.stagingDirectory, startedAt) {
+			return errors.Errorf("staging cluster stopped before reaching the consistent point, see %s",
+				filepath.Join(upgrader.workDirectory, "staging.log"))
+		}
+		time.Sleep(upgradeRecoveryPollPeriod)
+	}
+}
+
+// initNewCluster creates the new cluster with the settings pg_upgrade requires to match
+func (upgrader *clusterUpgrader) initNewCluster() error {
+	initdbArgs := []string{"-D", upgrader.args.NewDataDirectory}
+	if upgrader.args.Username != "" {
+		initdbArgs = append(initdbArgs, "-U", upgrader.args.Username)
+	}
+	if upgrader.controlData["Data page checksum version"] != "0" {
+		initdbArgs = append(initdbArgs, "--data-checksums")
+	}
+	walSegSize, err := strconv.Atoi(upgrader.controlData["Bytes per WAL segment"])
+	if err == nil && walSegSize != upgradeDefaultWalSegSize {
+		initdbArgs = append(initdbArgs, fmt.Sprintf("--wal-segsize=%d", walSegSize/1024/1024))
+	}
+	initdbArgs = append(initdbArgs, upgrader.args.InitdbFlags...)
+
+	tracelog.InfoLogger.Printf("Creating the new cluster in %s", upgrader.args.NewDataDirectory)
+	output, err := upgrader.command(upgrader.args.NewBinDir, "initdb", initdbArgs...).CombinedOutput()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("initdb output:\n%s", output)
+		return err
+	}
+	return nil
+}
+
+// upgrade runs pg_upgrade --check and then the upgrade itself, the incompatibilities found are reported
+func (upgrader *clusterUpgrader) upgrade() error {
+	upgradeArgs := []string{
+		"--old-bindir", upgrader.args.OldBinDir,
+		"--new-bindir", upgrader.args.NewBinDir,
+		"--old-datadir", upgrader.stagingDirectory,
+		"--new-datadir", upgrader.args.NewDataDirectory,
+		"--link",
+	}
+	if upgrader.args.Username != "" {
+		upgradeArgs = append(upgradeArgs, "--username", upgrader.args.Username)
+	}
+
+	tracelog.InfoLogger.Println("Running pg_upgrade --check")
+	output, err := upgrader.command(upgrader.args.NewBinDir, "pg_upgrade", append(upgradeArgs, "--check")...).CombinedOutput()
+	tracelog.InfoLogger.Printf("pg_upgrade --check output:\n%s", output)
+	if err != nil {
+		upgrader.reportIncompatibilities(output)
+		return errors.Wrap(err, "pg_upgrade --check found the clusters incompatible")
+	}
+
+	tracelog.InfoLogger.Println("Running pg_upgrade")
+	output, err = upgrader.command(upgrader.args.NewBinDir, "pg_upgrade", upgradeArgs...).CombinedOutput()
+	tracelog.InfoLogger.Printf("pg_upgrade output:\n%s", output)
+	if err != nil {
+		upgrader.reportIncompatibilities(output)
+		return errors.Wrap(err, "pg_upgrade failed")
+	}
+	return nil
+}
+
+func (upgrader *clusterUpgrader) reportIncompatibilities(output []byte) {
+	for _, problem := range findUpgradeProblems(output) {
+		tracelog.ErrorLogger.Printf("pg_upgrade: %s", problem)
+	}
+	reports, err := collectUpgradeReports(upgrader.workDirectory)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read pg_upgrade reports: %v", err)
+	}
+	for name, report := range reports {
+		tracelog.ErrorLogger.Printf("Incompatibility report %s:\n%s", name, report)
+	}
+}
+
+// pushNewCluster starts the upgraded cluster and pushes its full backup to the storage of the push config.
+// The upgraded cluster continues the WAL of the original one, so it must not be pushed to the same storage.
+func (upgrader *clusterUpgrader) pushNewCluster() error {
+	archiveCommand, err := walgCommandLine(upgrader.args.PushConfig, "wal-push", "%p")
+	if err != nil {
+		return err
+	}
+	options := []string{
+		"-c listen_addresses=''",
+		"-c unix_socket_directories=" + shellQuote(upgrader.workDirectory),
+		"-c port=" + strconv.Itoa(upgradeStagingPort),
+		"-c archive_mode=on",
+		"-c archive_command=" + shellQuote(archiveCommand),
+	}
+	tracelog.InfoLogger.Println("Starting the upgraded cluster")
+	startArgs := []string{"-D", upgrader.args.NewDataDirectory,
+		"-l", filepath.Join(upgrader.workDirectory, "upgraded.log"), "-o", strings.Join(options, " "), "-w", "start"}
+	startCmd := upgrader.command(upgrader.args.NewBinDir, "pg_ctl", startArgs...)
+	// the archive command of the upgraded cluster inherits the environment of the postmaster
+	startCmd.Env = upgradePushEnvironment(os.Environ(), viper.AllKeys())
+	err = runPgCtlCommand(startCmd, startArgs)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tracelog.ErrorLogger.PrintOnError(upgrader.runPgCtl(upgrader.args.NewBinDir,
+			"-D", upgrader.args.NewDataDirectory, "-w", "-m", "fast", "stop"))
+	}()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	backupCmd := upgrader.pushCommand(executable, os.Environ(), viper.AllKeys())
+	backupCmd.Stdout = os.Stdout
+	backupCmd.Stderr = os.Stderr
+	tracelog.InfoLogger.Printf("Pushing the upgraded cluster with %s", upgrader.args.PushConfig)
+	return backupCmd.Run()
+}
+
+// pushCommand builds the backup-push of the upgraded cluster, it connects to the cluster by the unix socket
+func (upgrader *clusterUpgrader) pushCommand(executable string, environ []string, configKeys []string) *exec.Cmd {
+	backupCmd := exec.Command(executable, "--config", upgrader.args.PushConfig,
+		"backup-push", upgrader.args.NewDataDirectory, "--full")
+	backupCmd.Env = append(upgradePushEnvironment(environ, configKeys),
+		"PGHOST="+upgrader.workDirectory,
+		"PGPORT="+strconv.Itoa(upgradeStagingPort),
+		"PGDATABASE=postgres")
+	if upgrader.args.Username != "" {
+		backupCmd.Env = append(backupCmd.Env, "PGUSER="+upgrader.args.Username)
+	}
+	return backupCmd
+}
+
+// upgradePushEnvironment removes the WAL-G settings from the environment of the processes pushing the upgraded cluster.
+// The settings of the original storage are exported to the environment on the config load, and the environment
+// overrides the push config, so they would push the upgraded cluster to the original storage.
+func upgradePushEnvironment(environ []string, configKeys []string) []string {
+	configSettings := make(map[string]bool, len(configKeys))
+	for _, key := range configKeys {
+		configSettings[strings.ToUpper(key)] = true
+	}
+	env := make([]string, 0, len(environ))
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, "WALG_") || strings.HasPrefix(name, "WALE_") ||
+			internal.AllowedSettings[name] || configSettings[strings.ToUpper(name)] {
+			continue
+		}
+		env = append(env, variable)
+	}
+	return env
+}
+
+func (upgrader *clusterUpgrader) command(binDir string, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(filepath.Join(binDir, name), args...)
+	// pg_upgrade writes its logs and reports to the current directory
+	cmd.Dir = upgrader.workDirectory
+	return cmd
+}
+
+func (upgrader *clusterUpgrader) runPgCtl(binDir string, args ...string) error {
+	return runPgCtlCommand(upgrader.command(binDir, "pg_ctl", args...), args)
+}
+
+func runPgCtlCommand(cmd *exec.Cmd, args []string) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("pg_ctl output:\n%s", output)
+		return errors.Wrapf(err, "pg_ctl %s failed", args[len(args)-1])
+	}
+	return nil
+}
+
+// writeUpgradeStagingConfig makes the restored cluster recover up to the consistent point from the WAL archive
+// and keeps it from archiving WAL and listening on the network
+func writeUpgradeStagingConfig(dataDirectory string, version int, socketDirectory string, restoreCommand string) error {
+	// the configuration files may be kept outside of the data directory, so they are not in the backup
+	configPath := filepath.Join(dataDirectory, "postgresql.conf")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		err = os.WriteFile(configPath, nil, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	settings := [][2]string{
+		{"archive_mode", "off"},
+		{"listen_addresses", ""},
+		{"unix_socket_directories", socketDirectory},
+		{"port", strconv.Itoa(upgradeStagingPort)},
+	}
+	recoverySettings := [][2]string{
+		{"restore_command", restoreCommand},
+		{"recovery_target", "immediate"},
+		{"recovery_target_action", "promote"},
+	}
+	if version >= 12 {
+		settings = append(settings, recoverySettings...)
+		err := os.WriteFile(filepath.Join(dataDirectory, "recovery.signal"), nil, 0600)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := os.WriteFile(filepath.Join(dataDirectory, "recovery.conf"), formatConfSettings(recoverySettings), 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	autoConf, err := os.OpenFile(filepath.Join(dataDirectory, "postgresql.auto.conf"),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = autoConf.Write(formatConfSettings(settings))
+	closeErr := autoConf.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func formatConfSettings(settings [][2]string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("\n" + upgradeStagingConfigHeader + "\n")
+	for _, setting := range settings {
+		buffer.WriteString(fmt.Sprintf("%s = '%s'\n", setting[0], strings.ReplaceAll(setting[1], "'", "''")))
+	}
+	return buffer.Bytes()
+}
+
+// readPgMajorVersion reads the major version from PG_VERSION, e.g. 96 for 9.6 and 15 for 15
+func readPgMajorVersion(dataDirectory string) (int, error) {
+	content, err := os.ReadFile(filepath.Join(dataDirectory, "PG_VERSION"))
+	if err != nil {
+		return 0, err
+	}
+	versionStr := strings.TrimSpace(string(content))
+	if strings.HasPrefix(versionStr, "9.") {
+		// the old versions are always older than any new one
+		return 9, nil
+	}
+	return strconv.Atoi(versionStr)
+}
+
+// readControlData runs pg_controldata and returns its fields by name
+func readControlData(binDir string, dataDirectory string) (map[string]string, error) {
+	cmd := exec.Command(filepath.Join(binDir, "pg_controldata"), "-D", dataDirectory)
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "pg_controldata failed")
+	}
+	return parseControlData(output), nil
+}
+
+func parseControlData(output []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if found {
+			fields[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// findUpgradeProblems returns the pg_upgrade output lines about the failed checks
+func findUpgradeProblems(output []byte) []string {
+	problems := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lowerLine := strings.ToLower(line)
+		if strings.HasSuffix(lowerLine, "fatal") || strings.Contains(lowerLine, "failure") ||
+			strings.HasPrefix(lowerLine, "your installation") || strings.HasPrefix(lowerLine, "could not") {
+			problems = append(problems, line)
+		}
+	}
+	return problems
+}
+
+// collectUpgradeReports reads the lists of incompatible objects pg_upgrade writes, e.g. loadable_libraries.txt
+func collectUpgradeReports(workDirectory string) (map[string]string, error) {
+	reports := make(map[string]string)
+	err := filepath.WalkDir(workDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".txt" {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(workDirectory, path)
+		reports[name] = string(content)
+		return nil
+	})
+	return reports, err
+}
+
+// walgCommandLine builds the shell command running this WAL-G binary
+func walgCommandLine(configFile string, args ...string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	commandLine := []string{shellQuote(executable)}
+	if configFile != "" {
+		commandLine = append(commandLine, "--config", shellQuote(configFile))
+	}
+	for _, arg := range args {
+		commandLine = append(commandLine, shellQuote(arg))
+	}
+	return strings.Join(commandLine, " "), nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
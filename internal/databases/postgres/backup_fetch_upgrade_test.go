@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wal-g/wal-g/internal"
+)
+
+func TestWriteUpgradeStagingConfig(t *testing.T) {
+	dataDirectory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "postgresql.auto.conf"), []byte("work_mem = '1MB'\n"), 0600))
+
+	require.NoError(t, writeUpgradeStagingConfig(dataDirectory, 15, "/tmp/sock", "'/bin/wal-g' wal-fetch '%f' '%p'"))
+
+	assert.FileExists(t, filepath.Join(dataDirectory, "postgresql.conf"))
+	assert.FileExists(t, filepath.Join(dataDirectory, "recovery.signal"))
+	assert.NoFileExists(t, filepath.Join(dataDirectory, "recovery.conf"))
+	content, err := os.ReadFile(filepath.Join(dataDirectory, "postgresql.auto.conf"))
+	require.NoError(t, err)
+	for name, expected := range map[string]string{
+		"work_mem":                "1MB",
+		"archive_mode":            "off",
+		"listen_addresses":        "",
+		"unix_socket_directories": "/tmp/sock",
+		"restore_command":         "'/bin/wal-g' wal-fetch '%f' '%p'",
+		"recovery_target":         "immediate",
+		"recovery_target_action":  "promote",
+	} {
+		value, found := parseConfParameter(string(content), name)
+		assert.True(t, found, name)
+		assert.Equal(t, expected, value, name)
+	}
+}
+
+func TestWriteUpgradeStagingConfig_RecoveryConf(t *testing.T) {
+	dataDirectory := t.TempDir()
+	require.NoError(t, writeUpgradeStagingConfig(dataDirectory, 11, "/tmp/sock", "wal-g wal-fetch %f %p"))
+
+	assert.NoFileExists(t, filepath.Join(dataDirectory, "recovery.signal"))
+	content, err := os.ReadFile(filepath.Join(dataDirectory, "recovery.conf"))
+	require.NoError(t, err)
+	value, _ := parseConfParameter(string(content), "restore_command")
+	assert.Equal(t, "wal-g wal-fetch %f %p", value)
+
+	autoContent, err := os.ReadFile(filepath.Join(dataDirectory, "postgresql.auto.conf"))
+	require.NoError(t, err)
+	_, found := parseConfParameter(string(autoContent), "restore_command")
+	assert.False(t, found)
+}
+
+func TestReadPgMajorVersion(t *testing.T) {
+	for content, expected := range map[string]int{"9.6\n": 9, "10\n": 10, "16": 16} {
+		dataDirectory := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dataDirectory, "PG_VERSION"), []byte(content), 0600))
+		version, err := readPgMajorVersion(dataDirectory)
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+}
+
+func TestIsStagingClusterStarting(t *testing.T) {
+	dir := t.TempDir()
+	assert.True(t, isStagingClusterStarting(dir, time.Now()))
+	assert.False(t, isStagingClusterStarting(dir, time.Now().Add(-upgradeStagingStartTimeout)))
+
+	// the postmaster which has written its pid file and is not running has stopped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "postmaster.pid"), []byte("12345\n"), 0600))
+	assert.False(t, isStagingClusterStarting(dir, time.Now()))
+}
+
+func TestParseControlData(t *testing.T) {
+	output := []byte(`pg_control version number:            1300
+Database cluster state:               shut down
+Latest checkpoint location:           0/3000060
+Bytes per WAL segment:                16777216
+Data page checksum version:           1
+`)
+	fields := parseControlData(output)
+	assert.Equal(t, "shut down", fields["Database cluster state"])
+	assert.Equal(t, "0/3000060", fields["Latest checkpoint location"])
+	assert.Equal(t, "16777216", fields["Bytes per WAL segment"])
+	assert.Equal(t, "1", fields["Data page checksum version"])
+}
+
+func TestFindUpgradeProblems(t *testing.T) {
+	output := []byte(`Performing Consistency Checks
+-----------------------------
+Checking cluster versions                                     ok
+Checking for presence of required libraries                   fatal
+
+Your installation references loadable libraries that are missing from the
+new installation.  You can add these libraries to the new installation,
+or remove the functions using them from the old installation.  A list of
+problem libraries is in the file:
+    loadable_libraries.txt
+
+Failure, exiting
+`)
+	assert.Equal(t, []string{
+		"Checking for presence of required libraries                   fatal",
+		"Your installation references loadable libraries that are missing from the",
+		"Failure, exiting",
+	}, findUpgradeProblems(output))
+}
+
+func TestCollectUpgradeReports(t *testing.T) {
+	workDirectory := t.TempDir()
+	reportDirectory := filepath.Join(workDirectory, "pg_upgrade_output.d", "20230101T000000.000")
+	require.NoError(t, os.MkdirAll(reportDirectory, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(reportDirectory, "loadable_libraries.txt"),
+		[]byte("could not load library \"$libdir/postgis-3\"\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(reportDirectory, "pg_upgrade_server.log"), []byte("log"), 0600))
+
+	reports, err := collectUpgradeReports(workDirectory)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		filepath.Join("pg_upgrade_output.d", "20230101T000000.000", "loadable_libraries.txt"): "could not load library \"$libdir/postgis-3\"\n",
+	}, reports)
+}
+
+func TestUpgradePushEnvironment(t *testing.T) {
+	internal.ConfigureSettings(internal.PG)
+	// the connection settings of the original cluster are removed too
+	env := upgradePushEnvironment([]string{
+		"HOME=/var/lib/postgresql",
+		"PATH=/usr/bin",
+		"WALG_S3_PREFIX=s3://original",
+		"WALE_S3_PREFIX=s3://original",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"WALG_LIBSODIUM_KEY=key",
+		"PGPASSWORD=password",
+		"CUSTOM_SETTING=value",
+	}, []string{"custom_setting"})
+	assert.Equal(t, []string{"HOME=/var/lib/postgresql", "PATH=/usr/bin"}, env)
+}
+
+// TestUpgradePushCommand_PushConfigStorage loads the config the way the pushing process does
+// and makes sure the upgraded cluster lands in the storage of the push config
+func TestUpgradePushCommand_PushConfigStorage(t *testing.T) {
+	internal.ConfigureSettings(internal.PG)
+	originalPrefix := t.TempDir()
+	pushPrefix := t.TempDir()
+	pushConfig := filepath.Join(t.TempDir(), "push.json")
+	require.NoError(t, os.WriteFile(pushConfig, []byte(`{"WALG_FILE_PREFIX": "`+pushPrefix+`"}`), 0600))
+	// the original config is exported to the environment on the config load
+	t.Setenv("WALG_FILE_PREFIX", originalPrefix)
+	t.Setenv("WALG_COMPRESSION_METHOD", "brotli")
+
+	upgrader := &clusterUpgrader{
+		args:          UpgradeArgs{NewDataDirectory: "/var/lib/postgresql/16/main", PushConfig: pushConfig, Username: "postgres"},
+		workDirectory: "/tmp/wal-g-upgrade-1",
+	}
+	backupCmd := upgrader.pushCommand("/usr/bin/wal-g", os.Environ(), []string{"walg_file_prefix"})
+	assert.Equal(t, []string{"/usr/bin/wal-g", "--config", pushConfig, "backup-push", "/var/lib/postgresql/16/main", "--full"},
+		backupCmd.Args)
+	assert.Contains(t, backupCmd.Env, "PGHOST=/tmp/wal-g-upgrade-1")
+	assert.Contains(t, backupCmd.Env, "PGUSER=postgres")
+
+	// the environment of the pushing process
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		t.Setenv(name, "")
+		require.NoError(t, os.Unsetenv(name))
+	}
+	for _, variable := range backupCmd.Env {
+		name, value, _ := strings.Cut(variable, "=")
+		t.Setenv(name, value)
+	}
+	config := viper.New()
+	config.AutomaticEnv()
+	internal.ReadConfigFromFile(config, pushConfig)
+	st, err := internal.ConfigureStorageForSpecificConfig(config)
+	require.NoError(t, err)
+	require.NoError(t, st.RootFolder().PutObject("basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json",
+		bytes.NewReader([]byte("{}"))))
+
+	assert.FileExists(t, filepath.Join(pushPrefix, "basebackups_005", "base_000000010000000000000002_backup_stop_sentinel.json"))
+	entries, err := os.ReadDir(originalPrefix)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	// IncludedWalFolderName is the backup subfolder for the WAL segments required to make the backup consistent
+	IncludedWalFolderName = "/wal"
+
+	includedWalPollInterval = 5 * time.Second
+)
+
+type IncludedWalTimeoutError struct {
+	error
+}
+
+func newIncludedWalTimeoutError(walFilename string, timeout time.Duration) IncludedWalTimeoutError {
+	return IncludedWalTimeoutError{errors.Errorf(
+		"WAL file %s has not been archived in %v, check that archive_command pushes WAL to the same storage",
+		walFilename, timeout)}
+}
+
+func (err IncludedWalTimeoutError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// GetIncludedWalFilenames returns the names of WAL segments from the backup start up to the backup finish.
+// The history file of the timeline is included as well, since recovery reads it from pg_wal.
+func GetIncludedWalFilenames(timeline uint32, startLSN, finishLSN LSN) []string {
+	filenames := make([]string, 0)
+	if timeline > 1 {
+		filenames = append(filenames, fmt.Sprintf(walHistoryFileFormat, timeline))
+	}
+	lastSegmentNo := NewWalSegmentNo(finishLSN - 1)
+	for segmentNo := NewWalSegmentNo(startLSN); segmentNo <= lastSegmentNo; segmentNo = segmentNo.Next() {
+		filenames = append(filenames, segmentNo.GetFilename(timeline))
+	}
+	return filenames
+}
+
+// CopyIncludedWal copies the WAL files, which are required to restore the backup, from the WAL archive
+// into the backup folder as is, waiting up to the timeout for each of them to be archived.
+// It returns the names of the copied files.
+func CopyIncludedWal(rootFolder storage.Folder, backupFolder storage.Folder, backupName string,
+	walFilenames []string, timeout time.Duration) ([]string, error) {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	includedWalFolder := backupFolder.GetSubFolder(backupName + IncludedWalFolderName)
+	copiedFilenames := make([]string, 0, len(walFilenames))
+	for _, walFilename := range walFilenames {
+		objectName, err := waitForArchivedWalFile(walFolder, walFilename, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if objectName == "" {
+			// only history files are optional: there is none for the timeline created by pg_resetwal
+			tracelog.WarningLogger.Printf("History file %s is not found in storage, skipping it", walFilename)
+			continue
+		}
+		err = copyObject(walFolder, includedWalFolder, objectName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to include WAL file %s into the backup", walFilename)
+		}
+		copiedFilenames = append(copiedFilenames, walFilename)
+		tracelog.InfoLogger.Printf("Included WAL file %s into the backup", walFilename)
+	}
+	return copiedFilenames, nil
+}
+
+// waitForArchivedWalFile returns the name of the archived WAL file object with the compression extension
+func waitForArchivedWalFile(walFolder storage.Folder, walFilename string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		objectName, err := findArchivedWalFile(walFolder, walFilename)
+		if err != nil || objectName != "" {
+			return objectName, err
+		}
+		if timelineHistoryFileRegexp.MatchString(walFilename) {
+			return "", nil
+		}
+		if time.Now().After(deadline) {
+			return "", newIncludedWalTimeoutError(walFilename, timeout)
+		}
+		tracelog.InfoLogger.Printf("Waiting for WAL file %s to be archived", walFilename)
+		time.Sleep(includedWalPollInterval)
+	}
+}
+
+func findArchivedWalFile(walFolder storage.Folder, walFilename string) (string, error) {
+	candidates := make([]string, 0, len(compression.Decompressors)+1)
+	for _, decompressor := range compression.Decompressors {
+		candidates = append(candidates, walFilename+"."+decompressor.FileExtension())
+	}
+	candidates = append(candidates, walFilename)
+	for _, candidate := range candidates {
+		exists, err := walFolder.Exists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+func copyObject(from storage.Folder, to storage.Folder, objectName string) error {
+	reader, err := from.ReadObject(objectName)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(reader, "")
+	return to.PutObject(objectName, reader)
+}
+
+func fetchIncludedWal(backup Backup, dbDataDirectory string) error {
+	sentinelDto, err := backup.GetSentinel()
+	if err != nil {
+		return err
+	}
+	return backup.FetchIncludedWal(dbDataDirectory, sentinelDto)
+}
+
+// FetchIncludedWal restores the WAL files included into the backup to the pg_wal (pg_xlog before PG 10) directory
+func (backup *Backup) FetchIncludedWal(dbDataDirectory string, sentinelDto BackupSentinelDto) error {
+	if len(sentinelDto.IncludedWal) == 0 {
+		return nil
+	}
+	walDirectory := filepath.Join(dbDataDirectory, "pg_wal")
+	if sentinelDto.PgVersion < 100000 {
+		walDirectory = filepath.Join(dbDataDirectory, "pg_xlog")
+	}
+	err := os.MkdirAll(walDirectory, 0700)
+	if err != nil {
+		return err
+	}
+	includedWalFolderReader := internal.NewFolderReader(backup.Folder.GetSubFolder(backup.Name + IncludedWalFolderName))
+	for _, walFilename := range sentinelDto.IncludedWal {
+		err = internal.DownloadFileTo(includedWalFolderReader, walFilename, filepath.Join(walDirectory, walFilename))
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore included WAL file %s", walFilename)
+		}
+	}
+	tracelog.InfoLogger.Printf("Restored %d WAL files included into the backup to %s",
+		len(sentinelDto.IncludedWal), walDirectory)
+	return nil
+}
@@ -0,0 +1,99 @@
+package postgres_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const testIncludedWalBackupName = "base_000000020000000000000003"
+
+func TestGetIncludedWalFilenames(t *testing.T) {
+	filenames := postgres.GetIncludedWalFilenames(2, 0x3000028, 0x5000100)
+	assert.Equal(t, []string{
+		"00000002.history",
+		"000000020000000000000003",
+		"000000020000000000000004",
+		"000000020000000000000005",
+	}, filenames)
+}
+
+func TestGetIncludedWalFilenames_FinishAtSegmentBoundary(t *testing.T) {
+	filenames := postgres.GetIncludedWalFilenames(1, 0x3000028, 0x4000000)
+	assert.Equal(t, []string{"000000010000000000000003"}, filenames)
+}
+
+func TestCopyIncludedWal(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	walFolder := folder.GetSubFolder(utility.WalPath)
+	require.NoError(t, walFolder.PutObject("000000020000000000000003.lz4", bytes.NewReader([]byte("first"))))
+	require.NoError(t, walFolder.PutObject("000000020000000000000004.zst", bytes.NewReader([]byte("second"))))
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+
+	walFilenames := postgres.GetIncludedWalFilenames(2, 0x3000028, 0x4000100)
+	copied, err := postgres.CopyIncludedWal(folder, backupFolder, testIncludedWalBackupName, walFilenames, 0)
+	require.NoError(t, err)
+
+	// the missing history file is skipped
+	assert.Equal(t, []string{"000000020000000000000003", "000000020000000000000004"}, copied)
+	includedWalFolder := backupFolder.GetSubFolder(testIncludedWalBackupName + postgres.IncludedWalFolderName)
+	for _, name := range []string{"000000020000000000000003.lz4", "000000020000000000000004.zst"} {
+		exists, err := includedWalFolder.Exists(name)
+		require.NoError(t, err)
+		assert.True(t, exists, name)
+	}
+}
+
+func TestCopyIncludedWal_MissingSegment(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject("000000020000000000000003.lz4",
+		bytes.NewReader([]byte("first"))))
+
+	walFilenames := postgres.GetIncludedWalFilenames(2, 0x3000028, 0x4000100)
+	_, err := postgres.CopyIncludedWal(folder, folder.GetSubFolder(utility.BaseBackupPath),
+		testIncludedWalBackupName, walFilenames, 0)
+	assert.IsType(t, postgres.IncludedWalTimeoutError{}, err)
+}
+
+func TestFetchIncludedWal(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	backupFolder := folder.GetSubFolder(utility.BaseBackupPath)
+	includedWalFolder := backupFolder.GetSubFolder(testIncludedWalBackupName + postgres.IncludedWalFolderName)
+	require.NoError(t, includedWalFolder.PutObject("00000002.history", bytes.NewReader([]byte("history"))))
+	require.NoError(t, includedWalFolder.PutObject("000000020000000000000003", bytes.NewReader([]byte("segment"))))
+	backup, err := postgres.NewBackup(backupFolder, testIncludedWalBackupName)
+	require.NoError(t, err)
+	dataDirectory := t.TempDir()
+
+	err = backup.FetchIncludedWal(dataDirectory, postgres.BackupSentinelDto{
+		PgVersion:   150000,
+		IncludedWal: []string{"00000002.history", "000000020000000000000003"},
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dataDirectory, "pg_wal", "000000020000000000000003"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("segment"), data)
+	data, err = os.ReadFile(filepath.Join(dataDirectory, "pg_wal", "00000002.history"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("history"), data)
+}
+
+func TestFetchIncludedWal_NothingIncluded(t *testing.T) {
+	backup, err := postgres.NewBackup(memory.NewFolder("in_memory/", memory.NewKVS()), testIncludedWalBackupName)
+	require.NoError(t, err)
+	dataDirectory := t.TempDir()
+
+	err = backup.FetchIncludedWal(dataDirectory, postgres.BackupSentinelDto{PgVersion: 150000})
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dataDirectory, "pg_wal"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -60,6 +60,7 @@ type BackupArguments struct {
 	isFullBackup          bool
 	deltaConfigurator     DeltaBackupConfigurator
 	withoutFilesMetadata  bool
+	includeWal            bool
 	composerInitFunc      func(handler *BackupHandler) error
 }
 
@@ -73,6 +74,7 @@ type CurBackupInfo struct {
 	compressedSize   int64
 	dataCatalogSize  int64
 	incrementCount   int
+	includedWal      []string
 }
 
 func NewPrevBackupInfo(name string, sentinel BackupSentinelDto, filesMeta FilesMetadataDto) PrevBackupInfo {
@@ -115,7 +117,8 @@ type BackupHandler struct {
 // NewBackupArguments creates a BackupArgument object to hold the arguments from the cmd
 func NewBackupArguments(uploader internal.Uploader, pgDataDirectory string, backupsFolder string, isPermanent bool,
 	verifyPageChecksums bool, isFullBackup bool, storeAllCorruptBlocks bool, tarBallComposerType TarBallComposerType,
-	deltaConfigurator DeltaBackupConfigurator, userData interface{}, withoutFilesMetadata bool) BackupArguments {
+	deltaConfigurator DeltaBackupConfigurator, userData interface{}, withoutFilesMetadata bool,
+	includeWal bool) BackupArguments {
 	return BackupArguments{
 		Uploader:              uploader,
 		pgDataDirectory:       pgDataDirectory,
@@ -127,6 +130,7 @@ func NewBackupArguments(uploader internal.Uploader, pgDataDirectory string, back
 		deltaConfigurator:     deltaConfigurator,
 		userData:              userData,
 		withoutFilesMetadata:  withoutFilesMetadata,
+		includeWal:            includeWal,
 		composerInitFunc: func(handler *BackupHandler) error {
 			return configureTarBallComposer(handler, tarBallComposerType)
 		},
@@ -151,6 +155,11 @@ func (bh *BackupHandler) createAndPushBackup(ctx context.Context) {
 	tracelog.ErrorLogger.FatalOnError(err)
 	bh.handleDeltaBackup(folder)
 	tarFileSets := bh.uploadBackup()
+	// the sentinel isn't uploaded for the standby backup until it's restorable
+	err = bh.waitForStandbyBackupWal(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+	err = bh.includeWal(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
 	sentinelDto, filesMetaDto, err := bh.setupDTO(tarFileSets)
 	tracelog.ErrorLogger.FatalOnError(err)
 	bh.markBackups(folder, sentinelDto)
@@ -232,7 +241,28 @@ func (bh *BackupHandler) setupDTO(tarFileSets internal.TarFileSets) (sentinelDto
 	filesMeta.setFiles(bh.Workers.Bundle.GetFiles())
 	filesMeta.TarFileSets = tarFileSets.Get()
 	filesMeta.DatabasesByNames, err = bh.collectDatabaseNamesMetadata()
-	return sentinelDto, filesMeta, err
+	if err != nil {
+		return sentinelDto, filesMeta, err
+	}
+	sentinelDto.SizeStats = bh.collectSizeStats(filesMeta.DatabasesByNames)
+	return sentinelDto, filesMeta, nil
+}
+
+// collectSizeStats builds the backup size breakdown, the compressed sizes are missing
+// if the uploaded backup parts can't be listed
+func (bh *BackupHandler) collectSizeStats(databases DatabasesByNames) *BackupSizeStats {
+	sizeStats := bh.Workers.Bundle.SizeStats
+	compressedSizes, err := getCompressedTarBallSizes(bh.Arguments.Uploader.Folder(), bh.CurBackupInfo.Name)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list the backup parts, compressed sizes by database are unknown: %v", err)
+	} else {
+		sizeStats.setCompressedSizes(compressedSizes)
+	}
+	tablespaceNames, err := bh.Workers.QueryRunner.getTablespaceNames()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the tablespace names: %v", err)
+	}
+	return sizeStats.build(databases, tablespaceNames)
 }
 
 func (bh *BackupHandler) markBackups(folder storage.Folder, sentinelDto BackupSentinelDto) {
@@ -375,6 +405,7 @@ func (bh *BackupHandler) handleBackupPushLocal(ctx context.Context) {
 func (bh *BackupHandler) createAndPushRemoteBackup(ctx context.Context) {
 	var err error
 	uploader := bh.Arguments.Uploader
+	folder := uploader.Folder()
 	uploader.ChangeDirectory(utility.BaseBackupPath)
 	tracelog.DebugLogger.Printf("Uploading folder: %s", uploader.Folder())
 
@@ -393,15 +424,37 @@ func (bh *BackupHandler) createAndPushRemoteBackup(ctx context.Context) {
 	bh.CurBackupInfo.uncompressedSize = baseBackup.UncompressedSize
 	bh.CurBackupInfo.compressedSize, err = bh.Arguments.Uploader.UploadedDataSize()
 	tracelog.ErrorLogger.FatalOnError(err)
+	bh.CurBackupInfo.Name = baseBackup.BackupName()
+	err = bh.includeWal(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
 	sentinelDto := NewBackupSentinelDto(bh, baseBackup.GetTablespaceSpec())
 	filesMetadataDto := NewFilesMetadataDto(baseBackup.Files, tarFileSets)
-	bh.CurBackupInfo.Name = baseBackup.BackupName()
 	tracelog.InfoLogger.Println("Uploading metadata")
 	bh.uploadMetadata(ctx, sentinelDto, filesMetadataDto)
 	// logging backup set Name
 	tracelog.InfoLogger.Printf("Wrote backup with name %s", bh.CurBackupInfo.Name)
 }
 
+// includeWal copies the WAL required to restore the backup into the backup folder if it's requested
+func (bh *BackupHandler) includeWal(rootFolder storage.Folder) error {
+	if !bh.Arguments.includeWal {
+		return nil
+	}
+	timeline, err := ParseTimelineFromBackupName(bh.CurBackupInfo.Name)
+	if err != nil {
+		return err
+	}
+	timeout, err := internal.GetDurationSetting(internal.PgIncludeWalTimeout)
+	if err != nil {
+		return err
+	}
+	walFilenames := GetIncludedWalFilenames(timeline, bh.CurBackupInfo.startLSN, bh.CurBackupInfo.endLSN)
+	tracelog.InfoLogger.Printf("Including %d WAL files into the backup", len(walFilenames))
+	bh.CurBackupInfo.includedWal, err = CopyIncludedWal(rootFolder, bh.Arguments.Uploader.Folder(),
+		bh.CurBackupInfo.Name, walFilenames, timeout)
+	return err
+}
+
 func (bh *BackupHandler) uploadMetadata(ctx context.Context, sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto) {
 	curBackupName := bh.CurBackupInfo.Name
 	meta := NewExtendedMetadataDto(bh.Arguments.isPermanent, bh.PgInfo.PgDataDirectory,
@@ -432,6 +485,14 @@ func (bh *BackupHandler) collectDatabaseNamesMetadata() (DatabasesByNames, error
 			if err != nil {
 				return err
 			}
+			info.TableRelations, err = currentRunner.getTableRelations()
+			if err != nil {
+				return err
+			}
+			info.SystemRelations, err = currentRunner.getRewrittenSystemRelations()
+			if err != nil {
+				return err
+			}
 
 			databases[db.Name] = *info
 			return nil
@@ -30,9 +30,15 @@ type BackupSentinelDto struct {
 	DataCatalogSize  int64           `json:"DataCatalogSize,omitempty"`
 	TablespaceSpec   *TablespaceSpec `json:"Spec"`
 
+	// SizeStats is the size breakdown by databases and tablespaces
+	SizeStats *BackupSizeStats `json:"SizeStats,omitempty"`
+
 	UserData interface{} `json:"UserData,omitempty"`
 
 	FilesMetadataDisabled bool `json:"FilesMetadataDisabled,omitempty"`
+
+	// IncludedWal lists the WAL files copied into the backup folder to restore it without the WAL archive
+	IncludedWal []string `json:"IncludedWal,omitempty"`
 }
 
 func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSentinelDto {
@@ -59,6 +65,7 @@ func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSent
 	sentinel.CompressedSize = bh.CurBackupInfo.compressedSize
 	sentinel.DataCatalogSize = bh.CurBackupInfo.dataCatalogSize
 	sentinel.FilesMetadataDisabled = bh.Arguments.withoutFilesMetadata
+	sentinel.IncludedWal = bh.CurBackupInfo.includedWal
 	return sentinel
 }
 
@@ -78,6 +85,8 @@ type ExtendedMetadataDto struct {
 	UncompressedSize int64 `json:"uncompressed_size"`
 	CompressedSize   int64 `json:"compressed_size"`
 
+	SizeStats *BackupSizeStats `json:"size_stats,omitempty"`
+
 	UserData interface{} `json:"user_data,omitempty"`
 }
 
@@ -102,6 +111,7 @@ func NewExtendedMetadataDto(isPermanent bool, dataDir string, startTime time.Tim
 	meta.UserData = sentinelDto.UserData
 	meta.UncompressedSize = sentinelDto.UncompressedSize
 	meta.CompressedSize = sentinelDto.CompressedSize
+	meta.SizeStats = sentinelDto.SizeStats
 	return meta
 }
 
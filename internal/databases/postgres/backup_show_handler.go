@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+// HandleBackupShow writes the backup details and its size breakdown by databases and tablespaces
+func HandleBackupShow(rootFolder storage.Folder, targetBackupSelector internal.BackupSelector,
+	jsonOutput bool, output io.Writer) error {
+	backup, err := targetBackupSelector.Select(rootFolder)
+	if err != nil {
+		return err
+	}
+	backupTime := internal.BackupTime{BackupName: backup.Name, StorageName: backup.GetStorageName()}
+	detail, err := GetBackupDetails(backup.Folder, backupTime)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(detail)
+	}
+	return writeBackupShow(output, detail)
+}
+
+func writeBackupShow(output io.Writer, detail BackupDetail) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"Backup name", detail.BackupName},
+		{"Start time", internal.FormatTime(detail.StartTime)},
+		{"Finish time", internal.FormatTime(detail.FinishTime)},
+		{"Hostname", detail.Hostname},
+		{"PG version", fmt.Sprint(detail.PgVersion)},
+		{"Start LSN", detail.StartLsn.String()},
+		{"Finish LSN", detail.FinishLsn.String()},
+		{"Uncompressed size", prettyFormatSize(detail.UncompressedSize)},
+		{"Compressed size", prettyFormatSize(detail.CompressedSize)},
+		{"Permanent", fmt.Sprint(detail.IsPermanent)},
+	} {
+		_, err := fmt.Fprintf(writer, "%s:\t%s\n", field.name, field.value)
+		if err != nil {
+			return err
+		}
+	}
+	err := writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	if detail.SizeStats == nil {
+		_, err = fmt.Fprintln(output, "\nThe backup has no size breakdown, it was made by an older WAL-G version")
+		return err
+	}
+	err = writeSizeStatsTable(output, "Database", detail.SizeStats.sortedDatabases(), detail.SizeStats.Databases)
+	if err != nil {
+		return err
+	}
+	return writeSizeStatsTable(output, "Tablespace", detail.SizeStats.sortedTablespaces(), detail.SizeStats.Tablespaces)
+}
+
+func writeSizeStatsTable(output io.Writer, title string, names []string, statsByName map[string]SizeStats) error {
+	writer := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	_, err := fmt.Fprintf(writer, "\n%s\tData size\tUncompressed\tCompressed\tChanged pages\tTotal pages\tChanged\t\n", title)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		stats := statsByName[name]
+		_, err = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%d\t%.1f%%\t\n", name,
+			prettyFormatSize(stats.DataSize), prettyFormatSize(stats.UncompressedSize),
+			prettyFormatSize(stats.CompressedSize), stats.ChangedPages, stats.TotalPages, stats.ChangedPagesPercent())
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	DefaultTablespaceName = "pg_default"
+	GlobalTablespaceName  = "pg_global"
+
+	// increment file header: the signature, the file size and the changed pages count
+	incrementHeaderSize = sizeofInt32 + sizeofInt64 + sizeofInt32
+)
+
+// SizeStats describes the part of the backup belonging to a database or a tablespace
+type SizeStats struct {
+	// DataSize is the size of the files in the data directory
+	DataSize int64 `json:"data_size"`
+	// UncompressedSize is the size of the data in the backup, only the changed pages of the incremented files count
+	UncompressedSize int64 `json:"uncompressed_size"`
+	// CompressedSize is estimated by splitting the size of every backup part among its files
+	CompressedSize int64 `json:"compressed_size"`
+	// ChangedPages is the count of the pages stored in the backup, all pages are stored for the full backup
+	ChangedPages int64 `json:"changed_pages"`
+	TotalPages   int64 `json:"total_pages"`
+}
+
+func (stats *SizeStats) add(other SizeStats) {
+	stats.DataSize += other.DataSize
+	stats.UncompressedSize += other.UncompressedSize
+	stats.CompressedSize += other.CompressedSize
+	stats.ChangedPages += other.ChangedPages
+	stats.TotalPages += other.TotalPages
+}
+
+// ChangedPagesPercent returns the share of the pages changed since the delta base
+func (stats SizeStats) ChangedPagesPercent() float64 {
+	if stats.TotalPages == 0 {
+		return 0
+	}
+	return float64(stats.ChangedPages) * 100 / float64(stats.TotalPages)
+}
+
+// BackupSizeStats holds the backup size breakdown by databases and tablespaces
+type BackupSizeStats struct {
+	Databases   map[string]SizeStats `json:"databases,omitempty"`
+	Tablespaces map[string]SizeStats `json:"tablespaces,omitempty"`
+}
+
+type sizeStatsKey struct {
+	// databaseOid is empty for the files of the cluster-wide catalogs
+	databaseOid string
+	// tablespace is the tablespace name for the default ones and the tablespace OID for the others
+	tablespace string
+}
+
+// SizeStatsCollector accumulates the sizes of the files packed into the backup
+type SizeStatsCollector struct {
+	mutex sync.Mutex
+	stats map[sizeStatsKey]*SizeStats
+	// the uncompressed size of the data of each key in each tarball
+	tarBallSizes map[string]map[sizeStatsKey]int64
+}
+
+func NewSizeStatsCollector() *SizeStatsCollector {
+	return &SizeStatsCollector{
+		stats:        make(map[sizeStatsKey]*SizeStats),
+		tarBallSizes: make(map[string]map[sizeStatsKey]int64),
+	}
+}
+
+// addFile records the file, the packed size is zero for the files skipped from the delta backup
+func (collector *SizeStatsCollector) addFile(fileName string, dataSize int64, tarBallName string,
+	packedSize int64, isIncremented bool, isPaged bool) {
+	if collector == nil {
+		return
+	}
+	key, ok := parseSizeStatsKey(fileName)
+	if !ok {
+		return
+	}
+	fileStats := SizeStats{DataSize: dataSize, UncompressedSize: packedSize}
+	if isPaged {
+		fileStats.TotalPages = dataSize / DatabasePageSize
+		switch {
+		case isIncremented:
+			fileStats.ChangedPages = (packedSize - incrementHeaderSize) / (sizeofInt32 + DatabasePageSize)
+		case tarBallName != "":
+			fileStats.ChangedPages = fileStats.TotalPages
+		}
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	stats, ok := collector.stats[key]
+	if !ok {
+		stats = &SizeStats{}
+		collector.stats[key] = stats
+	}
+	stats.add(fileStats)
+	if tarBallName == "" {
+		return
+	}
+	if collector.tarBallSizes[tarBallName] == nil {
+		collector.tarBallSizes[tarBallName] = make(map[sizeStatsKey]int64)
+	}
+	collector.tarBallSizes[tarBallName][key] += packedSize
+}
+
+// setCompressedSizes splits the compressed size of every tarball among the keys proportionally
+// to their uncompressed data in the tarball
+func (collector *SizeStatsCollector) setCompressedSizes(compressedTarBallSizes map[string]int64) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for tarBallName, keySizes := range collector.tarBallSizes {
+		compressedSize, ok := compressedTarBallSizes[tarBallName]
+		if !ok {
+			continue
+		}
+		var totalSize int64
+		for _, size := range keySizes {
+			totalSize += size
+		}
+		if totalSize == 0 {
+			continue
+		}
+		for key, size := range keySizes {
+			collector.stats[key].CompressedSize += int64(float64(compressedSize) * float64(size) / float64(totalSize))
+		}
+	}
+}
+
+// build names the databases and the tablespaces, the ones with unknown names are named by OID
+func (collector *SizeStatsCollector) build(databases DatabasesByNames, tablespaceNames map[string]string) *BackupSizeStats {
+	databaseNames := make(map[string]string, len(databases))
+	for name, info := range databases {
+		databaseNames[strconv.FormatUint(uint64(info.Oid), 10)] = name
+	}
+	result := &BackupSizeStats{
+		Databases:   make(map[string]SizeStats),
+		Tablespaces: make(map[string]SizeStats),
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	for key, stats := range collector.stats {
+		tablespace := key.tablespace
+		if name, ok := tablespaceNames[tablespace]; ok {
+			tablespace = name
+		}
+		tablespaceStats := result.Tablespaces[tablespace]
+		tablespaceStats.add(*stats)
+		result.Tablespaces[tablespace] = tablespaceStats
+
+		if key.databaseOid == "" {
+			continue
+		}
+		database := key.databaseOid
+		if name, ok := databaseNames[database]; ok {
+			database = name
+		}
+		databaseStats := result.Databases[database]
+		databaseStats.add(*stats)
+		result.Databases[database] = databaseStats
+	}
+	return result
+}
+
+// parseSizeStatsKey finds the database and the tablespace of the file by its path in the data directory
+func parseSizeStatsKey(fileName string) (sizeStatsKey, bool) {
+	parts := strings.Split(strings.TrimPrefix(fileName, "/"), "/")
+	switch {
+	case parts[0] == DefaultTablespace && len(parts) >= 3:
+		return sizeStatsKey{databaseOid: parts[1], tablespace: DefaultTablespaceName}, true
+	case parts[0] == GlobalTablespace && len(parts) >= 2:
+		return sizeStatsKey{tablespace: GlobalTablespaceName}, true
+	case parts[0] == NonDefaultTablespace && len(parts) >= 5:
+		// pg_tblspc/<tablespace oid>/<version directory>/<database oid>/<file>
+		return sizeStatsKey{databaseOid: parts[3], tablespace: parts[1]}, true
+	}
+	return sizeStatsKey{}, false
+}
+
+// getCompressedTarBallSizes returns the sizes of the uploaded backup parts by the tarball names
+func getCompressedTarBallSizes(backupsFolder storage.Folder, backupName string) (map[string]int64, error) {
+	objects, _, err := backupsFolder.GetSubFolder(backupName + internal.TarPartitionFolderName).ListFolder()
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(objects))
+	for _, object := range objects {
+		sizes[path.Base(object.GetName())] = object.GetSize()
+	}
+	return sizes, nil
+}
+
+// sortedDatabases returns the database names from the largest compressed size to the smallest
+func (stats *BackupSizeStats) sortedDatabases() []string {
+	return sortSizeStatsNames(stats.Databases)
+}
+
+func (stats *BackupSizeStats) sortedTablespaces() []string {
+	return sortSizeStatsNames(stats.Tablespaces)
+}
+
+func sortSizeStatsNames(statsByName map[string]SizeStats) []string {
+	names := make([]string, 0, len(statsByName))
+	for name := range statsByName {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		left, right := statsByName[names[i]], statsByName[names[j]]
+		if left.CompressedSize != right.CompressedSize {
+			return left.CompressedSize > right.CompressedSize
+		}
+		if left.UncompressedSize != right.UncompressedSize {
+			return left.UncompressedSize > right.UncompressedSize
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// prettyFormatSize formats the size in bytes with the binary units, e.g. 1.5 GiB
+func prettyFormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
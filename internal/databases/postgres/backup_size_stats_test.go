@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestSizeStatsCollector(t *testing.T) {
+	collector := NewSizeStatsCollector()
+	// a full copy of a 4-page relation
+	collector.addFile("base/16384/16385", 4*DatabasePageSize, "part_001.tar.lz4", 4*DatabasePageSize, false, true)
+	// an increment of a 10-page relation with 2 changed pages
+	incrementSize := incrementHeaderSize + 2*(sizeofInt32+DatabasePageSize)
+	collector.addFile("pg_tblspc/16400/PG_15_202209061/16384/16401", 10*DatabasePageSize,
+		"part_002.tar.lz4", incrementSize, true, true)
+	// an unchanged 3-page relation skipped from the delta backup
+	collector.addFile("base/5/1259", 3*DatabasePageSize, "", 0, false, true)
+	collector.addFile("global/1262", DatabasePageSize, "part_001.tar.lz4", DatabasePageSize, false, true)
+	// the files outside of the tablespaces are not in the breakdown
+	collector.addFile("pg_xact/0000", 100, "part_001.tar.lz4", 100, false, false)
+
+	collector.setCompressedSizes(map[string]int64{"part_001.tar.lz4": 5000, "part_002.tar.lz4": 1000})
+	stats := collector.build(DatabasesByNames{"app": {Oid: 16384}, "postgres": {Oid: 5}},
+		map[string]string{"16400": "fast_disk"})
+
+	assert.Equal(t, SizeStats{
+		DataSize:         14 * DatabasePageSize,
+		UncompressedSize: 4*DatabasePageSize + incrementSize,
+		CompressedSize:   4000 + 1000,
+		ChangedPages:     6,
+		TotalPages:       14,
+	}, stats.Databases["app"])
+	assert.Equal(t, SizeStats{DataSize: 3 * DatabasePageSize, TotalPages: 3}, stats.Databases["postgres"])
+	assert.Len(t, stats.Databases, 2)
+
+	assert.Equal(t, int64(1000), stats.Tablespaces["fast_disk"].CompressedSize)
+	assert.Equal(t, int64(2), stats.Tablespaces["fast_disk"].ChangedPages)
+	assert.Equal(t, int64(7), stats.Tablespaces[DefaultTablespaceName].TotalPages)
+	assert.Equal(t, int64(1000), stats.Tablespaces[GlobalTablespaceName].CompressedSize)
+	assert.Equal(t, []string{"app", "postgres"}, stats.sortedDatabases())
+	assert.Equal(t, 20.0, stats.Tablespaces["fast_disk"].ChangedPagesPercent())
+}
+
+func TestSizeStatsCollector_UnknownNames(t *testing.T) {
+	collector := NewSizeStatsCollector()
+	collector.addFile("pg_tblspc/16400/PG_15_202209061/1/16401", DatabasePageSize, "part_001.tar", DatabasePageSize,
+		false, true)
+	stats := collector.build(DatabasesByNames{}, nil)
+	assert.Contains(t, stats.Databases, "1")
+	assert.Contains(t, stats.Tablespaces, "16400")
+}
+
+func TestGetCompressedTarBallSizes(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	require.NoError(t, folder.PutObject("base_1/tar_partitions/part_001.tar.lz4", strings.NewReader("12345")))
+	require.NoError(t, folder.PutObject("base_1/tar_partitions/part_002.tar.lz4", strings.NewReader("12")))
+
+	sizes, err := getCompressedTarBallSizes(folder, "base_1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"part_001.tar.lz4": 5, "part_002.tar.lz4": 2}, sizes)
+}
+
+func TestPrettyFormatSize(t *testing.T) {
+	assert.Equal(t, "100 B", prettyFormatSize(100))
+	assert.Equal(t, "1.5 KiB", prettyFormatSize(1536))
+	assert.Equal(t, "2.0 GiB", prettyFormatSize(2*1024*1024*1024))
+}
+
+func TestHandleBackupShow(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	backupsFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	backupName := "base_000000010000000000000002"
+	meta := ExtendedMetadataDto{
+		StartTime:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		FinishTime:       time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC),
+		PgVersion:        150000,
+		UncompressedSize: 3 * 1024 * 1024,
+		CompressedSize:   1024 * 1024,
+		SizeStats: &BackupSizeStats{
+			Databases: map[string]SizeStats{
+				"app": {DataSize: 4 * 1024 * 1024, UncompressedSize: 3 * 1024 * 1024, CompressedSize: 1024 * 1024,
+					ChangedPages: 128, TotalPages: 512},
+			},
+			Tablespaces: map[string]SizeStats{
+				DefaultTablespaceName: {DataSize: 4 * 1024 * 1024},
+			},
+		},
+	}
+	require.NoError(t, internal.UploadDto(backupsFolder, BackupSentinelDto{}, internal.SentinelNameFromBackup(backupName)))
+	require.NoError(t, internal.UploadDto(backupsFolder, meta, backupName+"/"+utility.MetadataFileName))
+
+	selector, err := internal.NewTargetBackupSelector("", backupName, NewGenericMetaFetcher())
+	require.NoError(t, err)
+	var output bytes.Buffer
+	require.NoError(t, HandleBackupShow(rootFolder, selector, false, &output))
+	assert.Regexp(t, `Compressed size:\s+1.0 MiB`, output.String())
+	assert.Regexp(t, `app\s+4.0 MiB\s+3.0 MiB\s+1.0 MiB\s+128\s+512\s+25.0%`, output.String())
+	assert.Contains(t, output.String(), "pg_default")
+
+	output.Reset()
+	require.NoError(t, HandleBackupShow(rootFolder, selector, true, &output))
+	var detail BackupDetail
+	require.NoError(t, json.Unmarshal(output.Bytes(), &detail))
+	assert.Equal(t, int64(128), detail.SizeStats.Databases["app"].ChangedPages)
+}
@@ -69,6 +69,7 @@ type Bundle struct {
 	DeltaMap           PagedFileDeltaMap
 	TablespaceSpec     TablespaceSpec
 	DataCatalogSize    *int64
+	SizeStats          *SizeStatsCollector
 
 	forceIncremental bool
 }
@@ -92,6 +93,7 @@ func NewBundle(
 		TablespaceSpec:     NewTablespaceSpec(directory),
 		forceIncremental:   forceIncremental,
 		DataCatalogSize:    new(int64),
+		SizeStats:          NewSizeStatsCollector(),
 	}
 }
 
@@ -272,6 +274,7 @@ func (bundle *Bundle) addToBundle(path string, info os.FileInfo) error {
 			// File was not changed since previous backup
 			tracelog.DebugLogger.Println("Skipped due to unchanged modification time: " + path)
 			bundle.TarBallComposer.SkipFile(fileInfoHeader, info)
+			bundle.SizeStats.addFile(fileInfoHeader.Name, info.Size(), "", 0, false, isPagedFile(info, path))
 			return nil
 		}
 		incrementBaseLsn := bundle.getIncrementBaseLsn()
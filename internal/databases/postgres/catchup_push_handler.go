@@ -19,6 +19,10 @@ func HandleCatchupPush(ctx context.Context, pgDataDirectory string, fromLSN LSN)
 	uploader, err := internal.ConfigureUploader()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	pushCatchupBackup(ctx, uploader, pgDataDirectory, fromLSN)
+}
+
+func pushCatchupBackup(ctx context.Context, uploader internal.Uploader, pgDataDirectory string, fromLSN LSN) {
 	pgDataDirectory = utility.ResolveSymlink(pgDataDirectory)
 
 	fakePreviousBackupSentinelDto := BackupSentinelDto{
@@ -34,7 +38,7 @@ func HandleCatchupPush(ctx context.Context, pgDataDirectory string, fromLSN LSN)
 		uploader, pgDataDirectory, utility.CatchupPath, false,
 		false, false, false,
 		RegularComposer, NewCatchupDeltaBackupConfigurator(fakePreviousBackupSentinelDto),
-		userData, false)
+		userData, false, false)
 
 	backupConfig, err := NewBackupHandler(backupArguments)
 	tracelog.ErrorLogger.FatalOnError(err)
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// CatchupSource describes how catchup-receive reaches catchup-send: either the TCP address
+// catchup-send listens on, or the command running catchup-send with its stdin and stdout (e.g. through SSH)
+type CatchupSource struct {
+	Address string
+	Command string
+}
+
+type catchupCommandConn struct {
+	io.Reader
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (conn *catchupCommandConn) Close() error {
+	err := conn.WriteCloser.Close()
+	waitErr := conn.cmd.Wait()
+	if err != nil {
+		return err
+	}
+	return waitErr
+}
+
+// HandleCatchupReceive is invoked to perform a wal-g catchup-receive. It streams the catchup backup
+// from catchup-send and applies it to the replica as it arrives. If fromLSN is zero, the replica LSN
+// is taken from pg_control.
+func HandleCatchupReceive(source CatchupSource, pgDataDirectory string, fromLSN LSN, useNewUnwrap bool) {
+	pgDataDirectory = utility.ResolveSymlink(pgDataDirectory)
+	pgControl, err := ExtractPgControl(pgDataDirectory)
+	tracelog.ErrorLogger.FatalfOnError("Failed to read the replica pg_control: %v", err)
+	if fromLSN == 0 {
+		fromLSN = pgControl.GetCheckpointRedo()
+		tracelog.InfoLogger.Printf("Detected replica LSN from pg_control: %s", fromLSN)
+	}
+
+	conn, err := dialCatchupSource(source)
+	tracelog.ErrorLogger.FatalfOnError("Failed to connect to catchup-send: %v", err)
+	defer utility.LoggedClose(conn, "")
+
+	request := CatchupStreamRequest{
+		SystemIdentifier: pgControl.GetSystemIdentifier(),
+		FromLSN:          fromLSN,
+	}
+	if source.Command == "" {
+		request.Secret = viper.GetString(internal.PgCatchupStreamSecret)
+	}
+	err = WriteCatchupMessage(conn, request)
+	tracelog.ErrorLogger.FatalOnError(err)
+	streamReader := bufio.NewReaderSize(conn, catchupChunkSize)
+	var response CatchupStreamResponse
+	err = ReadCatchupMessage(streamReader, &response)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if response.Error != "" {
+		tracelog.ErrorLogger.Fatalf("catchup-send rejected the request: %s", response.Error)
+	}
+
+	tracelog.InfoLogger.Printf("Receiving catchup backup from LSN %s", fromLSN)
+	if useNewUnwrap {
+		useNewUnwrapImplementation = true
+	}
+	applier := NewCatchupStreamApplier(pgDataDirectory, fromLSN, internal.ConfigureCrypter())
+	err = ReceiveCatchupStream(streamReader, applier.PutObject)
+	tracelog.ErrorLogger.FatalfOnError("Failed to receive catchup backup: %v", err)
+	err = applier.Finish()
+	tracelog.ErrorLogger.FatalfOnError("Failed to apply catchup backup: %v", err)
+}
+
+func dialCatchupSource(source CatchupSource) (io.ReadWriteCloser, error) {
+	if source.Command == "" {
+		return net.Dial("tcp", source.Address)
+	}
+	cmd := exec.Command("sh", "-c", source.Command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &catchupCommandConn{Reader: stdout, WriteCloser: stdin, cmd: cmd}, nil
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// catchupRequestTimeout limits the time the connection accepted by catchup-send takes to send the request
+const catchupRequestTimeout = time.Minute
+
+type catchupStdioConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (conn catchupStdioConn) Close() error {
+	return nil
+}
+
+// HandleCatchupSend is invoked to perform a wal-g catchup-send. It serves a single catchup-receive
+// through stdin and stdout, or through the connection accepted on the listen address if it's set.
+func HandleCatchupSend(ctx context.Context, pgDataDirectory string, listenAddress string) {
+	conn, request, err := acceptCatchupRequest(listenAddress)
+	tracelog.ErrorLogger.FatalfOnError("Failed to accept catchup-receive connection: %v", err)
+	defer utility.LoggedClose(conn, "")
+
+	err = checkCatchupRequest(pgDataDirectory, request)
+	if err != nil {
+		tracelog.ErrorLogger.PrintOnError(WriteCatchupMessage(conn, CatchupStreamResponse{Error: err.Error()}))
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
+	err = WriteCatchupMessage(conn, CatchupStreamResponse{})
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Sending catchup backup from LSN %s", request.FromLSN)
+
+	streamWriter := bufio.NewWriterSize(conn, catchupChunkSize)
+	streamFolder := NewCatchupStreamFolder(streamWriter)
+	uploader, err := internal.ConfigureUploaderToFolder(streamFolder)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	pushCatchupBackup(ctx, uploader, pgDataDirectory, request.FromLSN)
+
+	err = streamFolder.Finish()
+	tracelog.ErrorLogger.FatalfOnError("Failed to finish catchup stream: %v", err)
+	err = streamWriter.Flush()
+	tracelog.ErrorLogger.FatalfOnError("Failed to finish catchup stream: %v", err)
+}
+
+// acceptCatchupRequest reads the catchup-receive request from stdin. If the listen address is set,
+// the connections are accepted until one of them is authenticated by the shared secret.
+func acceptCatchupRequest(listenAddress string) (io.ReadWriteCloser, CatchupStreamRequest, error) {
+	var request CatchupStreamRequest
+	if listenAddress == "" {
+		err := ReadCatchupMessage(bufio.NewReader(os.Stdin), &request)
+		return catchupStdioConn{Reader: os.Stdin, Writer: os.Stdout}, request, err
+	}
+	secret := viper.GetString(internal.PgCatchupStreamSecret)
+	if secret == "" {
+		return nil, request, errors.Errorf("%s must be set to accept catchup-receive over TCP",
+			internal.PgCatchupStreamSecret)
+	}
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return nil, request, err
+	}
+	defer utility.LoggedClose(listener, "")
+	tracelog.InfoLogger.Printf("Waiting for catchup-receive on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil, request, err
+		}
+		request, err = readCatchupRequest(conn, secret)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Rejected catchup-receive from %s: %v", conn.RemoteAddr(), err)
+			utility.LoggedClose(conn, "")
+			continue
+		}
+		tracelog.InfoLogger.Printf("Accepted catchup-receive from %s", conn.RemoteAddr())
+		return conn, request, nil
+	}
+}
+
+// readCatchupRequest reads the request from the TCP connection and checks its secret
+func readCatchupRequest(conn net.Conn, secret string) (CatchupStreamRequest, error) {
+	var request CatchupStreamRequest
+	err := conn.SetReadDeadline(time.Now().Add(catchupRequestTimeout))
+	if err != nil {
+		return request, err
+	}
+	err = ReadCatchupMessage(bufio.NewReader(conn), &request)
+	if err != nil {
+		return request, err
+	}
+	if subtle.ConstantTimeCompare([]byte(request.Secret), []byte(secret)) != 1 {
+		err = errors.Errorf("the secret doesn't match %s", internal.PgCatchupStreamSecret)
+		tracelog.ErrorLogger.PrintOnError(WriteCatchupMessage(conn, CatchupStreamResponse{Error: err.Error()}))
+		return request, err
+	}
+	return request, conn.SetReadDeadline(time.Time{})
+}
+
+// checkCatchupRequest makes sure the replica is a copy of this cluster
+func checkCatchupRequest(pgDataDirectory string, request CatchupStreamRequest) error {
+	if request.FromLSN == 0 {
+		return errors.New("catchup-receive didn't provide the replica LSN")
+	}
+	pgControl, err := ExtractPgControl(utility.ResolveSymlink(pgDataDirectory))
+	if err != nil {
+		return errors.Wrap(err, "failed to read pg_control")
+	}
+	if request.SystemIdentifier != 0 && request.SystemIdentifier != pgControl.GetSystemIdentifier() {
+		return errors.Errorf("replica system identifier %d doesn't match the cluster one %d",
+			request.SystemIdentifier, pgControl.GetSystemIdentifier())
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package postgres
+
+/*
+The catchup stream carries a catchup backup straight from the primary to the lagging replica.
+The receiver sends a CatchupStreamRequest, the sender answers with a CatchupStreamResponse
+and, if the request is accepted, streams the objects the catchup backup consists of.
+The objects are uploaded concurrently, so the stream multiplexes them by id:
+
+	type byte | object id uint32 | payload length uint32 | payload
+
+The open frame carries the object path, the data frames carry the object content
+and the close frame finishes the object. The end frame finishes the stream.
+*/
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	catchupOpenFrame  byte = 'o'
+	catchupDataFrame  byte = 'd'
+	catchupCloseFrame byte = 'c'
+	catchupEndFrame   byte = 'e'
+
+	catchupFrameHeaderSize = 9
+	catchupChunkSize       = 1 << 20
+	// the object paths are short, the limit protects from the corrupted streams
+	catchupMaxPathLength = 4096
+)
+
+// CatchupStreamRequest is sent by the receiver to describe the replica being caught up.
+// Secret authenticates the receiver connected over TCP.
+type CatchupStreamRequest struct {
+	SystemIdentifier uint64 `json:"system_identifier"`
+	FromLSN          LSN    `json:"from_lsn"`
+	Secret           string `json:"secret,omitempty"`
+}
+
+// CatchupStreamResponse is sent by the sender before the backup, Error is set if the request is rejected
+type CatchupStreamResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// WriteCatchupMessage writes the request or response as a JSON line
+func WriteCatchupMessage(writer io.Writer, message interface{}) error {
+	return json.NewEncoder(writer).Encode(message)
+}
+
+// ReadCatchupMessage reads the request or response written by WriteCatchupMessage
+func ReadCatchupMessage(reader *bufio.Reader, message interface{}) error {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return errors.Wrap(err, "failed to read catchup stream message")
+	}
+	return json.Unmarshal(line, message)
+}
+
+type catchupStreamWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	nextID uint32
+}
+
+func (streamWriter *catchupStreamWriter) writeFrame(frameType byte, id uint32, payload []byte) error {
+	streamWriter.mutex.Lock()
+	defer streamWriter.mutex.Unlock()
+	return writeCatchupFrame(streamWriter.writer, frameType, id, payload)
+}
+
+func writeCatchupFrame(writer io.Writer, frameType byte, id uint32, payload []byte) error {
+	header := make([]byte, catchupFrameHeaderSize)
+	header[0] = frameType
+	binary.BigEndian.PutUint32(header[1:5], id)
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+	_, err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(payload)
+	return err
+}
+
+func (streamWriter *catchupStreamWriter) putObject(ctx context.Context, objectPath string, content io.Reader) error {
+	streamWriter.mutex.Lock()
+	streamWriter.nextID++
+	id := streamWriter.nextID
+	err := writeCatchupFrame(streamWriter.writer, catchupOpenFrame, id, []byte(objectPath))
+	streamWriter.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	chunk := make([]byte, catchupChunkSize)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n, readErr := content.Read(chunk)
+		if n > 0 {
+			err = streamWriter.writeFrame(catchupDataFrame, id, chunk[:n])
+			if err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	return streamWriter.writeFrame(catchupCloseFrame, id, nil)
+}
+
+// CatchupStreamFolder is the write-only storage.Folder sending the uploaded objects to the catchup stream
+type CatchupStreamFolder struct {
+	streamWriter *catchupStreamWriter
+	path         string
+}
+
+func NewCatchupStreamFolder(writer io.Writer) *CatchupStreamFolder {
+	return &CatchupStreamFolder{streamWriter: &catchupStreamWriter{writer: writer}}
+}
+
+// Finish writes the end of the stream, the receiver treats the stream without it as broken
+func (folder *CatchupStreamFolder) Finish() error {
+	return folder.streamWriter.writeFrame(catchupEndFrame, 0, nil)
+}
+
+func (folder *CatchupStreamFolder) GetPath() string {
+	return folder.path
+}
+
+func (folder *CatchupStreamFolder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return nil, nil, nil
+}
+
+func (folder *CatchupStreamFolder) DeleteObjects(objectRelativePaths []string) error {
+	return nil
+}
+
+func (folder *CatchupStreamFolder) Exists(objectRelativePath string) (bool, error) {
+	return false, nil
+}
+
+func (folder *CatchupStreamFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &CatchupStreamFolder{
+		streamWriter: folder.streamWriter,
+		path:         storage.AddDelimiterToPath(storage.JoinPath(folder.path, subFolderRelativePath)),
+	}
+}
+
+func (folder *CatchupStreamFolder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return nil, storage.NewObjectNotFoundError(path.Join(folder.path, objectRelativePath))
+}
+
+func (folder *CatchupStreamFolder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *CatchupStreamFolder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	tracelog.DebugLogger.Printf("Sending %s to the catchup stream", path.Join(folder.path, name))
+	return folder.streamWriter.putObject(ctx, path.Join(folder.path, name), content)
+}
+
+func (folder *CatchupStreamFolder) CopyObject(srcPath string, dstPath string) error {
+	return fmt.Errorf("copying objects is not supported by the catchup stream")
+}
+
+// ReceiveCatchupStream passes the objects read from the catchup stream to putObject as they arrive,
+// the objects are passed concurrently and must be read till the end
+func ReceiveCatchupStream(reader io.Reader, putObject func(objectPath string, content io.Reader) error) error {
+	objects := make(map[uint32]*io.PipeWriter)
+	errGroup := new(errgroup.Group)
+	defer func() {
+		for _, objectWriter := range objects {
+			_ = objectWriter.CloseWithError(errors.New("catchup stream is broken"))
+		}
+		_ = errGroup.Wait()
+	}()
+
+	header := make([]byte, catchupFrameHeaderSize)
+	payload := make([]byte, catchupChunkSize)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return errors.Wrap(err, "catchup stream ended unexpectedly")
+		}
+		frameType, id, length := header[0], binary.BigEndian.Uint32(header[1:5]), binary.BigEndian.Uint32(header[5:9])
+		if length > catchupChunkSize {
+			return errors.Errorf("catchup stream frame of %d bytes is too large", length)
+		}
+		_, err = io.ReadFull(reader, payload[:length])
+		if err != nil {
+			return errors.Wrap(err, "catchup stream ended unexpectedly")
+		}
+
+		objectWriter, ok := objects[id]
+		switch frameType {
+		case catchupOpenFrame:
+			objectPath := string(payload[:length])
+			if ok || length > catchupMaxPathLength || !isSafeCatchupObjectPath(objectPath) {
+				return errors.Errorf("unexpected catchup stream object %q", objectPath)
+			}
+			objectReader, objectWriter := io.Pipe()
+			objects[id] = objectWriter
+			errGroup.Go(func() error {
+				err := putObject(objectPath, objectReader)
+				_ = objectReader.CloseWithError(err)
+				return errors.Wrapf(err, "failed to put %s", objectPath)
+			})
+		case catchupDataFrame:
+			if !ok {
+				return errors.Errorf("unexpected data of catchup stream object %d", id)
+			}
+			_, err = objectWriter.Write(payload[:length])
+			if err != nil {
+				return err
+			}
+		case catchupCloseFrame:
+			if !ok {
+				return errors.Errorf("unexpected close of catchup stream object %d", id)
+			}
+			delete(objects, id)
+			_ = objectWriter.Close()
+		case catchupEndFrame:
+			if len(objects) > 0 {
+				return errors.Errorf("catchup stream ended with %d unfinished objects", len(objects))
+			}
+			return errGroup.Wait()
+		default:
+			return errors.Errorf("unknown catchup stream frame type %q", frameType)
+		}
+	}
+}
+
+func isSafeCatchupObjectPath(objectPath string) bool {
+	cleanPath := path.Clean(objectPath)
+	return objectPath != "" && !path.IsAbs(cleanPath) && cleanPath != ".." && !strings.HasPrefix(cleanPath, "../")
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/crypto"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// catchupIncrementHeaderSize is the size of the increment file header, the file size and the block count
+const catchupIncrementHeaderSize = 16
+
+// CatchupStreamApplier applies the catchup backup to the data directory while it's being received.
+// The files metadata is sent after the data, so the increments are recognized by their header.
+// pg_control is applied after the other files, so the interrupted catchup leaves the replica LSN
+// in pg_control intact and the catchup can be repeated from it.
+type CatchupStreamApplier struct {
+	dbDirectory string
+	sentinel    BackupSentinelDto
+	crypter     crypto.Crypter
+
+	mutex            sync.Mutex
+	pgControlPath    string
+	pgControlTar     []byte
+	sentinelReceived bool
+}
+
+func NewCatchupStreamApplier(dbDirectory string, fromLSN LSN, crypter crypto.Crypter) *CatchupStreamApplier {
+	// the replica is the base of the increments
+	incrementBase := "replica"
+	incrementCount := 1
+	sentinel := BackupSentinelDto{
+		IncrementFromLSN:  &fromLSN,
+		IncrementFrom:     &incrementBase,
+		IncrementFullName: &incrementBase,
+		IncrementCount:    &incrementCount,
+	}
+	return &CatchupStreamApplier{dbDirectory: dbDirectory, sentinel: sentinel, crypter: crypter}
+}
+
+// PutObject applies the catchup stream object, it's passed to ReceiveCatchupStream
+func (applier *CatchupStreamApplier) PutObject(objectPath string, content io.Reader) error {
+	switch {
+	case strings.HasSuffix(objectPath, utility.SentinelSuffix):
+		applier.mutex.Lock()
+		applier.sentinelReceived = true
+		applier.mutex.Unlock()
+	case !strings.Contains(objectPath, internal.TarPartitionFolderName):
+		// the backup metadata isn't needed to apply the backup
+	case pgControlTarRegexp.MatchString(path.Base(objectPath)):
+		pgControlTar, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		applier.mutex.Lock()
+		applier.pgControlPath, applier.pgControlTar = objectPath, pgControlTar
+		applier.mutex.Unlock()
+		return nil
+	default:
+		return applier.extractTar(objectPath, content)
+	}
+	_, err := io.Copy(io.Discard, content)
+	return err
+}
+
+// Finish applies pg_control, it's called after the whole stream is received and applied
+func (applier *CatchupStreamApplier) Finish() error {
+	if !applier.sentinelReceived {
+		return errors.New("catchup stream has no backup sentinel, the backup is incomplete")
+	}
+	if applier.pgControlTar == nil {
+		return newPgControlNotFoundError()
+	}
+	err := applier.extractTar(applier.pgControlPath, bytes.NewReader(applier.pgControlTar))
+	if err != nil {
+		return errors.Wrap(err, "failed to extract pg_control")
+	}
+	tracelog.InfoLogger.Print("\nBackup extraction complete.\n")
+	return nil
+}
+
+func (applier *CatchupStreamApplier) extractTar(objectPath string, content io.Reader) error {
+	tracelog.DebugLogger.Printf("Extracting %s", objectPath)
+	extractingReader, err := internal.DecryptAndDecompressTar(content, objectPath, applier.crypter)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(extractingReader, "")
+	err = internal.ExtractTar(applier, extractingReader)
+	if err != nil {
+		return errors.Wrapf(err, "Extraction error in %s", objectPath)
+	}
+	tracelog.InfoLogger.Printf("Finished extraction of %s", objectPath)
+	// the decompressor may leave the end of the object unread
+	_, err = io.Copy(io.Discard, content)
+	return err
+}
+
+// Interpret extracts the catchup backup file, the file is applied as the increment if it has the increment header
+func (applier *CatchupStreamApplier) Interpret(reader io.Reader, header *tar.Header) error {
+	filesMetadata := FilesMetadataDto{Files: internal.BackupFileList{}}
+	if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+		bufferedReader := bufio.NewReader(reader)
+		prefix, _ := bufferedReader.Peek(catchupIncrementHeaderSize)
+		if isCatchupIncrement(prefix, header.Size) {
+			filesMetadata.Files[header.Name] = internal.BackupFileDescription{IsIncremented: true}
+		}
+		reader = bufferedReader
+	}
+	tarInterpreter := NewFileTarInterpreter(applier.dbDirectory, applier.sentinel, filesMetadata, nil, true)
+	return tarInterpreter.Interpret(reader, header)
+}
+
+// isCatchupIncrement checks the file begins with the increment header and has the size the header describes,
+// so the full copy of the file beginning with the same bytes isn't taken as the increment
+func isCatchupIncrement(prefix []byte, size int64) bool {
+	if len(prefix) < catchupIncrementHeaderSize || !bytes.Equal(prefix[:len(IncrementFileHeader)], IncrementFileHeader) {
+		return false
+	}
+	blockCount := int64(binary.LittleEndian.Uint32(prefix[12:catchupIncrementHeaderSize]))
+	return size == catchupIncrementHeaderSize+blockCount*(sizeofInt32+DatabasePageSize)
+}
@@ -0,0 +1,167 @@
+package postgres_test
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func TestCatchupStream_TransfersObjects(t *testing.T) {
+	var stream bytes.Buffer
+	streamFolder := postgres.NewCatchupStreamFolder(&stream)
+	backupFolder := streamFolder.GetSubFolder(utility.CatchupPath).GetSubFolder("base_000000010000000000000003")
+
+	// the objects are uploaded concurrently, so their frames interleave
+	objects := make(map[string][]byte)
+	for i := 0; i < 4; i++ {
+		objects[fmt.Sprintf("tar_partitions/part_%d.tar.lz4", i)] = bytes.Repeat([]byte{byte(i)}, 3<<20+i)
+	}
+	var wg sync.WaitGroup
+	for name, content := range objects {
+		wg.Add(1)
+		go func(name string, content []byte) {
+			defer wg.Done()
+			assert.NoError(t, backupFolder.PutObject(name, bytes.NewReader(content)))
+		}(name, content)
+	}
+	wg.Wait()
+	require.NoError(t, streamFolder.GetSubFolder(utility.CatchupPath).PutObject("sentinel.json", bytes.NewReader(nil)))
+	require.NoError(t, streamFolder.Finish())
+
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	require.NoError(t, postgres.ReceiveCatchupStream(&stream, folder.PutObject))
+
+	for name, content := range objects {
+		reader, err := folder.ReadObject(utility.CatchupPath + "base_000000010000000000000003/" + name)
+		require.NoError(t, err)
+		received, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, received, name)
+	}
+	exists, err := folder.Exists(utility.CatchupPath + "sentinel.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestCatchupStream_BrokenStream(t *testing.T) {
+	var stream bytes.Buffer
+	streamFolder := postgres.NewCatchupStreamFolder(&stream)
+	require.NoError(t, streamFolder.PutObject("object", bytes.NewReader([]byte("data"))))
+
+	// the stream without the end frame
+	err := postgres.ReceiveCatchupStream(&stream, memory.NewFolder("in_memory/", memory.NewKVS()).PutObject)
+	assert.Error(t, err)
+}
+
+func TestCatchupStream_RejectsPathsOutsideFolder(t *testing.T) {
+	var stream bytes.Buffer
+	streamFolder := postgres.NewCatchupStreamFolder(&stream)
+	require.NoError(t, streamFolder.PutObject("../../etc/passwd", bytes.NewReader([]byte("data"))))
+	require.NoError(t, streamFolder.Finish())
+
+	err := postgres.ReceiveCatchupStream(&stream, memory.NewFolder("in_memory/", memory.NewKVS()).PutObject)
+	assert.Error(t, err)
+}
+
+func TestCatchupStream_Messages(t *testing.T) {
+	var stream bytes.Buffer
+	request := postgres.CatchupStreamRequest{SystemIdentifier: 7071540364069855427, FromLSN: 0x3000028}
+	require.NoError(t, postgres.WriteCatchupMessage(&stream, request))
+	require.NoError(t, postgres.WriteCatchupMessage(&stream, postgres.CatchupStreamResponse{Error: "rejected"}))
+
+	reader := bufio.NewReader(&stream)
+	var receivedRequest postgres.CatchupStreamRequest
+	require.NoError(t, postgres.ReadCatchupMessage(reader, &receivedRequest))
+	assert.Equal(t, request, receivedRequest)
+	var response postgres.CatchupStreamResponse
+	require.NoError(t, postgres.ReadCatchupMessage(reader, &response))
+	assert.Equal(t, "rejected", response.Error)
+}
+
+func newTestTar(t *testing.T, files map[string][]byte) []byte {
+	var tarBytes bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBytes)
+	for name, content := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)),
+			Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return tarBytes.Bytes()
+}
+
+// newTestReplicaDirectory makes the data directory of the replica being caught up
+func newTestReplicaDirectory(t *testing.T) string {
+	dataDirectory := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, "base/1"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDirectory, "global"), 0700))
+	return dataDirectory
+}
+
+func putTestCatchupBackup(t *testing.T, withSentinel bool) *bytes.Buffer {
+	var stream bytes.Buffer
+	streamFolder := postgres.NewCatchupStreamFolder(&stream)
+	catchupFolder := streamFolder.GetSubFolder(utility.CatchupPath)
+	tarFolder := catchupFolder.GetSubFolder("base_000000010000000000000003/tar_partitions")
+
+	// the full file beginning with the increment header isn't taken as the increment
+	lookalike := append(append([]byte{}, postgres.IncrementFileHeader...), make([]byte, 100)...)
+	require.NoError(t, tarFolder.PutObject("part_1.tar", bytes.NewReader(newTestTar(t, map[string][]byte{
+		"base/1/1234": allBlocksTestIncrement.incrementBytes,
+		"base/1/5678": lookalike,
+		"PG_VERSION":  []byte("15\n"),
+	}))))
+	require.NoError(t, tarFolder.PutObject("pg_control.tar", bytes.NewReader(newTestTar(t, map[string][]byte{
+		"global/pg_control": []byte("pg_control"),
+	}))))
+	if withSentinel {
+		require.NoError(t, catchupFolder.PutObject("base_000000010000000000000003"+utility.SentinelSuffix,
+			bytes.NewReader([]byte("{}"))))
+	}
+	require.NoError(t, streamFolder.Finish())
+	return &stream
+}
+
+func TestCatchupStreamApplier_AppliesBackup(t *testing.T) {
+	dataDirectory := newTestReplicaDirectory(t)
+	applier := postgres.NewCatchupStreamApplier(dataDirectory, sampleLSN, nil)
+	require.NoError(t, postgres.ReceiveCatchupStream(putTestCatchupBackup(t, true), applier.PutObject))
+	require.NoError(t, applier.Finish())
+
+	relationFile, err := os.ReadFile(filepath.Join(dataDirectory, "base/1/1234"))
+	require.NoError(t, err)
+	assert.Equal(t, allBlocksTestIncrement.fileSize, uint64(len(relationFile)))
+	lookalike, err := os.ReadFile(filepath.Join(dataDirectory, "base/1/5678"))
+	require.NoError(t, err)
+	assert.Len(t, lookalike, len(postgres.IncrementFileHeader)+100)
+	pgVersion, err := os.ReadFile(filepath.Join(dataDirectory, "PG_VERSION"))
+	require.NoError(t, err)
+	assert.Equal(t, "15\n", string(pgVersion))
+	pgControl, err := os.ReadFile(filepath.Join(dataDirectory, "global/pg_control"))
+	require.NoError(t, err)
+	assert.Equal(t, "pg_control", string(pgControl))
+}
+
+func TestCatchupStreamApplier_IncompleteBackup(t *testing.T) {
+	dataDirectory := newTestReplicaDirectory(t)
+	applier := postgres.NewCatchupStreamApplier(dataDirectory, sampleLSN, nil)
+	require.NoError(t, postgres.ReceiveCatchupStream(putTestCatchupBackup(t, false), applier.PutObject))
+	assert.Error(t, applier.Finish())
+
+	// pg_control keeps the replica LSN to repeat the catchup from
+	_, err := os.Stat(filepath.Join(dataDirectory, "global/pg_control"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -117,7 +117,7 @@ func (maker *CopyTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBallCom
 	}
 	files := &internal.RegularBundleFiles{}
 	tarBallFilePacker := NewTarBallFilePacker(bundle.DeltaMap,
-		bundle.IncrementFromLsn, files, maker.filePackerOptions)
+		bundle.IncrementFromLsn, files, maker.filePackerOptions, bundle.SizeStats)
 	return NewCopyTarBallComposer(bundle.TarBallQueue, tarBallFilePacker, files,
 		bundle.Crypter, maker.previousBackup, maker.newBackupName, tarUnchangedFilesCount,
 		prevFileTar, prevTarFileSets)
@@ -1,14 +1,13 @@
 package postgres
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -59,6 +58,7 @@ func (h *CheckMessageHandler) Handle(_ context.Context, _ []byte) error {
 type ArchiveMessageHandler struct {
 	fd       net.Conn
 	uploader *WalUploader
+	state    *DaemonState
 }
 
 func (h *ArchiveMessageHandler) Handle(ctx context.Context, messageBody []byte) error {
@@ -77,7 +77,9 @@ func (h *ArchiveMessageHandler) Handle(ctx context.Context, messageBody []byte)
 	}
 	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
 	defer cancel()
+	h.state.startWalPush()
 	err = HandleWALPush(ctx, h.uploader, fullPath)
+	h.state.finishWalPush(walFileName, err)
 	if err != nil {
 		return fmt.Errorf("file archiving failed: %w", err)
 	}
@@ -91,6 +93,7 @@ func (h *ArchiveMessageHandler) Handle(ctx context.Context, messageBody []byte)
 type WalFetchMessageHandler struct {
 	fd     net.Conn
 	reader internal.StorageFolderReader
+	state  *DaemonState
 }
 
 func (h *WalFetchMessageHandler) Handle(_ context.Context, messageBody []byte) error {
@@ -109,7 +112,8 @@ func (h *WalFetchMessageHandler) Handle(_ context.Context, messageBody []byte) e
 	}
 	tracelog.DebugLogger.Printf("starting wal-fetch: %v -> %v\n", args[0], fullPath)
 
-	err = HandleWALFetch(h.reader, walFileName, fullPath, DaemonPrefetcher{})
+	err = h.state.fetchWal(h.reader, walFileName, fullPath)
+	h.state.finishWalFetch(err)
 	if _, isArchNonExistErr := err.(internal.ArchiveNonExistenceError); isArchNonExistErr {
 		tracelog.WarningLogger.Printf("ArchiveNonExistenceError: %v\n", err.Error())
 		_, err = h.fd.Write(daemon.ArchiveNonExistenceType.ToBytes())
@@ -129,10 +133,128 @@ func (h *WalFetchMessageHandler) Handle(_ context.Context, messageBody []byte) e
 	return nil
 }
 
+type VersionMessageHandler struct {
+	fd net.Conn
+}
+
+func (h *VersionMessageHandler) Handle(_ context.Context, messageBody []byte) error {
+	args, err := bytesToServiceArgs(messageBody)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("protocol version handshake incorrect arguments count")
+	}
+	clientVersion, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid client protocol version %q: %w", args[0], err)
+	}
+	if clientVersion < daemon.ProtocolVersion {
+		return fmt.Errorf("client protocol version %d is not supported, the daemon speaks version %d",
+			clientVersion, daemon.ProtocolVersion)
+	}
+	tracelog.DebugLogger.Printf("protocol version handshake: client %d, daemon %d\n", clientVersion, daemon.ProtocolVersion)
+	return writeServiceResponse(h.fd, []byte(strconv.Itoa(daemon.ProtocolVersion)))
+}
+
+type StatusMessageHandler struct {
+	fd    net.Conn
+	state *DaemonState
+}
+
+func (h *StatusMessageHandler) Handle(_ context.Context, _ []byte) error {
+	multiSt, err := ConfigureMultiStorage(false)
+	defer utility.LoggedClose(multiSt, "close multi-storage")
+	status := h.state.Status(multiSt)
+	if err != nil {
+		status.StorageError = fmt.Sprintf("configure multi-storage: %v", err)
+	}
+	response, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return writeServiceResponse(h.fd, response)
+}
+
+type MetricsMessageHandler struct {
+	fd    net.Conn
+	state *DaemonState
+}
+
+func (h *MetricsMessageHandler) Handle(_ context.Context, _ []byte) error {
+	response, err := h.state.Metrics()
+	if err != nil {
+		return err
+	}
+	return writeServiceResponse(h.fd, response)
+}
+
+type BackupPushMessageHandler struct {
+	fd    net.Conn
+	state *DaemonState
+}
+
+func (h *BackupPushMessageHandler) Handle(_ context.Context, messageBody []byte) error {
+	args, err := bytesToServiceArgs(messageBody)
+	if err != nil {
+		return err
+	}
+	backupPush, err := h.state.StartBackupPush(args)
+	if err != nil {
+		return err
+	}
+	response, err := json.Marshal(backupPush)
+	if err != nil {
+		return err
+	}
+	return writeServiceResponse(h.fd, response)
+}
+
+type BackupStatusMessageHandler struct {
+	fd    net.Conn
+	state *DaemonState
+}
+
+func (h *BackupStatusMessageHandler) Handle(_ context.Context, _ []byte) error {
+	backupPush := h.state.BackupPushStatus()
+	if backupPush == nil {
+		return fmt.Errorf("no backup-push has been started by the daemon")
+	}
+	response, err := json.Marshal(backupPush)
+	if err != nil {
+		return err
+	}
+	return writeServiceResponse(h.fd, response)
+}
+
+// NewServiceMessageHandler creates the handler for the messages available after the protocol version handshake,
+// they don't need the storage configured upfront
+func NewServiceMessageHandler(
+	messageType daemon.SocketMessageType,
+	c net.Conn,
+	state *DaemonState,
+) SocketMessageHandler {
+	switch messageType {
+	case daemon.VersionType:
+		return &VersionMessageHandler{c}
+	case daemon.StatusType:
+		return &StatusMessageHandler{c, state}
+	case daemon.MetricsType:
+		return &MetricsMessageHandler{c, state}
+	case daemon.BackupPushType:
+		return &BackupPushMessageHandler{c, state}
+	case daemon.BackupStatusType:
+		return &BackupStatusMessageHandler{c, state}
+	default:
+		return nil
+	}
+}
+
 func NewMessageHandler(
 	messageType daemon.SocketMessageType,
 	c net.Conn,
 	storage storage.Storage,
+	state *DaemonState,
 ) (SocketMessageHandler, error) {
 	switch messageType {
 	case daemon.CheckType:
@@ -142,14 +264,14 @@ func NewMessageHandler(
 		if err != nil {
 			return nil, err
 		}
-		return &ArchiveMessageHandler{c, walUploader}, nil
+		return &ArchiveMessageHandler{c, walUploader, state}, nil
 	case daemon.WalFetchType:
 		folderReader, err := internal.PrepareMultiStorageFolderReader(storage.RootFolder(), "")
 		if err != nil {
 			return nil, err
 		}
 
-		return &WalFetchMessageHandler{c, folderReader}, nil
+		return &WalFetchMessageHandler{c, folderReader, state}, nil
 	default:
 		return nil, nil
 	}
@@ -165,24 +287,7 @@ func NewMessageReader(c net.Conn) *SocketMessageReader {
 
 // Next method reads messages sequentially from the Reader
 func (r SocketMessageReader) Next() (messageType daemon.SocketMessageType, messageBody []byte, err error) {
-	messageParameters := make([]byte, 3)
-	_, err = io.ReadFull(r.c, messageParameters)
-	if err != nil {
-		return daemon.ErrorType, nil, fmt.Errorf("failed to read params: %w", err)
-	}
-	messageType = daemon.SocketMessageType(messageParameters[0])
-	var messageLength uint16
-	l := bytes.NewReader(messageParameters[1:3])
-	err = binary.Read(l, binary.BigEndian, &messageLength)
-	if err != nil {
-		return daemon.ErrorType, nil, fmt.Errorf("fail to read message len: %w", err)
-	}
-	messageBody = make([]byte, messageLength-3)
-	_, err = io.ReadFull(r.c, messageBody)
-	if err != nil {
-		return daemon.ErrorType, nil, fmt.Errorf("failed to read msg body: %w", err)
-	}
-	return messageType, messageBody, err
+	return daemon.ReadMessage(r.c)
 }
 
 // HandleDaemon is invoked to perform daemon mode
@@ -198,6 +303,12 @@ func HandleDaemon(options DaemonOptions) {
 		tracelog.ErrorLogger.Fatal("Error on listening socket:", err)
 	}
 
+	state := NewDaemonState()
+	walReadAhead, err := ConfigureWalReadAhead()
+	if err != nil {
+		tracelog.ErrorLogger.Fatal("Failed to configure WAL read-ahead:", err)
+	}
+	state.SetWalReadAhead(walReadAhead)
 	sdNotifyTicker := time.NewTicker(30 * time.Second)
 	defer sdNotifyTicker.Stop()
 	go SendSdNotify(sdNotifyTicker.C)
@@ -207,21 +318,39 @@ func HandleDaemon(options DaemonOptions) {
 		if err != nil {
 			tracelog.ErrorLogger.Fatal("Failed to accept, err:", err)
 		}
-		go Listen(context.Background(), fd)
+		go Listen(context.Background(), fd, state)
 	}
 }
 
 // Listen is used for listening connection and processing messages
-func Listen(ctx context.Context, c net.Conn) {
+func Listen(ctx context.Context, c net.Conn, state *DaemonState) {
 	defer utility.LoggedClose(c, fmt.Sprintf("Failed to close connection with %s \n", c.RemoteAddr()))
 	messageReader := NewMessageReader(c)
+	versionNegotiated := false
 	for {
 		messageType, messageBody, err := messageReader.Next()
 		if err != nil {
 			failAndLogError(c, fmt.Errorf("read message from %s, err: %v", c.RemoteAddr(), err))
 			return
 		}
-		err = handleMessage(ctx, messageType, messageBody, c)
+		if messageHandler := NewServiceMessageHandler(messageType, c, state); messageHandler != nil {
+			if messageType != daemon.VersionType && !versionNegotiated {
+				failServiceMessage(c, fmt.Errorf("message type %s requires the protocol version handshake",
+					string(messageType)))
+				return
+			}
+			err = messageHandler.Handle(ctx, messageBody)
+			if err != nil {
+				failServiceMessage(c, err)
+				return
+			}
+			if messageType != daemon.VersionType {
+				return
+			}
+			versionNegotiated = true
+			continue
+		}
+		err = handleMessage(ctx, messageType, messageBody, c, state)
 		if err != nil {
 			failAndLogError(c, err)
 			return
@@ -242,13 +371,14 @@ func handleMessage(
 	messageType daemon.SocketMessageType,
 	messageBody []byte,
 	conn net.Conn,
+	state *DaemonState,
 ) error {
 	multiSt, err := ConfigureMultiStorage(true)
 	defer utility.LoggedClose(multiSt, "close multi-storage")
 	if err != nil {
 		return fmt.Errorf("configure multi-storage: %w", err)
 	}
-	messageHandler, err := NewMessageHandler(messageType, conn, multiSt)
+	messageHandler, err := NewMessageHandler(messageType, conn, multiSt, state)
 	if err != nil {
 		return fmt.Errorf("init handler for message type %s: %v", string(messageType), err)
 	}
@@ -270,6 +400,39 @@ func failAndLogError(c net.Conn, err error) {
 	}
 }
 
+// failServiceMessage responds to the service message with the error message
+func failServiceMessage(c net.Conn, err error) {
+	tracelog.ErrorLogger.Printf("Service message failure: %v", err)
+	response, err := daemon.NewMessage(daemon.ErrorType, []byte(err.Error()))
+	if err != nil {
+		response = daemon.ErrorType.ToBytes()
+	}
+	_, err = c.Write(response)
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Sending error response failed: %v", err)
+	}
+}
+
+func writeServiceResponse(c net.Conn, body []byte) error {
+	response, err := daemon.NewMessage(daemon.OkType, body)
+	if err != nil {
+		return err
+	}
+	_, err = c.Write(response)
+	if err != nil {
+		return newSocketWriteFailedError(err)
+	}
+	return nil
+}
+
+// bytesToServiceArgs decodes the service message args, the empty body stands for no args
+func bytesToServiceArgs(messageBody []byte) ([]string, error) {
+	if len(messageBody) == 0 {
+		return nil, nil
+	}
+	return daemon.BytesToArgs(messageBody)
+}
+
 func SendSdNotify(c <-chan time.Time) {
 	for {
 		<-c
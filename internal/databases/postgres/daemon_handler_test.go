@@ -0,0 +1,101 @@
+package postgres_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/daemon"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func startTestDaemonConnection(t *testing.T, state *postgres.DaemonState) net.Conn {
+	client, server := net.Pipe()
+	go postgres.Listen(context.Background(), server, state)
+	t.Cleanup(func() { _ = client.Close() })
+	require.NoError(t, client.SetDeadline(time.Now().Add(5*time.Second)))
+	return client
+}
+
+func sendTestServiceMessage(t *testing.T, conn net.Conn, messageType daemon.SocketMessageType,
+	args ...string) (daemon.SocketMessageType, []byte) {
+	body, err := daemon.ArgsToBytes(args...)
+	require.NoError(t, err)
+	message, err := daemon.NewMessage(messageType, body)
+	require.NoError(t, err)
+	_, err = conn.Write(message)
+	require.NoError(t, err)
+	responseType, responseBody, err := daemon.ReadMessage(conn)
+	require.NoError(t, err)
+	return responseType, responseBody
+}
+
+func negotiateTestProtocolVersion(t *testing.T, conn net.Conn) {
+	responseType, responseBody := sendTestServiceMessage(t, conn, daemon.VersionType,
+		strconv.Itoa(daemon.ProtocolVersion))
+	require.Equal(t, daemon.OkType, responseType, string(responseBody))
+	assert.Equal(t, strconv.Itoa(daemon.ProtocolVersion), string(responseBody))
+}
+
+func TestDaemonMetrics(t *testing.T) {
+	conn := startTestDaemonConnection(t, postgres.NewDaemonState())
+	negotiateTestProtocolVersion(t, conn)
+
+	responseType, responseBody := sendTestServiceMessage(t, conn, daemon.MetricsType)
+	require.Equal(t, daemon.OkType, responseType)
+	assert.Contains(t, string(responseBody), "walg_daemon_wal_push_total 0")
+	assert.Contains(t, string(responseBody), "walg_daemon_pending_uploads 0")
+}
+
+func TestDaemonServiceMessageRequiresHandshake(t *testing.T) {
+	conn := startTestDaemonConnection(t, postgres.NewDaemonState())
+
+	responseType, responseBody := sendTestServiceMessage(t, conn, daemon.MetricsType)
+	assert.Equal(t, daemon.ErrorType, responseType)
+	assert.Contains(t, string(responseBody), "handshake")
+}
+
+func TestDaemonOldClientVersion(t *testing.T) {
+	conn := startTestDaemonConnection(t, postgres.NewDaemonState())
+
+	responseType, _ := sendTestServiceMessage(t, conn, daemon.VersionType, "1")
+	assert.Equal(t, daemon.ErrorType, responseType)
+}
+
+func TestDaemonBackupPush(t *testing.T) {
+	state := postgres.NewDaemonState()
+	var pushArgs []string
+	state.SetBackupPushCommandMaker(func(args []string) (*exec.Cmd, error) {
+		pushArgs = args
+		return exec.Command("sh", "-c", "echo 'INFO: Wrote backup with name base_000000010000000000000002' >&2"), nil
+	})
+	conn := startTestDaemonConnection(t, state)
+	negotiateTestProtocolVersion(t, conn)
+
+	responseType, responseBody := sendTestServiceMessage(t, conn, daemon.BackupPushType, "--full", "--permanent")
+	require.Equal(t, daemon.OkType, responseType, string(responseBody))
+	var run postgres.BackupPushRun
+	require.NoError(t, json.Unmarshal(responseBody, &run))
+	assert.Equal(t, postgres.BackupPushRunning, run.State)
+	assert.Equal(t, []string{"--full", "--permanent"}, pushArgs)
+
+	assert.Eventually(t, func() bool {
+		return state.BackupPushStatus().State == postgres.BackupPushSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "base_000000010000000000000002", state.BackupPushStatus().BackupName)
+}
+
+func TestDaemonBackupPushUnsupportedFlag(t *testing.T) {
+	conn := startTestDaemonConnection(t, postgres.NewDaemonState())
+	negotiateTestProtocolVersion(t, conn)
+
+	responseType, responseBody := sendTestServiceMessage(t, conn, daemon.BackupPushType, "--target-storage")
+	assert.Equal(t, daemon.ErrorType, responseType)
+	assert.Contains(t, string(responseBody), "--target-storage")
+}
@@ -0,0 +1,377 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/daemon"
+	"github.com/wal-g/wal-g/internal/multistorage"
+)
+
+const (
+	BackupPushRunning   = "running"
+	BackupPushSucceeded = "succeeded"
+	BackupPushFailed    = "failed"
+
+	daemonMetricsPrefix = "walg_daemon_"
+)
+
+var (
+	backupPushNameRegexp = regexp.MustCompile(`Wrote backup with name (\S+)`)
+
+	// daemonBackupPushFlags are the backup-push flags accepted from the daemon clients
+	daemonBackupPushFlags = map[string]bool{
+		"--full":                   true,
+		"--permanent":              true,
+		"--verify":                 true,
+		"--include-wal":            true,
+		"--without-files-metadata": true,
+		"--add-user-data":          false,
+		"--delta-from-name":        false,
+		"--delta-from-user-data":   false,
+	}
+)
+
+type BackupPushAlreadyRunningError struct {
+	error
+}
+
+func newBackupPushAlreadyRunningError(startTime time.Time) BackupPushAlreadyRunningError {
+	return BackupPushAlreadyRunningError{errors.Errorf("backup-push started at %s is still running",
+		startTime.Format(time.RFC3339))}
+}
+
+func (err BackupPushAlreadyRunningError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// DaemonStatus is the response to the daemon status message
+type DaemonStatus struct {
+	ProtocolVersion  int             `json:"protocol_version"`
+	UptimeSeconds    int64           `json:"uptime_seconds"`
+	PendingUploads   int64           `json:"pending_uploads"`
+	LastArchivedWal  string          `json:"last_archived_wal,omitempty"`
+	LastArchivedTime *time.Time      `json:"last_archived_time,omitempty"`
+	Storages         map[string]bool `json:"storages,omitempty"`
+	StorageError     string          `json:"storage_error,omitempty"`
+	BackupPush       *BackupPushRun  `json:"backup_push,omitempty"`
+}
+
+// BackupPushRun describes the last backup-push started by the daemon
+type BackupPushRun struct {
+	State      string     `json:"state"`
+	Args       []string   `json:"args"`
+	StartTime  time.Time  `json:"start_time"`
+	FinishTime *time.Time `json:"finish_time,omitempty"`
+	BackupName string     `json:"backup_name,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// DaemonState keeps the daemon activity reported by the status and metrics messages
+type DaemonState struct {
+	startTime      time.Time
+	pendingUploads atomic.Int64
+
+	mutex            sync.Mutex
+	lastArchivedWal  string
+	lastArchivedTime time.Time
+	backupPush       *BackupPushRun
+
+	registry         *prometheus.Registry
+	walPushTotal     prometheus.Counter
+	walPushFailed    prometheus.Counter
+	walFetchTotal    prometheus.Counter
+	walFetchNotFound prometheus.Counter
+	walFetchFailed   prometheus.Counter
+	backupPushTotal  prometheus.Counter
+	backupPushFailed prometheus.Counter
+	readAheadHits    prometheus.Counter
+	readAheadMisses  prometheus.Counter
+
+	walReadAhead *WalReadAhead
+
+	// makeBackupPushCommand creates the backup-push process, it's replaced in tests
+	makeBackupPushCommand func(args []string) (*exec.Cmd, error)
+}
+
+func NewDaemonState() *DaemonState {
+	newCounter := func(name, help string) prometheus.Counter {
+		return prometheus.NewCounter(prometheus.CounterOpts{Name: daemonMetricsPrefix + name, Help: help})
+	}
+	state := &DaemonState{
+		startTime:             time.Now(),
+		registry:              prometheus.NewRegistry(),
+		walPushTotal:          newCounter("wal_push_total", "Number of WAL files archived by the daemon."),
+		walPushFailed:         newCounter("wal_push_failed_total", "Number of WAL archiving failures."),
+		walFetchTotal:         newCounter("wal_fetch_total", "Number of WAL files fetched by the daemon."),
+		walFetchNotFound:      newCounter("wal_fetch_not_found_total", "Number of requested WAL files missing in storage."),
+		walFetchFailed:        newCounter("wal_fetch_failed_total", "Number of WAL fetching failures."),
+		backupPushTotal:       newCounter("backup_push_total", "Number of backup-push runs started by the daemon."),
+		backupPushFailed:      newCounter("backup_push_failed_total", "Number of failed backup-push runs."),
+		readAheadHits:         newCounter("wal_read_ahead_hits_total", "Number of WAL files served from the read-ahead window."),
+		readAheadMisses:       newCounter("wal_read_ahead_misses_total", "Number of WAL files missing in the read-ahead window."),
+		makeBackupPushCommand: makeBackupPushCommand,
+	}
+	state.registry.MustRegister(state.walPushTotal, state.walPushFailed, state.walFetchTotal,
+		state.walFetchNotFound, state.walFetchFailed, state.backupPushTotal, state.backupPushFailed,
+		state.readAheadHits, state.readAheadMisses)
+	state.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: daemonMetricsPrefix + "wal_read_ahead_window",
+		Help: "Number of WAL files downloaded ahead of replay.",
+	}, func() float64 {
+		if state.walReadAhead == nil {
+			return 0
+		}
+		return float64(state.walReadAhead.Window())
+	}))
+	state.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: daemonMetricsPrefix + "pending_uploads",
+		Help: "Number of WAL files being archived.",
+	}, func() float64 { return float64(state.pendingUploads.Load()) }))
+	state.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: daemonMetricsPrefix + "uptime_seconds",
+		Help: "Time since the daemon start.",
+	}, func() float64 { return time.Since(state.startTime).Seconds() }))
+	state.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: daemonMetricsPrefix + "last_archived_timestamp_seconds",
+		Help: "Time of the last successful WAL archiving.",
+	}, func() float64 {
+		state.mutex.Lock()
+		defer state.mutex.Unlock()
+		if state.lastArchivedTime.IsZero() {
+			return 0
+		}
+		return float64(state.lastArchivedTime.Unix())
+	}))
+	return state
+}
+
+// SetBackupPushCommandMaker replaces the way the backup-push process is created
+func (state *DaemonState) SetBackupPushCommandMaker(makeCommand func(args []string) (*exec.Cmd, error)) {
+	state.makeBackupPushCommand = makeCommand
+}
+
+// SetWalReadAhead makes wal-fetch use the read-ahead window instead of the prefetch
+func (state *DaemonState) SetWalReadAhead(walReadAhead *WalReadAhead) {
+	state.walReadAhead = walReadAhead
+}
+
+func (state *DaemonState) fetchWal(reader internal.StorageFolderReader, walFileName string, location string) error {
+	if state.walReadAhead == nil {
+		return HandleWALFetch(reader, walFileName, location, DaemonPrefetcher{})
+	}
+	hit, err := state.walReadAhead.Fetch(reader, walFileName, location)
+	if hit {
+		state.readAheadHits.Inc()
+	} else {
+		state.readAheadMisses.Inc()
+	}
+	return err
+}
+
+func (state *DaemonState) startWalPush() {
+	state.pendingUploads.Add(1)
+}
+
+func (state *DaemonState) finishWalPush(walFileName string, err error) {
+	state.pendingUploads.Add(-1)
+	if err != nil {
+		state.walPushFailed.Inc()
+		return
+	}
+	state.walPushTotal.Inc()
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.lastArchivedWal = walFileName
+	state.lastArchivedTime = time.Now()
+}
+
+func (state *DaemonState) finishWalFetch(err error) {
+	switch err.(type) {
+	case nil:
+		state.walFetchTotal.Inc()
+	case internal.ArchiveNonExistenceError:
+		state.walFetchNotFound.Inc()
+	default:
+		state.walFetchFailed.Inc()
+	}
+}
+
+// Status collects the daemon status, the storages health is checked when the storage is provided
+func (state *DaemonState) Status(ms *multistorage.Storage) DaemonStatus {
+	status := DaemonStatus{
+		ProtocolVersion: daemon.ProtocolVersion,
+		UptimeSeconds:   int64(time.Since(state.startTime).Seconds()),
+		PendingUploads:  state.pendingUploads.Load(),
+	}
+	state.mutex.Lock()
+	if state.lastArchivedWal != "" {
+		lastArchivedTime := state.lastArchivedTime
+		status.LastArchivedWal = state.lastArchivedWal
+		status.LastArchivedTime = &lastArchivedTime
+	}
+	if state.backupPush != nil {
+		backupPush := *state.backupPush
+		status.BackupPush = &backupPush
+	}
+	state.mutex.Unlock()
+
+	if ms != nil {
+		status.Storages, status.StorageError = getStoragesHealth(ms)
+	}
+	return status
+}
+
+func getStoragesHealth(ms *multistorage.Storage) (map[string]bool, string) {
+	health := make(map[string]bool)
+	for _, name := range ms.StorageNames() {
+		health[name] = false
+	}
+	aliveFolder, err := multistorage.UseAllAliveStorages(ms.RootFolder())
+	if err != nil {
+		return health, err.Error()
+	}
+	for _, name := range multistorage.UsedStorages(aliveFolder) {
+		health[name] = true
+	}
+	return health, ""
+}
+
+// Metrics renders the daemon metrics in the Prometheus text format
+func (state *DaemonState) Metrics() ([]byte, error) {
+	metricFamilies, err := state.registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	for _, metricFamily := range metricFamilies {
+		_, err = expfmt.MetricFamilyToText(&buffer, metricFamily)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// BackupPushStatus returns the last backup-push run started by the daemon
+func (state *DaemonState) BackupPushStatus() *BackupPushRun {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.backupPush == nil {
+		return nil
+	}
+	backupPush := *state.backupPush
+	return &backupPush
+}
+
+// StartBackupPush runs backup-push in the background, only a single run is allowed at a time
+func (state *DaemonState) StartBackupPush(args []string) (*BackupPushRun, error) {
+	err := validateDaemonBackupPushArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.backupPush != nil && state.backupPush.State == BackupPushRunning {
+		return nil, newBackupPushAlreadyRunningError(state.backupPush.StartTime)
+	}
+	cmd, err := state.makeBackupPushCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	output, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = cmd.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start backup-push")
+	}
+	tracelog.InfoLogger.Printf("Started backup-push with args %v", args)
+	state.backupPushTotal.Inc()
+	state.backupPush = &BackupPushRun{
+		State:     BackupPushRunning,
+		Args:      args,
+		StartTime: time.Now(),
+	}
+	go state.waitBackupPush(cmd, output)
+	backupPush := *state.backupPush
+	return &backupPush, nil
+}
+
+func (state *DaemonState) waitBackupPush(cmd *exec.Cmd, output io.Reader) {
+	var backupName, lastLine string
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		lastLine = scanner.Text()
+		if match := backupPushNameRegexp.FindStringSubmatch(lastLine); match != nil {
+			backupName = match[1]
+		}
+	}
+	err := cmd.Wait()
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	finishTime := time.Now()
+	state.backupPush.FinishTime = &finishTime
+	state.backupPush.BackupName = backupName
+	if err != nil {
+		state.backupPushFailed.Inc()
+		state.backupPush.State = BackupPushFailed
+		state.backupPush.Error = fmt.Sprintf("%v: %s", err, lastLine)
+		tracelog.ErrorLogger.Printf("backup-push failed: %s", state.backupPush.Error)
+		return
+	}
+	state.backupPush.State = BackupPushSucceeded
+	tracelog.InfoLogger.Printf("backup-push finished, backup name: %s", backupName)
+}
+
+func validateDaemonBackupPushArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		isBoolFlag, ok := daemonBackupPushFlags[args[i]]
+		if !ok {
+			return errors.Errorf("backup-push flag %q is not supported by the daemon", args[i])
+		}
+		if !isBoolFlag {
+			i++
+			if i == len(args) {
+				return errors.Errorf("backup-push flag %q requires a value", args[i-1])
+			}
+		}
+	}
+	return nil
+}
+
+func makeBackupPushCommand(args []string) (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	pgData, err := getFullPath("")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{"backup-push", pgData}
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		cmdArgs = append(cmdArgs, "--config", configFile)
+	}
+	cmd := exec.Command(executable, append(cmdArgs, args...)...)
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
@@ -27,7 +27,8 @@ func NewDirDatabaseTarBallComposerMaker(files internal.BundleFiles, filePackerOp
 }
 
 func (m DirDatabaseTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBallComposer, error) {
-	tarPacker := NewTarBallFilePacker(bundle.DeltaMap, bundle.IncrementFromLsn, m.files, m.filePackerOptions)
+	tarPacker := NewTarBallFilePacker(bundle.DeltaMap, bundle.IncrementFromLsn, m.files, m.filePackerOptions,
+		bundle.SizeStats)
 	return newDirDatabaseTarBallComposer(
 		m.files,
 		bundle.TarBallQueue,
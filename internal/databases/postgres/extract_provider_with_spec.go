@@ -83,12 +83,14 @@ func (m DefaultRestoreDescMaker) Make(restoreParameters []string, names Database
 	restoredDatabases := make(RestoreDesc)
 
 	for _, parameter := range restoreParameters {
-		dbID, tableID, err := names.Resolve(parameter)
+		dbID, tableIDs, err := names.ResolveWithRelations(parameter)
 		if err != nil {
 			return nil, err
 		}
 
-		restoredDatabases.Add(dbID, tableID)
+		for _, tableID := range tableIDs {
+			restoredDatabases.Add(dbID, tableID)
+		}
 	}
 
 	return restoredDatabases, nil
@@ -118,25 +120,30 @@ func (m RegexpRestoreDescMaker) Make(restoreParameters []string, names Databases
 type ExtractProviderDBSpec struct {
 	RestoreParameters []string
 	restoreDescMaker  RestoreDescMaker
+	// restoreDesc is resolved from the catalog snapshot of the backup being fetched
+	// and then reused for its whole delta chain, since relations may be missing or have other names in base backups
+	restoreDesc RestoreDesc
 }
 
 func NewExtractProviderDBSpec(restoreParameters []string) *ExtractProviderDBSpec {
-	return &ExtractProviderDBSpec{restoreParameters, DefaultRestoreDescMaker{}}
+	return &ExtractProviderDBSpec{RestoreParameters: restoreParameters, restoreDescMaker: DefaultRestoreDescMaker{}}
 }
 
-func (p ExtractProviderDBSpec) Get(
+func (p *ExtractProviderDBSpec) Get(
 	backup Backup,
 	filesToUnwrap map[string]bool,
 	skipRedundantTars bool,
 	dbDataDir string,
 	createNewIncrementalFiles bool,
 ) (IncrementalTarInterpreter, []internal.ReaderMaker, string, error) {
-	_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
-	tracelog.ErrorLogger.FatalOnError(err)
+	if p.restoreDesc == nil {
+		_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
+		tracelog.ErrorLogger.FatalOnError(err)
 
-	desc, err := p.restoreDescMaker.Make(p.RestoreParameters, filesMeta.DatabasesByNames)
-	tracelog.ErrorLogger.FatalOnError(err)
-	desc.FilterFilesToUnwrap(filesToUnwrap)
+		p.restoreDesc, err = p.restoreDescMaker.Make(p.RestoreParameters, filesMeta.DatabasesByNames)
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
+	p.restoreDesc.FilterFilesToUnwrap(filesToUnwrap)
 
 	return ExtractProviderImpl{}.Get(backup, filesToUnwrap, skipRedundantTars, dbDataDir, createNewIncrementalFiles)
 }
@@ -105,3 +105,18 @@ func TestRestoreDesc_NoSystemDatabaseSkip(t *testing.T) {
 	restoreDesc.Add(20000, 30000)
 	assert.Equal(t, false, restoreDesc.IsSkipped(10000, 40000))
 }
+
+func TestDefaultRestoreDescMaker_AddsTableRelations(t *testing.T) {
+	names := make(postgres.DatabasesByNames)
+	names["db"] = *postgres.NewDatabaseObjectsInfo(20000)
+	names["db"].Tables["public.tbl"] = 30000
+	names["db"].Tables["public.other"] = 31000
+	names["db"].TableRelations["public.tbl"] = []uint32{30001, 30002}
+
+	restoreDesc, err := postgres.DefaultRestoreDescMaker{}.Make([]string{"db/tbl"}, names)
+	assert.NoError(t, err)
+	assert.Equal(t, false, restoreDesc.IsSkipped(20000, 30000))
+	assert.Equal(t, false, restoreDesc.IsSkipped(20000, 30001))
+	assert.Equal(t, false, restoreDesc.IsSkipped(20000, 30002))
+	assert.Equal(t, true, restoreDesc.IsSkipped(20000, 31000))
+}
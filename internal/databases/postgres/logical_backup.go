@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/printlist"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+)
+
+const (
+	// LogicalDatabaseDump is the pg_dump custom format archive of a single database
+	LogicalDatabaseDump = "database"
+	// LogicalGlobalsDump is the pg_dumpall --globals-only SQL script with the roles and tablespaces
+	LogicalGlobalsDump = "globals"
+	// LogicalClusterDump is the pg_dumpall SQL script of the whole cluster
+	LogicalClusterDump = "cluster"
+)
+
+// LogicalDump is a single pg_dump or pg_dumpall output stored in the logical backup
+type LogicalDump struct {
+	Type     string
+	Database string `json:",omitempty"`
+	// File is the name of the dump in the backup folder without the compression extension
+	File string
+}
+
+// LogicalBackupSentinelDto describes the logical backup made by logical-backup-push
+type LogicalBackupSentinelDto struct {
+	StartLocalTime   time.Time
+	StopLocalTime    time.Time
+	Hostname         string
+	ServerVersion    int
+	DumpFlags        []string `json:",omitempty"`
+	Dumps            []LogicalDump
+	UncompressedSize int64
+	CompressedSize   int64
+	IsPermanent      bool
+	UserData         interface{} `json:",omitempty"`
+}
+
+func (dto *LogicalBackupSentinelDto) Databases() []string {
+	databases := make([]string, 0, len(dto.Dumps))
+	for _, dump := range dto.Dumps {
+		if dump.Type == LogicalDatabaseDump {
+			databases = append(databases, dump.Database)
+		}
+	}
+	return databases
+}
+
+func (dto *LogicalBackupSentinelDto) findDump(dumpType string, database string) (LogicalDump, bool) {
+	for _, dump := range dto.Dumps {
+		if dump.Type == dumpType && dump.Database == database {
+			return dump, true
+		}
+	}
+	return LogicalDump{}, false
+}
+
+type LogicalBackupMetaFetcher struct{}
+
+func NewLogicalBackupMetaFetcher() LogicalBackupMetaFetcher {
+	return LogicalBackupMetaFetcher{}
+}
+
+func (mf LogicalBackupMetaFetcher) Fetch(backupName string, backupFolder storage.Folder) (internal.GenericMetadata, error) {
+	backup, err := internal.NewBackup(backupFolder, backupName)
+	if err != nil {
+		return internal.GenericMetadata{}, err
+	}
+	var sentinel LogicalBackupSentinelDto
+	err = backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return internal.GenericMetadata{}, err
+	}
+
+	return internal.GenericMetadata{
+		BackupName:       backupName,
+		UncompressedSize: sentinel.UncompressedSize,
+		CompressedSize:   sentinel.CompressedSize,
+		Hostname:         sentinel.Hostname,
+		StartTime:        sentinel.StartLocalTime,
+		FinishTime:       sentinel.StopLocalTime,
+		IsPermanent:      sentinel.IsPermanent,
+		IncrementDetails: &internal.NopIncrementDetailsFetcher{},
+		UserData:         sentinel.UserData,
+	}, nil
+}
+
+type LogicalBackupDetail struct {
+	BackupName       string      `json:"backup_name"`
+	ModifyTime       time.Time   `json:"modify_time"`
+	StartLocalTime   time.Time   `json:"start_local_time"`
+	StopLocalTime    time.Time   `json:"stop_local_time"`
+	Hostname         string      `json:"hostname"`
+	ServerVersion    int         `json:"server_version"`
+	Databases        []string    `json:"databases"`
+	DumpFlags        []string    `json:"dump_flags,omitempty"`
+	UncompressedSize int64       `json:"uncompressed_size"`
+	CompressedSize   int64       `json:"compressed_size"`
+	IsPermanent      bool        `json:"is_permanent"`
+	UserData         interface{} `json:"user_data,omitempty"`
+}
+
+//nolint:gocritic
+func NewLogicalBackupDetail(backupTime internal.BackupTime, sentinel LogicalBackupSentinelDto) LogicalBackupDetail {
+	databases := sentinel.Databases()
+	if _, ok := sentinel.findDump(LogicalClusterDump, ""); ok {
+		databases = []string{"*"}
+	}
+	return LogicalBackupDetail{
+		BackupName:       backupTime.BackupName,
+		ModifyTime:       backupTime.Time,
+		StartLocalTime:   sentinel.StartLocalTime,
+		StopLocalTime:    sentinel.StopLocalTime,
+		Hostname:         sentinel.Hostname,
+		ServerVersion:    sentinel.ServerVersion,
+		Databases:        databases,
+		DumpFlags:        sentinel.DumpFlags,
+		UncompressedSize: sentinel.UncompressedSize,
+		CompressedSize:   sentinel.CompressedSize,
+		IsPermanent:      sentinel.IsPermanent,
+		UserData:         sentinel.UserData,
+	}
+}
+
+func (bd *LogicalBackupDetail) PrintableFields() []printlist.TableField {
+	prettyModifyTime := internal.PrettyFormatTime(bd.ModifyTime)
+	prettyStartTime := internal.PrettyFormatTime(bd.StartLocalTime)
+	prettyStopTime := internal.PrettyFormatTime(bd.StopLocalTime)
+	return []printlist.TableField{
+		{
+			Name:       "name",
+			PrettyName: "Name",
+			Value:      bd.BackupName,
+		},
+		{
+			Name:        "last_modified",
+			PrettyName:  "Last modified",
+			Value:       internal.FormatTime(bd.ModifyTime),
+			PrettyValue: &prettyModifyTime,
+		},
+		{
+			Name:        "start_time",
+			PrettyName:  "Start time",
+			Value:       internal.FormatTime(bd.StartLocalTime),
+			PrettyValue: &prettyStartTime,
+		},
+		{
+			Name:        "stop_time",
+			PrettyName:  "Stop time",
+			Value:       internal.FormatTime(bd.StopLocalTime),
+			PrettyValue: &prettyStopTime,
+		},
+		{
+			Name:       "hostname",
+			PrettyName: "Hostname",
+			Value:      bd.Hostname,
+		},
+		{
+			Name:       "server_version",
+			PrettyName: "PG version",
+			Value:      strconv.Itoa(bd.ServerVersion),
+		},
+		{
+			Name:       "databases",
+			PrettyName: "Databases",
+			Value:      strings.Join(bd.Databases, ","),
+		},
+		{
+			Name:       "uncompressed_size",
+			PrettyName: "Uncompressed size",
+			Value:      strconv.FormatInt(bd.UncompressedSize, 10),
+		},
+		{
+			Name:       "compressed_size",
+			PrettyName: "Compressed size",
+			Value:      strconv.FormatInt(bd.CompressedSize, 10),
+		},
+		{
+			Name:       "is_permanent",
+			PrettyName: "Permanent",
+			Value:      fmt.Sprintf("%v", bd.IsPermanent),
+		},
+	}
+}
+
+// HandleDetailedLogicalBackupList prints the logical backups with the databases they contain
+func HandleDetailedLogicalBackupList(folder storage.Folder, pretty, json bool) {
+	backupTimes, err := internal.GetBackups(folder)
+	tracelog.ErrorLogger.FatalfOnError("Failed to fetch list of backups in storage: %s", err)
+
+	backupDetails := make([]LogicalBackupDetail, 0, len(backupTimes))
+	for _, backupTime := range backupTimes {
+		backup, err := internal.NewBackup(folder, backupTime.BackupName)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		var sentinel LogicalBackupSentinelDto
+		err = backup.FetchSentinel(&sentinel)
+		tracelog.ErrorLogger.FatalfOnError("Failed to load sentinel for backup %s", err)
+
+		backupDetails = append(backupDetails, NewLogicalBackupDetail(backupTime, sentinel))
+	}
+
+	printableEntities := make([]printlist.Entity, len(backupDetails))
+	for i := range backupDetails {
+		printableEntities[i] = &backupDetails[i]
+	}
+	err = printlist.List(printableEntities, os.Stdout, pretty, json)
+	tracelog.ErrorLogger.FatalfOnError("Print backups: %v", err)
+}
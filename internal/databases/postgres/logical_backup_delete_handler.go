@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type LogicalBackupDeleteHandler struct {
+	internal.DeleteHandler
+	permanentBackups []string
+}
+
+// NewLogicalBackupDeleteHandler creates the handler deleting the logical backups, the folder is the logical backups root
+func NewLogicalBackupDeleteHandler(folder storage.Folder) (*LogicalBackupDeleteHandler, error) {
+	backupSentinels, err := internal.GetBackupSentinelObjects(folder)
+	if err != nil {
+		return nil, err
+	}
+	backupObjects := make([]internal.BackupObject, 0, len(backupSentinels))
+	for _, object := range backupSentinels {
+		backupObjects = append(backupObjects, internal.NewDefaultBackupObject(object))
+	}
+
+	permanentBackups := internal.GetPermanentBackups(folder.GetSubFolder(utility.BaseBackupPath),
+		NewLogicalBackupMetaFetcher())
+	permanentBackupNames := make([]string, 0, len(permanentBackups))
+	for name := range permanentBackups {
+		permanentBackupNames = append(permanentBackupNames, name)
+	}
+	isPermanentFunc := func(object storage.Object) bool {
+		return internal.IsPermanent(object.GetName(), permanentBackups, internal.StreamBackupNameLength)
+	}
+
+	return &LogicalBackupDeleteHandler{
+		DeleteHandler: *internal.NewDeleteHandler(
+			folder,
+			backupObjects,
+			makeLogicalBackupLessFunc(),
+			internal.IsPermanentFunc(isPermanentFunc),
+		),
+		permanentBackups: permanentBackupNames,
+	}, nil
+}
+
+func (h *LogicalBackupDeleteHandler) HandleDeleteEverything(args []string, confirmed bool) {
+	h.DeleteHandler.HandleDeleteEverything(args, h.permanentBackups, confirmed)
+}
+
+func makeLogicalBackupLessFunc() func(object1, object2 storage.Object) bool {
+	return func(object1, object2 storage.Object) bool {
+		time1, ok1 := utility.TryFetchTimeRFC3999(object1.GetName())
+		time2, ok2 := utility.TryFetchTimeRFC3999(object2.GetName())
+		if !ok1 || !ok2 {
+			return object2.GetLastModified().After(object1.GetLastModified())
+		}
+		return time1 < time2
+	}
+}
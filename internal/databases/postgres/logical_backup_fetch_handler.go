@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// LogicalBackupFetchTarget selects what logical-backup-fetch restores: the dump of a single database
+// or the globals dump written to the output, or all dumps written to the output directory
+type LogicalBackupFetchTarget struct {
+	Database        string
+	Globals         bool
+	OutputDirectory string
+}
+
+// HandleLogicalBackupFetch is invoked to perform a wal-g logical-backup-fetch.
+// The folder is the logical backups root.
+func HandleLogicalBackupFetch(folder storage.Folder, targetBackupSelector internal.BackupSelector,
+	target LogicalBackupFetchTarget, output io.Writer) {
+	backup, err := targetBackupSelector.Select(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Fetching logical backup %s", backup.Name)
+
+	err = fetchLogicalBackup(backup, target, output)
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+func fetchLogicalBackup(backup internal.Backup, target LogicalBackupFetchTarget, output io.Writer) error {
+	var sentinel LogicalBackupSentinelDto
+	err := backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return err
+	}
+
+	if target.OutputDirectory != "" {
+		return fetchLogicalDumpsToDirectory(backup, &sentinel, target.OutputDirectory)
+	}
+
+	var dump LogicalDump
+	var ok bool
+	if target.Globals {
+		dump, ok = sentinel.findDump(LogicalGlobalsDump, "")
+		if !ok {
+			return errors.Errorf("backup %s has no globals dump", backup.Name)
+		}
+	} else {
+		dump, ok = sentinel.findDump(LogicalDatabaseDump, target.Database)
+		if !ok {
+			return errors.Errorf("backup %s has no dump of database %q, available databases: %s",
+				backup.Name, target.Database, strings.Join(sentinel.Databases(), ", "))
+		}
+	}
+	return fetchLogicalDump(backup, dump, output)
+}
+
+func fetchLogicalDump(backup internal.Backup, dump LogicalDump, output io.Writer) error {
+	reader, err := internal.DownloadAndDecompressStorageFile(internal.NewFolderReader(backup.Folder),
+		path.Join(backup.Name, dump.File))
+	if err != nil {
+		return errors.Wrapf(err, "failed to download %s", dump.File)
+	}
+	defer utility.LoggedClose(reader, "")
+	_, err = utility.FastCopy(output, reader)
+	return errors.Wrapf(err, "failed to fetch %s", dump.File)
+}
+
+func fetchLogicalDumpsToDirectory(backup internal.Backup, sentinel *LogicalBackupSentinelDto, directory string) error {
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return err
+	}
+	for _, dump := range sentinel.Dumps {
+		fileName := logicalDumpOutputFileName(dump)
+		tracelog.InfoLogger.Printf("Writing %s", fileName)
+		file, err := os.OpenFile(filepath.Join(directory, fileName), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		err = fetchLogicalDump(backup, dump, file)
+		closeErr := file.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
+
+// logicalDumpOutputFileName names the restored dump after the database, escaping the characters
+// which are not allowed in file names
+func logicalDumpOutputFileName(dump LogicalDump) string {
+	switch dump.Type {
+	case LogicalDatabaseDump:
+		return url.PathEscape(dump.Database) + ".dump"
+	default:
+		return dump.Type + ".sql"
+	}
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/limiters"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// logicalDumpCommand creates the pg_dump and pg_dumpall commands, tests replace it
+var logicalDumpCommand = exec.CommandContext
+
+// LogicalBackupPushArgs holds the arguments of logical-backup-push
+type LogicalBackupPushArgs struct {
+	// Databases to dump with pg_dump, all connectable databases except the templates if empty
+	Databases []string
+	// SkipGlobals disables the pg_dumpall --globals-only dump of the roles and tablespaces
+	SkipGlobals bool
+	// DumpAll makes a single pg_dumpall dump of the whole cluster instead of the per-database dumps
+	DumpAll bool
+	// DumpFlags are passed to pg_dump, or to pg_dumpall if DumpAll is set
+	DumpFlags   []string
+	IsPermanent bool
+	UserDataRaw string
+}
+
+// HandleLogicalBackupPush is invoked to perform a wal-g logical-backup-push. Every dump is streamed
+// to the backup folder and described in the backup sentinel.
+// The uploader must point to the basebackups folder of the logical backups root.
+func HandleLogicalBackupPush(ctx context.Context, uploader internal.Uploader, args LogicalBackupPushArgs) {
+	userData, err := internal.UnmarshalSentinelUserData(args.UserDataRaw)
+	tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+
+	conn, err := Connect()
+	tracelog.ErrorLogger.FatalOnError(err)
+	queryRunner, err := NewPgQueryRunner(conn)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	databases := args.Databases
+	if len(databases) == 0 && !args.DumpAll {
+		databases, err = getLogicalBackupDatabases(queryRunner)
+		tracelog.ErrorLogger.FatalfOnError("Failed to list databases: %v", err)
+	}
+	serverVersion := queryRunner.Version
+	utility.LoggedClose(conn, "")
+
+	sentinel, backupName, err := pushLogicalBackup(ctx, uploader, databases, args)
+	tracelog.ErrorLogger.FatalOnError(err)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to obtain the OS hostname")
+	}
+	sentinel.Hostname = hostname
+	sentinel.ServerVersion = serverVersion
+	sentinel.IsPermanent = args.IsPermanent
+	sentinel.UserData = userData
+
+	err = internal.UploadSentinel(uploader, sentinel, backupName)
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Wrote logical backup with name %s", backupName)
+}
+
+func getLogicalBackupDatabases(queryRunner *PgQueryRunner) ([]string, error) {
+	databaseInfos, err := queryRunner.GetDatabaseInfos()
+	if err != nil {
+		return nil, err
+	}
+	databases := make([]string, 0, len(databaseInfos))
+	for _, info := range databaseInfos {
+		// the templates are restored with the cluster, same as pg_dumpall skips them
+		if info.Name == "template0" || info.Name == "template1" {
+			continue
+		}
+		databases = append(databases, info.Name)
+	}
+	return databases, nil
+}
+
+func pushLogicalBackup(ctx context.Context, uploader internal.Uploader, databases []string,
+	args LogicalBackupPushArgs) (*LogicalBackupSentinelDto, string, error) {
+	backupName := internal.StreamPrefix + utility.TimeNowCrossPlatformUTC().Format(utility.BackupTimeFormat)
+	sentinel := &LogicalBackupSentinelDto{
+		StartLocalTime: utility.TimeNowCrossPlatformLocal(),
+		DumpFlags:      args.DumpFlags,
+		Dumps:          make([]LogicalDump, 0, len(databases)+1),
+	}
+
+	if args.DumpAll {
+		dump := LogicalDump{Type: LogicalClusterDump, File: "dumpall.sql"}
+		err := pushLogicalDump(ctx, uploader, backupName, dump, "pg_dumpall", args.DumpFlags)
+		if err != nil {
+			return nil, "", err
+		}
+		sentinel.Dumps = append(sentinel.Dumps, dump)
+	} else {
+		if !args.SkipGlobals {
+			dump := LogicalDump{Type: LogicalGlobalsDump, File: "globals.sql"}
+			err := pushLogicalDump(ctx, uploader, backupName, dump, "pg_dumpall", []string{"--globals-only"})
+			if err != nil {
+				return nil, "", err
+			}
+			sentinel.Dumps = append(sentinel.Dumps, dump)
+		}
+		for i, database := range databases {
+			dump := LogicalDump{Type: LogicalDatabaseDump, Database: database, File: fmt.Sprintf("db_%04d.dump", i)}
+			dumpArgs := append([]string{"--format=custom"}, args.DumpFlags...)
+			dumpArgs = append(dumpArgs, "--dbname="+logicalDumpConnString(database))
+			err := pushLogicalDump(ctx, uploader, backupName, dump, "pg_dump", dumpArgs)
+			if err != nil {
+				return nil, "", err
+			}
+			sentinel.Dumps = append(sentinel.Dumps, dump)
+		}
+	}
+	sentinel.StopLocalTime = utility.TimeNowCrossPlatformLocal()
+
+	var err error
+	sentinel.CompressedSize, err = uploader.UploadedDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc uploaded data size: %v", err)
+	}
+	sentinel.UncompressedSize, err = uploader.RawDataSize()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Failed to calc raw data size: %v", err)
+	}
+	return sentinel, backupName, nil
+}
+
+func pushLogicalDump(ctx context.Context, uploader internal.Uploader, backupName string, dump LogicalDump,
+	command string, commandArgs []string) error {
+	if dump.Database != "" {
+		tracelog.InfoLogger.Printf("Dumping database %s", dump.Database)
+	} else {
+		tracelog.InfoLogger.Printf("Dumping %s", dump.Type)
+	}
+	dumpCmd := logicalDumpCommand(ctx, command, commandArgs...)
+	tracelog.DebugLogger.Printf("Command to execute: %s", strings.Join(dumpCmd.Args, " "))
+	stdout, stderr, err := utility.StartCommandWithStdoutStderr(dumpCmd)
+	if err != nil {
+		return errors.Wrapf(err, "failed to start %s", command)
+	}
+
+	dstPath := path.Join(backupName, dump.File) + "." + uploader.Compression().FileExtension()
+	err = uploader.PushStreamToDestination(ctx, limiters.NewDiskLimitReader(stdout), dstPath)
+	if err != nil {
+		_ = dumpCmd.Wait()
+		return errors.Wrapf(err, "failed to push %s", dstPath)
+	}
+
+	err = dumpCmd.Wait()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("%s output:\n%s", command, stderr.String())
+		return errors.Wrapf(err, "%s failed", command)
+	}
+	return nil
+}
+
+// logicalDumpConnString quotes the database name, so pg_dump doesn't take it for a connection string
+func logicalDumpConnString(database string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)
+	return "dbname='" + escaped + "'"
+}
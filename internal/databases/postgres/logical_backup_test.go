@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// the fake dump command prints its name and arguments instead of the dump
+func fakeLogicalDumpCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", append([]string{"-c", `echo "$0 $*"`, name}, args...)...)
+}
+
+func pushTestLogicalBackup(t *testing.T, rootFolder storage.Folder, databases []string,
+	args LogicalBackupPushArgs) internal.Backup {
+	logicalDumpCommand = fakeLogicalDumpCommand
+	defer func() { logicalDumpCommand = exec.CommandContext }()
+
+	backupsFolder := rootFolder.GetSubFolder(utility.LogicalBackupPath).GetSubFolder(utility.BaseBackupPath)
+	uploader := internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], backupsFolder)
+	sentinel, backupName, err := pushLogicalBackup(context.Background(), uploader, databases, args)
+	require.NoError(t, err)
+	require.NoError(t, internal.UploadSentinel(uploader, sentinel, backupName))
+
+	backup, err := internal.NewBackup(backupsFolder, backupName)
+	require.NoError(t, err)
+	return backup
+}
+
+func TestLogicalBackup_PushAndFetchDatabase(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	backup := pushTestLogicalBackup(t, rootFolder, []string{"app", "it's"},
+		LogicalBackupPushArgs{DumpFlags: []string{"--no-owner"}})
+
+	var sentinel LogicalBackupSentinelDto
+	require.NoError(t, backup.FetchSentinel(&sentinel))
+	assert.Equal(t, []string{"app", "it's"}, sentinel.Databases())
+	assert.Equal(t, []string{"--no-owner"}, sentinel.DumpFlags)
+	assert.Len(t, sentinel.Dumps, 3)
+	assert.True(t, sentinel.UncompressedSize > 0)
+
+	var output bytes.Buffer
+	require.NoError(t, fetchLogicalBackup(backup, LogicalBackupFetchTarget{Database: "it's"}, &output))
+	assert.Equal(t, `pg_dump --format=custom --no-owner --dbname=dbname='it\'s'`+"\n", output.String())
+
+	output.Reset()
+	require.NoError(t, fetchLogicalBackup(backup, LogicalBackupFetchTarget{Globals: true}, &output))
+	assert.Equal(t, "pg_dumpall --globals-only\n", output.String())
+
+	err := fetchLogicalBackup(backup, LogicalBackupFetchTarget{Database: "missing"}, &output)
+	assert.Error(t, err)
+}
+
+func TestLogicalBackup_FetchToDirectory(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	backup := pushTestLogicalBackup(t, rootFolder, []string{"app", "a/b"}, LogicalBackupPushArgs{})
+
+	directory := t.TempDir()
+	require.NoError(t, fetchLogicalBackup(backup, LogicalBackupFetchTarget{OutputDirectory: directory}, nil))
+
+	names := make([]string, 0)
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"globals.sql", "app.dump", "a%2Fb.dump"}, names)
+
+	content, err := os.ReadFile(filepath.Join(directory, "app.dump"))
+	require.NoError(t, err)
+	assert.Equal(t, "pg_dump --format=custom --dbname=dbname='app'\n", string(content))
+}
+
+func TestLogicalBackup_PushDumpAll(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	backup := pushTestLogicalBackup(t, rootFolder, nil,
+		LogicalBackupPushArgs{DumpAll: true, DumpFlags: []string{"--clean"}})
+
+	var sentinel LogicalBackupSentinelDto
+	require.NoError(t, backup.FetchSentinel(&sentinel))
+	assert.Equal(t, []LogicalDump{{Type: LogicalClusterDump, File: "dumpall.sql"}}, sentinel.Dumps)
+
+	directory := t.TempDir()
+	require.NoError(t, fetchLogicalBackup(backup, LogicalBackupFetchTarget{OutputDirectory: directory}, nil))
+	content, err := os.ReadFile(filepath.Join(directory, "cluster.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "pg_dumpall --clean\n", string(content))
+}
+
+func TestLogicalBackup_DeleteKeepsPermanent(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewKVS())
+	logicalFolder := rootFolder.GetSubFolder(utility.LogicalBackupPath)
+	backupsFolder := logicalFolder.GetSubFolder(utility.BaseBackupPath)
+	for _, backup := range []struct {
+		name        string
+		isPermanent bool
+	}{
+		{"stream_20230101T000000Z", true},
+		{"stream_20230102T000000Z", false},
+		{"stream_20230103T000000Z", false},
+	} {
+		sentinel := LogicalBackupSentinelDto{IsPermanent: backup.isPermanent}
+		require.NoError(t, internal.UploadDto(backupsFolder, sentinel, internal.SentinelNameFromBackup(backup.name)))
+		require.NoError(t, backupsFolder.PutObject(backup.name+"/db_0000.dump.lz4", bytes.NewReader(nil)))
+	}
+
+	deleteHandler, err := NewLogicalBackupDeleteHandler(logicalFolder)
+	require.NoError(t, err)
+	deleteHandler.HandleDeleteRetain([]string{"1"}, true)
+
+	backups, err := internal.GetBackups(backupsFolder)
+	require.NoError(t, err)
+	names := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		names = append(names, backup.BackupName)
+	}
+	assert.ElementsMatch(t, []string{"stream_20230101T000000Z", "stream_20230103T000000Z"}, names)
+}
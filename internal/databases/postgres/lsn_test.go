@@ -0,0 +1,23 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := postgres.ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, postgres.LSN(0x16B374D848), lsn)
+	assert.Equal(t, "16/B374D848", lsn.String())
+}
+
+func TestParseLSN_Invalid(t *testing.T) {
+	for _, lsnStr := range []string{"", "16", "16/XYZ", "not an lsn"} {
+		_, err := postgres.ParseLSN(lsnStr)
+		assert.Error(t, err, lsnStr)
+	}
+}
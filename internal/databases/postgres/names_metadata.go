@@ -14,10 +14,26 @@ type DatabasesByNames map[string]DatabaseObjectsInfo
 type DatabaseObjectsInfo struct {
 	Oid    uint32            `json:"oid"`
 	Tables map[string]uint32 `json:"tables,omitempty"`
+	// TableRelations maps the table name to the relfilenodes of its TOAST table, TOAST index and indexes
+	TableRelations map[string][]uint32 `json:"table_relations,omitempty"`
+	// SystemRelations contains the relfilenodes of the rewritten system catalogs (e.g. by VACUUM FULL),
+	// which are not recognizable as system ones by the relfilenode value
+	SystemRelations []uint32 `json:"system_relations,omitempty"`
 }
 
 func NewDatabaseObjectsInfo(oid uint32) *DatabaseObjectsInfo {
-	return &DatabaseObjectsInfo{Oid: oid, Tables: make(map[string]uint32)}
+	return &DatabaseObjectsInfo{
+		Oid:            oid,
+		Tables:         make(map[string]uint32),
+		TableRelations: make(map[string][]uint32),
+	}
+}
+
+// relatedRelations returns the relfilenodes to be restored along with the table to make it usable
+func (info DatabaseObjectsInfo) relatedRelations(table string) []uint32 {
+	relations := make([]uint32, 0, len(info.TableRelations[table])+len(info.SystemRelations))
+	relations = append(relations, info.TableRelations[table]...)
+	return append(relations, info.SystemRelations...)
 }
 
 func (meta DatabasesByNames) Resolve(key string) (uint32, uint32, error) {
@@ -37,6 +53,22 @@ func (meta DatabasesByNames) Resolve(key string) (uint32, uint32, error) {
 	return 0, 0, newMetaDatabaseNameError(database)
 }
 
+// ResolveWithRelations resolves the database and the table like Resolve, but also returns
+// the relfilenodes of the table's TOAST table and indexes and of the rewritten system catalogs
+func (meta DatabasesByNames) ResolveWithRelations(key string) (uint32, []uint32, error) {
+	dbID, tableID, err := meta.Resolve(key)
+	if err != nil || tableID == 0 {
+		return dbID, []uint32{tableID}, err
+	}
+	_, table, _ := meta.unpackKey(key)
+	for _, info := range meta {
+		if info.Oid == dbID {
+			return dbID, append([]uint32{tableID}, info.relatedRelations(table)...), nil
+		}
+	}
+	return dbID, []uint32{tableID}, nil
+}
+
 func (meta DatabasesByNames) ResolveRegexp(key string) (map[uint32][]uint32, error) {
 	database, table, err := meta.unpackKey(key)
 	if err != nil {
@@ -59,6 +91,7 @@ func (meta DatabasesByNames) ResolveRegexp(key string) (map[uint32][]uint32, err
 			for name, oid := range dbInfo.Tables {
 				if tableRegexp.MatchString(name) {
 					toRestore[dbInfo.Oid] = append(toRestore[dbInfo.Oid], oid)
+					toRestore[dbInfo.Oid] = append(toRestore[dbInfo.Oid], dbInfo.relatedRelations(name)...)
 				}
 			}
 		}
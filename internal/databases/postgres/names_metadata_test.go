@@ -80,3 +80,43 @@ func TestDatabasesByNames_ResolveDots(t *testing.T) {
 	assert.Equal(t, uint32(0), tableID)
 	assert.Error(t, err)
 }
+
+func TestDatabasesByNames_ResolveWithRelations(t *testing.T) {
+	meta := genDatabasesByNames()
+	meta["my_database"].TableRelations["public.my_table"] = []uint32{30001, 30002, 30003}
+	info := meta["my_database"]
+	info.SystemRelations = []uint32{25000}
+	meta["my_database"] = info
+
+	dbID, tableIDs, err := meta.ResolveWithRelations("my_database/my_table")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20000), dbID)
+	assert.Equal(t, []uint32{30000, 30001, 30002, 30003, 25000}, tableIDs)
+}
+
+func TestDatabasesByNames_ResolveWithRelationsNoRelations(t *testing.T) {
+	meta := genDatabasesByNames()
+
+	dbID, tableIDs, err := meta.ResolveWithRelations("my_database/namespace.other_table")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20000), dbID)
+	assert.Equal(t, []uint32{31000}, tableIDs)
+}
+
+func TestDatabasesByNames_ResolveWithRelationsOnlyDatabase(t *testing.T) {
+	meta := genDatabasesByNames()
+
+	dbID, tableIDs, err := meta.ResolveWithRelations("my_database")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20000), dbID)
+	assert.Equal(t, []uint32{0}, tableIDs)
+}
+
+func TestDatabasesByNames_ResolveRegexpWithRelations(t *testing.T) {
+	meta := genDatabasesByNames()
+	meta["my_database"].TableRelations["public.my_table"] = []uint32{30001, 30002, 30003}
+
+	oids, err := meta.ResolveRegexp("my_database/my_*")
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint32][]uint32{20000: {30000, 30001, 30002, 30003}}, oids)
+}
@@ -53,3 +53,15 @@ func TestExtractPgControlData_NewVersion(t *testing.T) {
 	assert.Equal(t, uint64(9876), pgControlData.GetSystemIdentifier())
 	assert.Equal(t, uint32(7), pgControlData.GetCurrentTimeline())
 }
+
+func TestExtractPgControlData_CheckpointRedo(t *testing.T) {
+	for version, redoOffset := range map[uint32]int{1002: 48, 1300: 40} {
+		bytes := make([]byte, pgControlSize)
+		binary.LittleEndian.PutUint32(bytes[8:12], version)
+		binary.LittleEndian.PutUint64(bytes[redoOffset:redoOffset+8], 0x3000028)
+
+		pgControlData, err := extractPgControlData(bytes2.NewReader(bytes))
+		assert.Nil(t, err)
+		assert.Equal(t, LSN(0x3000028), pgControlData.GetCheckpointRedo())
+	}
+}
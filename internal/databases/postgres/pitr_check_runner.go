@@ -0,0 +1,261 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type PitrCheckDetails struct {
+	CurrentTimelineID uint32 `json:"current_timeline_id"`
+	// RecoverableFrom and RecoverableUntil bound the latest continuous window of the current timeline
+	RecoverableFrom  *time.Time    `json:"recoverable_from,omitempty"`
+	RecoverableUntil *time.Time    `json:"recoverable_until,omitempty"`
+	Windows          []*PitrWindow `json:"windows"`
+}
+
+func (details PitrCheckDetails) NewPlainTextReader() (io.Reader, error) {
+	var outputBuffer bytes.Buffer
+
+	if details.RecoverableFrom != nil {
+		outputBuffer.WriteString(fmt.Sprintf("Current timeline %d is recoverable from %s until %s\n",
+			details.CurrentTimelineID, internal.FormatTime(*details.RecoverableFrom),
+			internal.FormatTime(*details.RecoverableUntil)))
+	} else {
+		outputBuffer.WriteString(fmt.Sprintf("Current timeline %d is not recoverable\n", details.CurrentTimelineID))
+	}
+
+	tableWriter := table.NewWriter()
+	tableWriter.SetOutputMirror(&outputBuffer)
+	defer tableWriter.Render()
+
+	tableWriter.AppendHeader(table.Row{"TLI", "Backup", "Start LSN", "Start time", "End segment", "End time"})
+	for _, row := range details.Windows {
+		tableWriter.AppendRow(table.Row{row.TimelineID, row.BackupName, row.StartLsn,
+			internal.FormatTime(row.StartTime), row.EndSegment, internal.FormatTime(row.EndTime)})
+	}
+
+	return &outputBuffer, nil
+}
+
+// PitrWindow is a continuous time range the timeline can be recovered to:
+// it starts when the backup becomes consistent and ends with the last archived segment
+// of the continuous WAL sequence following the backup
+type PitrWindow struct {
+	TimelineID uint32    `json:"timeline_id"`
+	BackupName string    `json:"backup_name"`
+	StartLsn   LSN       `json:"start_lsn"`
+	StartTime  time.Time `json:"start_time"`
+	EndSegment string    `json:"end_segment"`
+	// EndTime is the time the end segment was archived at
+	EndTime time.Time `json:"end_time"`
+}
+
+// PitrCheckRunner finds the continuous recoverable time windows of every timeline in storage.
+// The WAL of a timeline is followed through its ancestors according to the .history file,
+// the same way Postgres requests it during recovery.
+type PitrCheckRunner struct {
+	currentTimeline uint32
+	walFolder       storage.Folder
+	walObjects      []storage.Object
+	backups         []BackupDetail
+}
+
+func NewPitrCheckRunner(rootFolder storage.Folder, currentWalSegment WalSegmentDescription) (PitrCheckRunner, error) {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	// the archive time of the segments is required, so the pre-fetched filenames are not enough
+	walObjects, _, err := walFolder.ListFolder()
+	if err != nil {
+		return PitrCheckRunner{}, errors.Wrap(err, "Failed to list the WAL folder")
+	}
+
+	backups, err := getTimelineBackups(rootFolder)
+	if err != nil {
+		return PitrCheckRunner{}, errors.Wrap(err, "Failed to get backups")
+	}
+
+	return PitrCheckRunner{
+		currentTimeline: currentWalSegment.Timeline,
+		walFolder:       walFolder,
+		walObjects:      walObjects,
+		backups:         backups,
+	}, nil
+}
+
+func (check PitrCheckRunner) Run() (WalVerifyCheckResult, error) {
+	segmentTimes := make(map[WalSegmentDescription]time.Time)
+	timelines := map[uint32]bool{check.currentTimeline: true}
+	for _, object := range check.walObjects {
+		timeline, ok := tryParseTimelineID(object.GetName())
+		if ok {
+			timelines[timeline] = true
+		}
+		segment, err := NewWalSegmentDescription(utility.TrimFileExtension(object.GetName()))
+		if err == nil {
+			segmentTimes[segment] = object.GetLastModified()
+		}
+	}
+
+	details := PitrCheckDetails{CurrentTimelineID: check.currentTimeline, Windows: make([]*PitrWindow, 0)}
+	lastSegmentNo := WalSegmentNo(0)
+	for timeline := range timelines {
+		path, err := newPitrTimelinePath(timeline, check.walFolder)
+		if err != nil {
+			return WalVerifyCheckResult{}, err
+		}
+		windows, err := path.findWindows(check.backups, segmentTimes)
+		if err != nil {
+			return WalVerifyCheckResult{}, err
+		}
+		details.Windows = append(details.Windows, windows...)
+		if timeline == check.currentTimeline {
+			lastSegmentNo = path.lastSegmentNo(segmentTimes)
+		}
+	}
+	sort.Slice(details.Windows, func(i, j int) bool {
+		if details.Windows[i].TimelineID != details.Windows[j].TimelineID {
+			return details.Windows[i].TimelineID < details.Windows[j].TimelineID
+		}
+		return details.Windows[i].StartLsn < details.Windows[j].StartLsn
+	})
+
+	return newPitrCheckResult(details, lastSegmentNo), nil
+}
+
+func (check PitrCheckRunner) Type() WalVerifyCheckType {
+	return WalVerifyPitrCheck
+}
+
+// newPitrCheckResult check produces the WalVerifyCheckResult with status:
+// StatusOk if the current timeline is recoverable up to its last archived segment
+// StatusWarning if there are segments archived after the latest window of the current timeline
+// StatusFailure if the current timeline has no recoverable window
+func newPitrCheckResult(details PitrCheckDetails, lastSegmentNo WalSegmentNo) WalVerifyCheckResult {
+	result := WalVerifyCheckResult{Status: StatusFailure, Details: details}
+
+	var currentWindow *PitrWindow
+	for _, window := range details.Windows {
+		if window.TimelineID == details.CurrentTimelineID &&
+			(currentWindow == nil || window.EndTime.After(currentWindow.EndTime)) {
+			currentWindow = window
+		}
+	}
+	if currentWindow == nil {
+		return result
+	}
+	details.RecoverableFrom = &currentWindow.StartTime
+	details.RecoverableUntil = &currentWindow.EndTime
+	result.Details = details
+
+	_, endSegmentNo, err := ParseWALFilename(currentWindow.EndSegment)
+	if err == nil && WalSegmentNo(endSegmentNo) >= lastSegmentNo {
+		result.Status = StatusOk
+	} else {
+		result.Status = StatusWarning
+	}
+	return result
+}
+
+// pitrTimelinePath maps the segment numbers to the timelines Postgres takes them from
+// when recovering to the timeline
+type pitrTimelinePath struct {
+	timeline       uint32
+	historyRecords []*TimelineHistoryRecord
+}
+
+func newPitrTimelinePath(timeline uint32, walFolder storage.Folder) (*pitrTimelinePath, error) {
+	historyRecords, err := GetTimeLineHistoryRecords(timeline, walFolder)
+	if _, ok := err.(HistoryFileNotFoundError); ok {
+		historyRecords = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &pitrTimelinePath{timeline: timeline, historyRecords: historyRecords}, nil
+}
+
+func (path *pitrTimelinePath) segmentTimeline(segmentNo WalSegmentNo) uint32 {
+	return timelineForSegment(segmentNo, path.timeline, path.historyRecords)
+}
+
+func (path *pitrTimelinePath) segment(segmentNo WalSegmentNo) WalSegmentDescription {
+	return WalSegmentDescription{Timeline: path.segmentTimeline(segmentNo), Number: segmentNo}
+}
+
+func (path *pitrTimelinePath) lastSegmentNo(segmentTimes map[WalSegmentDescription]time.Time) WalSegmentNo {
+	lastSegmentNo := WalSegmentNo(0)
+	for segment := range segmentTimes {
+		if segment.Number > lastSegmentNo && path.segmentTimeline(segment.Number) == segment.Timeline {
+			lastSegmentNo = segment.Number
+		}
+	}
+	return lastSegmentNo
+}
+
+// findWindows returns a window for each continuous WAL sequence, which starts with a backup
+// taken on the path and contains the WAL the backup requires
+func (path *pitrTimelinePath) findWindows(backups []BackupDetail,
+	segmentTimes map[WalSegmentDescription]time.Time) ([]*PitrWindow, error) {
+	type pathBackup struct {
+		*BackupDetail
+		startSegmentNo WalSegmentNo
+	}
+	pathBackups := make([]pathBackup, 0)
+	for i := range backups {
+		timeline, startSegmentNo, err := ParseWALFilename(backups[i].WalFileName)
+		if err != nil {
+			return nil, err
+		}
+		// skip the backups of the other branches
+		if path.segmentTimeline(WalSegmentNo(startSegmentNo)) == timeline {
+			pathBackups = append(pathBackups, pathBackup{&backups[i], WalSegmentNo(startSegmentNo)})
+		}
+	}
+	sort.Slice(pathBackups, func(i, j int) bool {
+		if pathBackups[i].startSegmentNo != pathBackups[j].startSegmentNo {
+			return pathBackups[i].startSegmentNo < pathBackups[j].startSegmentNo
+		}
+		return pathBackups[i].FinishLsn < pathBackups[j].FinishLsn
+	})
+
+	windows := make([]*PitrWindow, 0)
+	var lastEndSegmentNo WalSegmentNo
+	for _, backup := range pathBackups {
+		if _, ok := segmentTimes[path.segment(backup.startSegmentNo)]; !ok {
+			continue
+		}
+		endSegmentNo := backup.startSegmentNo
+		for {
+			if _, ok := segmentTimes[path.segment(endSegmentNo.Next())]; !ok {
+				break
+			}
+			endSegmentNo = endSegmentNo.Next()
+		}
+		if backup.FinishLsn > 0 && NewWalSegmentNo(backup.FinishLsn-1) > endSegmentNo {
+			// the backup can't be made consistent
+			continue
+		}
+		if len(windows) > 0 && lastEndSegmentNo == endSegmentNo {
+			// the earlier backup covers the same WAL sequence
+			continue
+		}
+		endSegment := path.segment(endSegmentNo)
+		windows = append(windows, &PitrWindow{
+			TimelineID: path.timeline,
+			BackupName: backup.BackupName,
+			StartLsn:   backup.FinishLsn,
+			StartTime:  backup.FinishTime,
+			EndSegment: endSegment.GetFileName(),
+			EndTime:    segmentTimes[endSegment],
+		})
+		lastEndSegmentNo = endSegmentNo
+	}
+	return windows, nil
+}
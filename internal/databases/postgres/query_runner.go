@@ -135,6 +135,20 @@ func (queryRunner *PgQueryRunner) BuildStopBackup() (string, error) {
 	}
 }
 
+// BuildSwitchWal formats a query that forces the switch to the next WAL segment
+func (queryRunner *PgQueryRunner) BuildSwitchWal() (string, error) {
+	switch {
+	case queryRunner.Version >= 100000:
+		return "SELECT pg_switch_wal()::text", nil
+	case queryRunner.Version >= 90000:
+		return "SELECT pg_switch_xlog()::text", nil
+	case queryRunner.Version == 0:
+		return "", NewNoPostgresVersionError()
+	default:
+		return "", NewUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
 // NewPgQueryRunner builds QueryRunner from available connection
 func NewPgQueryRunner(conn *pgx.Conn) (*PgQueryRunner, error) {
 	timeout, err := getStopBackupTimeoutSetting()
@@ -270,6 +284,34 @@ func (queryRunner *PgQueryRunner) stopBackup() (label string, offsetMap string,
 	return label, offsetMap, lsnStr, nil
 }
 
+// switchWal makes the primary finish the current WAL segment, so it can be archived
+func (queryRunner *PgQueryRunner) switchWal() (lsn LSN, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	tracelog.InfoLogger.Println("Calling pg_switch_wal()")
+	switchWalQuery, err := queryRunner.BuildSwitchWal()
+	if err != nil {
+		return 0, errors.Wrap(err, "QueryRunner SwitchWal: Building switch WAL query failed")
+	}
+
+	var lsnStr string
+	err = queryRunner.Connection.QueryRow(switchWalQuery).Scan(&lsnStr)
+	if err != nil {
+		return 0, errors.Wrap(err, "QueryRunner SwitchWal: switch WAL failed")
+	}
+	return ParseLSN(lsnStr)
+}
+
+// isInRecovery checks if the server is a standby
+func (queryRunner *PgQueryRunner) isInRecovery() (inRecovery bool, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	err = queryRunner.Connection.QueryRow("SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	return inRecovery, errors.Wrap(err, "QueryRunner IsInRecovery: pg_is_in_recovery() failed")
+}
+
 // BuildStatisticsQuery formats a query that fetch relations statistics from database
 func (queryRunner *PgQueryRunner) BuildStatisticsQuery() (string, error) {
 	switch {
@@ -554,3 +596,153 @@ func (queryRunner *PgQueryRunner) getTables() (map[string]uint32, error) {
 
 	return tables, nil
 }
+
+func (queryRunner *PgQueryRunner) BuildGetTableRelationsQuery() (string, error) {
+	switch {
+	case queryRunner.Version >= 90000:
+		// the TOAST table, the indexes and the TOAST indexes of the tables and the materialized views
+		return fmt.Sprintf("SELECT n.nspname, c.relname, dep.relfilenode FROM pg_class c "+
+			"JOIN pg_namespace n ON c.relnamespace = n.oid "+
+			"JOIN (SELECT oid AS relid, reltoastrelid AS depid FROM pg_class "+
+			"UNION SELECT indrelid, indexrelid FROM pg_index "+
+			"UNION SELECT t.oid, i.indexrelid FROM pg_class t JOIN pg_index i ON i.indrelid = t.reltoastrelid) rel "+
+			"ON rel.relid = c.oid "+
+			"JOIN pg_class dep ON dep.oid = rel.depid "+
+			"WHERE c.relkind IN ('r', 'p', 'm') AND c.oid >= %d AND dep.relfilenode <> 0", systemIDLimit), nil
+	case queryRunner.Version == 0:
+		return "", NewNoPostgresVersionError()
+	default:
+		return "", NewUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// getTableRelations fetches the relfilenodes of TOAST tables, TOAST indexes and indexes of each table
+func (queryRunner *PgQueryRunner) getTableRelations() (map[string][]uint32, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	getTableRelationsQuery, err := queryRunner.BuildGetTableRelationsQuery()
+	conn := queryRunner.Connection
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTableRelations: Building query failed")
+	}
+
+	rows, err := conn.Query(getTableRelationsQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTableRelations: Query failed")
+	}
+	defer rows.Close()
+
+	relations := make(map[string][]uint32)
+	for rows.Next() {
+		var namespaceName string
+		var tableName string
+		var relFileNode uint32
+		if err := rows.Scan(&namespaceName, &tableName, &relFileNode); err != nil {
+			tracelog.WarningLogger.Printf("GetTableRelations:  %v\n", err.Error())
+			continue
+		}
+		table := fmt.Sprintf("%s.%s", namespaceName, tableName)
+		relations[table] = append(relations[table], relFileNode)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return relations, nil
+}
+
+func (queryRunner *PgQueryRunner) BuildGetRewrittenSystemRelationsQuery() (string, error) {
+	switch {
+	case queryRunner.Version >= 90000:
+		return fmt.Sprintf("SELECT pg_relation_filenode(oid) FROM pg_class "+
+			"WHERE oid < %d AND pg_relation_filenode(oid) >= %d", systemIDLimit, systemIDLimit), nil
+	case queryRunner.Version == 0:
+		return "", NewNoPostgresVersionError()
+	default:
+		return "", NewUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// getRewrittenSystemRelations fetches the relfilenodes of system catalogs, which were rewritten
+// and got the relfilenode out of the system range
+func (queryRunner *PgQueryRunner) getRewrittenSystemRelations() ([]uint32, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	getSystemRelationsQuery, err := queryRunner.BuildGetRewrittenSystemRelationsQuery()
+	conn := queryRunner.Connection
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetRewrittenSystemRelations: Building query failed")
+	}
+
+	rows, err := conn.Query(getSystemRelationsQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetRewrittenSystemRelations: Query failed")
+	}
+	defer rows.Close()
+
+	relations := make([]uint32, 0)
+	for rows.Next() {
+		var relFileNode uint32
+		if err := rows.Scan(&relFileNode); err != nil {
+			tracelog.WarningLogger.Printf("GetRewrittenSystemRelations:  %v\n", err.Error())
+			continue
+		}
+		relations = append(relations, relFileNode)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return relations, nil
+}
+
+// BuildGetTablespacesQuery formats a query to get the names of the tablespaces
+func (queryRunner *PgQueryRunner) BuildGetTablespacesQuery() (string, error) {
+	switch {
+	case queryRunner.Version >= 90000:
+		return "SELECT oid, spcname FROM pg_tablespace", nil
+	case queryRunner.Version == 0:
+		return "", NewNoPostgresVersionError()
+	default:
+		return "", NewUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// getTablespaceNames fetches the tablespace names by OID
+func (queryRunner *PgQueryRunner) getTablespaceNames() (map[string]string, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	getTablespacesQuery, err := queryRunner.BuildGetTablespacesQuery()
+	conn := queryRunner.Connection
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTablespaceNames: Building query failed")
+	}
+
+	rows, err := conn.Query(getTablespacesQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTablespaceNames: Query failed")
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var oid uint32
+		var name string
+		if err := rows.Scan(&oid, &name); err != nil {
+			tracelog.WarningLogger.Printf("GetTablespaceNames:  %v\n", err.Error())
+			continue
+		}
+		names[strconv.FormatUint(uint64(oid), 10)] = name
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return names, nil
+}
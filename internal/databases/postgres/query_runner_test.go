@@ -61,3 +61,35 @@ func TestBuildStopBackup(t *testing.T) {
 	queryString, err = queryBuilder.BuildStopBackup()
 	assert.Equal(t, "SELECT labelfile, spcmapfile, lsn FROM pg_backup_stop(false)", queryString)
 }
+
+// Tests building switch WAL query
+func TestBuildSwitchWal(t *testing.T) {
+	queryBuilder := &postgres.PgQueryRunner{Version: 0}
+	_, err := queryBuilder.BuildSwitchWal()
+	assert.Error(t, err)
+
+	queryBuilder.Version = 81000
+	_, err = queryBuilder.BuildSwitchWal()
+	assert.IsType(t, err, postgres.UnsupportedPostgresVersionError{})
+
+	queryBuilder.Version = 90600
+	queryString, err := queryBuilder.BuildSwitchWal()
+	assert.Equal(t, "SELECT pg_switch_xlog()::text", queryString)
+
+	queryBuilder.Version = 100000
+	queryString, err = queryBuilder.BuildSwitchWal()
+	assert.Equal(t, "SELECT pg_switch_wal()::text", queryString)
+}
+
+func TestBuildGetTableRelationsQuery(t *testing.T) {
+	queryBuilder := &postgres.PgQueryRunner{Version: 0}
+	_, err := queryBuilder.BuildGetTableRelationsQuery()
+	assert.Error(t, err)
+
+	queryBuilder.Version = 90600
+	queryString, err := queryBuilder.BuildGetTableRelationsQuery()
+	assert.NoError(t, err)
+	assert.Contains(t, queryString, "UNION SELECT indrelid, indexrelid FROM pg_index")
+	assert.Contains(t, queryString, "c.relkind IN ('r', 'p', 'm')")
+	assert.NotContains(t, queryString, " OR ")
+}
@@ -60,7 +60,7 @@ func (maker *RegularTarBallComposerMaker) Make(bundle *Bundle) (internal.TarBall
 	bundleFiles := maker.files
 	tarFileSets := maker.tarFileSets
 	tarBallFilePacker := NewTarBallFilePacker(bundle.DeltaMap,
-		bundle.IncrementFromLsn, bundleFiles, maker.filePackerOptions)
+		bundle.IncrementFromLsn, bundleFiles, maker.filePackerOptions, bundle.SizeStats)
 	return NewRegularTarBallComposer(bundle.TarBallQueue, tarBallFilePacker, bundleFiles, tarFileSets, bundle.Crypter), nil
 }
 
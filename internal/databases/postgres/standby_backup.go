@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const primaryConnInfoParameter = "primary_conninfo"
+
+// waitForStandbyBackupWal makes the backup taken on standby restorable when backup-push returns.
+// The standby can't switch WAL segments, so the primary is asked to finish the segment containing
+// the backup stop LSN, and then the segment is waited to be archived.
+func (bh *BackupHandler) waitForStandbyBackupWal(rootFolder storage.Folder) error {
+	if !bh.Workers.Bundle.Replica || !viper.GetBool(internal.PgStandbyWaitWal) {
+		return nil
+	}
+	timeline, err := ParseTimelineFromBackupName(bh.CurBackupInfo.Name)
+	if err != nil {
+		return err
+	}
+	timeout, err := internal.GetDurationSetting(internal.PgStandbyWaitWalTimeout)
+	if err != nil {
+		return err
+	}
+	lastSegmentNo := NewWalSegmentNo(bh.CurBackupInfo.endLSN - 1)
+	walFilename := lastSegmentNo.GetFilename(timeline)
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+
+	objectName, err := findArchivedWalFile(walFolder, walFilename)
+	if err != nil {
+		return err
+	}
+	if objectName != "" {
+		return nil
+	}
+	err = switchPrimaryWal(bh.Workers.QueryRunner, bh.PgInfo.PgDataDirectory, lastSegmentNo)
+	if err != nil {
+		return errors.Wrap(err, "failed to switch WAL on primary, "+
+			"set WALG_STANDBY_WAIT_WAL=false if the WAL is archived otherwise")
+	}
+	tracelog.InfoLogger.Printf("Waiting for the backup stop WAL file %s to be archived", walFilename)
+	_, err = waitForArchivedWalFile(walFolder, walFilename, timeout)
+	return err
+}
+
+// switchPrimaryWal makes the primary finish the WAL segment, if it's still being written
+func switchPrimaryWal(standbyQueryRunner *PgQueryRunner, pgDataDirectory string, segmentNo WalSegmentNo) error {
+	connInfo, err := getPrimaryConnInfo(standbyQueryRunner, pgDataDirectory)
+	if err != nil {
+		return err
+	}
+	config, err := newPrimaryConnConfig(connInfo)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the primary connection string")
+	}
+	tracelog.InfoLogger.Printf("Connecting to primary %s:%d", config.Host, config.Port)
+	conn, err := pgx.Connect(config)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to primary")
+	}
+	defer utility.LoggedClose(conn, "")
+	queryRunner, err := NewPgQueryRunner(conn)
+	if err != nil {
+		return err
+	}
+
+	inRecovery, err := queryRunner.isInRecovery()
+	if err != nil {
+		return err
+	}
+	if inRecovery {
+		return errors.Errorf("the upstream server is a standby too, set %s to the primary connection string",
+			internal.PgStandbyPrimaryConnInfo)
+	}
+	currentLsnStr, err := queryRunner.getCurrentLsn()
+	if err != nil {
+		return err
+	}
+	currentLsn, err := ParseLSN(currentLsnStr)
+	if err != nil {
+		return err
+	}
+	if NewWalSegmentNo(currentLsn) > segmentNo {
+		// the primary has already finished the segment
+		return nil
+	}
+	_, err = queryRunner.switchWal()
+	return err
+}
+
+// getPrimaryConnInfo returns the connection string of the primary the standby replicates from
+func getPrimaryConnInfo(queryRunner *PgQueryRunner, pgDataDirectory string) (string, error) {
+	if connInfo := viper.GetString(internal.PgStandbyPrimaryConnInfo); connInfo != "" {
+		return connInfo, nil
+	}
+	var connInfo string
+	if queryRunner.Version >= 120000 {
+		var err error
+		connInfo, err = queryRunner.GetParameter(primaryConnInfoParameter)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s, set %s to the primary connection string",
+				primaryConnInfoParameter, internal.PgStandbyPrimaryConnInfo)
+		}
+	} else {
+		// before PostgreSQL 12 the standby settings are kept in recovery.conf
+		content, err := os.ReadFile(filepath.Join(pgDataDirectory, "recovery.conf"))
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read recovery.conf")
+		}
+		connInfo, _ = parseConfParameter(string(content), primaryConnInfoParameter)
+	}
+	if connInfo == "" {
+		return "", errors.Errorf("%s is not set, set %s to the primary connection string",
+			primaryConnInfoParameter, internal.PgStandbyPrimaryConnInfo)
+	}
+	return connInfo, nil
+}
+
+// newPrimaryConnConfig builds the primary connection config from the standby primary_conninfo
+func newPrimaryConnConfig(connInfo string) (pgx.ConnConfig, error) {
+	envConfig, err := pgx.ParseEnvLibpq()
+	if err != nil {
+		return pgx.ConnConfig{}, err
+	}
+	config, err := pgx.ParseConnectionString(connInfo)
+	if err != nil {
+		return pgx.ConnConfig{}, err
+	}
+	// primary_conninfo is written for the replication connection: it has no database
+	// and may have the libpq options, which are not the server settings
+	config.RuntimeParams = map[string]string{}
+	if config.User == "" {
+		config.User = envConfig.User
+	}
+	if config.Database == "" {
+		config.Database = envConfig.Database
+	}
+	if config.Database == "" {
+		config.Database = "postgres"
+	}
+	return config, nil
+}
+
+// parseConfParameter reads the parameter from the postgresql.conf formatted content, the last value wins
+func parseConfParameter(content string, name string) (value string, found bool) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		rest := strings.TrimLeft(line[len(name):], " \t")
+		if len(rest) == len(line[len(name):]) && !strings.HasPrefix(rest, "=") {
+			// the other parameter with the same prefix
+			continue
+		}
+		rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+		lineValue, ok := parseConfValue(rest)
+		if ok {
+			value, found = lineValue, true
+		}
+	}
+	return value, found
+}
+
+func parseConfValue(rest string) (string, bool) {
+	if !strings.HasPrefix(rest, "'") {
+		end := strings.IndexAny(rest, " \t#")
+		if end >= 0 {
+			rest = rest[:end]
+		}
+		return rest, rest != ""
+	}
+	var value strings.Builder
+	for i := 1; i < len(rest); i++ {
+		switch {
+		case rest[i] == '\'' && i+1 < len(rest) && rest[i+1] == '\'':
+			value.WriteByte('\'')
+			i++
+		case rest[i] == '\'':
+			return value.String(), true
+		case rest[i] == '\\' && i+1 < len(rest):
+			value.WriteByte(rest[i+1])
+			i++
+		default:
+			value.WriteByte(rest[i])
+		}
+	}
+	// unterminated quoted value
+	return "", false
+}
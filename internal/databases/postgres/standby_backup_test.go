@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfParameter(t *testing.T) {
+	content := `# primary_conninfo = 'host=commented'
+standby_mode = 'on'
+primary_conninfo_extra = 'host=other'
+primary_conninfo = 'host=old'
+primary_conninfo='user=''rep'' password=p\'s host=primary port=5433' # comment
+`
+	value, found := parseConfParameter(content, "primary_conninfo")
+	assert.True(t, found)
+	assert.Equal(t, `user='rep' password=p's host=primary port=5433`, value)
+
+	value, found = parseConfParameter("primary_slot_name slot_1 # comment", "primary_slot_name")
+	assert.True(t, found)
+	assert.Equal(t, "slot_1", value)
+
+	_, found = parseConfParameter("primary_conninfo = 'host=primary", "primary_conninfo")
+	assert.False(t, found)
+}
+
+func TestNewPrimaryConnConfig(t *testing.T) {
+	t.Setenv("PGUSER", "walg")
+	t.Setenv("PGDATABASE", "")
+
+	config, err := newPrimaryConnConfig("host=primary port=5433 sslmode=disable " +
+		"passfile=/var/lib/postgresql/.pgpass gssencmode=prefer target_session_attrs=any")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", config.Host)
+	assert.Equal(t, uint16(5433), config.Port)
+	assert.Equal(t, "walg", config.User)
+	assert.Equal(t, "postgres", config.Database)
+	assert.Empty(t, config.RuntimeParams)
+
+	config, err = newPrimaryConnConfig("postgres://rep@primary:5432/app")
+	require.NoError(t, err)
+	assert.Equal(t, "rep", config.User)
+	assert.Equal(t, "app", config.Database)
+}
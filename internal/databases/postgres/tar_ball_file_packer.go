@@ -46,15 +46,17 @@ type TarBallFilePackerImpl struct {
 	incrementFromLsn *LSN
 	files            internal.BundleFiles
 	options          TarBallFilePackerOptions
+	sizeStats        *SizeStatsCollector
 }
 
 func NewTarBallFilePacker(deltaMap PagedFileDeltaMap, incrementFromLsn *LSN, files internal.BundleFiles,
-	options TarBallFilePackerOptions) *TarBallFilePackerImpl {
+	options TarBallFilePackerOptions, sizeStats *SizeStatsCollector) *TarBallFilePackerImpl {
 	return &TarBallFilePackerImpl{
 		deltaMap:         deltaMap,
 		incrementFromLsn: incrementFromLsn,
 		files:            files,
 		options:          options,
+		sizeStats:        sizeStats,
 	}
 }
 
@@ -76,6 +78,7 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		switch err.(type) {
 		case SkippedFileError:
 			p.files.AddSkippedFile(cfi.Header, cfi.FileInfo)
+			p.sizeStats.addFile(cfi.Header.Name, cfi.FileInfo.Size(), "", 0, false, isPagedFile(cfi.FileInfo, cfi.Path))
 			return nil
 		case internal.FileNotExistError:
 			// File was deleted before opening.
@@ -115,6 +118,8 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		if packedFileSize != cfi.Header.Size {
 			return newTarSizeError(packedFileSize, cfi.Header.Size)
 		}
+		p.sizeStats.addFile(cfi.Header.Name, cfi.FileInfo.Size(), tarBall.Name(), packedFileSize,
+			cfi.IsIncremented, isPagedFile(cfi.FileInfo, cfi.Path))
 		return nil
 	})
 
@@ -12,6 +12,8 @@ type FilesToExtractProvider interface {
 		tarsToExtract []internal.ReaderMaker, pgControlKey string, err error)
 }
 
+var pgControlTarRegexp = regexp.MustCompile(`^.*?pg_control\.tar(\..+$|$)`)
+
 type FilesToExtractProviderImpl struct {
 }
 
@@ -29,14 +31,13 @@ func (t FilesToExtractProviderImpl) Get(backup Backup, filesToUnwrap map[string]
 	tracelog.DebugLogger.Printf("Tars to extract: '%+v'\n", tarNames)
 	tarsToExtract = make([]internal.ReaderMaker, 0, len(tarNames))
 
-	pgControlRe := regexp.MustCompile(`^.*?pg_control\.tar(\..+$|$)`)
 	for _, tarName := range tarNames {
 		// Separate the pg_control tarName from the others to
 		// extract it at the end, as to prevent server startup
 		// with incomplete backup restoration.  But only if it
 		// exists: it won't in the case of WAL-E backup
 		// backwards compatibility.
-		if pgControlRe.MatchString(tarName) {
+		if pgControlTarRegexp.MatchString(tarName) {
 			if pgControlKey != "" {
 				panic("expect only one pg_control tar name match")
 			}
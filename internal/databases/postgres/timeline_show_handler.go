@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// TimelineNode is a timeline in the tree of timelines found in storage
+type TimelineNode struct {
+	ID       uint32 `json:"id"`
+	ParentID uint32 `json:"parent_id,omitempty"`
+	// SwitchLsn is the position the timeline branched off the parent at
+	SwitchLsn LSN `json:"switch_lsn,omitempty"`
+	// SwitchTime is the time the .history file of the timeline was archived at
+	SwitchTime   *time.Time `json:"switch_time,omitempty"`
+	SwitchReason string     `json:"switch_reason,omitempty"`
+	StartSegment string     `json:"start_segment,omitempty"`
+	EndSegment   string     `json:"end_segment,omitempty"`
+	// ParentDiverged is set when the parent timeline has WAL archived after the switch point,
+	// which is the case when the old primary kept running after the failover
+	ParentDiverged bool `json:"parent_diverged"`
+	// DeadEnd is set for the branches, which are neither continued by other timelines nor the latest one
+	DeadEnd  bool              `json:"dead_end"`
+	Backups  []*TimelineBackup `json:"backups"`
+	Children []*TimelineNode   `json:"children"`
+}
+
+// TimelineBackup is a backup taken on the timeline
+type TimelineBackup struct {
+	Name     string    `json:"name"`
+	StartLsn LSN       `json:"start_lsn"`
+	Time     time.Time `json:"time"`
+}
+
+// HandleTimelineShow builds the tree of timelines from the .history files, WAL segments and backups
+// found in storage and writes it as a text tree or JSON
+func HandleTimelineShow(rootFolder storage.Folder, jsonOutput bool, output io.Writer) error {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	walObjects, _, err := walFolder.ListFolder()
+	if err != nil {
+		return fmt.Errorf("failed to list the WAL folder: %w", err)
+	}
+	backups, err := getTimelineBackups(rootFolder)
+	if err != nil {
+		return fmt.Errorf("failed to get backups: %w", err)
+	}
+	roots, err := BuildTimelineTree(walFolder, walObjects, backups)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return json.NewEncoder(output).Encode(roots)
+	}
+	return writeTimelineTree(output, roots)
+}
+
+// BuildTimelineTree builds the timeline tree, the roots are the timelines without known parents
+func BuildTimelineTree(walFolder storage.Folder, walObjects []storage.Object,
+	backups []BackupDetail) ([]*TimelineNode, error) {
+	nodes := make(map[uint32]*TimelineNode)
+	getNode := func(id uint32) *TimelineNode {
+		node, ok := nodes[id]
+		if !ok {
+			node = &TimelineNode{ID: id, Backups: make([]*TimelineBackup, 0), Children: make([]*TimelineNode, 0)}
+			nodes[id] = node
+		}
+		return node
+	}
+
+	filenames := make([]string, 0, len(walObjects))
+	for _, object := range walObjects {
+		filenames = append(filenames, object.GetName())
+		match := timelineHistoryFileRegexp.FindStringSubmatch(object.GetName())
+		if match == nil {
+			continue
+		}
+		id, err := strconv.ParseUint(match[1], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		node := getNode(uint32(id))
+		historyRecords, err := GetTimeLineHistoryRecords(node.ID, walFolder)
+		if err != nil {
+			return nil, err
+		}
+		if len(historyRecords) > 0 {
+			switchRecord := historyRecords[len(historyRecords)-1]
+			node.ParentID = switchRecord.timeline
+			node.SwitchLsn = switchRecord.lsn
+			node.SwitchReason = switchRecord.comment
+			getNode(node.ParentID)
+		}
+		switchTime := object.GetLastModified()
+		node.SwitchTime = &switchTime
+	}
+
+	segmentsByTimelines := groupSegmentsByTimelines(getSegmentsFromFiles(filenames))
+	for id, segments := range segmentsByTimelines {
+		node := getNode(id)
+		node.StartSegment = segments.MinSegmentNo.GetFilename(id)
+		node.EndSegment = segments.MaxSegmentNo.GetFilename(id)
+	}
+
+	for idx := range backups {
+		backup := &backups[idx]
+		backupTimeline, _, err := ParseWALFilename(backup.WalFileName)
+		if err != nil {
+			return nil, err
+		}
+		getNode(backupTimeline).Backups = append(getNode(backupTimeline).Backups, &TimelineBackup{
+			Name:     backup.BackupName,
+			StartLsn: backup.StartLsn,
+			Time:     backup.Time,
+		})
+	}
+
+	return linkTimelineNodes(nodes, segmentsByTimelines), nil
+}
+
+func linkTimelineNodes(nodes map[uint32]*TimelineNode, segmentsByTimelines map[uint32]*WalSegmentsSequence) []*TimelineNode {
+	ids := make([]uint32, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	roots := make([]*TimelineNode, 0)
+	if len(ids) == 0 {
+		return roots
+	}
+	for _, id := range ids {
+		node := nodes[id]
+		sort.Slice(node.Backups, func(i, j int) bool { return node.Backups[i].StartLsn < node.Backups[j].StartLsn })
+		parent, ok := nodes[node.ParentID]
+		if node.ParentID == 0 || !ok || node.ParentID >= node.ID {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+		if parentSegments, ok := segmentsByTimelines[parent.ID]; ok {
+			// the switch segment itself is archived by the old primary as .partial or in full
+			node.ParentDiverged = parentSegments.MaxSegmentNo > NewWalSegmentNo(node.SwitchLsn)
+		}
+	}
+
+	latestID := ids[len(ids)-1]
+	for _, node := range nodes {
+		node.DeadEnd = len(node.Children) == 0 && node.ID != latestID
+	}
+	return roots
+}
+
+func getTimelineBackups(rootFolder storage.Folder) ([]BackupDetail, error) {
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	backups, err := internal.GetBackups(baseBackupFolder)
+	if _, ok := err.(internal.NoBackupsFoundError); ok {
+		tracelog.InfoLogger.Println("No backups found in storage.")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetBackupsDetails(baseBackupFolder, backups)
+}
+
+func writeTimelineTree(output io.Writer, roots []*TimelineNode) error {
+	if len(roots) == 0 {
+		_, err := fmt.Fprintln(output, "No timelines found in storage")
+		return err
+	}
+	for _, root := range roots {
+		err := writeTimelineNode(output, root, "", "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTimelineNode(output io.Writer, node *TimelineNode, linePrefix, childPrefix string) error {
+	_, err := fmt.Fprintf(output, "%s%s\n", linePrefix, describeTimelineNode(node))
+	if err != nil {
+		return err
+	}
+	for _, backup := range node.Backups {
+		_, err = fmt.Fprintf(output, "%s    backup %s at %s (%s)\n", childPrefix, backup.Name, backup.StartLsn,
+			internal.FormatTime(backup.Time))
+		if err != nil {
+			return err
+		}
+	}
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		err = writeTimelineNode(output, child, childPrefix+branch, childPrefix+indent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describeTimelineNode(node *TimelineNode) string {
+	parts := []string{fmt.Sprintf("timeline %d", node.ID)}
+	if node.ParentID != 0 {
+		switchPoint := fmt.Sprintf("switched from %d at %s", node.ParentID, node.SwitchLsn)
+		if node.SwitchTime != nil {
+			switchPoint += " on " + internal.FormatTime(*node.SwitchTime)
+		}
+		if node.SwitchReason != "" {
+			switchPoint += " (" + node.SwitchReason + ")"
+		}
+		parts = append(parts, switchPoint)
+	}
+	if node.StartSegment != "" {
+		parts = append(parts, fmt.Sprintf("WAL %s - %s", node.StartSegment, node.EndSegment))
+	} else {
+		parts = append(parts, "no WAL")
+	}
+	if node.ParentDiverged {
+		parts = append(parts, "[parent diverged]")
+	}
+	if node.DeadEnd {
+		parts = append(parts, "[dead end]")
+	}
+	return strings.Join(parts, ", ")
+}
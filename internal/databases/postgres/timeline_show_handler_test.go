@@ -0,0 +1,99 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// putTestTimelines puts timeline 1 with two children: the timeline 2, which was abandoned,
+// and the timeline 3 branched off later
+func putTestTimelines(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	walFolder := folder.GetSubFolder(utility.WalPath)
+	files := map[string]string{
+		"00000002.history":         "1\t0/3000060\tno recovery target specified\n",
+		"00000003.history":         "1\t0/5000060\tno recovery target specified\n",
+		"000000010000000000000003": "",
+		"000000010000000000000004": "",
+		"000000010000000000000005": "",
+		"000000010000000000000006": "",
+		"000000020000000000000003": "",
+		"000000030000000000000005": "",
+		"000000030000000000000006": "",
+	}
+	for name, content := range files {
+		require.NoError(t, walFolder.PutObject(name, strings.NewReader(content)))
+	}
+	return folder
+}
+
+func TestBuildTimelineTree(t *testing.T) {
+	folder := putTestTimelines(t)
+	walFolder := folder.GetSubFolder(utility.WalPath)
+	objects, _, err := walFolder.ListFolder()
+	require.NoError(t, err)
+
+	roots, err := postgres.BuildTimelineTree(walFolder, objects, nil)
+	require.NoError(t, err)
+
+	require.Len(t, roots, 1)
+	root := roots[0]
+	assert.Equal(t, uint32(1), root.ID)
+	assert.Equal(t, "000000010000000000000003", root.StartSegment)
+	assert.Equal(t, "000000010000000000000006", root.EndSegment)
+	assert.False(t, root.DeadEnd)
+	require.Len(t, root.Children, 2)
+
+	abandoned, latest := root.Children[0], root.Children[1]
+	assert.Equal(t, uint32(2), abandoned.ID)
+	assert.Equal(t, postgres.LSN(0x3000060), abandoned.SwitchLsn)
+	assert.NotNil(t, abandoned.SwitchTime)
+	assert.True(t, abandoned.DeadEnd)
+	assert.True(t, abandoned.ParentDiverged)
+
+	assert.Equal(t, uint32(3), latest.ID)
+	assert.Equal(t, uint32(1), latest.ParentID)
+	assert.False(t, latest.DeadEnd)
+	assert.True(t, latest.ParentDiverged)
+}
+
+func TestHandleTimelineShow_Text(t *testing.T) {
+	var output bytes.Buffer
+	err := postgres.HandleTimelineShow(putTestTimelines(t), false, &output)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "timeline 1, WAL 000000010000000000000003"))
+	assert.True(t, strings.HasPrefix(lines[1], "├── timeline 2, switched from 1 at 0/3000060"))
+	assert.Contains(t, lines[1], "[dead end]")
+	assert.True(t, strings.HasPrefix(lines[2], "└── timeline 3, switched from 1 at 0/5000060"))
+	assert.NotContains(t, lines[2], "[dead end]")
+}
+
+func TestHandleTimelineShow_JSON(t *testing.T) {
+	var output bytes.Buffer
+	err := postgres.HandleTimelineShow(putTestTimelines(t), true, &output)
+	require.NoError(t, err)
+
+	var roots []*postgres.TimelineNode
+	require.NoError(t, json.Unmarshal(output.Bytes(), &roots))
+	require.Len(t, roots, 1)
+	assert.Len(t, roots[0].Children, 2)
+}
+
+func TestHandleTimelineShow_Empty(t *testing.T) {
+	var output bytes.Buffer
+	err := postgres.HandleTimelineShow(memory.NewFolder("in_memory/", memory.NewKVS()), false, &output)
+	require.NoError(t, err)
+	assert.Equal(t, "No timelines found in storage\n", output.String())
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// HandleWalDeltaBuild builds the delta files for the archived WAL segments between firstSegmentName
+// and lastSegmentName, the range is extended to whole delta file groups. Groups already having
+// a delta file in storage are skipped. The uploader must use the wal folder and have the DeltaFileManager set.
+func HandleWalDeltaBuild(ctx context.Context, rootFolder storage.Folder, uploader *WalUploader,
+	firstSegmentName, lastSegmentName string) error {
+	timeline, firstSegmentNo, lastSegmentNo, err := parseWalSegmentBounds(firstSegmentName, lastSegmentName)
+	if err != nil {
+		return err
+	}
+	if !uploader.getUseWalDelta() {
+		return errors.New("WAL uploader has no delta file manager configured")
+	}
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	existingDeltas, err := getExistingDeltaFilenames(walFolder)
+	if err != nil {
+		return err
+	}
+
+	builder := &walDeltaBuilder{
+		walFolderReader: internal.NewFolderReader(walFolder),
+		manager:         uploader.DeltaFileManager,
+		timeline:        timeline,
+	}
+	previousDeltaRecorded := false
+	lastDeltaNo := newDeltaNoFromWalSegmentNo(lastSegmentNo)
+	for deltaNo := newDeltaNoFromWalSegmentNo(firstSegmentNo); deltaNo <= lastDeltaNo; deltaNo = deltaNo.next() {
+		deltaFilename := deltaNo.getFilename(timeline)
+		if existingDeltas[deltaFilename] {
+			tracelog.InfoLogger.Printf("Delta file %s already exists, skipping it", deltaFilename)
+			previousDeltaRecorded = false
+			continue
+		}
+		if !previousDeltaRecorded {
+			err = builder.restorePreviousWalHead(deltaNo)
+			if err != nil {
+				return err
+			}
+		}
+		err = builder.recordDelta(deltaNo)
+		if err != nil {
+			return err
+		}
+		previousDeltaRecorded = true
+	}
+	uploader.FlushFiles(ctx)
+	return nil
+}
+
+func getExistingDeltaFilenames(walFolder storage.Folder) (map[string]bool, error) {
+	filenames, err := getFolderFilenames(walFolder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the WAL folder filenames")
+	}
+	deltaFilenames := make(map[string]bool)
+	for _, filename := range filenames {
+		baseName := utility.TrimFileExtension(filename)
+		if strings.HasSuffix(baseName, DeltaFilenameSuffix) {
+			deltaFilenames[baseName] = true
+		}
+	}
+	return deltaFilenames, nil
+}
+
+type walDeltaBuilder struct {
+	walFolderReader internal.StorageFolderReader
+	manager         *DeltaFileManager
+	timeline        uint32
+}
+
+// restorePreviousWalHead saves the beginning of the record continuing from the previous delta group
+// into the part file, which is done by wal-push when it records the last segment of the previous group
+func (builder *walDeltaBuilder) restorePreviousWalHead(deltaNo DeltaNo) error {
+	partFile, err := builder.manager.GetPartFile(deltaNo.getFilename(builder.timeline))
+	if err != nil {
+		return err
+	}
+	if deltaNo.firstWalSegmentNo() == 0 {
+		partFile.PreviousWalHead = make([]byte, 0)
+		return nil
+	}
+	previousSegmentName := deltaNo.firstWalSegmentNo().previous().GetFilename(builder.timeline)
+	segmentReader, err := internal.DownloadAndDecompressStorageFile(builder.walFolderReader, previousSegmentName)
+	if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+		tracelog.WarningLogger.Printf("WAL segment %s preceding the delta group is missing in storage, "+
+			"delta file %s can't be completed", previousSegmentName, deltaNo.getFilename(builder.timeline))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(segmentReader, "")
+
+	walParser := walparser.NewWalParser()
+	_, err = walparser.ExtractLocationsFromWalFile(walParser, segmentReader)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse WAL segment %s", previousSegmentName)
+	}
+	partFile.PreviousWalHead = walParser.GetCurrentRecordData()
+	if partFile.PreviousWalHead == nil {
+		partFile.PreviousWalHead = make([]byte, 0)
+	}
+	return nil
+}
+
+func (builder *walDeltaBuilder) recordDelta(deltaNo DeltaNo) error {
+	tracelog.InfoLogger.Printf("Building delta file %s", deltaNo.getFilename(builder.timeline))
+	segmentNo := deltaNo.firstWalSegmentNo()
+	for i := uint64(0); i < WalFileInDelta; i++ {
+		err := builder.recordSegment(segmentNo.GetFilename(builder.timeline))
+		if err != nil {
+			return err
+		}
+		segmentNo = segmentNo.Next()
+	}
+	return nil
+}
+
+func (builder *walDeltaBuilder) recordSegment(segmentName string) error {
+	segmentReader, err := internal.DownloadAndDecompressStorageFile(builder.walFolderReader, segmentName)
+	if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+		tracelog.WarningLogger.Printf("WAL segment %s is missing in storage, its delta file can't be completed",
+			segmentName)
+		builder.manager.CancelRecording(segmentName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(segmentReader, "")
+
+	recordingReader, err := NewWalDeltaRecordingReader(segmentReader, segmentName, builder.manager)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, recordingReader)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read WAL segment %s", segmentName)
+	}
+	return recordingReader.Close()
+}
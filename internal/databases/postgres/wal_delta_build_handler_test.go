@@ -0,0 +1,114 @@
+package postgres_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/compression"
+	"github.com/wal-g/wal-g/internal/compression/lz4"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/testtools"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const testDeltaFilename = "000000010000000000000010_delta"
+
+var (
+	testBuildLocations = []walparser.BlockLocation{
+		*walparser.NewBlockLocation(1663, 16384, 16385, 7),
+		*walparser.NewBlockLocation(1663, 16384, 16390, 42),
+	}
+)
+
+func newTestWalDeltaBuildUploader(folder storage.Folder) *postgres.WalUploader {
+	return postgres.NewWalUploader(
+		internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder.GetSubFolder(utility.WalPath)),
+		postgres.NewDeltaFileManager(testtools.NewMockDataFolder()))
+}
+
+// putTestDeltaGroup puts the second group of 16 segments and the last segment of the first group
+func putTestDeltaGroup(t *testing.T, folder storage.Folder, skipSegmentNo uint64) {
+	for segmentNo := uint64(15); segmentNo < 32; segmentNo++ {
+		if segmentNo == skipSegmentNo {
+			continue
+		}
+		// wal-push records the segment parts only for segments having records, as real segments always do
+		record := testWalRecord{resourceManager: walparser.RmXlogID}
+		switch segmentNo {
+		case 17:
+			record = testWalRecord{xid: 1, resourceManager: walparser.RmHeapID, blocks: testBuildLocations[:1]}
+		case 30:
+			record = testWalRecord{xid: 2, resourceManager: walparser.RmHeapID, blocks: testBuildLocations[1:]}
+		}
+		putTestWalSegment(t, folder, 1, segmentNo, record)
+	}
+}
+
+func loadTestDeltaFile(folder storage.Folder) (*postgres.DeltaFile, error) {
+	reader, err := internal.DownloadAndDecompressStorageFile(
+		internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)), testDeltaFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return postgres.LoadDeltaFile(reader)
+}
+
+func TestHandleWalDeltaBuild_BuildsDeltaFile(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	putTestDeltaGroup(t, folder, 0)
+
+	err := postgres.HandleWalDeltaBuild(context.Background(), folder, newTestWalDeltaBuildUploader(folder),
+		"000000010000000000000012", "000000010000000000000012")
+	require.NoError(t, err)
+
+	deltaFile, err := loadTestDeltaFile(folder)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, testBuildLocations, deltaFile.Locations)
+}
+
+func TestHandleWalDeltaBuild_SkipsIncompleteGroup(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	putTestDeltaGroup(t, folder, 20)
+
+	err := postgres.HandleWalDeltaBuild(context.Background(), folder, newTestWalDeltaBuildUploader(folder),
+		"000000010000000000000010", "00000001000000000000001F")
+	require.NoError(t, err)
+
+	_, err = loadTestDeltaFile(folder)
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+}
+
+func TestHandleWalDeltaBuild_SkipsExistingDeltaFile(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	putTestDeltaGroup(t, folder, 0)
+	existingDelta := []byte("existing delta")
+	err := folder.GetSubFolder(utility.WalPath).PutObject(testDeltaFilename+".lz4", bytes.NewReader(existingDelta))
+	require.NoError(t, err)
+
+	err = postgres.HandleWalDeltaBuild(context.Background(), folder, newTestWalDeltaBuildUploader(folder),
+		"000000010000000000000010", "00000001000000000000001F")
+	require.NoError(t, err)
+
+	reader, err := folder.GetSubFolder(utility.WalPath).ReadObject(testDeltaFilename + ".lz4")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, existingDelta, data)
+}
+
+func TestHandleWalDeltaBuild_InvalidRange(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+
+	err := postgres.HandleWalDeltaBuild(context.Background(), folder, newTestWalDeltaBuildUploader(folder),
+		"00000001000000000000001F", "000000010000000000000010")
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// walFindTimeProbeWindow is the number of segments a binary search probe reads at most to find a commit,
+// so the long runs of the segments without commits don't make the search linear
+const walFindTimeProbeWindow = 16
+
+type NoCommitBeforeTimeError struct {
+	error
+}
+
+func newNoCommitBeforeTimeError(targetTime time.Time, timeline uint32) NoCommitBeforeTimeError {
+	return NoCommitBeforeTimeError{errors.Errorf(
+		"no transaction commits at or before %s found in WAL of timeline %d", targetTime.Format(time.RFC3339), timeline)}
+}
+
+func (err NoCommitBeforeTimeError) Error() string {
+	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
+}
+
+// WalCommitInfo describes the transaction commit record found in archived WAL
+type WalCommitInfo struct {
+	LSN      string    `json:"lsn"`
+	XactID   uint32    `json:"xid"`
+	Time     time.Time `json:"time"`
+	Timeline uint32    `json:"timeline"`
+	Segment  string    `json:"segment"`
+}
+
+// HandleWalFindTime finds the last transaction commit at or before the target time in the WAL
+// of the timeline (the highest timeline in storage if zero), following the timeline history
+func HandleWalFindTime(rootFolder storage.Folder, targetTime time.Time, timeline uint32,
+	outputWriter WalFindTimeOutputWriter) error {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	filenames, err := getFolderFilenames(walFolder)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the WAL folder filenames")
+	}
+	walSegments := getSegmentsFromFiles(filenames)
+	if timeline == 0 {
+		timeline = findHighestTimeline(walSegments)
+	}
+	segments, err := getTimelineHistorySegments(walSegments, timeline, walFolder)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Searching %d WAL segments of timeline %d history", len(segments), timeline)
+
+	finder := newWalCommitFinder(internal.NewFolderReader(walFolder), segments)
+	commit, err := finder.findLastCommitBefore(targetTime)
+	if err != nil {
+		return err
+	}
+	if commit == nil {
+		return newNoCommitBeforeTimeError(targetTime, timeline)
+	}
+	return outputWriter.Write(commit)
+}
+
+func findHighestTimeline(walSegments map[WalSegmentDescription]bool) uint32 {
+	var highestTimeline uint32
+	for segment := range walSegments {
+		if segment.Timeline > highestTimeline {
+			highestTimeline = segment.Timeline
+		}
+	}
+	return highestTimeline
+}
+
+// getTimelineHistorySegments selects the segments, which the timeline consists of:
+// the segments before the switch points are taken from the parent timelines
+func getTimelineHistorySegments(walSegments map[WalSegmentDescription]bool, timeline uint32,
+	walFolder storage.Folder) ([]WalSegmentDescription, error) {
+	historyRecords, err := GetTimeLineHistoryRecords(timeline, walFolder)
+	if _, ok := err.(HistoryFileNotFoundError); ok {
+		historyRecords = nil
+	} else if err != nil {
+		return nil, err
+	}
+	segments := make([]WalSegmentDescription, 0)
+	for segment := range walSegments {
+		if segment.Timeline == timelineForSegment(segment.Number, timeline, historyRecords) {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Number < segments[j].Number
+	})
+	return segments, nil
+}
+
+// timelineForSegment returns the timeline, which the segment should be read from to follow the timeline history.
+// The segment containing the switch point is read from the child timeline.
+func timelineForSegment(segmentNo WalSegmentNo, timeline uint32, historyRecords []*TimelineHistoryRecord) uint32 {
+	for _, record := range historyRecords {
+		if record.lsn >= segmentNo.Next().firstLsn() {
+			return record.timeline
+		}
+	}
+	return timeline
+}
+
+type walCommitFinder struct {
+	walFolderReader internal.StorageFolderReader
+	segments        []WalSegmentDescription
+}
+
+func newWalCommitFinder(walFolderReader internal.StorageFolderReader, segments []WalSegmentDescription) *walCommitFinder {
+	return &walCommitFinder{walFolderReader: walFolderReader, segments: segments}
+}
+
+func (finder *walCommitFinder) findLastCommitBefore(targetTime time.Time) (*WalCommitInfo, error) {
+	startIdx, err := finder.findStartSegment(targetTime)
+	if err != nil || startIdx < 0 {
+		return nil, err
+	}
+
+	var lastCommit *WalCommitInfo
+	var recordReader *walparser.XLogRecordReader
+	for idx := startIdx; idx < len(finder.segments); idx++ {
+		continuous := idx > startIdx && finder.segments[idx-1].Number.Next() == finder.segments[idx].Number
+		if !continuous {
+			recordReader = nil
+		}
+		var reachedTarget bool
+		recordReader, reachedTarget, err = finder.scanSegment(idx, recordReader, func(commit *WalCommitInfo) bool {
+			if commit.Time.After(targetTime) {
+				return false
+			}
+			lastCommit = commit
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if reachedTarget {
+			break
+		}
+	}
+	return lastCommit, nil
+}
+
+// findStartSegment binary searches for the last segment with the first commit at or before the target time.
+// Segments without commits are skipped in favour of the next segments with commits within the probe window,
+// the search goes to the earlier segments if the window has no commits, which is a valid but farther start.
+func (finder *walCommitFinder) findStartSegment(targetTime time.Time) (int, error) {
+	result := -1
+	skippedEmptyWindow := false
+	low, high := 0, len(finder.segments)-1
+	for low <= high {
+		mid := (low + high) / 2
+		probe, commitTime, err := finder.findFirstCommitTime(mid, utility.Min(high, mid+walFindTimeProbeWindow-1))
+		if err != nil {
+			return -1, err
+		}
+		if probe < 0 || commitTime.After(targetTime) {
+			skippedEmptyWindow = skippedEmptyWindow || probe < 0
+			high = mid - 1
+			continue
+		}
+		result = probe
+		low = probe + 1
+	}
+	if result < 0 && skippedEmptyWindow {
+		// the commits before the target time may be only in the skipped windows
+		probe, commitTime, err := finder.findFirstCommitTime(0, len(finder.segments)-1)
+		if err != nil || probe < 0 || commitTime.After(targetTime) {
+			return -1, err
+		}
+		return probe, nil
+	}
+	return result, nil
+}
+
+// findFirstCommitTime returns the first segment in [from, to] having commit records and its first commit time
+func (finder *walCommitFinder) findFirstCommitTime(from, to int) (int, time.Time, error) {
+	for idx := from; idx <= to; idx++ {
+		var commitTime *time.Time
+		_, _, err := finder.scanSegment(idx, nil, func(commit *WalCommitInfo) bool {
+			commitTime = &commit.Time
+			return false
+		})
+		if err != nil {
+			return -1, time.Time{}, err
+		}
+		if commitTime != nil {
+			return idx, *commitTime, nil
+		}
+	}
+	return -1, time.Time{}, nil
+}
+
+// scanSegment passes the commits of the segment to the consumer until it returns false.
+// The record reader of the previous segment is continued if it's passed.
+func (finder *walCommitFinder) scanSegment(idx int, recordReader *walparser.XLogRecordReader,
+	consumer func(commit *WalCommitInfo) bool) (*walparser.XLogRecordReader, bool, error) {
+	segment := finder.segments[idx]
+	segmentName := segment.GetFileName()
+	segmentReader, err := internal.DownloadAndDecompressStorageFile(finder.walFolderReader, segmentName)
+	if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+		tracelog.WarningLogger.Printf("WAL segment %s is missing in storage, skipping it", segmentName)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer utility.LoggedClose(segmentReader, "")
+
+	if recordReader == nil {
+		recordReader = walparser.NewXLogRecordReader(segmentReader)
+	} else {
+		recordReader.NextSegment(segmentReader)
+	}
+	for {
+		record, err := recordReader.Next()
+		if err == io.EOF {
+			return recordReader, false, nil
+		}
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to read WAL segment %s", segmentName)
+		}
+		if !record.Record.IsXactCommit() {
+			continue
+		}
+		commitTime, err := record.Record.XactTime()
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to decode commit record at %s", LSN(record.LSN))
+		}
+		commit := &WalCommitInfo{
+			LSN:      LSN(record.LSN).String(),
+			XactID:   record.Record.Header.XactID,
+			Time:     commitTime,
+			Timeline: segment.Timeline,
+			Segment:  segmentName,
+		}
+		if !consumer(commit) {
+			return recordReader, true, nil
+		}
+	}
+}
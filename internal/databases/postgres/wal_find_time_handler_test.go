@@ -0,0 +1,217 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+type testWalRecord struct {
+	xid             uint32
+	resourceManager uint8
+	info            uint8
+	mainData        []byte
+	blocks          []walparser.BlockLocation
+}
+
+func newTestCommitRecord(xid uint32, commitTime time.Time) testWalRecord {
+	mainData := make([]byte, 8)
+	micros := commitTime.Sub(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)).Microseconds()
+	binary.LittleEndian.PutUint64(mainData, uint64(micros))
+	return testWalRecord{xid: xid, resourceManager: walparser.RmXactID, info: walparser.XLogXactCommit, mainData: mainData}
+}
+
+// buildTestWalSegment builds the first page of the WAL segment with the records, the rest of segment is omitted
+func buildTestWalSegment(timeline uint32, segmentNo uint64, records ...testWalRecord) []byte {
+	pageAddress := segmentNo * postgres.WalSegmentSize
+	page := new(bytes.Buffer)
+	_ = binary.Write(page, binary.LittleEndian, uint16(0xD10D))
+	_ = binary.Write(page, binary.LittleEndian, uint16(walparser.XlpLongHeader))
+	_ = binary.Write(page, binary.LittleEndian, timeline)
+	_ = binary.Write(page, binary.LittleEndian, pageAddress)
+	_ = binary.Write(page, binary.LittleEndian, uint32(0))
+	_ = binary.Write(page, binary.LittleEndian, uint32(0)) // padding
+	_ = binary.Write(page, binary.LittleEndian, uint64(1)) // system identifier
+	_ = binary.Write(page, binary.LittleEndian, uint32(postgres.WalSegmentSize))
+	_ = binary.Write(page, binary.LittleEndian, uint32(walparser.WalPageSize))
+
+	prevRecordPtr := uint64(0)
+	for _, record := range records {
+		recordPtr := pageAddress + uint64(page.Len())
+		// each block reference is written as id, fork flags, data length, relfilenode and block number
+		blockRefsLength := len(record.blocks) * (1 + 1 + 2 + 12 + 4)
+		totalLength := uint32(walparser.XLogRecordHeaderSize + blockRefsLength + 2 + len(record.mainData))
+		_ = binary.Write(page, binary.LittleEndian, totalLength)
+		_ = binary.Write(page, binary.LittleEndian, record.xid)
+		_ = binary.Write(page, binary.LittleEndian, prevRecordPtr)
+		_ = binary.Write(page, binary.LittleEndian, record.info)
+		_ = binary.Write(page, binary.LittleEndian, record.resourceManager)
+		_ = binary.Write(page, binary.LittleEndian, uint16(0))
+		_ = binary.Write(page, binary.LittleEndian, uint32(0)) // crc
+		for blockID, block := range record.blocks {
+			page.WriteByte(uint8(blockID))
+			page.WriteByte(0) // main fork without image and data
+			_ = binary.Write(page, binary.LittleEndian, uint16(0))
+			_ = binary.Write(page, binary.LittleEndian, block.RelationFileNode)
+			_ = binary.Write(page, binary.LittleEndian, block.BlockNo)
+		}
+		page.WriteByte(walparser.XlrBlockIDDataShort)
+		page.WriteByte(uint8(len(record.mainData)))
+		page.Write(record.mainData)
+		for page.Len()%walparser.XLogRecordAlignment != 0 {
+			page.WriteByte(0)
+		}
+		prevRecordPtr = recordPtr
+	}
+	data := make([]byte, walparser.WalPageSize)
+	copy(data, page.Bytes())
+	return data
+}
+
+func putTestWalSegment(t *testing.T, folder storage.Folder, timeline uint32, segmentNo uint64, records ...testWalRecord) {
+	name := postgres.WalSegmentNo(segmentNo).GetFilename(timeline)
+	err := folder.GetSubFolder(utility.WalPath).PutObject(name,
+		bytes.NewReader(buildTestWalSegment(timeline, segmentNo, records...)))
+	require.NoError(t, err)
+}
+
+type MockWalFindTimeOutputWriter struct {
+	commit *postgres.WalCommitInfo
+}
+
+func (writer *MockWalFindTimeOutputWriter) Write(commit *postgres.WalCommitInfo) error {
+	writer.commit = commit
+	return nil
+}
+
+var walFindTimeBase = time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+func minutesAfterBase(minutes int) time.Time {
+	return walFindTimeBase.Add(time.Duration(minutes) * time.Minute)
+}
+
+func setupWalFindTimeFolder(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	putTestWalSegment(t, folder, 1, 1, newTestCommitRecord(100, minutesAfterBase(0)), newTestCommitRecord(101, minutesAfterBase(1)))
+	putTestWalSegment(t, folder, 1, 2)
+	putTestWalSegment(t, folder, 1, 3, newTestCommitRecord(102, minutesAfterBase(2)), newTestCommitRecord(103, minutesAfterBase(4)))
+	putTestWalSegment(t, folder, 1, 4, newTestCommitRecord(104, minutesAfterBase(6)))
+	putTestWalSegment(t, folder, 1, 5, newTestCommitRecord(105, minutesAfterBase(8)))
+	// timeline 2 forks from timeline 1 in the middle of segment 4
+	putTestWalSegment(t, folder, 2, 4, newTestCommitRecord(204, minutesAfterBase(7)))
+	putTestWalSegment(t, folder, 2, 5, newTestCommitRecord(205, minutesAfterBase(9)))
+	err := folder.GetSubFolder(utility.WalPath).PutObject("00000002.history",
+		bytes.NewBufferString("1\t0/4000100\tno recovery target specified\n"))
+	require.NoError(t, err)
+	return folder
+}
+
+func executeWalFindTime(t *testing.T, targetTime time.Time, timeline uint32) (*postgres.WalCommitInfo, error) {
+	outputWriter := &MockWalFindTimeOutputWriter{}
+	err := postgres.HandleWalFindTime(setupWalFindTimeFolder(t), targetTime, timeline, outputWriter)
+	return outputWriter.commit, err
+}
+
+func TestWalFindTime_FindsLastCommitBeforeTime(t *testing.T) {
+	commit, err := executeWalFindTime(t, minutesAfterBase(3), 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(102), commit.XactID)
+	assert.Equal(t, "000000010000000000000003", commit.Segment)
+	assert.Equal(t, minutesAfterBase(2), commit.Time)
+}
+
+func TestWalFindTime_CommitAtTargetTimeIncluded(t *testing.T) {
+	commit, err := executeWalFindTime(t, minutesAfterBase(6), 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(104), commit.XactID)
+}
+
+func TestWalFindTime_LastCommitInPreviousSegment(t *testing.T) {
+	commit, err := executeWalFindTime(t, minutesAfterBase(5), 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(103), commit.XactID)
+}
+
+func TestWalFindTime_FollowsTimelineHistory(t *testing.T) {
+	commit, err := executeWalFindTime(t, minutesAfterBase(8), 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(204), commit.XactID)
+	assert.Equal(t, uint32(2), commit.Timeline)
+
+	commit, err = executeWalFindTime(t, minutesAfterBase(3), 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(102), commit.XactID)
+	assert.Equal(t, uint32(1), commit.Timeline)
+}
+
+func TestWalFindTime_HighestTimelineByDefault(t *testing.T) {
+	commit, err := executeWalFindTime(t, minutesAfterBase(100), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(205), commit.XactID)
+}
+
+func TestWalFindTime_NoCommitsBeforeTime(t *testing.T) {
+	_, err := executeWalFindTime(t, minutesAfterBase(-1), 1)
+	assert.IsType(t, postgres.NoCommitBeforeTimeError{}, err)
+}
+
+// readCountingFolder counts the objects read from the folder and its subfolders, missing ones aren't counted
+type readCountingFolder struct {
+	storage.Folder
+	reads *int
+}
+
+func (folder readCountingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return readCountingFolder{folder.Folder.GetSubFolder(subFolderRelativePath), folder.reads}
+}
+
+func (folder readCountingFolder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	reader, err := folder.Folder.ReadObject(objectRelativePath)
+	if err == nil {
+		*folder.reads++
+	}
+	return reader, err
+}
+
+func TestWalFindTime_LongRunWithoutCommits(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	for segmentNo := uint64(1); segmentNo <= 10; segmentNo++ {
+		putTestWalSegment(t, folder, 1, segmentNo, newTestCommitRecord(uint32(100+segmentNo), minutesAfterBase(int(segmentNo))))
+	}
+	// the idle server keeps archiving the segments without commits
+	for segmentNo := uint64(11); segmentNo <= 300; segmentNo++ {
+		putTestWalSegment(t, folder, 1, segmentNo)
+	}
+
+	reads := 0
+	outputWriter := &MockWalFindTimeOutputWriter{}
+	err := postgres.HandleWalFindTime(readCountingFolder{folder, &reads}, minutesAfterBase(5), 1, outputWriter)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(105), outputWriter.commit.XactID)
+	assert.Less(t, reads, 100)
+
+	// the commits before the target time are only in the windows skipped by the search
+	outputWriter = &MockWalFindTimeOutputWriter{}
+	err = postgres.HandleWalFindTime(folder, minutesAfterBase(100), 1, outputWriter)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(110), outputWriter.commit.XactID)
+}
+
+func TestWalFindTimeJSONOutputWriter(t *testing.T) {
+	var output bytes.Buffer
+	writer := postgres.NewWalFindTimeOutputWriter(postgres.WalFindTimeJSONOutput, &output)
+	require.NoError(t, writer.Write(&postgres.WalCommitInfo{LSN: "0/3000028", XactID: 102, Time: walFindTimeBase,
+		Timeline: 1, Segment: "000000010000000000000003"}))
+	assert.Equal(t, `{"lsn":"0/3000028","xid":102,"time":"2023-03-01T12:00:00Z","timeline":1,`+
+		`"segment":"000000010000000000000003"}`+"\n", output.String())
+}
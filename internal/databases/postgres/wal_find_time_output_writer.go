@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type WalFindTimeOutputType int
+
+const (
+	WalFindTimeTextOutput WalFindTimeOutputType = iota + 1
+	WalFindTimeJSONOutput
+)
+
+// WalFindTimeOutputWriter writes the commit found by wal-find-time
+type WalFindTimeOutputWriter interface {
+	Write(commit *WalCommitInfo) error
+}
+
+// WalFindTimeTextOutputWriter writes the commit info as plaintext
+type WalFindTimeTextOutputWriter struct {
+	output io.Writer
+}
+
+func (writer *WalFindTimeTextOutputWriter) Write(commit *WalCommitInfo) error {
+	_, err := fmt.Fprintf(writer.output, "LSN: %s\nXID: %d\nCommit time: %s\nTimeline: %d\nSegment: %s\n",
+		commit.LSN, commit.XactID, commit.Time.Format(time.RFC3339Nano), commit.Timeline, commit.Segment)
+	return err
+}
+
+// WalFindTimeJSONOutputWriter writes the commit info in JSON format
+type WalFindTimeJSONOutputWriter struct {
+	output io.Writer
+}
+
+func (writer *WalFindTimeJSONOutputWriter) Write(commit *WalCommitInfo) error {
+	return json.NewEncoder(writer.output).Encode(commit)
+}
+
+func NewWalFindTimeOutputWriter(outputType WalFindTimeOutputType, output io.Writer) WalFindTimeOutputWriter {
+	switch outputType {
+	case WalFindTimeJSONOutput:
+		return &WalFindTimeJSONOutputWriter{output: output}
+	default:
+		return &WalFindTimeTextOutputWriter{output: output}
+	}
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/utility"
+)
+
+var forkNames = []string{"main", "fsm", "vm", "init"}
+
+// WalInspectFilter selects WAL records to be shown by wal-inspect.
+// Zero values of the fields mean no filtering by the field.
+type WalInspectFilter struct {
+	ResourceManagers []uint8
+	RelFileNode      *walparser.RelFileNode
+	XactID           *uint32
+	StartLSN         LSN
+	EndLSN           LSN
+}
+
+// Matches checks if the record passes all the filter conditions
+func (filter *WalInspectFilter) Matches(record *walparser.LocatedXLogRecord) bool {
+	lsn := LSN(record.LSN)
+	if lsn < filter.StartLSN || (filter.EndLSN != 0 && lsn >= filter.EndLSN) {
+		return false
+	}
+	if filter.XactID != nil && record.Record.Header.XactID != *filter.XactID {
+		return false
+	}
+	if len(filter.ResourceManagers) > 0 && !filter.matchesResourceManager(record.Record.Header.ResourceManagerID) {
+		return false
+	}
+	if filter.RelFileNode != nil && !filter.matchesRelFileNode(record.Record.Blocks) {
+		return false
+	}
+	return true
+}
+
+func (filter *WalInspectFilter) matchesResourceManager(resourceManagerID uint8) bool {
+	for _, id := range filter.ResourceManagers {
+		if id == resourceManagerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter *WalInspectFilter) matchesRelFileNode(blocks []walparser.XLogRecordBlock) bool {
+	for _, block := range blocks {
+		if block.Header.BlockLocation.RelationFileNode == *filter.RelFileNode {
+			return true
+		}
+	}
+	return false
+}
+
+// WalRecordBlockRef describes a block reference of the WAL record
+type WalRecordBlockRef struct {
+	BlockID     uint8  `json:"block_id"`
+	RelFileNode string `json:"relfilenode"`
+	Fork        string `json:"fork"`
+	BlockNo     uint32 `json:"block_no"`
+	HasImage    bool   `json:"has_image"`
+	WillInit    bool   `json:"will_init"`
+}
+
+// WalRecordDescription is the wal-inspect representation of a single WAL record
+type WalRecordDescription struct {
+	LSN             string              `json:"lsn"`
+	PrevLSN         string              `json:"prev_lsn"`
+	Segment         string              `json:"segment"`
+	ResourceManager string              `json:"rmgr"`
+	Info            uint8               `json:"info"`
+	XactID          uint32              `json:"xid"`
+	RecordLength    uint32              `json:"rec_len"`
+	TotalLength     uint32              `json:"tot_len"`
+	BlockRefs       []WalRecordBlockRef `json:"block_refs,omitempty"`
+}
+
+func NewWalRecordDescription(record *walparser.LocatedXLogRecord, segment string) *WalRecordDescription {
+	header := record.Record.Header
+	description := &WalRecordDescription{
+		LSN:             LSN(record.LSN).String(),
+		PrevLSN:         LSN(header.PrevRecordPtr).String(),
+		Segment:         segment,
+		ResourceManager: walparser.ResourceManagerName(header.ResourceManagerID),
+		Info:            header.Info,
+		XactID:          header.XactID,
+		RecordLength:    header.TotalRecordLength,
+		TotalLength:     header.TotalRecordLength,
+	}
+	for _, block := range record.Record.Blocks {
+		description.RecordLength -= uint32(len(block.Image))
+		description.BlockRefs = append(description.BlockRefs, WalRecordBlockRef{
+			BlockID:     block.Header.BlockID,
+			RelFileNode: formatRelFileNode(block.Header.BlockLocation.RelationFileNode),
+			Fork:        forkName(block.Header.ForkNum()),
+			BlockNo:     block.Header.BlockLocation.BlockNo,
+			HasImage:    block.Header.HasImage(),
+			WillInit:    block.Header.WillInit(),
+		})
+	}
+	return description
+}
+
+func forkName(forkNum uint8) string {
+	if int(forkNum) < len(forkNames) {
+		return forkNames[forkNum]
+	}
+	return strconv.Itoa(int(forkNum))
+}
+
+func formatRelFileNode(node walparser.RelFileNode) string {
+	return fmt.Sprintf("%d/%d/%d", node.SpcNode, node.DBNode, node.RelNode)
+}
+
+// ParseRelFileNode parses the relation file node in the "tablespace/database/relation" form
+func ParseRelFileNode(s string) (*walparser.RelFileNode, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return nil, errors.Errorf("invalid relfilenode '%s', expected format: tablespace/database/relation", s)
+	}
+	oids := make([]walparser.Oid, 0, 3)
+	for _, part := range parts {
+		oid, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid relfilenode '%s'", s)
+		}
+		oids = append(oids, walparser.Oid(oid))
+	}
+	return &walparser.RelFileNode{SpcNode: oids[0], DBNode: oids[1], RelNode: oids[2]}, nil
+}
+
+// ParseWalSegmentRange parses the single WAL segment name or the range of segments in the "first-last" form
+func ParseWalSegmentRange(segmentRange string) (timeline uint32, first, last WalSegmentNo, err error) {
+	firstName, lastName, isRange := strings.Cut(segmentRange, "-")
+	if !isRange {
+		lastName = firstName
+	}
+	return parseWalSegmentBounds(firstName, lastName)
+}
+
+func parseWalSegmentBounds(firstName, lastName string) (timeline uint32, first, last WalSegmentNo, err error) {
+	timeline, firstNo, err := ParseWALFilename(firstName)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	lastTimeline, lastNo, err := ParseWALFilename(lastName)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if lastTimeline != timeline {
+		return 0, 0, 0, errors.Errorf("segments %s and %s belong to different timelines", firstName, lastName)
+	}
+	if lastNo < firstNo {
+		return 0, 0, 0, errors.Errorf("segment %s precedes %s", lastName, firstName)
+	}
+	return timeline, WalSegmentNo(firstNo), WalSegmentNo(lastNo), nil
+}
+
+// HandleWalInspect decodes the records of the WAL segments range from storage
+// and writes the ones matching the filter to the output writer
+func HandleWalInspect(folderReader internal.StorageFolderReader, segmentRange string,
+	filter WalInspectFilter, outputWriter WalInspectOutputWriter) error {
+	timeline, firstSegmentNo, lastSegmentNo, err := ParseWalSegmentRange(segmentRange)
+	if err != nil {
+		return err
+	}
+	walFolderReader := folderReader.SubFolder(utility.WalPath)
+
+	var recordReader *walparser.XLogRecordReader
+	for segmentNo := firstSegmentNo; segmentNo <= lastSegmentNo; segmentNo = segmentNo.Next() {
+		if filter.EndLSN != 0 && segmentNo.firstLsn() >= filter.EndLSN {
+			break
+		}
+		if segmentNo.Next().firstLsn() <= filter.StartLSN {
+			recordReader = nil
+			continue
+		}
+		segmentName := segmentNo.GetFilename(timeline)
+		segmentReader, err := internal.DownloadAndDecompressStorageFile(walFolderReader, segmentName)
+		if _, ok := err.(internal.ArchiveNonExistenceError); ok {
+			tracelog.WarningLogger.Printf("WAL segment %s is missing in storage, skipping it", segmentName)
+			recordReader = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if recordReader == nil {
+			recordReader = walparser.NewXLogRecordReader(segmentReader)
+		} else {
+			recordReader.NextSegment(segmentReader)
+		}
+		err = inspectWalSegment(recordReader, segmentName, filter, outputWriter)
+		utility.LoggedClose(segmentReader, "")
+		if err != nil {
+			return errors.Wrapf(err, "failed to inspect WAL segment %s", segmentName)
+		}
+	}
+	return nil
+}
+
+func inspectWalSegment(recordReader *walparser.XLogRecordReader, segmentName string,
+	filter WalInspectFilter, outputWriter WalInspectOutputWriter) error {
+	for {
+		record, err := recordReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !filter.Matches(record) {
+			continue
+		}
+		err = outputWriter.Write(NewWalRecordDescription(record, segmentName))
+		if err != nil {
+			return err
+		}
+	}
+}
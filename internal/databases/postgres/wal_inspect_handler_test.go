@@ -0,0 +1,105 @@
+package postgres_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/internal/walparser"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// the test WAL file contains the pages of segment 0x78 on timeline 1
+const (
+	walInspectTestDataPath = "../../walparser/testdata/wal_switch_test"
+	walInspectTestSegment  = "000000010000000000000078"
+)
+
+type MockWalInspectOutputWriter struct {
+	records []*postgres.WalRecordDescription
+}
+
+func (writer *MockWalInspectOutputWriter) Write(record *postgres.WalRecordDescription) error {
+	writer.records = append(writer.records, record)
+	return nil
+}
+
+func executeWalInspect(t *testing.T, segmentRange string, filter postgres.WalInspectFilter) []*postgres.WalRecordDescription {
+	data, err := os.ReadFile(walInspectTestDataPath)
+	require.NoError(t, err)
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	err = folder.GetSubFolder(utility.WalPath).PutObject(walInspectTestSegment, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	outputWriter := &MockWalInspectOutputWriter{}
+	err = postgres.HandleWalInspect(internal.NewFolderReader(folder), segmentRange, filter, outputWriter)
+	require.NoError(t, err)
+	return outputWriter.records
+}
+
+func TestWalInspect_AllRecords(t *testing.T) {
+	records := executeWalInspect(t, walInspectTestSegment, postgres.WalInspectFilter{})
+	require.NotEmpty(t, records)
+	for i, record := range records {
+		assert.Equal(t, walInspectTestSegment, record.Segment)
+		if i > 0 {
+			assert.Equal(t, records[i-1].LSN, record.PrevLSN)
+		}
+	}
+	assert.Equal(t, "XLOG", records[len(records)-1].ResourceManager)
+}
+
+func TestWalInspect_MissingSegmentsAreSkipped(t *testing.T) {
+	all := executeWalInspect(t, walInspectTestSegment, postgres.WalInspectFilter{})
+	records := executeWalInspect(t, "000000010000000000000077-000000010000000000000079", postgres.WalInspectFilter{})
+	assert.Equal(t, all, records)
+}
+
+func TestWalInspect_FilterByResourceManager(t *testing.T) {
+	records := executeWalInspect(t, walInspectTestSegment, postgres.WalInspectFilter{
+		ResourceManagers: []uint8{walparser.RmXlogID},
+	})
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, "XLOG", record.ResourceManager)
+	}
+}
+
+func TestWalInspect_FilterByLSN(t *testing.T) {
+	all := executeWalInspect(t, walInspectTestSegment, postgres.WalInspectFilter{})
+	require.True(t, len(all) > 2)
+	startLSN, err := postgres.ParseLSN(all[1].LSN)
+	require.NoError(t, err)
+	endLSN, err := postgres.ParseLSN(all[2].LSN)
+	require.NoError(t, err)
+
+	records := executeWalInspect(t, walInspectTestSegment, postgres.WalInspectFilter{StartLSN: startLSN, EndLSN: endLSN})
+	assert.Equal(t, all[1:2], records)
+}
+
+func TestParseWalSegmentRange(t *testing.T) {
+	timeline, first, last, err := postgres.ParseWalSegmentRange("000000020000000100000001-000000020000000100000003")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), timeline)
+	assert.Equal(t, postgres.WalSegmentNo(0x101), first)
+	assert.Equal(t, postgres.WalSegmentNo(0x103), last)
+
+	_, _, _, err = postgres.ParseWalSegmentRange("000000020000000100000001-000000030000000100000003")
+	assert.Error(t, err)
+	_, _, _, err = postgres.ParseWalSegmentRange("000000020000000100000003-000000020000000100000001")
+	assert.Error(t, err)
+}
+
+func TestParseRelFileNode(t *testing.T) {
+	node, err := postgres.ParseRelFileNode("1663/5/16384")
+	assert.NoError(t, err)
+	assert.Equal(t, walparser.RelFileNode{SpcNode: 1663, DBNode: 5, RelNode: 16384}, *node)
+
+	_, err = postgres.ParseRelFileNode("1663/5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type WalInspectOutputType int
+
+const (
+	WalInspectTextOutput WalInspectOutputType = iota + 1
+	WalInspectJSONOutput
+)
+
+// WalInspectOutputWriter writes the WAL records found by wal-inspect
+type WalInspectOutputWriter interface {
+	Write(record *WalRecordDescription) error
+}
+
+// WalInspectTextOutputWriter writes the records in pg_waldump-like format, one per line
+type WalInspectTextOutputWriter struct {
+	output io.Writer
+}
+
+func (writer *WalInspectTextOutputWriter) Write(record *WalRecordDescription) error {
+	var line strings.Builder
+	fmt.Fprintf(&line, "rmgr: %-11s len (rec/tot): %6d/%6d, tx: %10d, lsn: %s, prev %s, info: 0x%02X",
+		record.ResourceManager, record.RecordLength, record.TotalLength, record.XactID,
+		record.LSN, record.PrevLSN, record.Info)
+	for _, blockRef := range record.BlockRefs {
+		fmt.Fprintf(&line, ", blkref #%d: rel %s fork %s blk %d",
+			blockRef.BlockID, blockRef.RelFileNode, blockRef.Fork, blockRef.BlockNo)
+		if blockRef.HasImage {
+			line.WriteString(" FPW")
+		}
+	}
+	line.WriteString("\n")
+	_, err := io.WriteString(writer.output, line.String())
+	return err
+}
+
+// WalInspectJSONOutputWriter writes the records as a stream of JSON objects, one per line
+type WalInspectJSONOutputWriter struct {
+	encoder *json.Encoder
+}
+
+func (writer *WalInspectJSONOutputWriter) Write(record *WalRecordDescription) error {
+	return writer.encoder.Encode(record)
+}
+
+func NewWalInspectOutputWriter(outputType WalInspectOutputType, output io.Writer) WalInspectOutputWriter {
+	switch outputType {
+	case WalInspectJSONOutput:
+		return &WalInspectJSONOutputWriter{encoder: json.NewEncoder(output)}
+	default:
+		return &WalInspectTextOutputWriter{output: output}
+	}
+}
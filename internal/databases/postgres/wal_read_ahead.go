@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/utility"
+)
+
+const (
+	initialWalReadAheadWindow = 4
+	walReadAheadRateAlpha     = 0.3
+)
+
+type walReadAheadEntry struct {
+	done chan struct{}
+	// data keeps the segment in memory when there is no read-ahead directory
+	data []byte
+	path string
+	err  error
+}
+
+// WalReadAhead keeps a bounded window of WAL segments following the last segment requested by replay.
+// The window is downloaded in parallel and sized to keep up with the replay rate: it grows while replay
+// waits for downloads and shrinks while replay is slower than the storage.
+type WalReadAhead struct {
+	maxWindow int
+	// directory keeps the downloaded segments on disk, they are kept in memory if it's empty
+	directory string
+
+	mutex           sync.Mutex
+	window          int
+	entries         map[string]*walReadAheadEntry
+	lastRequestTime time.Time
+	requestInterval time.Duration
+	fetchDuration   time.Duration
+}
+
+func NewWalReadAhead(maxWindow int, directory string) *WalReadAhead {
+	return &WalReadAhead{
+		maxWindow: maxWindow,
+		directory: directory,
+		window:    utility.Min(initialWalReadAheadWindow, maxWindow),
+		entries:   make(map[string]*walReadAheadEntry),
+	}
+}
+
+// ConfigureWalReadAhead creates the daemon WAL read-ahead, it returns nil if the read-ahead is disabled
+func ConfigureWalReadAhead() (*WalReadAhead, error) {
+	maxWindow := viper.GetInt(internal.PgDaemonWalReadAhead)
+	if maxWindow <= 0 {
+		return nil, nil
+	}
+	directory, ok := internal.GetSetting(internal.PgDaemonWalReadAheadDir)
+	if !ok {
+		return NewWalReadAhead(maxWindow, ""), nil
+	}
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return NewWalReadAhead(maxWindow, directory), nil
+}
+
+// Fetch writes the WAL file to the location, taking it from the read-ahead window if it's there,
+// and extends the window past the requested segment. It reports whether the window had the segment.
+func (ra *WalReadAhead) Fetch(baseReader internal.StorageFolderReader, walFileName string,
+	location string) (bool, error) {
+	reader := baseReader.SubFolder(utility.WalPath)
+	location = utility.ResolveSymlink(location)
+	if !isWalFilename(walFileName) {
+		return false, internal.DownloadFileTo(reader, walFileName, location)
+	}
+
+	ra.mutex.Lock()
+	entry := ra.entries[walFileName]
+	delete(ra.entries, walFileName)
+	ra.adaptWindow(time.Now())
+	ra.readAhead(reader, walFileName)
+	ra.mutex.Unlock()
+
+	if entry != nil {
+		<-entry.done
+		if entry.err == nil {
+			tracelog.DebugLogger.Printf("WAL read-ahead hit: %s", walFileName)
+			return true, ra.writeEntry(entry, location)
+		}
+		tracelog.DebugLogger.Printf("WAL read-ahead of %s failed, downloading it again: %v", walFileName, entry.err)
+		ra.dropEntry(entry)
+	}
+
+	startTime := time.Now()
+	err := internal.DownloadFileTo(reader, walFileName, location)
+	if err == nil {
+		ra.mutex.Lock()
+		ra.updateFetchDuration(time.Since(startTime))
+		ra.mutex.Unlock()
+	}
+	return false, err
+}
+
+// Window returns the current read-ahead window size
+func (ra *WalReadAhead) Window() int {
+	ra.mutex.Lock()
+	defer ra.mutex.Unlock()
+	return ra.window
+}
+
+func (ra *WalReadAhead) adaptWindow(requestTime time.Time) {
+	if !ra.lastRequestTime.IsZero() {
+		ra.requestInterval = updateWalReadAheadRate(ra.requestInterval, requestTime.Sub(ra.lastRequestTime))
+	}
+	ra.lastRequestTime = requestTime
+	if ra.requestInterval > 0 && ra.fetchDuration > 0 {
+		ra.window = GetWalReadAheadWindow(ra.fetchDuration, ra.requestInterval, ra.maxWindow)
+	}
+}
+
+// GetWalReadAheadWindow returns the number of segments to download in parallel to deliver them
+// as fast as replay requests them
+func GetWalReadAheadWindow(fetchDuration, requestInterval time.Duration, maxWindow int) int {
+	window := int(math.Ceil(float64(fetchDuration)/float64(requestInterval))) + 1
+	return utility.Max(1, utility.Min(window, maxWindow))
+}
+
+func (ra *WalReadAhead) updateFetchDuration(duration time.Duration) {
+	ra.fetchDuration = updateWalReadAheadRate(ra.fetchDuration, duration)
+}
+
+func updateWalReadAheadRate(average, value time.Duration) time.Duration {
+	if average == 0 {
+		return value
+	}
+	return time.Duration(walReadAheadRateAlpha*float64(value) + (1-walReadAheadRateAlpha)*float64(average))
+}
+
+// readAhead drops the segments outside the window following the requested one and starts downloading
+// the missing ones, it's called under the mutex
+func (ra *WalReadAhead) readAhead(reader internal.StorageFolderReader, walFileName string) {
+	windowFileNames := make(map[string]bool, ra.window)
+	fileName := walFileName
+	for i := 0; i < ra.window; i++ {
+		var err error
+		fileName, err = GetNextWalFilename(fileName)
+		if err != nil {
+			tracelog.ErrorLogger.Printf("WAL read-ahead: get next filename: %v", err)
+			break
+		}
+		windowFileNames[fileName] = true
+		if _, ok := ra.entries[fileName]; !ok {
+			entry := &walReadAheadEntry{done: make(chan struct{})}
+			ra.entries[fileName] = entry
+			go ra.download(reader, fileName, entry)
+		}
+	}
+	for fileName, entry := range ra.entries {
+		if !windowFileNames[fileName] {
+			delete(ra.entries, fileName)
+			go func(entry *walReadAheadEntry) {
+				<-entry.done
+				ra.dropEntry(entry)
+			}(entry)
+		}
+	}
+}
+
+func (ra *WalReadAhead) download(reader internal.StorageFolderReader, walFileName string, entry *walReadAheadEntry) {
+	defer close(entry.done)
+	startTime := time.Now()
+	fileReader, err := internal.DownloadAndDecompressStorageFile(reader, walFileName)
+	if err != nil {
+		entry.err = err
+		return
+	}
+	defer utility.LoggedClose(fileReader, "")
+
+	if ra.directory == "" {
+		entry.data, entry.err = io.ReadAll(fileReader)
+	} else {
+		entry.path, entry.err = writeWalReadAheadTempFile(ra.directory, walFileName, fileReader)
+	}
+	if entry.err != nil {
+		return
+	}
+	ra.mutex.Lock()
+	ra.updateFetchDuration(time.Since(startTime))
+	ra.mutex.Unlock()
+}
+
+// writeWalReadAheadTempFile writes the segment to a file with a unique name, since the segment
+// evicted from the window may still be being removed when it's downloaded again
+func writeWalReadAheadTempFile(directory string, walFileName string, reader io.Reader) (string, error) {
+	file, err := os.CreateTemp(directory, walFileName+"_*")
+	if err != nil {
+		return "", err
+	}
+	defer utility.LoggedClose(file, "")
+	_, err = utility.FastCopy(file, reader)
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func writeWalReadAheadFile(path string, reader io.Reader) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(file, "")
+	_, err = utility.FastCopy(file, reader)
+	return err
+}
+
+func (ra *WalReadAhead) writeEntry(entry *walReadAheadEntry, location string) error {
+	defer ra.dropEntry(entry)
+	var source io.Reader = bytes.NewReader(entry.data)
+	if entry.path != "" {
+		file, err := os.Open(entry.path)
+		if err != nil {
+			return err
+		}
+		defer utility.LoggedClose(file, "")
+		source = file
+	}
+	return writeWalReadAheadFile(location, source)
+}
+
+func (ra *WalReadAhead) dropEntry(entry *walReadAheadEntry) {
+	entry.data = nil
+	if entry.path != "" {
+		_ = os.Remove(entry.path)
+	}
+}
@@ -0,0 +1,79 @@
+package postgres_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wal-g/wal-g/internal"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+	"github.com/wal-g/wal-g/pkg/storages/memory"
+	"github.com/wal-g/wal-g/pkg/storages/storage"
+	"github.com/wal-g/wal-g/utility"
+)
+
+func putTestReadAheadSegments(t *testing.T, folder storage.Folder, segmentNos ...uint64) {
+	for _, segmentNo := range segmentNos {
+		name := postgres.WalSegmentNo(segmentNo).GetFilename(1)
+		require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject(name, bytes.NewReader([]byte(name))))
+	}
+}
+
+func newTestReadAheadReader(t *testing.T, segmentNos ...uint64) internal.StorageFolderReader {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	putTestReadAheadSegments(t, folder, segmentNos...)
+	return internal.NewFolderReader(folder)
+}
+
+func fetchTestReadAhead(t *testing.T, readAhead *postgres.WalReadAhead, reader internal.StorageFolderReader,
+	segmentNo uint64) bool {
+	name := postgres.WalSegmentNo(segmentNo).GetFilename(1)
+	location := filepath.Join(t.TempDir(), name)
+	hit, err := readAhead.Fetch(reader, name, location)
+	require.NoError(t, err)
+	data, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(name), data)
+	return hit
+}
+
+func TestGetWalReadAheadWindow(t *testing.T) {
+	// replay waits for the storage: download more segments in parallel
+	assert.Equal(t, 5, postgres.GetWalReadAheadWindow(time.Second, 250*time.Millisecond, 16))
+	// replay is slower than the storage: the next segment is enough
+	assert.Equal(t, 2, postgres.GetWalReadAheadWindow(100*time.Millisecond, time.Second, 16))
+	assert.Equal(t, 16, postgres.GetWalReadAheadWindow(time.Minute, time.Millisecond, 16))
+}
+
+func TestWalReadAhead_ServesFromWindow(t *testing.T) {
+	for _, directory := range []string{"", t.TempDir()} {
+		reader := newTestReadAheadReader(t, 1, 2, 3, 4, 5)
+		readAhead := postgres.NewWalReadAhead(2, directory)
+
+		assert.False(t, fetchTestReadAhead(t, readAhead, reader, 1))
+		assert.True(t, fetchTestReadAhead(t, readAhead, reader, 2))
+		assert.True(t, fetchTestReadAhead(t, readAhead, reader, 3))
+		// the window moves past the requested segment only
+		assert.False(t, fetchTestReadAhead(t, readAhead, reader, 1))
+	}
+}
+
+func TestWalReadAhead_MissingSegment(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewKVS())
+	reader := internal.NewFolderReader(folder)
+	putTestReadAheadSegments(t, folder, 1)
+	readAhead := postgres.NewWalReadAhead(4, "")
+
+	assert.False(t, fetchTestReadAhead(t, readAhead, reader, 1))
+	name := postgres.WalSegmentNo(2).GetFilename(1)
+	_, err := readAhead.Fetch(reader, name, filepath.Join(t.TempDir(), name))
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+
+	// the segment failed to be read ahead is downloaded once it's archived
+	putTestReadAheadSegments(t, folder, 3)
+	fetchTestReadAhead(t, readAhead, reader, 3)
+}
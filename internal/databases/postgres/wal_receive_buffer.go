@@ -0,0 +1,180 @@
+package postgres
+
+/*
+This object keeps the received part of the current wal segment on local disk.
+wal-receive acting as a synchronous standby confirms the flush of WAL to Postgres
+as soon as it's synced to the buffer, and uploads the segment once it's complete.
+*/
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+	"github.com/wal-g/wal-g/internal/ioextensions"
+	"github.com/wal-g/wal-g/utility"
+)
+
+// The WalReceiveBuffer durably stores the received WAL data of the segment being streamed.
+type WalReceiveBuffer struct {
+	directory string
+	file      *os.File
+	fileName  string
+	flushed   int
+}
+
+// NewWalReceiveBuffer creates the buffer keeping the segment parts in the directory
+func NewWalReceiveBuffer(directory string) (*WalReceiveBuffer, error) {
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create wal-receive buffer directory")
+	}
+	return &WalReceiveBuffer{directory: directory}, nil
+}
+
+// Write syncs the segment data received since the last write to the buffer file of the segment
+func (buffer *WalReceiveBuffer) Write(seg *WalSegment) error {
+	err := buffer.open(seg.baseName())
+	if err != nil {
+		return err
+	}
+	if seg.writeIndex <= buffer.flushed {
+		return nil
+	}
+	_, err = buffer.file.WriteAt(seg.data[buffer.flushed:seg.writeIndex], int64(buffer.flushed))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write wal-receive buffer %s", buffer.fileName)
+	}
+	err = buffer.file.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "failed to sync wal-receive buffer %s", buffer.fileName)
+	}
+	buffer.flushed = seg.writeIndex
+	return nil
+}
+
+// Load fills the segment with the data buffered before the restart
+func (buffer *WalReceiveBuffer) Load(seg *WalSegment) error {
+	err := buffer.open(seg.baseName())
+	if err != nil {
+		return err
+	}
+	n, err := io.ReadFull(buffer.file, seg.data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return errors.Wrapf(err, "failed to read wal-receive buffer %s", buffer.fileName)
+	}
+	seg.writeIndex = n
+	buffer.flushed = n
+	if n > 0 {
+		tracelog.InfoLogger.Printf("Loaded %d bytes of %s from the wal-receive buffer", n, buffer.fileName)
+	}
+	return nil
+}
+
+// FlushedLSN returns the position up to which the segment data is durably stored
+func (buffer *WalReceiveBuffer) FlushedLSN(seg *WalSegment) LSN {
+	if buffer.fileName != seg.baseName() {
+		return LSN(seg.StartLSN)
+	}
+	return LSN(seg.StartLSN) + LSN(buffer.flushed)
+}
+
+// Remove drops the buffer file of the uploaded segment
+func (buffer *WalReceiveBuffer) Remove(seg *WalSegment) error {
+	name := seg.baseName()
+	if buffer.fileName == name {
+		utility.LoggedClose(buffer.file, "")
+		buffer.file = nil
+		buffer.fileName = ""
+		buffer.flushed = 0
+	}
+	err := os.Remove(filepath.Join(buffer.directory, name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// UploadCompleteSegments uploads the complete segments left in the buffer by the previous run,
+// they are confirmed to Postgres already and may be gone from the server
+func (buffer *WalReceiveBuffer) UploadCompleteSegments(ctx context.Context, uploader *WalUploader,
+	walSegmentBytes uint64) ([]string, error) {
+	entries, err := os.ReadDir(buffer.directory)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := make([]string, 0)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if !isWalFilename(entry.Name()) || uint64(info.Size()) != walSegmentBytes {
+			continue
+		}
+		path := filepath.Join(buffer.directory, entry.Name())
+		err = uploadBufferedSegment(ctx, uploader, path, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+		uploaded = append(uploaded, entry.Name())
+	}
+	return uploaded, nil
+}
+
+func uploadBufferedSegment(ctx context.Context, uploader *WalUploader, path string, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(file, "")
+	tracelog.InfoLogger.Printf("Uploading %s from the wal-receive buffer", name)
+	err = uploader.UploadWalFile(ctx, ioextensions.NewNamedReaderImpl(file, name))
+	if err != nil {
+		return err
+	}
+	return uploadRemoteWalMetadata(ctx, name, uploader.Uploader)
+}
+
+func (buffer *WalReceiveBuffer) open(name string) error {
+	if buffer.fileName == name {
+		return nil
+	}
+	if buffer.file != nil {
+		utility.LoggedClose(buffer.file, "")
+	}
+	path := filepath.Join(buffer.directory, name)
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open wal-receive buffer %s", name)
+	}
+	if os.IsNotExist(statErr) {
+		// the new file entry must survive a crash as well as its content
+		err = syncDirectory(buffer.directory)
+		if err != nil {
+			utility.LoggedClose(file, "")
+			return err
+		}
+	}
+	buffer.file = file
+	buffer.fileName = name
+	buffer.flushed = 0
+	return nil
+}
+
+func syncDirectory(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(dir, "")
+	return dir.Sync()
+}
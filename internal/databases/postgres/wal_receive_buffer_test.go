@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReceiveSegmentBytes = 16
+
+func receiveTestData(seg *WalSegment, data string) {
+	seg.writeIndex += copy(seg.data[seg.writeIndex:], data)
+}
+
+func TestWalReceiveBuffer_WriteAndLoad(t *testing.T) {
+	directory := t.TempDir()
+	buffer, err := NewWalReceiveBuffer(directory)
+	require.NoError(t, err)
+	seg := NewWalSegment(1, 0x20, testReceiveSegmentBytes)
+	seg.buffer = buffer
+	assert.Equal(t, LSN(0x20), buffer.FlushedLSN(seg))
+
+	receiveTestData(seg, "first")
+	require.NoError(t, buffer.Write(seg))
+	receiveTestData(seg, "second")
+	require.NoError(t, buffer.Write(seg))
+	assert.Equal(t, LSN(0x2b), buffer.FlushedLSN(seg))
+	status := seg.standbyStatus()
+	assert.Equal(t, pglogrepl.LSN(0x2b), status.WALFlushPosition)
+
+	// the restarted wal-receive continues from the buffered position
+	restartedBuffer, err := NewWalReceiveBuffer(directory)
+	require.NoError(t, err)
+	restartedSeg := NewWalSegment(1, 0x25, testReceiveSegmentBytes)
+	require.NoError(t, restartedBuffer.Load(restartedSeg))
+	assert.Equal(t, pglogrepl.LSN(0x2b), restartedSeg.ReceivedLSN())
+	assert.Equal(t, []byte("firstsecond"), restartedSeg.data[:restartedSeg.writeIndex])
+
+	require.NoError(t, restartedBuffer.Remove(restartedSeg))
+	_, err = os.Stat(filepath.Join(directory, restartedSeg.baseName()))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWalReceiveBuffer_LoadMissing(t *testing.T) {
+	buffer, err := NewWalReceiveBuffer(t.TempDir())
+	require.NoError(t, err)
+	seg := NewWalSegment(1, 0x20, testReceiveSegmentBytes)
+
+	require.NoError(t, buffer.Load(seg))
+	assert.Equal(t, pglogrepl.LSN(0x20), seg.ReceivedLSN())
+}
+
+func TestWalSegment_StandbyStatusWithoutBuffer(t *testing.T) {
+	seg := NewWalSegment(1, 0x20, testReceiveSegmentBytes)
+	receiveTestData(seg, "data")
+
+	// the flush is confirmed once the segment is uploaded
+	assert.Equal(t, pglogrepl.StandbyStatusUpdate{WALWritePosition: 0x20}, seg.standbyStatus())
+}
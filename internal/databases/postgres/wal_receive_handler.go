@@ -22,24 +22,30 @@ const (
 
 /*
 NOTE: Preventing a WAL gap is a complex one (also not 100% fixed with arch_command).
-* Using replication slot helps, but that should be created and maintained
-  by wal-g on standby's too (making sure unconsumed wals are preserved on
-  potential new masters too)
-* Using sync replication is another option, but non-promotable, and we
-  should locally cache to disconnect S3 performance from database performance
+* The replication slot is created and advanced on all the configured HA cluster members
+  (see SlotMembers), so unconsumed WAL is preserved on a potential new master too.
+* With the buffer directory configured wal-receive acts as a synchronous standby: the WAL is
+  confirmed as flushed once it's synced to the local buffer (see WalReceiveBuffer), which
+  disconnects S3 performance from database performance. The segment is uploaded when complete.
 * Making something that checks 'what is in wal-g s repo' vs 'where postgres is
   is another option, but when wal-g is no longer running there would be nothing
   preventing postgres from advancing and cleaning, which is what slots are for.
-Cleanest would probably be to create the slot on all postgres instances and advance all of them.
-Can be done, but first, lets focus on creating wal files from repl msg...
 
 Things to do (future):
 * unittests for queryrunner code
 * upgrade to pgx/v4
-* we might want to add a feature to have wal-g advance multiple slots to support HA setups natively
 * Test with different wal size (>=pg11)
 */
 
+// WalReceiveOptions configures the synchronous standby mode and the HA slot management of wal-receive
+type WalReceiveOptions struct {
+	// BufferDirectory keeps the received WAL until it's uploaded, the flush is confirmed once
+	// the WAL is synced there. The flush is confirmed after the upload if it's empty.
+	BufferDirectory string
+	// SlotHosts are the HA cluster members to create and advance the replication slot on
+	SlotHosts []SlotHost
+}
+
 type genericWalReceiveError struct {
 	error
 }
@@ -49,7 +55,7 @@ func (err genericWalReceiveError) Error() string {
 }
 
 // HandleWALReceive is invoked to receive wal with a replication connection and push
-func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
+func HandleWALReceive(ctx context.Context, uploader *WalUploader, options WalReceiveOptions) {
 	// Connect to postgres.
 	var XLogPos pglogrepl.LSN
 	var segment *WalSegment
@@ -60,6 +66,19 @@ func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
 	tracelog.ErrorLogger.FatalOnError(err)
 	tracelog.DebugLogger.Printf("WAL segment bytes: %d", walSegmentBytes)
 
+	var buffer *WalReceiveBuffer
+	if options.BufferDirectory != "" {
+		buffer, err = NewWalReceiveBuffer(options.BufferDirectory)
+		tracelog.ErrorLogger.FatalOnError(err)
+		// the segments confirmed to Postgres before the restart may be gone from the server already
+		uploaded, err := buffer.UploadCompleteSegments(ctx, uploader, walSegmentBytes)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tracelog.InfoLogger.Printf("Uploaded %d complete segments from the wal-receive buffer", len(uploaded))
+	}
+	slotMembers := NewSlotMembers(slot.Name, options.SlotHosts)
+	defer slotMembers.Close()
+	slotMembers.Ensure()
+
 	conn, err := pgconn.Connect(context.Background(), "replication=yes")
 	tracelog.ErrorLogger.FatalOnError(err)
 	defer conn.Close(context.Background())
@@ -81,7 +100,7 @@ func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
 	timeline, err := getStartTimeline(ctx, conn, uploader, uint32(sysident.Timeline), XLogPos)
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
+	segment = newReceivedWalSegment(timeline, XLogPos, walSegmentBytes, buffer)
 	startReplication(conn, segment, slot.Name)
 	for {
 		streamResult, err := segment.Stream(conn, StandbyMessageTimeout)
@@ -96,6 +115,10 @@ func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
 			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
 			XLogPos = segment.endLSN
+			slotMembers.Advance(LSN(XLogPos))
+			if buffer != nil {
+				tracelog.ErrorLogger.FatalOnError(buffer.Remove(segment))
+			}
 			segment, err = segment.NextWalSegment()
 			tracelog.ErrorLogger.FatalOnError(err)
 		case ProcessMessageCopyDone:
@@ -104,6 +127,9 @@ func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
 			tracelog.ErrorLogger.FatalOnError(err)
 			err = uploadRemoteWalMetadata(ctx, segment.Name(), uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
+			if buffer != nil {
+				tracelog.ErrorLogger.FatalOnError(buffer.Remove(segment))
+			}
 			timeline++
 			timelinehistfile, err := pglogrepl.TimelineHistory(context.Background(), conn, int32(timeline))
 			tracelog.ErrorLogger.FatalOnError(err)
@@ -113,7 +139,7 @@ func HandleWALReceive(ctx context.Context, uploader *WalUploader) {
 			tracelog.ErrorLogger.FatalOnError(err)
 			err = uploadRemoteWalMetadata(ctx, tlh.Name(), uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
-			segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
+			segment = newReceivedWalSegment(timeline, XLogPos, walSegmentBytes, buffer)
 			startReplication(conn, segment, slot.Name)
 		default:
 			tracelog.ErrorLogger.FatalOnError(errors.Errorf("Unexpected result from WalSegment.Stream() %v", streamResult))
@@ -145,9 +171,21 @@ func getStartTimeline(ctx context.Context,
 	return 0, nil
 }
 
+// newReceivedWalSegment creates the segment to stream, filling it with the buffered data
+func newReceivedWalSegment(timeline uint32, location pglogrepl.LSN, walSegmentBytes uint64,
+	buffer *WalReceiveBuffer) *WalSegment {
+	segment := NewWalSegment(timeline, location, walSegmentBytes)
+	if buffer != nil {
+		tracelog.ErrorLogger.FatalOnError(buffer.Load(segment))
+		segment.buffer = buffer
+	}
+	return segment
+}
+
 func startReplication(conn *pgconn.PgConn, segment *WalSegment, slotName string) {
-	tracelog.DebugLogger.Printf("Starting replication from %s: ", segment.StartLSN)
-	err := pglogrepl.StartReplication(context.Background(), conn, slotName, segment.StartLSN,
+	// the buffered part of the segment is not streamed again
+	tracelog.DebugLogger.Printf("Starting replication from %s: ", segment.ReceivedLSN())
+	err := pglogrepl.StartReplication(context.Background(), conn, slotName, segment.ReceivedLSN(),
 		pglogrepl.StartReplicationOptions{Timeline: int32(segment.TimeLine), Mode: pglogrepl.PhysicalReplication})
 	tracelog.ErrorLogger.FatalOnError(err)
 	tracelog.DebugLogger.Println("Started replication")
@@ -0,0 +1,180 @@
+package postgres
+
+/*
+This object maintains the wal-receive replication slot on the members of a HA cluster.
+The slot on the server wal-receive streams from is advanced by the replication protocol itself,
+the slots on other members are advanced up to the archived WAL position, so whichever member
+is promoted retains the WAL that is not archived yet.
+*/
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const defaultPgPort = 5432
+
+// SlotHost is the address of a HA cluster member
+type SlotHost struct {
+	Host string
+	Port uint16
+}
+
+func (host SlotHost) String() string {
+	return net.JoinHostPort(host.Host, strconv.Itoa(int(host.Port)))
+}
+
+// ParseSlotHosts parses the comma separated list of host[:port] addresses
+func ParseSlotHosts(hosts string) ([]SlotHost, error) {
+	slotHosts := make([]SlotHost, 0)
+	for _, address := range strings.Split(hosts, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		host, port := address, defaultPgPort
+		if strings.Contains(address, ":") {
+			var portStr string
+			var err error
+			host, portStr, err = net.SplitHostPort(address)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid slot host %q", address)
+			}
+			port, err = strconv.Atoi(portStr)
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, errors.Errorf("invalid port of slot host %q", address)
+			}
+		}
+		slotHosts = append(slotHosts, SlotHost{Host: host, Port: uint16(port)})
+	}
+	return slotHosts, nil
+}
+
+type slotMember struct {
+	host SlotHost
+	conn *pgx.Conn
+}
+
+// SlotMembers keeps the physical replication slot on every HA cluster member
+type SlotMembers struct {
+	slotName string
+	members  []*slotMember
+}
+
+func NewSlotMembers(slotName string, hosts []SlotHost) *SlotMembers {
+	members := make([]*slotMember, len(hosts))
+	for i, host := range hosts {
+		members[i] = &slotMember{host: host}
+	}
+	return &SlotMembers{slotName: slotName, members: members}
+}
+
+// Ensure creates the slot on the members, which don't have it
+func (slotMembers *SlotMembers) Ensure() {
+	for _, member := range slotMembers.members {
+		err := slotMembers.ensure(member)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to create replication slot %s on %s: %v",
+				slotMembers.slotName, member.host, err)
+			member.close()
+		}
+	}
+}
+
+// Advance moves the slot on the members up to the archived WAL position. Unavailable members are skipped,
+// the slot is created and advanced on them once they are back.
+func (slotMembers *SlotMembers) Advance(archivedLSN LSN) {
+	for _, member := range slotMembers.members {
+		err := slotMembers.advance(member, archivedLSN)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to advance replication slot %s on %s: %v",
+				slotMembers.slotName, member.host, err)
+			member.close()
+		}
+	}
+}
+
+func (slotMembers *SlotMembers) Close() {
+	for _, member := range slotMembers.members {
+		member.close()
+	}
+}
+
+func (slotMembers *SlotMembers) ensure(member *slotMember) error {
+	conn, err := member.connect()
+	if err != nil {
+		return err
+	}
+	var exists bool
+	err = conn.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)",
+		slotMembers.slotName).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Creating replication slot %s on %s", slotMembers.slotName, member.host)
+	_, err = conn.Exec("SELECT pg_create_physical_replication_slot($1, true)", slotMembers.slotName)
+	return err
+}
+
+func (slotMembers *SlotMembers) advance(member *slotMember, archivedLSN LSN) error {
+	err := slotMembers.ensure(member)
+	if err != nil {
+		return err
+	}
+	var active bool
+	var restartLSN string
+	err = member.conn.QueryRow("SELECT active, COALESCE(restart_lsn::text, '') FROM pg_replication_slots "+
+		"WHERE slot_name = $1", slotMembers.slotName).Scan(&active, &restartLSN)
+	if err != nil {
+		return err
+	}
+	if active {
+		// wal-receive streams through this slot, it's advanced by the replication protocol
+		return nil
+	}
+	if restartLSN != "" {
+		currentLSN, err := ParseLSN(restartLSN)
+		if err != nil {
+			return err
+		}
+		if currentLSN >= archivedLSN {
+			return nil
+		}
+	}
+	_, err = member.conn.Exec("SELECT pg_replication_slot_advance($1, $2::pg_lsn)",
+		slotMembers.slotName, archivedLSN.String())
+	if err != nil {
+		return err
+	}
+	tracelog.DebugLogger.Printf("Advanced replication slot %s on %s to %s", slotMembers.slotName, member.host, archivedLSN)
+	return nil
+}
+
+func (member *slotMember) connect() (*pgx.Conn, error) {
+	if member.conn != nil {
+		return member.conn, nil
+	}
+	config, err := pgx.ParseEnvLibpq()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read environment variables")
+	}
+	config.Host = member.host.Host
+	config.Port = member.host.Port
+	member.conn, err = pgx.Connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return member.conn, nil
+}
+
+func (member *slotMember) close() {
+	if member.conn != nil {
+		_ = member.conn.Close()
+		member.conn = nil
+	}
+}
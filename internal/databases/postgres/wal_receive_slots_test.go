@@ -0,0 +1,30 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wal-g/wal-g/internal/databases/postgres"
+)
+
+func TestParseSlotHosts(t *testing.T) {
+	hosts, err := postgres.ParseSlotHosts("pg1, pg2:5433,[::1]:6432,")
+	assert.NoError(t, err)
+	assert.Equal(t, []postgres.SlotHost{
+		{Host: "pg1", Port: 5432},
+		{Host: "pg2", Port: 5433},
+		{Host: "::1", Port: 6432},
+	}, hosts)
+	assert.Equal(t, "[::1]:6432", hosts[2].String())
+}
+
+func TestParseSlotHosts_Empty(t *testing.T) {
+	hosts, err := postgres.ParseSlotHosts("")
+	assert.NoError(t, err)
+	assert.Empty(t, hosts)
+}
+
+func TestParseSlotHosts_InvalidPort(t *testing.T) {
+	_, err := postgres.ParseSlotHosts("pg1:port")
+	assert.Error(t, err)
+}
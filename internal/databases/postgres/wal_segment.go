@@ -32,6 +32,8 @@ type WalSegment struct {
 	readIndex       int
 	writeIndex      int
 	lastMsg         *pgproto3.BackendMessage
+	// buffer stores the received data durably to confirm its flush before the segment is uploaded
+	buffer *WalReceiveBuffer
 }
 
 // The ProcessMessageResult is an enum representing possible results from the methods
@@ -75,6 +77,7 @@ func (seg *WalSegment) NextWalSegment() (*WalSegment, error) {
 			errors.Errorf("Cannot run NextWalSegment until isComplete")}
 	}
 	nextSegment := NewWalSegment(seg.TimeLine, seg.endLSN, seg.walSegmentBytes)
+	nextSegment.buffer = seg.buffer
 	if seg.lastMsg != nil {
 		// Apparaently the last message crossed the border between the two segments,
 		// so lets have it processed into the next segment too.
@@ -93,13 +96,36 @@ func (seg *WalSegment) NextWalSegment() (*WalSegment, error) {
 // Name returns the filename of this wal segment.
 // This is also used by the WalUploader to set the name of the destination file during upload of the wal segment.
 func (seg *WalSegment) Name() string {
+	if seg.isComplete() {
+		return seg.baseName()
+	}
+	return seg.baseName() + ".partial"
+}
+
+// baseName returns the filename of this wal segment regardless of its completeness.
+func (seg *WalSegment) baseName() string {
 	// Example LSN -> Name:
 	// '0/2A33FE00' -> '00000001000000000000002A'
 	segID := uint64(seg.StartLSN) / seg.walSegmentBytes
-	if seg.isComplete() {
-		return formatWALFileName(seg.TimeLine, segID)
+	return formatWALFileName(seg.TimeLine, segID)
+}
+
+// ReceivedLSN returns the position up to which the wal segment data is received.
+func (seg *WalSegment) ReceivedLSN() pglogrepl.LSN {
+	return seg.StartLSN + pglogrepl.LSN(seg.writeIndex)
+}
+
+// standbyStatus reports the data as flushed once it is durably stored: either in the buffer or uploaded.
+func (seg *WalSegment) standbyStatus() pglogrepl.StandbyStatusUpdate {
+	if seg.buffer == nil {
+		return pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN}
+	}
+	flushed := pglogrepl.LSN(seg.buffer.FlushedLSN(seg))
+	return pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: seg.ReceivedLSN(),
+		WALFlushPosition: flushed,
+		WALApplyPosition: flushed,
 	}
-	return formatWALFileName(seg.TimeLine, segID) + ".partial"
 }
 
 // processMessage is a method that processes a message from Postgres and copies its data
@@ -165,12 +191,17 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 
 	var err error
 	var msg pgproto3.BackendMessage
+	if seg.buffer != nil {
+		// store the data carried over from the previous segment before confirming anything
+		err = seg.buffer.Write(seg)
+		if err != nil {
+			return ProcessMessageUnknown, err
+		}
+	}
 	nextStandbyMessageDeadline := time.Now()
 	for {
 		if time.Now().After(nextStandbyMessageDeadline) {
-			err = pglogrepl.SendStandbyStatusUpdate(context.Background(),
-				conn,
-				pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN})
+			err = pglogrepl.SendStandbyStatusUpdate(context.Background(), conn, seg.standbyStatus())
 			tracelog.ErrorLogger.FatalOnError(err)
 			tracelog.DebugLogger.Println("Sent Standby status message")
 			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
@@ -187,6 +218,21 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 		result, err := seg.processMessage(msg)
 		switch result {
 		case ProcessMessageOK:
+			if seg.buffer != nil && seg.buffer.FlushedLSN(seg) < LSN(seg.ReceivedLSN()) {
+				err = seg.buffer.Write(seg)
+				if err != nil {
+					return result, err
+				}
+				// a synchronous primary waits for the flush confirmation to commit
+				nextStandbyMessageDeadline = time.Time{}
+				if seg.isComplete() {
+					// confirm the flush before the complete segment is uploaded
+					err = pglogrepl.SendStandbyStatusUpdate(context.Background(), conn, seg.standbyStatus())
+					if err != nil {
+						return result, err
+					}
+				}
+			}
 			if seg.isComplete() {
 				return ProcessMessageOK, nil
 			}
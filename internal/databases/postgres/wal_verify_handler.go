@@ -15,10 +15,12 @@ type WalVerifyCheckType int
 const (
 	WalVerifyIntegrityCheck = iota + 1
 	WalVerifyTimelineCheck
+	WalVerifyBackupCoverageCheck
+	WalVerifyPitrCheck
 )
 
 func (checkType WalVerifyCheckType) String() string {
-	return [...]string{"", "integrity", "timeline"}[checkType]
+	return [...]string{"", "integrity", "timeline", "backup-coverage", "pitr"}[checkType]
 }
 
 func (checkType WalVerifyCheckType) MarshalText() (text []byte, err error) {
@@ -118,6 +120,10 @@ func BuildWalVerifyCheckRunner(
 		checkRunner, err = NewTimelineCheckRunner(walFolderFilenames, currentWalSegment)
 	case WalVerifyIntegrityCheck:
 		checkRunner, err = NewIntegrityCheckRunner(rootFolder, walFolderFilenames, currentWalSegment)
+	case WalVerifyBackupCoverageCheck:
+		checkRunner, err = NewBackupCoverageCheckRunner(rootFolder, walFolderFilenames, currentWalSegment)
+	case WalVerifyPitrCheck:
+		checkRunner, err = NewPitrCheckRunner(rootFolder, currentWalSegment)
 	default:
 		return nil, NewUnknownWalVerifyCheckError(checkType)
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -693,3 +694,163 @@ func newMockExtendedMetadataDto(isPermanent bool) postgres.ExtendedMetadataDto {
 		UserData:         nil,
 	}
 }
+
+func newMockBackupMetadata(startSegmentNo, finishSegmentNo uint64) postgres.ExtendedMetadataDto {
+	meta := newMockExtendedMetadataDto(false)
+	meta.StartLsn = postgres.LSN(startSegmentNo*postgres.WalSegmentSize + 40)
+	meta.FinishLsn = postgres.LSN(finishSegmentNo*postgres.WalSegmentSize + 100)
+	return meta
+}
+
+func runWalVerifyCheck(t *testing.T, checkType postgres.WalVerifyCheckType,
+	walFilenames []string, storageFiles map[string]*bytes.Buffer,
+	currentWalSegment postgres.WalSegmentDescription) postgres.WalVerifyCheckResult {
+	rootFolder := setupTestStorageFolder()
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	for name, content := range storageFiles {
+		assert.NoError(t, rootFolder.PutObject(name, content))
+	}
+	putWalSegments(walFilenames, walFolder)
+	walFolderFilenames := append([]string{}, walFilenames...)
+	for name := range storageFiles {
+		if strings.HasPrefix(name, utility.WalPath) {
+			walFolderFilenames = append(walFolderFilenames, strings.TrimPrefix(name, utility.WalPath))
+		}
+	}
+
+	runner, err := postgres.BuildWalVerifyCheckRunner(checkType, rootFolder, walFolderFilenames, currentWalSegment)
+	assert.NoError(t, err)
+	assert.Equal(t, checkType, runner.Type())
+	result, err := runner.Run()
+	assert.NoError(t, err)
+	return result
+}
+
+func TestWalVerify_BackupCoverage(t *testing.T) {
+	storageSegments := []string{
+		"000000010000000000000001",
+		"000000010000000000000002",
+		"000000010000000000000003",
+		"000000010000000000000005",
+		"000000010000000000000006",
+		"00000001000000000000000F",
+	}
+	storageFiles := make(map[string]*bytes.Buffer)
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000010000000000000001": newMockBackupMetadata(1, 2),
+		"000000010000000000000003": newMockBackupMetadata(3, 4),
+		"00000001000000000000000F": newMockBackupMetadata(15, 16),
+	}, storageFiles)
+	currentSegment, _ := postgres.NewWalSegmentDescription("000000010000000000000011")
+
+	result := runWalVerifyCheck(t, postgres.WalVerifyBackupCoverageCheck,
+		storageSegments, storageFiles, currentSegment)
+
+	assert.Equal(t, postgres.StatusFailure, result.Status)
+	assert.Equal(t, postgres.BackupCoverageCheckDetails{
+		{
+			BackupName:      "base_000000010000000000000001",
+			TimelineID:      1,
+			StartSegment:    "000000010000000000000001",
+			EndSegment:      "000000010000000000000002",
+			MissingSegments: []string{},
+			Status:          postgres.Found,
+		},
+		{
+			BackupName:      "base_000000010000000000000003",
+			TimelineID:      1,
+			StartSegment:    "000000010000000000000003",
+			EndSegment:      "000000010000000000000004",
+			MissingSegments: []string{"000000010000000000000004"},
+			Status:          postgres.Lost,
+		},
+		{
+			// the latest segments may be still uploading
+			BackupName:      "base_00000001000000000000000F",
+			TimelineID:      1,
+			StartSegment:    "00000001000000000000000F",
+			EndSegment:      "000000010000000000000010",
+			MissingSegments: []string{"000000010000000000000010"},
+			Status:          postgres.ProbablyUploading,
+		},
+	}, result.Details)
+}
+
+func TestWalVerify_BackupCoverage_NoBackups(t *testing.T) {
+	currentSegment, _ := postgres.NewWalSegmentDescription("000000010000000000000011")
+	result := runWalVerifyCheck(t, postgres.WalVerifyBackupCoverageCheck,
+		[]string{"000000010000000000000001"}, nil, currentSegment)
+	assert.Equal(t, postgres.StatusWarning, result.Status)
+}
+
+func newPitrTestStorage(t *testing.T) ([]string, map[string]*bytes.Buffer) {
+	storageSegments := []string{
+		"000000050000000000000001",
+		"000000050000000000000002",
+		"000000050000000000000003",
+		"000000050000000000000004",
+		// archived by the old primary after the switch
+		"000000050000000000000005",
+		"000000050000000000000006",
+
+		"000000060000000000000005",
+		"000000060000000000000006",
+		"000000060000000000000008",
+		"000000060000000000000009",
+	}
+	storageFiles := make(map[string]*bytes.Buffer)
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000050000000000000002": newMockBackupMetadata(2, 3),
+		// this backup requires the missing segment
+		"000000060000000000000006": newMockBackupMetadata(6, 7),
+		"000000060000000000000008": newMockBackupMetadata(8, 8),
+	}, storageFiles)
+
+	switchPointLsn := 5*postgres.WalSegmentSize + 100
+	historyContents := fmt.Sprintf("%d\t0/%X\tsome comment...\n\n", 5, switchPointLsn)
+	historyName, historyFile, err := newTimelineHistoryFile(historyContents, 6)
+	assert.NoError(t, err)
+	storageFiles[utility.WalPath+historyName] = historyFile
+	return storageSegments, storageFiles
+}
+
+func TestWalVerify_Pitr(t *testing.T) {
+	storageSegments, storageFiles := newPitrTestStorage(t)
+	currentSegment, _ := postgres.NewWalSegmentDescription("00000006000000000000000A")
+
+	result := runWalVerifyCheck(t, postgres.WalVerifyPitrCheck, storageSegments, storageFiles, currentSegment)
+
+	assert.Equal(t, postgres.StatusOk, result.Status)
+	details := result.Details.(postgres.PitrCheckDetails)
+	windows := make([]string, 0, len(details.Windows))
+	for _, window := range details.Windows {
+		windows = append(windows, fmt.Sprintf("%d:%s-%s", window.TimelineID, window.BackupName, window.EndSegment))
+	}
+	assert.Equal(t, []string{
+		"5:base_000000050000000000000002-000000050000000000000006",
+		"6:base_000000050000000000000002-000000060000000000000006",
+		"6:base_000000060000000000000008-000000060000000000000009",
+	}, windows)
+	assert.NotNil(t, details.RecoverableFrom)
+	assert.Equal(t, details.Windows[2].StartTime, *details.RecoverableFrom)
+	assert.Equal(t, details.Windows[2].EndTime, *details.RecoverableUntil)
+}
+
+func TestWalVerify_Pitr_GapAfterWindow(t *testing.T) {
+	storageSegments, storageFiles := newPitrTestStorage(t)
+	currentSegment, _ := postgres.NewWalSegmentDescription("00000005000000000000000A")
+	storageSegments = append(storageSegments, "000000050000000000000008")
+
+	result := runWalVerifyCheck(t, postgres.WalVerifyPitrCheck, storageSegments, storageFiles, currentSegment)
+
+	assert.Equal(t, postgres.StatusWarning, result.Status)
+}
+
+func TestWalVerify_Pitr_NoBackups(t *testing.T) {
+	currentSegment, _ := postgres.NewWalSegmentDescription("000000010000000000000003")
+	result := runWalVerifyCheck(t, postgres.WalVerifyPitrCheck,
+		[]string{"000000010000000000000001", "000000010000000000000002"}, nil, currentSegment)
+
+	assert.Equal(t, postgres.StatusFailure, result.Status)
+	assert.Nil(t, result.Details.(postgres.PitrCheckDetails).RecoverableFrom)
+}
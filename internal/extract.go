@@ -95,6 +95,15 @@ func extractOneTar(tarInterpreter TarInterpreter, source io.Reader) error {
 	return nil
 }
 
+// ExtractTar extracts the tar bundle read once from the source, e.g. from the stream, which can't be retried
+func ExtractTar(tarInterpreter TarInterpreter, source io.Reader) error {
+	err := extractOneTar(tarInterpreter, source)
+	if err != nil {
+		return err
+	}
+	return readTrailingZeros(source)
+}
+
 func extractNonTar(tarInterpreter TarInterpreter, source io.Reader, path string, fileType FileType, mode int64) error {
 	var typeFlag byte
 	if fileType == RegularFileType {
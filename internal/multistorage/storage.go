@@ -75,6 +75,11 @@ func (s *Storage) RootFolder() storage.Folder {
 	return s.rootFolder
 }
 
+// StorageNames provides the names of all configured storages, the primary one goes first.
+func (s *Storage) StorageNames() []string {
+	return NamedStorages(s.specificStorages).Names()
+}
+
 func (s *Storage) Close() error {
 	if s == nil || len(s.specificStorages) == 0 {
 		return nil
@@ -27,6 +27,16 @@ func (fsr *FolderReaderImpl) SubFolder(subFolderRelativePath string) StorageFold
 }
 
 func PrepareMultiStorageFolderReader(folder storage.Folder, targetStorage string) (StorageFolderReader, error) {
+	folder, err := PrepareMultiStorageFolder(folder, targetStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFolderReader(folder), nil
+}
+
+// PrepareMultiStorageFolder merges the objects of all the alive storages, or uses only the target storage if it's set
+func PrepareMultiStorageFolder(folder storage.Folder, targetStorage string) (storage.Folder, error) {
 	folder = multistorage.SetPolicies(folder, policies.MergeAllStorages)
 	var err error
 	if targetStorage == "" {
@@ -39,5 +49,5 @@ func PrepareMultiStorageFolderReader(folder storage.Folder, targetStorage string
 		return nil, err
 	}
 
-	return NewFolderReader(folder), nil
+	return folder, nil
 }
@@ -1,5 +1,7 @@
 package walparser
 
+import "strings"
+
 /* List of postgres resource managers, for clarification you can look at postgres code:
  * src/include/access/rmgrlist.h
  */
@@ -30,3 +32,46 @@ const (
 
 	RmNextFreeID
 )
+
+var resourceManagerNames = [RmNextFreeID]string{
+	"XLOG",
+	"Transaction",
+	"Storage",
+	"CLOG",
+	"Database",
+	"Tablespace",
+	"MultiXact",
+	"RelMap",
+	"Standby",
+	"Heap2",
+	"Heap",
+	"Btree",
+	"Hash",
+	"Gin",
+	"Gist",
+	"Sequence",
+	"SPGist",
+	"BRIN",
+	"CommitTs",
+	"ReplicationOrigin",
+	"Generic",
+	"LogicalMessage",
+}
+
+// ResourceManagerName returns the name of resource manager as it is shown by pg_waldump
+func ResourceManagerName(resourceManagerID uint8) string {
+	if resourceManagerID >= RmNextFreeID {
+		return "Unknown"
+	}
+	return resourceManagerNames[resourceManagerID]
+}
+
+// ResourceManagerIDByName looks up the resource manager by its pg_waldump name, case-insensitive
+func ResourceManagerIDByName(name string) (uint8, bool) {
+	for id, rmName := range resourceManagerNames {
+		if strings.EqualFold(rmName, name) {
+			return uint8(id), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,59 @@
+package walparser
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/wal-g/internal/walparser/parsingutil"
+)
+
+/* Transaction resource manager record types, for clarification you can look at postgres code:
+ * src/include/access/xact.h
+ */
+const (
+	XLogXactCommit         = 0x00
+	XLogXactPrepare        = 0x10
+	XLogXactAbort          = 0x20
+	XLogXactCommitPrepared = 0x30
+	XLogXactAbortPrepared  = 0x40
+	XLogXactAssignment     = 0x50
+	XLogXactInvalidations  = 0x60
+	XLogXactOpMask         = 0x70
+)
+
+// postgresEpoch is the zero point of postgres TimestampTz
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func (record *XLogRecord) xactOperation() (uint8, bool) {
+	if record.Header.ResourceManagerID != RmXactID {
+		return 0, false
+	}
+	return record.Header.Info & XLogXactOpMask, true
+}
+
+// IsXactCommit checks if the record is a commit of a regular or prepared transaction
+func (record *XLogRecord) IsXactCommit() bool {
+	operation, ok := record.xactOperation()
+	return ok && (operation == XLogXactCommit || operation == XLogXactCommitPrepared)
+}
+
+// IsXactAbort checks if the record is an abort of a regular or prepared transaction
+func (record *XLogRecord) IsXactAbort() bool {
+	operation, ok := record.xactOperation()
+	return ok && (operation == XLogXactAbort || operation == XLogXactAbortPrepared)
+}
+
+// XactTime decodes the transaction end time from the commit or abort record main data,
+// both xl_xact_commit and xl_xact_abort start with TimestampTz xact_time
+func (record *XLogRecord) XactTime() (time.Time, error) {
+	if !record.IsXactCommit() && !record.IsXactAbort() {
+		return time.Time{}, errors.New("record is neither transaction commit nor abort")
+	}
+	var xactTime int64
+	err := parsingutil.NewFieldToParse(&xactTime, "xactTime").ParseFrom(bytes.NewReader(record.MainData))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return postgresEpoch.Add(time.Duration(xactTime) * time.Microsecond), nil
+}
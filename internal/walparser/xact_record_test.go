@@ -0,0 +1,30 @@
+package walparser
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXLogRecord_XactTime(t *testing.T) {
+	mainData := make([]byte, 8)
+	binary.LittleEndian.PutUint64(mainData, uint64(24*time.Hour/time.Microsecond))
+	record := XLogRecord{
+		Header:   XLogRecordHeader{ResourceManagerID: RmXactID, Info: XLogXactCommit},
+		MainData: mainData,
+	}
+	assert.True(t, record.IsXactCommit())
+	assert.False(t, record.IsXactAbort())
+	xactTime, err := record.XactTime()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC), xactTime)
+}
+
+func TestXLogRecord_XactTimeOfNonXactRecord(t *testing.T) {
+	record := XLogRecord{Header: XLogRecordHeader{ResourceManagerID: RmHeapID, Info: XLogXactAbort}}
+	assert.False(t, record.IsXactAbort())
+	_, err := record.XactTime()
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package walparser
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MAXALIGN'ed sizes of XLogPageHeaderData and XLogLongPageHeaderData
+	XLogShortPageHeaderSize = 24
+	XLogLongPageHeaderSize  = 40
+)
+
+// LocatedXLogRecord is a decoded WAL record together with the LSN it starts at
+type LocatedXLogRecord struct {
+	LSN    XLogRecordPtr
+	Record XLogRecord
+}
+
+// XLogRecordReader decodes WAL records page by page and keeps track of the record positions.
+// Records crossing segment boundaries are decoded as long as the segments are passed in order via NextSegment.
+type XLogRecordReader struct {
+	pageReader *WalPageReader
+	parser     *WalParser
+	// LSN of the record, whose beginning is stored in the parser
+	pendingRecordLSN XLogRecordPtr
+	records          []LocatedXLogRecord
+	segmentFinished  bool
+}
+
+func NewXLogRecordReader(walFileReader io.Reader) *XLogRecordReader {
+	return &XLogRecordReader{
+		pageReader: NewWalPageReader(walFileReader),
+		parser:     NewWalParser(),
+	}
+}
+
+// NextSegment switches the reader to the next WAL segment,
+// the beginning of the unfinished record from the previous segment is kept.
+func (reader *XLogRecordReader) NextSegment(walFileReader io.Reader) {
+	reader.pageReader = NewWalPageReader(walFileReader)
+	reader.records = nil
+	reader.segmentFinished = false
+}
+
+// Next returns the next record of the current segment or io.EOF if there are no more records in it
+func (reader *XLogRecordReader) Next() (*LocatedXLogRecord, error) {
+	for len(reader.records) == 0 {
+		if reader.segmentFinished {
+			return nil, io.EOF
+		}
+		err := reader.readPage()
+		if err != nil {
+			return nil, err
+		}
+	}
+	record := reader.records[0]
+	reader.records = reader.records[1:]
+	return &record, nil
+}
+
+func (reader *XLogRecordReader) readPage() error {
+	pageData, err := reader.pageReader.ReadPageData()
+	if err == io.EOF || errors.Cause(err) == io.ErrUnexpectedEOF {
+		reader.segmentFinished = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	pageHeader, err := readXLogPageHeader(bytes.NewReader(pageData))
+	if _, ok := err.(ZeroPageHeaderError); ok {
+		// the rest of the segment after WAL-switch or the end of .partial file
+		reader.segmentFinished = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	hadRecordBeginning := reader.parser.hasCurrentRecordBeginning
+	_, records, err := reader.parser.ParseRecordsFromPage(bytes.NewReader(pageData))
+	if _, ok := err.(PartialPageError); ok {
+		reader.segmentFinished = true
+	} else if _, ok := err.(ZeroPageError); ok {
+		reader.segmentFinished = true
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	headerSize := uint32(XLogShortPageHeaderSize)
+	if pageHeader.IsLong() {
+		headerSize = XLogLongPageHeaderSize
+	}
+	if pageHeader.RemainingDataLen > uint32(WalPageSize)-headerSize {
+		// the whole page is occupied by the continuation of some record
+		return nil
+	}
+
+	if hadRecordBeginning && len(records) > 0 {
+		reader.records = append(reader.records, LocatedXLogRecord{reader.pendingRecordLSN, records[0]})
+		records = records[1:]
+	}
+	offset := alignUint32(headerSize+pageHeader.RemainingDataLen, XLogRecordAlignment)
+	for _, record := range records {
+		reader.records = append(reader.records,
+			LocatedXLogRecord{pageHeader.PageAddress + XLogRecordPtr(offset), record})
+		offset += alignUint32(record.Header.TotalRecordLength, XLogRecordAlignment)
+	}
+	if reader.parser.hasCurrentRecordBeginning {
+		reader.pendingRecordLSN = pageHeader.PageAddress + XLogRecordPtr(offset)
+	}
+	return nil
+}
+
+func alignUint32(value uint32, alignment uint32) uint32 {
+	return (value + alignment - 1) / alignment * alignment
+}
@@ -0,0 +1,78 @@
+package walparser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAllLocatedRecords(t *testing.T, reader *XLogRecordReader) []LocatedXLogRecord {
+	records := make([]LocatedXLogRecord, 0)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, *record)
+	}
+}
+
+func assertRecordChainIsConsistent(t *testing.T, records []LocatedXLogRecord) {
+	for i := 1; i < len(records); i++ {
+		assert.Equal(t, records[i-1].LSN, records[i].Record.Header.PrevRecordPtr,
+			"record %d must point to the LSN of the previous record", i)
+	}
+}
+
+func TestXLogRecordReader_LSNs(t *testing.T) {
+	for _, path := range []string{WalSwitchTestPath, LongRecordTestPath, CutWALSwitchTestPath, PartialTestPath} {
+		t.Run(path, func(t *testing.T) {
+			walFile, err := os.Open(path)
+			require.NoError(t, err)
+			defer walFile.Close()
+
+			records := readAllLocatedRecords(t, NewXLogRecordReader(walFile))
+			assert.NotEmpty(t, records)
+			assertRecordChainIsConsistent(t, records)
+		})
+	}
+}
+
+func TestXLogRecordReader_WalSwitchEndsSegment(t *testing.T) {
+	data, err := os.ReadFile(WalSwitchTestPath)
+	require.NoError(t, err)
+
+	records := readAllLocatedRecords(t, NewXLogRecordReader(bytes.NewReader(data)))
+	require.NotEmpty(t, records)
+	assert.True(t, records[len(records)-1].Record.isWALSwitch())
+}
+
+func TestXLogRecordReader_NextSegmentKeepsUnfinishedRecord(t *testing.T) {
+	data, err := os.ReadFile(LongRecordTestPath)
+	require.NoError(t, err)
+
+	wholeReader := NewXLogRecordReader(bytes.NewReader(data))
+	expected := readAllLocatedRecords(t, wholeReader)
+
+	splitReader := NewXLogRecordReader(bytes.NewReader(data[:WalPageSize]))
+	actual := readAllLocatedRecords(t, splitReader)
+	splitReader.NextSegment(bytes.NewReader(data[WalPageSize:]))
+	actual = append(actual, readAllLocatedRecords(t, splitReader)...)
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestResourceManagerIDByName(t *testing.T) {
+	id, ok := ResourceManagerIDByName("heap")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(RmHeapID), id)
+	assert.Equal(t, "Heap", ResourceManagerName(id))
+
+	_, ok = ResourceManagerIDByName("unknown")
+	assert.False(t, ok)
+}
@@ -60,6 +60,9 @@ const (
 	Mebibyte               = 1024 * 1024
 )
 
+// LogicalBackupPath is the root of the logical backups, which keep the basebackups layout inside
+const LogicalBackupPath = "logical_" + VersionStr + "/"
+
 // MaxTime not really the maximal value, but high enough.
 var MaxTime time.Time
 